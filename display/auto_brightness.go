@@ -0,0 +1,389 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// 自动亮度：根据环境光传感器的读数，按照 lux-亮度曲线设置内置显示器的亮度。
+// 在自动亮度开启时用户手动设置并保存亮度，会把当前环境光下的亮度记录到曲线上。
+
+const (
+	// 环境光的变化小于这个值时不调整亮度，用 log10(lux+1) 计算
+	autoBrightnessLuxHysteresis = 0.15
+	// 计算出的亮度变化小于这个值时不调整亮度
+	autoBrightnessMinDelta = 0.02
+	// 学习时，曲线上 log10(lux+1) 相差小于这个值的点被替换
+	autoBrightnessLearnMergeDist = 0.1
+)
+
+// LuxBrightnessPoint 是 lux-亮度曲线上的一个点，亮度范围为 0-1。
+type LuxBrightnessPoint struct {
+	Lux        float64
+	Brightness float64
+}
+
+// luxBrightnessCurve 是按 lux 从小到大排列的点
+type luxBrightnessCurve []LuxBrightnessPoint
+
+func getDefaultLuxBrightnessCurve() luxBrightnessCurve {
+	return luxBrightnessCurve{
+		{Lux: 0, Brightness: 0.2},
+		{Lux: 10, Brightness: 0.3},
+		{Lux: 50, Brightness: 0.45},
+		{Lux: 200, Brightness: 0.6},
+		{Lux: 1000, Brightness: 0.85},
+		{Lux: 5000, Brightness: 1},
+	}
+}
+
+func luxToLog(lux float64) float64 {
+	if lux < 0 {
+		lux = 0
+	}
+	return math.Log10(lux + 1)
+}
+
+// check 检查曲线是否有效，至少 2 个点，lux 严格递增，亮度在 0-1 范围内且不递减。
+func (c luxBrightnessCurve) check() error {
+	if len(c) < 2 {
+		return errors.New("the curve needs at least 2 points")
+	}
+	for i, p := range c {
+		if p.Lux < 0 || math.IsNaN(p.Lux) || math.IsInf(p.Lux, 0) {
+			return fmt.Errorf("invalid lux %v", p.Lux)
+		}
+		if p.Brightness < 0 || p.Brightness > 1 || math.IsNaN(p.Brightness) {
+			return fmt.Errorf("invalid brightness %v, the range is 0-1", p.Brightness)
+		}
+		if i > 0 {
+			if p.Lux <= c[i-1].Lux {
+				return errors.New("lux of the curve must be strictly increasing")
+			}
+			if p.Brightness < c[i-1].Brightness {
+				return errors.New("brightness of the curve must not be decreasing")
+			}
+		}
+	}
+	return nil
+}
+
+func (c luxBrightnessCurve) clone() luxBrightnessCurve {
+	return append(luxBrightnessCurve(nil), c...)
+}
+
+// brightnessAt 按 log10(lux+1) 线性插值计算亮度，超出曲线范围时取两端的值。
+func (c luxBrightnessCurve) brightnessAt(lux float64) float64 {
+	if len(c) == 0 {
+		return 1
+	}
+	if lux <= c[0].Lux {
+		return c[0].Brightness
+	}
+	last := c[len(c)-1]
+	if lux >= last.Lux {
+		return last.Brightness
+	}
+	x := luxToLog(lux)
+	for i := 1; i < len(c); i++ {
+		p0, p1 := c[i-1], c[i]
+		if lux > p1.Lux {
+			continue
+		} else if lux == p1.Lux {
+			return p1.Brightness
+		}
+		x0, x1 := luxToLog(p0.Lux), luxToLog(p1.Lux)
+		return p0.Brightness + (p1.Brightness-p0.Brightness)*(x-x0)/(x1-x0)
+	}
+	return last.Brightness
+}
+
+// learn 返回新的曲线，在 lux 处的亮度为 brightness。附近的点被替换，
+// 其他点的亮度被调整，使亮度随 lux 不递减。
+func (c luxBrightnessCurve) learn(lux, brightness float64) luxBrightnessCurve {
+	if lux < 0 {
+		lux = 0
+	}
+	brightness = math.Max(0, math.Min(1, brightness))
+	x := luxToLog(lux)
+	result := make(luxBrightnessCurve, 0, len(c)+1)
+	for _, p := range c {
+		if math.Abs(luxToLog(p.Lux)-x) < autoBrightnessLearnMergeDist {
+			continue
+		}
+		if p.Lux < lux {
+			p.Brightness = math.Min(p.Brightness, brightness)
+		} else {
+			p.Brightness = math.Max(p.Brightness, brightness)
+		}
+		result = append(result, p)
+	}
+	result = append(result, LuxBrightnessPoint{Lux: lux, Brightness: brightness})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Lux < result[j].Lux
+	})
+	if len(result) < 2 {
+		// 其他点都被替换了，补一个端点
+		if lux > 0 {
+			result = append(luxBrightnessCurve{{Lux: 0, Brightness: brightness}}, result...)
+		} else {
+			result = append(result, LuxBrightnessPoint{Lux: 1, Brightness: brightness})
+		}
+	}
+	return result
+}
+
+// autoBrightnessController 监听环境光传感器，根据曲线调整亮度。
+type autoBrightnessController struct {
+	mu      sync.Mutex
+	sensor  lightSensor
+	curve   luxBrightnessCurve
+	running bool
+	// 最近一次读到的环境光
+	lux    float64
+	hasLux bool
+	// 最近一次调整亮度时的环境光和亮度
+	appliedLux        float64
+	appliedBrightness float64
+	applied           bool
+	apply             func(value float64) error
+}
+
+func newAutoBrightnessController(sensor lightSensor, curve luxBrightnessCurve,
+	apply func(value float64) error) *autoBrightnessController {
+	if curve.check() != nil {
+		curve = getDefaultLuxBrightnessCurve()
+	}
+	return &autoBrightnessController{
+		sensor: sensor,
+		curve:  curve,
+		apply:  apply,
+	}
+}
+
+func (c *autoBrightnessController) setEnabled(enabled bool) error {
+	c.mu.Lock()
+	if c.running == enabled {
+		c.mu.Unlock()
+		return nil
+	}
+	if !enabled {
+		c.running = false
+		c.applied = false
+		c.mu.Unlock()
+		c.sensor.stop()
+		return nil
+	}
+	c.mu.Unlock()
+
+	err := c.sensor.start(c.handleLux)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.running = true
+	c.mu.Unlock()
+
+	lux, err := c.sensor.getLightLevel()
+	if err != nil {
+		logger.Warning("failed to get light level:", err)
+		return nil
+	}
+	c.handleLux(lux)
+	return nil
+}
+
+// shouldApply 判断环境光变化后是否要调整亮度，调用时需要持有 c.mu。
+func (c *autoBrightnessController) shouldApply(lux, brightness float64) bool {
+	if !c.applied {
+		return true
+	}
+	if math.Abs(luxToLog(lux)-luxToLog(c.appliedLux)) < autoBrightnessLuxHysteresis {
+		return false
+	}
+	return math.Abs(brightness-c.appliedBrightness) >= autoBrightnessMinDelta
+}
+
+func (c *autoBrightnessController) handleLux(lux float64) {
+	c.mu.Lock()
+	c.lux = lux
+	c.hasLux = true
+	if !c.running {
+		c.mu.Unlock()
+		return
+	}
+	brightness := c.curve.brightnessAt(lux)
+	if !c.shouldApply(lux, brightness) {
+		c.mu.Unlock()
+		return
+	}
+	c.appliedLux = lux
+	c.appliedBrightness = brightness
+	c.applied = true
+	c.mu.Unlock()
+
+	logger.Debugf("auto brightness: lux %v, brightness %v", lux, brightness)
+	err := c.apply(brightness)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// learn 把用户在当前环境光下设置的亮度记录到曲线上，返回新的曲线。
+func (c *autoBrightnessController) learn(brightness float64) (luxBrightnessCurve, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running || !c.hasLux {
+		return nil, false
+	}
+	c.curve = c.curve.learn(c.lux, brightness)
+	c.appliedLux = c.lux
+	c.appliedBrightness = brightness
+	c.applied = true
+	return c.curve.clone(), true
+}
+
+func (c *autoBrightnessController) getCurve() luxBrightnessCurve {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.curve.clone()
+}
+
+func (c *autoBrightnessController) setCurve(curve luxBrightnessCurve) {
+	c.mu.Lock()
+	c.curve = curve.clone()
+	c.applied = false
+	lux, hasLux, running := c.lux, c.hasLux, c.running
+	c.mu.Unlock()
+
+	if running && hasLux {
+		c.handleLux(lux)
+	}
+}
+
+func (m *Manager) initAutoBrightness(conn *dbus.Conn) {
+	sensor, err := findLightSensor(conn)
+	if err != nil {
+		logger.Debug("ambient light sensor not found:", err)
+		return
+	}
+
+	m.userCfgMu.Lock()
+	cfg := m.userConfig.AutoBrightness.clone()
+	m.userCfgMu.Unlock()
+
+	var curve luxBrightnessCurve
+	if cfg != nil {
+		curve = cfg.Curve
+	}
+	controller := newAutoBrightnessController(sensor, curve, m.applyAutoBrightness)
+
+	m.PropsMu.Lock()
+	m.autoBrightness = controller
+	m.setPropHasAmbientLightSensor(true)
+	m.PropsMu.Unlock()
+
+	if cfg != nil && cfg.Enabled {
+		err = controller.setEnabled(true)
+		if err != nil {
+			logger.Warning(err)
+			return
+		}
+		m.PropsMu.Lock()
+		m.setPropAutoBrightness(true)
+		m.PropsMu.Unlock()
+	}
+}
+
+func (m *Manager) getAutoBrightnessController() *autoBrightnessController {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	return m.autoBrightness
+}
+
+// applyAutoBrightness 设置内置显示器的亮度，不保存到配置。
+func (m *Manager) applyAutoBrightness(value float64) error {
+	builtinMonitor := m.getBuiltinMonitor()
+	if builtinMonitor == nil {
+		return nil
+	}
+	return m.setBrightnessAndSync(builtinMonitor.Name, value, false)
+}
+
+func (m *Manager) setAutoBrightness(enabled bool) error {
+	controller := m.getAutoBrightnessController()
+	if controller == nil {
+		return errors.New("no ambient light sensor")
+	}
+	err := controller.setEnabled(enabled)
+	if err != nil {
+		return err
+	}
+	m.PropsMu.Lock()
+	m.setPropAutoBrightness(enabled)
+	m.PropsMu.Unlock()
+
+	m.saveAutoBrightnessInCfg(func(cfg *UserAutoBrightnessConfig) {
+		cfg.Enabled = enabled
+	})
+	return nil
+}
+
+func (m *Manager) setAutoBrightnessCurve(points []LuxBrightnessPoint) error {
+	curve := luxBrightnessCurve(points)
+	err := curve.check()
+	if err != nil {
+		return err
+	}
+	controller := m.getAutoBrightnessController()
+	if controller == nil {
+		return errors.New("no ambient light sensor")
+	}
+	controller.setCurve(curve)
+	m.saveAutoBrightnessInCfg(func(cfg *UserAutoBrightnessConfig) {
+		cfg.Curve = curve.clone()
+	})
+	return nil
+}
+
+// learnAutoBrightness 在开启自动亮度时，记录用户手动设置的内置显示器亮度。
+func (m *Manager) learnAutoBrightness(outputName string, value float64) {
+	builtinMonitor := m.getBuiltinMonitor()
+	if builtinMonitor == nil || builtinMonitor.Name != outputName {
+		return
+	}
+	controller := m.getAutoBrightnessController()
+	if controller == nil {
+		return
+	}
+	curve, ok := controller.learn(value)
+	if !ok {
+		return
+	}
+	logger.Debug("auto brightness curve learned:", curve)
+	m.saveAutoBrightnessInCfg(func(cfg *UserAutoBrightnessConfig) {
+		cfg.Curve = curve
+	})
+}
+
+func (m *Manager) saveAutoBrightnessInCfg(fn func(cfg *UserAutoBrightnessConfig)) {
+	m.userCfgMu.Lock()
+	defer m.userCfgMu.Unlock()
+	if m.userConfig.AutoBrightness == nil {
+		m.userConfig.AutoBrightness = &UserAutoBrightnessConfig{}
+	}
+	fn(m.userConfig.AutoBrightness)
+	err := m.saveUserConfigNoLock()
+	if err != nil {
+		logger.Warning(err)
+	}
+}
@@ -0,0 +1,226 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_luxBrightnessCurve(t *testing.T) {
+	curve := getDefaultLuxBrightnessCurve()
+	require.NoError(t, curve.check())
+	assert.Equal(t, 0.2, curve.brightnessAt(0))
+	assert.Equal(t, 0.45, curve.brightnessAt(50))
+	assert.Equal(t, 1.0, curve.brightnessAt(100000))
+	v := curve.brightnessAt(100)
+	assert.Greater(t, v, 0.45)
+	assert.Less(t, v, 0.6)
+
+	assert.Error(t, luxBrightnessCurve{{Lux: 0, Brightness: 0.5}}.check())
+	assert.Error(t, luxBrightnessCurve{{Lux: 10, Brightness: 0.5}, {Lux: 10, Brightness: 0.6}}.check())
+	assert.Error(t, luxBrightnessCurve{{Lux: 0, Brightness: 0.5}, {Lux: 10, Brightness: 0.4}}.check())
+	assert.Error(t, luxBrightnessCurve{{Lux: 0, Brightness: 0.5}, {Lux: 10, Brightness: 1.5}}.check())
+}
+
+func Test_luxBrightnessCurve_learn(t *testing.T) {
+	curve := getDefaultLuxBrightnessCurve()
+	learned := curve.learn(100, 0.9)
+	require.NoError(t, learned.check())
+	assert.InDelta(t, 0.9, learned.brightnessAt(100), 0.0001)
+	// 更亮的环境下亮度不能更低
+	assert.GreaterOrEqual(t, learned.brightnessAt(200), 0.9)
+	assert.Equal(t, 0.2, learned.brightnessAt(0))
+	// 原曲线不变
+	assert.Equal(t, getDefaultLuxBrightnessCurve(), curve)
+
+	// 附近的点被替换
+	learned = curve.learn(52, 0.3)
+	require.NoError(t, learned.check())
+	assert.Len(t, learned, len(curve))
+	assert.InDelta(t, 0.3, learned.brightnessAt(52), 0.0001)
+	assert.LessOrEqual(t, learned.brightnessAt(10), 0.3)
+
+	learned = luxBrightnessCurve{{Lux: 0, Brightness: 0.1}, {Lux: 1, Brightness: 0.2}}.learn(0, 0.5)
+	require.NoError(t, learned.check())
+}
+
+type fakeLightSensor struct {
+	mu  sync.Mutex
+	lux float64
+	cb  func(lux float64)
+}
+
+func (s *fakeLightSensor) getLightLevel() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lux, nil
+}
+
+func (s *fakeLightSensor) start(cb func(lux float64)) error {
+	s.mu.Lock()
+	s.cb = cb
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *fakeLightSensor) stop() {
+	s.mu.Lock()
+	s.cb = nil
+	s.mu.Unlock()
+}
+
+func (s *fakeLightSensor) setLux(lux float64) {
+	s.mu.Lock()
+	s.lux = lux
+	cb := s.cb
+	s.mu.Unlock()
+	if cb != nil {
+		cb(lux)
+	}
+}
+
+func TestAutoBrightnessController(t *testing.T) {
+	sensor := &fakeLightSensor{lux: 50}
+	r := &brightnessRecorder{}
+	c := newAutoBrightnessController(sensor, nil, func(value float64) error {
+		return r.apply("", value)
+	})
+
+	sensor.setLux(1000)
+	sensor.setLux(50)
+	assert.Empty(t, r.get())
+
+	require.NoError(t, c.setEnabled(true))
+	assert.Equal(t, []float64{0.45}, r.get())
+
+	// 变化太小，不调整
+	sensor.setLux(55)
+	assert.Len(t, r.get(), 1)
+
+	sensor.setLux(1000)
+	assert.Equal(t, []float64{0.45, 0.85}, r.get())
+
+	// 学习后立即使用新的曲线
+	curve, ok := c.learn(0.95)
+	require.True(t, ok)
+	assert.InDelta(t, 0.95, curve.brightnessAt(1000), 0.0001)
+	sensor.setLux(10)
+	sensor.setLux(1000)
+	values := r.get()
+	assert.Equal(t, 0.95, values[len(values)-1])
+
+	require.NoError(t, c.setEnabled(false))
+	r.reset()
+	sensor.setLux(0)
+	assert.Empty(t, r.get())
+	_, ok = c.learn(0.5)
+	assert.False(t, ok)
+}
+
+// mockHadessSensor 模拟 iio-sensor-proxy 的 net.hadess.SensorProxy 服务
+type mockHadessSensor struct {
+	service *dbusutil.Service
+	PropsMu sync.RWMutex
+
+	HasAmbientLight bool
+	LightLevel      float64
+	LightLevelUnit  string
+
+	claimed int
+}
+
+func (s *mockHadessSensor) GetInterfaceName() string {
+	return hadessSensorProxyInterface
+}
+
+func (s *mockHadessSensor) GetExportedMethods() dbusutil.ExportedMethods {
+	return dbusutil.ExportedMethods{
+		{Name: "ClaimLight", Fn: s.ClaimLight},
+		{Name: "ReleaseLight", Fn: s.ReleaseLight},
+	}
+}
+
+func (s *mockHadessSensor) ClaimLight() *dbus.Error {
+	s.PropsMu.Lock()
+	s.claimed++
+	s.PropsMu.Unlock()
+	return nil
+}
+
+func (s *mockHadessSensor) ReleaseLight() *dbus.Error {
+	s.PropsMu.Lock()
+	s.claimed--
+	s.PropsMu.Unlock()
+	return nil
+}
+
+func (s *mockHadessSensor) getClaimed() int {
+	s.PropsMu.RLock()
+	defer s.PropsMu.RUnlock()
+	return s.claimed
+}
+
+func (s *mockHadessSensor) setLightLevel(lux float64) {
+	s.PropsMu.Lock()
+	s.LightLevel = lux
+	s.PropsMu.Unlock()
+	_ = s.service.EmitPropertyChanged(s, "LightLevel", lux)
+}
+
+func TestHadessLightSensor(t *testing.T) {
+	service, err := dbusutil.NewSessionService()
+	if err != nil {
+		t.Skip("failed to get session service:", err)
+	}
+	mock := &mockHadessSensor{
+		service:         service,
+		HasAmbientLight: true,
+		LightLevel:      50,
+		LightLevelUnit:  "lux",
+	}
+	require.NoError(t, service.Export(hadessSensorProxyPath, mock))
+	require.NoError(t, service.RequestName(hadessSensorProxyService))
+	defer func() {
+		_ = service.ReleaseName(hadessSensorProxyService)
+		_ = service.StopExport(mock)
+	}()
+
+	conn, err := dbus.SessionBusPrivate()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Auth(nil))
+	require.NoError(t, conn.Hello())
+
+	sensor, err := findLightSensor(conn)
+	require.NoError(t, err)
+	require.IsType(t, &hadessLightSensor{}, sensor)
+	lux, err := sensor.getLightLevel()
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, lux)
+
+	r := &brightnessRecorder{}
+	c := newAutoBrightnessController(sensor, nil, func(value float64) error {
+		return r.apply("", value)
+	})
+	require.NoError(t, c.setEnabled(true))
+	assert.Equal(t, 1, mock.getClaimed())
+	assert.Equal(t, []float64{0.45}, r.get())
+
+	mock.setLightLevel(5000)
+	assert.Eventually(t, func() bool {
+		values := r.get()
+		return values[len(values)-1] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, c.setEnabled(false))
+	assert.Equal(t, 0, mock.getClaimed())
+}
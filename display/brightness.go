@@ -96,7 +96,7 @@ func (m *Manager) changeBrightness(raised bool) error {
 			br = 0.0
 		}
 		logger.Debug("[changeBrightness] will set to:", monitor.Name, br)
-		err := m.setBrightnessAndSync(monitor.Name, br)
+		err := m.setBrightnessAndSync(monitor.Name, br, false)
 		if err != nil {
 			logger.Warning(err)
 			continue
@@ -175,18 +175,25 @@ func (m *Manager) isBuiltinMonitor(name string) bool {
 	return false
 }
 
-func (m *Manager) setMonitorBrightness(monitor *Monitor, brightnessValue float64, temperature int) error {
+// setMonitorBrightness 设置显示器的亮度，同时设置显示器当前的色温。
+func (m *Manager) setMonitorBrightness(monitor *Monitor, brightnessValue float64) error {
+	temperature := m.getMonitorColorTemperatureValue(monitor)
 	if !isValidColorTempValue(int32(temperature)) {
 		temperature = defaultTemperatureManual
 	}
 
 	isBuiltin := m.isBuiltinMonitor(monitor.Name)
+	monitor.PropsMu.RLock()
+	edid := monitor.edid
+	calibration := monitor.calibration
+	monitor.PropsMu.RUnlock()
 	err := brightness.Set(brightnessValue, temperature, m.getBrightnessSetter(), isBuiltin,
-		monitor.ID, m.xConn)
+		monitor.ID, monitor.Name, edid, calibration, m.xConn)
 	return err
 }
 
-func (m *Manager) setBrightnessAux(fake bool, name string, value float64) error {
+// setBrightnessAux 设置显示器的亮度，instant 为 false 时用动画过渡到新的亮度，Brightness 属性直接设置为目标值。
+func (m *Manager) setBrightnessAux(fake bool, name string, value float64, instant bool) error {
 	monitors := m.getConnectedMonitors()
 	monitor := monitors.GetByName(name)
 	if monitor == nil {
@@ -199,12 +206,16 @@ func (m *Manager) setBrightnessAux(fake bool, name string, value float64) error
 
 	value = math.Round(value*1000) / 1000 // 通过该方法，用来对亮度值(亮度值范围为0-1)四舍五入保留小数点后三位有效数字
 	if !fake && enabled {
-		temperature := m.getColorTemperatureValue()
 		// 保持最小亮度，不能全黑
 		if value <= 0.1 {
 			value = 0.1
 		}
-		err := m.setMonitorBrightness(monitor, value, temperature)
+		var err error
+		if m.brightnessAnimator != nil {
+			err = m.brightnessAnimator.set(name, value, instant)
+		} else {
+			err = m.applyMonitorBrightness(name, value)
+		}
 		if err != nil {
 			logger.Warningf("failed to set brightness for %s: %v", name, err)
 			return err
@@ -217,11 +228,11 @@ func (m *Manager) setBrightnessAux(fake bool, name string, value float64) error
 }
 
 func (m *Manager) setBrightness(name string, value float64) error {
-	return m.setBrightnessAux(false, name, value)
+	return m.setBrightnessAux(false, name, value, false)
 }
 
-func (m *Manager) setBrightnessAndSync(name string, value float64) error {
-	err := m.setBrightness(name, value)
+func (m *Manager) setBrightnessAndSync(name string, value float64, instant bool) error {
+	err := m.setBrightnessAux(false, name, value, instant)
 	if err == nil {
 		m.syncPropBrightness()
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/linuxdeepin/go-lib/multierr"
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/startdde/display/icc"
 )
 
 var _useWayland bool
@@ -27,6 +28,7 @@ const (
 	SetterAuto      = "auto"
 	SetterGamma     = "gamma"
 	SetterBacklight = "backlight"
+	SetterDDCCI     = "ddcci"
 )
 
 var logger = log.NewLogger("daemon/display/brightness")
@@ -42,7 +44,10 @@ func InitBacklightHelper() {
 	helper = backlight.NewBacklight(sysBus)
 }
 
-func Set(brightness float64, temperature int, setter string, isBuiltin bool, outputId uint32, conn *x.Conn) error {
+// Set 设置显示器的亮度和色温，outputName 和 edid 用来找到显示器的 DDC/CI 设备，
+// calibration 是显示器 ICC 配置文件中的校准曲线，可以为 nil。
+func Set(brightness float64, temperature int, setter string, isBuiltin bool, outputId uint32, outputName string,
+	edid []byte, calibration *icc.VCGT, conn *x.Conn) error {
 	if brightness < 0 {
 		brightness = 0
 	} else if brightness > 1 {
@@ -62,6 +67,26 @@ func Set(brightness float64, temperature int, setter string, isBuiltin bool, out
 		err = setOutputCrtcGamma(gammaSetting{
 			brightness:  1,
 			temperature: temperature,
+			calibration: calibration,
+		}, output, conn)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+		}
+		return errs
+	}
+
+	// 亮度用 DDC/CI 设置显示器的背光，色温用 gamma
+	setDDCGamma := func() error {
+		var errs error
+		err := setDDCBrightness(brightness, outputName, edid)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+		}
+
+		err = setOutputCrtcGamma(gammaSetting{
+			brightness:  1,
+			temperature: temperature,
+			calibration: calibration,
 		}, output, conn)
 		if err != nil {
 			errs = multierr.Append(errs, err)
@@ -74,6 +99,7 @@ func Set(brightness float64, temperature int, setter string, isBuiltin bool, out
 		return setOutputCrtcGamma(gammaSetting{
 			brightness:  brightness,
 			temperature: temperature,
+			calibration: calibration,
 		}, output, conn)
 	}
 
@@ -81,15 +107,45 @@ func Set(brightness float64, temperature int, setter string, isBuiltin bool, out
 	switch setter {
 	case SetterBacklight:
 		setFn = setBlGamma
+	case SetterDDCCI:
+		setFn = setDDCGamma
 	case SetterAuto:
 		if isBuiltin && supportBacklight() {
 			setFn = setBlGamma
+		} else if !isBuiltin && supportDDC(outputName, edid) {
+			// 外接显示器优先用 DDC/CI 调节背光，写失败时改用 gamma
+			setFn = func() error {
+				err := setDDCBrightness(brightness, outputName, edid)
+				if err != nil {
+					logger.Warningf("set brightness of %s by ddc/ci failed, fallback to gamma: %v", outputName, err)
+					return setGamma()
+				}
+				return setOutputCrtcGamma(gammaSetting{
+					brightness:  1,
+					temperature: temperature,
+					calibration: calibration,
+				}, output, conn)
+			}
 		}
 		//case SetterGamma
 	}
 	return setFn()
 }
 
+// IsGammaOnly 判断是否只用 gamma 设置显示器的亮度，和 Set 选择设置方式的逻辑相同。
+func IsGammaOnly(setter string, isBuiltin bool, outputName string, edid []byte) bool {
+	switch setter {
+	case SetterBacklight, SetterDDCCI:
+		return false
+	case SetterAuto:
+		if isBuiltin {
+			return !supportBacklight()
+		}
+		return !supportDDC(outputName, edid)
+	}
+	return true
+}
+
 // unused function
 //func Get(setter string, isButiltin bool, outputId uint32, conn *x.Conn) (float64, error) {
 //	output := randr.Output(outputId)
@@ -162,12 +218,21 @@ func setOutputCrtcGamma(setting gammaSetting, output randr.Output, conn *x.Conn)
 		return fmt.Errorf("output(%v) has invalid gamma size", output)
 	}
 
-	red, green, blue := initGammaRamp(int(gamma.Size))
-	fillColorRamp(red, green, blue, setting)
+	red, green, blue := genColorRamp(int(gamma.Size), setting)
 	return randr.SetCrtcGammaChecked(conn, outputInfo.Crtc,
 		red, green, blue).Check(conn)
 }
 
+// genColorRamp 生成 gamma 表，先用校准曲线，再叠加亮度和色温。
+func genColorRamp(size int, setting gammaSetting) (red, green, blue []uint16) {
+	red, green, blue = initGammaRamp(size)
+	if setting.calibration != nil {
+		setting.calibration.Apply(red, green, blue)
+	}
+	fillColorRamp(red, green, blue, setting)
+	return
+}
+
 func initGammaRamp(size int) (red, green, blue []uint16) {
 	red = make([]uint16, size)
 	green = make([]uint16, size)
@@ -4,6 +4,8 @@
 
 package brightness
 
+import "github.com/linuxdeepin/startdde/display/icc"
+
 // 从 redshift 项目复制的
 
 /* Whitepoint values for temperatures at 100K intervals.
@@ -265,6 +267,7 @@ func interpolateColor(a float64, c1, c2 []float64) [3]float64 {
 type gammaSetting struct {
 	brightness  float64
 	temperature int
+	calibration *icc.VCGT
 }
 
 func fillColorRamp(gammaR, gammaG, gammaB []uint16, setting gammaSetting) {
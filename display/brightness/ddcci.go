@@ -0,0 +1,524 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package brightness
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DDC/CI 通过显示器 DDC 通道上的 I2C 总线读写 MCCS 的 VCP 功能，可以直接调节外接显示器的背光亮度和对比度，
+// 而不是像 gamma 那样只把画面调暗。I2C 很慢，显示器是否支持和 VCP 的最大值都会缓存起来。
+
+const (
+	ddcciAddr = 0x37
+	// 主机和显示器在 DDC/CI 协议中的地址
+	ddcHostAddr          = 0x51
+	ddcDisplayAddr       = 0x6e
+	ddcReplyChecksumAddr = 0x50
+
+	ddcOpGetVcp      = 0x01
+	ddcOpGetVcpReply = 0x02
+	ddcOpSetVcp      = 0x03
+
+	VcpBrightness = 0x10
+	VcpContrast   = 0x12
+
+	// linux/i2c-dev.h
+	i2cSlave = 0x0703
+
+	ddcReplyDelay    = 40 * time.Millisecond
+	ddcWriteInterval = 50 * time.Millisecond
+	ddcRetryCount    = 3
+	// 不支持 DDC/CI 的显示器过一段时间后再检测，显示器可能在菜单中打开了 DDC/CI
+	ddcRecheckInterval = 5 * time.Minute
+)
+
+var (
+	errVcpUnsupported = errors.New("vcp feature is not supported")
+	errDDCNullReply   = errors.New("ddc/ci null reply")
+)
+
+// 测试时替换
+var (
+	sysDrmDir     = "/sys/class/drm"
+	devDir        = "/dev"
+	openI2cDevice = openI2cDev
+	ddcSleep      = time.Sleep
+)
+
+type i2cDevice interface {
+	io.ReadWriteCloser
+}
+
+func openI2cDev(path string) (i2cDevice, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), i2cSlave, ddcciAddr)
+	if errno != 0 {
+		_ = f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+func ddcChecksum(init byte, data []byte) byte {
+	sum := init
+	for _, b := range data {
+		sum ^= b
+	}
+	return sum
+}
+
+func buildGetVcpRequest(code byte) []byte {
+	msg := []byte{ddcHostAddr, 0x82, ddcOpGetVcp, code}
+	return append(msg, ddcChecksum(ddcDisplayAddr, msg))
+}
+
+func buildSetVcpRequest(code byte, value uint16) []byte {
+	msg := []byte{ddcHostAddr, 0x84, ddcOpSetVcp, code, byte(value >> 8), byte(value)}
+	return append(msg, ddcChecksum(ddcDisplayAddr, msg))
+}
+
+// parseGetVcpReply 解析 Get VCP Feature 的回复，
+// 格式为：源地址 长度 0x02 结果 VCP 类型 最大值(2) 当前值(2) 校验和。
+func parseGetVcpReply(code byte, data []byte) (current, max uint16, err error) {
+	if len(data) >= 3 && data[0] == ddcDisplayAddr && data[1] == 0x80 {
+		// 显示器忙，需要重试
+		return 0, 0, errDDCNullReply
+	}
+	if len(data) < 11 {
+		return 0, 0, fmt.Errorf("ddc/ci reply is too short: %d", len(data))
+	}
+	if data[0] != ddcDisplayAddr || data[1] != 0x88 || data[2] != ddcOpGetVcpReply {
+		return 0, 0, fmt.Errorf("invalid ddc/ci reply % x", data[:3])
+	}
+	if ddcChecksum(ddcReplyChecksumAddr, data[:10]) != data[10] {
+		return 0, 0, errors.New("ddc/ci reply checksum mismatch")
+	}
+	if data[3] != 0 {
+		return 0, 0, errVcpUnsupported
+	}
+	if data[4] != code {
+		return 0, 0, fmt.Errorf("ddc/ci reply vcp code mismatch: %#x", data[4])
+	}
+	max = uint16(data[6])<<8 | uint16(data[7])
+	current = uint16(data[8])<<8 | uint16(data[9])
+	return current, max, nil
+}
+
+// ddcDevice 是一个显示器的 DDC/CI 设备，缓存 VCP 的最大值和最后设置的值。
+type ddcDevice struct {
+	mu        sync.Mutex
+	path      string
+	maxValues map[byte]uint16
+	values    map[byte]uint16
+	lastWrite time.Time
+}
+
+func newDDCDevice(path string) *ddcDevice {
+	return &ddcDevice{
+		path:      path,
+		maxValues: make(map[byte]uint16),
+		values:    make(map[byte]uint16),
+	}
+}
+
+// waitWriteInterval DDC/CI 要求两次写之间至少间隔 50 毫秒
+func (d *ddcDevice) waitWriteInterval() {
+	elapsed := time.Since(d.lastWrite)
+	if elapsed < ddcWriteInterval {
+		ddcSleep(ddcWriteInterval - elapsed)
+	}
+}
+
+func (d *ddcDevice) write(dev i2cDevice, data []byte) error {
+	d.waitWriteInterval()
+	_, err := dev.Write(data)
+	d.lastWrite = time.Now()
+	return err
+}
+
+func (d *ddcDevice) readVcp(code byte) (current, max uint16, err error) {
+	dev, err := openI2cDevice(d.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer dev.Close()
+
+	for i := 0; i < ddcRetryCount; i++ {
+		err = d.write(dev, buildGetVcpRequest(code))
+		if err != nil {
+			continue
+		}
+		ddcSleep(ddcReplyDelay)
+		buf := make([]byte, 11)
+		var n int
+		n, err = dev.Read(buf)
+		if err != nil {
+			continue
+		}
+		current, max, err = parseGetVcpReply(code, buf[:n])
+		if err == nil || err == errVcpUnsupported {
+			break
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	d.maxValues[code] = max
+	d.values[code] = current
+	return current, max, nil
+}
+
+func (d *ddcDevice) writeVcp(code byte, value uint16) error {
+	dev, err := openI2cDevice(d.path)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	err = d.write(dev, buildSetVcpRequest(code, value))
+	if err != nil {
+		return err
+	}
+	d.values[code] = value
+	return nil
+}
+
+// getVcp 获取 VCP 的值，范围为 0~1，有缓存时不读取设备。
+func (d *ddcDevice) getVcp(code byte) (float64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current, ok := d.values[code]
+	max := d.maxValues[code]
+	if !ok || max == 0 {
+		var err error
+		current, max, err = d.readVcp(code)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if max == 0 {
+		return 0, errVcpUnsupported
+	}
+	return float64(current) / float64(max), nil
+}
+
+// setVcp 设置 VCP 的值，value 的范围为 0~1，与上次设置的值相同时不写设备。
+func (d *ddcDevice) setVcp(code byte, value float64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	max, ok := d.maxValues[code]
+	if !ok {
+		var err error
+		_, max, err = d.readVcp(code)
+		if err != nil {
+			return err
+		}
+	}
+	if max == 0 {
+		return errVcpUnsupported
+	}
+	raw := uint16(math.Round(value * float64(max)))
+	if current, ok := d.values[code]; ok && current == raw {
+		return nil
+	}
+	logger.Debugf("ddc/ci set vcp %#x of %s to %d/%d", code, d.path, raw, max)
+	return d.writeVcp(code, raw)
+}
+
+var regCardConnector = regexp.MustCompile(`^card\d+-(.+)$`)
+var regI2cBus = regexp.MustCompile(`^i2c-\d+$`)
+
+// regOutputName 匹配 RandR 输出名称，比如 modesetting 的 HDMI-1、amdgpu 的 DisplayPort-0 和 intel 的 eDP1。
+var regOutputName = regexp.MustCompile(`^([A-Za-z]+(?:-[A-Z])?)-?(\d+)$`)
+
+// 和 DRM 连接器类型名称不同的 RandR 输出类型名称
+var outputTypeDrmNames = map[string]string{
+	"HDMI":        "HDMI-A",
+	"DisplayPort": "DP",
+}
+
+func edidEqual(edid1, edid2 []byte) bool {
+	if len(edid1) == len(edid2) {
+		return bytes.Equal(edid1, edid2)
+	}
+	// 有的地方只能拿到基本块
+	if len(edid1) >= 128 && len(edid2) >= 128 && (len(edid1) == 128 || len(edid2) == 128) {
+		return bytes.Equal(edid1[:128], edid2[:128])
+	}
+	return false
+}
+
+// getConnectorNamesForOutput 返回 RandR 输出可能对应的 DRM 连接器名称，不包含 cardN- 前缀，可能性大的在前。
+// DRM 连接器的序号从 1 开始，modesetting 和 intel 驱动的输出序号也从 1 开始，amdgpu、radeon 和 nvidia 驱动从 0 开始。
+func getConnectorNamesForOutput(outputName string) []string {
+	match := regOutputName.FindStringSubmatch(outputName)
+	if match == nil {
+		return nil
+	}
+	typeName := match[1]
+	if drmName, ok := outputTypeDrmNames[typeName]; ok {
+		typeName = drmName
+	}
+	idx, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil
+	}
+	oneBased := fmt.Sprintf("%s-%d", typeName, idx)
+	zeroBased := fmt.Sprintf("%s-%d", typeName, idx+1)
+	// amdgpu 和 radeon 驱动的名称是 DisplayPort-N 或 HDMI-A-N 这种形式，modesetting 驱动把 HDMI-A 叫做 HDMI
+	if idx == 0 || match[1] == "DisplayPort" || match[1] == "HDMI-A" {
+		return []string{zeroBased, oneBased}
+	}
+	return []string{oneBased, zeroBased}
+}
+
+// findDRMConnector 根据 RandR 输出名称找到对应的 DRM 连接器目录名称，比如 card0-HDMI-A-1，
+// EDID 用来排除名称相近但是连接了其他显示器的连接器。
+// 名称无法对应时（比如 DP MST 的 DP-1-1），只有 EDID 唯一匹配一个连接器时才使用该连接器，
+// 因为两个相同型号的显示器 EDID 可能完全相同。
+func findDRMConnector(outputName string, edid []byte) (string, error) {
+	fileInfos, err := ioutil.ReadDir(sysDrmDir)
+	if err != nil {
+		return "", err
+	}
+	connectors := make(map[string]string) // 连接器名称 => 目录名称
+	var edidMatched []string
+	for _, info := range fileInfos {
+		match := regCardConnector.FindStringSubmatch(info.Name())
+		if match == nil {
+			continue
+		}
+		status, err := ioutil.ReadFile(filepath.Join(sysDrmDir, info.Name(), "status"))
+		if err == nil && strings.TrimSpace(string(status)) != "connected" {
+			continue
+		}
+		sysEdid, _ := ioutil.ReadFile(filepath.Join(sysDrmDir, info.Name(), "edid"))
+		if len(edid) > 0 && len(sysEdid) > 0 && !edidEqual(edid, sysEdid) {
+			// 连接了其他显示器
+			continue
+		}
+		if _, ok := connectors[match[1]]; !ok {
+			connectors[match[1]] = info.Name()
+		}
+		if len(edid) > 0 && len(sysEdid) > 0 {
+			edidMatched = append(edidMatched, info.Name())
+		}
+	}
+
+	for _, name := range getConnectorNamesForOutput(outputName) {
+		if dirName, ok := connectors[name]; ok {
+			return dirName, nil
+		}
+	}
+	if len(edidMatched) == 1 {
+		return edidMatched[0], nil
+	}
+	return "", fmt.Errorf("drm connector of output %q not found", outputName)
+}
+
+// findDDCBus 找到 DRM 连接器的 I2C 总线设备
+func findDDCBus(connector string) (string, error) {
+	bus := getConnectorI2cBus(filepath.Join(sysDrmDir, connector))
+	if bus == "" {
+		return "", fmt.Errorf("connector %s has no i2c bus", connector)
+	}
+	return filepath.Join(devDir, bus), nil
+}
+
+func getConnectorI2cBus(connectorDir string) string {
+	// HDMI、DVI 等通常有 ddc 链接
+	target, err := os.Readlink(filepath.Join(connectorDir, "ddc"))
+	if err == nil {
+		bus := filepath.Base(target)
+		if regI2cBus.MatchString(bus) {
+			return bus
+		}
+	}
+	// DP 的 AUX 通道会在连接器下面创建 i2c-N
+	fileInfos, err := ioutil.ReadDir(connectorDir)
+	if err != nil {
+		return ""
+	}
+	for _, info := range fileInfos {
+		if regI2cBus.MatchString(info.Name()) {
+			return info.Name()
+		}
+	}
+	return ""
+}
+
+// ddcCacheEntry 是一个 DRM 连接器的检测结果，mu 只在检测这个连接器时加锁，不影响其他显示器。
+type ddcCacheEntry struct {
+	mu        sync.Mutex
+	connector string
+	edid      []byte
+	dev       *ddcDevice
+	err       error
+	checkedAt time.Time
+}
+
+// ddcCache 的键是 DRM 连接器，相同型号的显示器 EDID 可能相同，不能用 EDID 区分。
+// connectors 缓存 RandR 输出名称对应的 DRM 连接器。
+var ddcCache = struct {
+	mu         sync.Mutex
+	connectors map[string]string
+	entries    map[string]*ddcCacheEntry
+}{
+	connectors: make(map[string]string),
+	entries:    make(map[string]*ddcCacheEntry),
+}
+
+func getDDCCacheEntry(outputName string, edid []byte) (*ddcCacheEntry, error) {
+	ddcCache.mu.Lock()
+	connector, ok := ddcCache.connectors[outputName]
+	ddcCache.mu.Unlock()
+	if !ok {
+		var err error
+		connector, err = findDRMConnector(outputName, edid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ddcCache.mu.Lock()
+	defer ddcCache.mu.Unlock()
+	ddcCache.connectors[outputName] = connector
+	entry := ddcCache.entries[connector]
+	if entry == nil {
+		entry = &ddcCacheEntry{connector: connector}
+		ddcCache.entries[connector] = entry
+	}
+	return entry, nil
+}
+
+// getDDCDevice 获取显示器的 DDC/CI 设备，第一次获取时检测是否支持调节亮度，检测结果会被缓存。
+// 检测时不持有全局的锁，一个显示器检测很慢时不会阻塞其他显示器。
+func getDDCDevice(outputName string, edid []byte) (*ddcDevice, error) {
+	entry, err := getDDCCacheEntry(outputName, edid)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !edidEqual(entry.edid, edid) {
+		// 连接器上换了显示器，重新检测
+		entry.edid = edid
+		entry.dev = nil
+		entry.checkedAt = time.Time{}
+	}
+	if entry.dev != nil {
+		return entry.dev, nil
+	}
+	if !entry.checkedAt.IsZero() && time.Since(entry.checkedAt) < ddcRecheckInterval {
+		return nil, entry.err
+	}
+
+	dev, err := probeDDCDevice(entry.connector)
+	if err != nil {
+		logger.Debug("ddc/ci is not available:", err)
+		dev = nil
+	}
+	entry.dev = dev
+	entry.err = err
+	entry.checkedAt = time.Now()
+	return dev, err
+}
+
+func probeDDCDevice(connector string) (*ddcDevice, error) {
+	path, err := findDDCBus(connector)
+	if err != nil {
+		return nil, err
+	}
+	dev := newDDCDevice(path)
+	_, err = dev.getVcp(VcpBrightness)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return dev, nil
+}
+
+// removeDDCDevice 读写失败时删除缓存，下次重新查找连接器和总线，比如显示器换了接口。
+func removeDDCDevice(outputName string) {
+	ddcCache.mu.Lock()
+	connector, ok := ddcCache.connectors[outputName]
+	if ok {
+		delete(ddcCache.connectors, outputName)
+		delete(ddcCache.entries, connector)
+	}
+	ddcCache.mu.Unlock()
+}
+
+func supportDDC(outputName string, edid []byte) bool {
+	_, err := getDDCDevice(outputName, edid)
+	return err == nil
+}
+
+func setDDCVcp(code byte, value float64, outputName string, edid []byte) error {
+	dev, err := getDDCDevice(outputName, edid)
+	if err != nil {
+		return err
+	}
+	err = dev.setVcp(code, value)
+	if err != nil && !errors.Is(err, errVcpUnsupported) {
+		removeDDCDevice(outputName)
+	}
+	return err
+}
+
+func getDDCVcp(code byte, outputName string, edid []byte) (float64, error) {
+	dev, err := getDDCDevice(outputName, edid)
+	if err != nil {
+		return 0, err
+	}
+	value, err := dev.getVcp(code)
+	if err != nil && !errors.Is(err, errVcpUnsupported) {
+		removeDDCDevice(outputName)
+	}
+	return value, err
+}
+
+func setDDCBrightness(value float64, outputName string, edid []byte) error {
+	return setDDCVcp(VcpBrightness, value, outputName, edid)
+}
+
+// GetDDCBrightness 通过 DDC/CI 获取显示器的亮度，范围为 0~1。
+func GetDDCBrightness(outputName string, edid []byte) (float64, error) {
+	return getDDCVcp(VcpBrightness, outputName, edid)
+}
+
+// GetContrast 通过 DDC/CI 获取显示器的对比度，范围为 0~1。
+func GetContrast(outputName string, edid []byte) (float64, error) {
+	return getDDCVcp(VcpContrast, outputName, edid)
+}
+
+// SetContrast 通过 DDC/CI 设置显示器的对比度，范围为 0~1。
+func SetContrast(outputName string, edid []byte, value float64) error {
+	if value < 0 {
+		value = 0
+	} else if value > 1 {
+		value = 1
+	}
+	return setDDCVcp(VcpContrast, value, outputName, edid)
+}
@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package brightness
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVcp 是假设备中一个 VCP 功能的值
+type fakeVcp struct {
+	Current uint16
+	Max     uint16
+}
+
+// fakeI2cDevice 模拟 DDC/CI 显示器，VCP 的值保存在设备文件中，JSON 格式。
+type fakeI2cDevice struct {
+	path   string
+	reply  []byte
+	writes *int
+}
+
+func (d *fakeI2cDevice) load() map[byte]*fakeVcp {
+	var vcps map[byte]*fakeVcp
+	data, _ := ioutil.ReadFile(d.path)
+	_ = json.Unmarshal(data, &vcps)
+	return vcps
+}
+
+func (d *fakeI2cDevice) save(vcps map[byte]*fakeVcp) {
+	data, _ := json.Marshal(vcps)
+	_ = ioutil.WriteFile(d.path, data, 0644)
+}
+
+func (d *fakeI2cDevice) Write(data []byte) (int, error) {
+	*d.writes++
+	vcps := d.load()
+	switch data[2] {
+	case ddcOpGetVcp:
+		code := data[3]
+		reply := []byte{ddcDisplayAddr, 0x88, ddcOpGetVcpReply, 0, code, 0, 0, 0, 0, 0}
+		if vcp, ok := vcps[code]; ok {
+			reply[6], reply[7] = byte(vcp.Max>>8), byte(vcp.Max)
+			reply[8], reply[9] = byte(vcp.Current>>8), byte(vcp.Current)
+		} else {
+			reply[3] = 1
+		}
+		d.reply = append(reply, ddcChecksum(ddcReplyChecksumAddr, reply))
+	case ddcOpSetVcp:
+		vcp := vcps[data[3]]
+		if vcp != nil {
+			vcp.Current = uint16(data[4])<<8 | uint16(data[5])
+			d.save(vcps)
+		}
+	}
+	return len(data), nil
+}
+
+func (d *fakeI2cDevice) Read(buf []byte) (int, error) {
+	return copy(buf, d.reply), nil
+}
+
+func (d *fakeI2cDevice) Close() error {
+	return nil
+}
+
+const testOutput = "HDMI-1"
+
+var testEdid = append([]byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, make([]byte, 120)...)
+
+// setupFakeDDC 创建假的 sysfs 和 /dev 目录，card0-HDMI-A-1 的 I2C 总线为 i2c-5，对应的 RandR 输出为 HDMI-1。
+func setupFakeDDC(t *testing.T, vcps map[byte]*fakeVcp) (devPath string, writes *int) {
+	dir := t.TempDir()
+	connectorDir := filepath.Join(dir, "drm", "card0-HDMI-A-1")
+	require.NoError(t, os.MkdirAll(connectorDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(connectorDir, "edid"), testEdid, 0644))
+	require.NoError(t, os.Symlink("../../i2c-5", filepath.Join(connectorDir, "ddc")))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "dev"), 0755))
+	devPath = filepath.Join(dir, "dev", "i2c-5")
+	writes = new(int)
+	(&fakeI2cDevice{path: devPath}).save(vcps)
+
+	oldSysDrmDir, oldDevDir, oldOpen, oldSleep := sysDrmDir, devDir, openI2cDevice, ddcSleep
+	sysDrmDir = filepath.Join(dir, "drm")
+	devDir = filepath.Join(dir, "dev")
+	openI2cDevice = func(path string) (i2cDevice, error) {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		return &fakeI2cDevice{path: path, writes: writes}, nil
+	}
+	ddcSleep = func(time.Duration) {}
+	t.Cleanup(func() {
+		sysDrmDir, devDir, openI2cDevice, ddcSleep = oldSysDrmDir, oldDevDir, oldOpen, oldSleep
+		ddcCache.mu.Lock()
+		ddcCache.connectors = make(map[string]string)
+		ddcCache.entries = make(map[string]*ddcCacheEntry)
+		ddcCache.mu.Unlock()
+	})
+	return devPath, writes
+}
+
+func Test_buildVcpRequest(t *testing.T) {
+	assert.Equal(t, []byte{0x51, 0x82, 0x01, 0x10, 0xac}, buildGetVcpRequest(VcpBrightness))
+	assert.Equal(t, []byte{0x51, 0x84, 0x03, 0x10, 0x00, 0x32, 0x9a}, buildSetVcpRequest(VcpBrightness, 50))
+}
+
+func Test_parseGetVcpReply(t *testing.T) {
+	reply := []byte{0x6e, 0x88, 0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32}
+	reply = append(reply, ddcChecksum(ddcReplyChecksumAddr, reply))
+	current, max, err := parseGetVcpReply(VcpBrightness, reply)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(50), current)
+	assert.Equal(t, uint16(100), max)
+
+	_, _, err = parseGetVcpReply(VcpContrast, reply)
+	assert.Error(t, err)
+
+	reply[10]++
+	_, _, err = parseGetVcpReply(VcpBrightness, reply)
+	assert.Error(t, err)
+
+	_, _, err = parseGetVcpReply(VcpBrightness, []byte{0x6e, 0x80, 0xbe})
+	assert.Equal(t, errDDCNullReply, err)
+}
+
+func Test_getConnectorNamesForOutput(t *testing.T) {
+	// modesetting
+	assert.Equal(t, []string{"HDMI-A-1", "HDMI-A-2"}, getConnectorNamesForOutput("HDMI-1"))
+	assert.Equal(t, []string{"eDP-1", "eDP-2"}, getConnectorNamesForOutput("eDP-1"))
+	assert.Equal(t, []string{"DVI-D-1", "DVI-D-2"}, getConnectorNamesForOutput("DVI-D-1"))
+	// intel
+	assert.Equal(t, []string{"DP-2", "DP-3"}, getConnectorNamesForOutput("DP2"))
+	// amdgpu
+	assert.Equal(t, []string{"DP-1", "DP-0"}, getConnectorNamesForOutput("DisplayPort-0"))
+	assert.Equal(t, []string{"HDMI-A-2", "HDMI-A-1"}, getConnectorNamesForOutput("HDMI-A-1"))
+	// DP MST
+	assert.Nil(t, getConnectorNamesForOutput("DP-1-1"))
+}
+
+func Test_findDRMConnector(t *testing.T) {
+	devPath, _ := setupFakeDDC(t, nil)
+	connector, err := findDRMConnector("HDMI-1", testEdid)
+	require.NoError(t, err)
+	assert.Equal(t, "card0-HDMI-A-1", connector)
+	path, err := findDDCBus(connector)
+	require.NoError(t, err)
+	assert.Equal(t, devPath, path)
+
+	// DP 连接器下的 i2c-N 目录
+	connectorDir := filepath.Join(sysDrmDir, "card0-DP-1")
+	otherEdid := append([]byte{}, testEdid...)
+	otherEdid[8] = 1
+	require.NoError(t, os.MkdirAll(filepath.Join(connectorDir, "i2c-7"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(connectorDir, "edid"), otherEdid, 0644))
+	connector, err = findDRMConnector("DP-1", otherEdid)
+	require.NoError(t, err)
+	assert.Equal(t, "card0-DP-1", connector)
+	path, err = findDDCBus(connector)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(devDir, "i2c-7"), path)
+
+	// 名称无法对应时用 EDID 查找
+	connector, err = findDRMConnector("DP-1-1", otherEdid)
+	require.NoError(t, err)
+	assert.Equal(t, "card0-DP-1", connector)
+
+	// 跳过没有连接显示器的连接器
+	connectorDir = filepath.Join(sysDrmDir, "card0-DP-2")
+	require.NoError(t, os.MkdirAll(filepath.Join(connectorDir, "i2c-8"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(connectorDir, "status"), []byte("disconnected\n"), 0644))
+	_, err = findDRMConnector("DP-2", nil)
+	assert.Error(t, err)
+
+	// 连接器上的显示器不是这个显示器
+	otherEdid[9] = 1
+	_, err = findDRMConnector("DP-1", otherEdid)
+	assert.Error(t, err)
+	_, err = findDRMConnector("DP-1-1", otherEdid)
+	assert.Error(t, err)
+}
+
+// 两个相同型号的显示器 EDID 相同，要按接口区分
+func TestDDCSameEdid(t *testing.T) {
+	devPath, writes := setupFakeDDC(t, map[byte]*fakeVcp{
+		VcpBrightness: {Current: 80, Max: 100},
+	})
+	connectorDir := filepath.Join(sysDrmDir, "card0-DP-1")
+	require.NoError(t, os.MkdirAll(filepath.Join(connectorDir, "i2c-7"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(connectorDir, "edid"), testEdid, 0644))
+	dpDevPath := filepath.Join(devDir, "i2c-7")
+	(&fakeI2cDevice{path: dpDevPath}).save(map[byte]*fakeVcp{
+		VcpBrightness: {Current: 40, Max: 100},
+	})
+
+	// 名称无法对应，EDID 匹配了两个连接器
+	_, err := findDRMConnector("DP-1-1", testEdid)
+	assert.Error(t, err)
+
+	value, err := GetDDCBrightness("HDMI-1", testEdid)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.8, value, 0.001)
+	value, err = GetDDCBrightness("DP-1", testEdid)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.4, value, 0.001)
+
+	require.NoError(t, setDDCBrightness(0.3, "DP-1", testEdid))
+	assert.Equal(t, uint16(80), (&fakeI2cDevice{path: devPath}).load()[VcpBrightness].Current)
+	assert.Equal(t, uint16(30), (&fakeI2cDevice{path: dpDevPath}).load()[VcpBrightness].Current)
+	assert.Equal(t, 3, *writes)
+}
+
+// 一个显示器检测很慢时，不影响其他显示器
+func TestDDCProbeNotBlocking(t *testing.T) {
+	_, _ = setupFakeDDC(t, map[byte]*fakeVcp{
+		VcpBrightness: {Current: 80, Max: 100},
+	})
+	connectorDir := filepath.Join(sysDrmDir, "card0-DP-1")
+	require.NoError(t, os.MkdirAll(filepath.Join(connectorDir, "i2c-7"), 0755))
+	otherEdid := append([]byte{}, testEdid...)
+	otherEdid[8] = 1
+	require.NoError(t, ioutil.WriteFile(filepath.Join(connectorDir, "edid"), otherEdid, 0644))
+	dpDevPath := filepath.Join(devDir, "i2c-7")
+	(&fakeI2cDevice{path: dpDevPath}).save(map[byte]*fakeVcp{
+		VcpBrightness: {Current: 40, Max: 100},
+	})
+
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	open := openI2cDevice
+	openI2cDevice = func(path string) (i2cDevice, error) {
+		if path == dpDevPath {
+			close(blocked)
+			<-release
+		}
+		return open(path)
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- supportDDC("DP-1", otherEdid)
+	}()
+	<-blocked
+	assert.True(t, supportDDC("HDMI-1", testEdid))
+	close(release)
+	assert.True(t, <-done)
+}
+
+func TestDDCBrightness(t *testing.T) {
+	devPath, writes := setupFakeDDC(t, map[byte]*fakeVcp{
+		VcpBrightness: {Current: 80, Max: 100},
+		VcpContrast:   {Current: 50, Max: 100},
+	})
+	assert.True(t, supportDDC(testOutput, testEdid))
+	// 检测时读了一次亮度
+	assert.Equal(t, 1, *writes)
+
+	value, err := GetDDCBrightness(testOutput, testEdid)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.8, value, 0.001)
+	assert.Equal(t, 1, *writes)
+
+	require.NoError(t, setDDCBrightness(0.3, testOutput, testEdid))
+	assert.Equal(t, 2, *writes)
+	vcps := (&fakeI2cDevice{path: devPath}).load()
+	assert.Equal(t, uint16(30), vcps[VcpBrightness].Current)
+
+	// 值没有变化时不写设备
+	require.NoError(t, setDDCBrightness(0.3, testOutput, testEdid))
+	assert.Equal(t, 2, *writes)
+
+	value, err = GetContrast(testOutput, testEdid)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, value, 0.001)
+	require.NoError(t, SetContrast(testOutput, testEdid, 0.75))
+	vcps = (&fakeI2cDevice{path: devPath}).load()
+	assert.Equal(t, uint16(75), vcps[VcpContrast].Current)
+}
+
+func TestDDCUnsupported(t *testing.T) {
+	_, writes := setupFakeDDC(t, map[byte]*fakeVcp{})
+	assert.False(t, supportDDC(testOutput, testEdid))
+	n := *writes
+	assert.NotZero(t, n)
+
+	// 不支持的结果被缓存，不会再访问设备
+	assert.False(t, supportDDC(testOutput, testEdid))
+	assert.Equal(t, n, *writes)
+	assert.Error(t, setDDCBrightness(0.5, testOutput, testEdid))
+	assert.Equal(t, n, *writes)
+}
@@ -4,7 +4,13 @@
 
 package brightness
 
-import "testing"
+import (
+	"math"
+	"testing"
+
+	"github.com/linuxdeepin/startdde/display/icc"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestFillColorRamp(t *testing.T) {
 	const size = 1024
@@ -24,3 +30,29 @@ func TestFillColorRamp(t *testing.T) {
 		}
 	}
 }
+
+func TestGenColorRamp(t *testing.T) {
+	const size = 256
+	r, g, b := genColorRamp(size, gammaSetting{
+		brightness:  1,
+		temperature: 6500,
+	})
+	r0, _, _ := initGammaRamp(size)
+	assert.Equal(t, r0, r)
+	assert.Equal(t, r0, g)
+	assert.Equal(t, r0, b)
+
+	calibration := &icc.VCGT{Channels: [3][]float64{
+		{0, 0.5},
+		{0, 1},
+		{1, 1},
+	}}
+	r, g, b = genColorRamp(size, gammaSetting{
+		brightness:  0.5,
+		temperature: 6500,
+		calibration: calibration,
+	})
+	assert.InDelta(t, float64(r0[128])/4, float64(r[128]), 2)
+	assert.InDelta(t, float64(r0[128])/2, float64(g[128]), 2)
+	assert.InDelta(t, math.MaxUint16/2, float64(b[0]), 2)
+}
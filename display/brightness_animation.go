@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/startdde/display/brightness"
+)
+
+// 亮度动画：设置亮度时在一段时间内逐步改变背光或者 gamma 的值，避免画面突然变亮或变暗。
+// 每一步都用当前的色温值重新设置 gamma，所以动画过程中色温改变也会一起生效。
+
+const (
+	brightnessAnimationInterval = 16 * time.Millisecond
+	// 背光要通过 DBus 调用 helper 设置，DDC/CI 写一次要几十毫秒，动画用更长的间隔和更少的步数
+	brightnessSlowAnimationInterval = 200 * time.Millisecond
+
+	maxBrightnessAnimationDuration = 2 * time.Second
+)
+
+type brightnessRamp struct {
+	target float64
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+// brightnessAnimator 管理各个显示器的亮度动画，键是显示器名称。
+type brightnessAnimator struct {
+	mu       sync.Mutex
+	duration time.Duration
+	ramps    map[string]*brightnessRamp
+	// 最后一次实际设置的亮度
+	current map[string]float64
+	// apply 实际设置显示器的亮度
+	apply func(name string, value float64) error
+	// interval 返回显示器动画每一步的间隔
+	interval func(name string) time.Duration
+}
+
+func newBrightnessAnimator(duration time.Duration, apply func(name string, value float64) error,
+	interval func(name string) time.Duration) *brightnessAnimator {
+	a := &brightnessAnimator{
+		ramps:    make(map[string]*brightnessRamp),
+		current:  make(map[string]float64),
+		apply:    apply,
+		interval: interval,
+	}
+	a.setDuration(duration)
+	return a
+}
+
+func (a *brightnessAnimator) setDuration(duration time.Duration) {
+	if duration < 0 {
+		duration = 0
+	} else if duration > maxBrightnessAnimationDuration {
+		duration = maxBrightnessAnimationDuration
+	}
+	a.mu.Lock()
+	a.duration = duration
+	a.mu.Unlock()
+}
+
+// calcBrightnessRamp 计算动画每一步的亮度值，最后一个是 to。
+func calcBrightnessRamp(from, to float64, duration, interval time.Duration) []float64 {
+	steps := int(duration / interval)
+	if steps < 1 {
+		steps = 1
+	}
+	values := make([]float64, steps)
+	for i := 1; i <= steps; i++ {
+		values[i-1] = from + (to-from)*float64(i)/float64(steps)
+	}
+	values[steps-1] = to
+	return values
+}
+
+// stopRamp 取消正在进行的动画，并等待它结束，调用时需要持有 a.mu。
+func (a *brightnessAnimator) stopRamp(name string) {
+	ramp := a.ramps[name]
+	if ramp == nil {
+		return
+	}
+	delete(a.ramps, name)
+	close(ramp.cancel)
+	a.mu.Unlock()
+	<-ramp.done
+	a.mu.Lock()
+}
+
+// set 把显示器的亮度设置为 value，instant 为 true 时不使用动画。
+// 同一个显示器有新的请求时，正在进行的动画会被取消，新的动画从当前的亮度开始。
+func (a *brightnessAnimator) set(name string, value float64, instant bool) error {
+	// 可能要检测显示器是否支持 DDC/CI，不能持有 a.mu
+	interval := a.interval(name)
+	a.mu.Lock()
+	if ramp := a.ramps[name]; ramp != nil && ramp.target == value && !instant {
+		// 动画的目标相同，比如色温改变，下一步会用新的色温
+		a.mu.Unlock()
+		return nil
+	}
+	a.stopRamp(name)
+
+	from, known := a.current[name]
+	if instant || a.duration <= 0 || !known || from == value {
+		a.mu.Unlock()
+		return a.applyValue(name, value)
+	}
+
+	values := calcBrightnessRamp(from, value, a.duration, interval)
+	ramp := &brightnessRamp{
+		target: value,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	a.ramps[name] = ramp
+	a.mu.Unlock()
+
+	// 第一步同步设置，设置失败时直接返回错误
+	err := a.applyValue(name, values[0])
+	if err != nil {
+		a.mu.Lock()
+		if a.ramps[name] == ramp {
+			delete(a.ramps, name)
+		}
+		a.mu.Unlock()
+		close(ramp.done)
+		return err
+	}
+	go a.runRamp(name, ramp, values[1:], interval)
+	return nil
+}
+
+func (a *brightnessAnimator) runRamp(name string, ramp *brightnessRamp, values []float64, interval time.Duration) {
+	defer close(ramp.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for _, value := range values {
+		select {
+		case <-ramp.cancel:
+			return
+		case <-ticker.C:
+		}
+		err := a.applyValue(name, value)
+		if err != nil {
+			logger.Warningf("failed to set brightness for %s: %v", name, err)
+		}
+	}
+
+	a.mu.Lock()
+	if a.ramps[name] == ramp {
+		delete(a.ramps, name)
+	}
+	a.mu.Unlock()
+}
+
+func (a *brightnessAnimator) applyValue(name string, value float64) error {
+	err := a.apply(name, value)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.current[name] = value
+	a.mu.Unlock()
+	return nil
+}
+
+// applyMonitorBrightness 用当前的色温值设置显示器的亮度，是亮度动画的每一步。
+func (m *Manager) applyMonitorBrightness(name string, value float64) error {
+	monitor := m.getConnectedMonitors().GetByName(name)
+	if monitor == nil {
+		return InvalidOutputNameError{Name: name}
+	}
+	return m.setMonitorBrightness(monitor, value)
+}
+
+// getBrightnessAnimationInterval 只用 gamma 设置亮度时动画每一步的间隔很短，用背光或者 DDC/CI 时间隔比较长。
+func (m *Manager) getBrightnessAnimationInterval(name string) time.Duration {
+	monitor := m.getConnectedMonitors().GetByName(name)
+	if monitor == nil {
+		return brightnessAnimationInterval
+	}
+	monitor.PropsMu.RLock()
+	edid := monitor.edid
+	monitor.PropsMu.RUnlock()
+	if brightness.IsGammaOnly(m.getBrightnessSetter(), m.isBuiltinMonitor(name), name, edid) {
+		return brightnessAnimationInterval
+	}
+	return brightnessSlowAnimationInterval
+}
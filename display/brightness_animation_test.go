@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type brightnessRecorder struct {
+	mu     sync.Mutex
+	values []float64
+}
+
+func (r *brightnessRecorder) apply(name string, value float64) error {
+	r.mu.Lock()
+	r.values = append(r.values, value)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *brightnessRecorder) reset() {
+	r.mu.Lock()
+	r.values = nil
+	r.mu.Unlock()
+}
+
+func (r *brightnessRecorder) get() []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.values...)
+}
+
+func Test_calcBrightnessRamp(t *testing.T) {
+	values := calcBrightnessRamp(0.2, 1, 4*brightnessAnimationInterval, brightnessAnimationInterval)
+	require.Len(t, values, 4)
+	assert.InDelta(t, 0.4, values[0], 0.0001)
+	assert.InDelta(t, 0.6, values[1], 0.0001)
+	assert.InDelta(t, 0.8, values[2], 0.0001)
+	assert.Equal(t, 1.0, values[3])
+
+	assert.Equal(t, []float64{0.5}, calcBrightnessRamp(1, 0.5, 0, brightnessAnimationInterval))
+}
+
+func TestBrightnessAnimator(t *testing.T) {
+	r := &brightnessRecorder{}
+	a := newBrightnessAnimator(5*brightnessAnimationInterval, r.apply, func(string) time.Duration {
+		return brightnessAnimationInterval
+	})
+
+	// 不知道当前亮度时直接设置
+	require.NoError(t, a.set("HDMI-1", 0.5, false))
+	assert.Equal(t, []float64{0.5}, r.get())
+
+	require.NoError(t, a.set("HDMI-1", 1, false))
+	assert.Eventually(t, func() bool {
+		values := r.get()
+		return values[len(values)-1] == 1
+	}, time.Second, brightnessAnimationInterval)
+	values := r.get()
+	assert.Len(t, values, 6)
+	for i := 1; i < len(values); i++ {
+		assert.Greater(t, values[i], values[i-1])
+	}
+
+	// instant 取消正在进行的动画
+	r.reset()
+	require.NoError(t, a.set("HDMI-1", 0.2, false))
+	require.NoError(t, a.set("HDMI-1", 0.7, true))
+	time.Sleep(8 * brightnessAnimationInterval)
+	values = r.get()
+	assert.Equal(t, 0.7, values[len(values)-1])
+	assert.Len(t, values, 2)
+
+	// 关闭动画
+	r.reset()
+	a.setDuration(0)
+	require.NoError(t, a.set("HDMI-1", 0.3, false))
+	assert.Equal(t, []float64{0.3}, r.get())
+}
+
+func TestBrightnessAnimator_slowInterval(t *testing.T) {
+	r := &brightnessRecorder{}
+	// DP-1 用 DDC/CI 设置亮度
+	a := newBrightnessAnimator(2*brightnessSlowAnimationInterval, r.apply, func(name string) time.Duration {
+		if name == "DP-1" {
+			return brightnessSlowAnimationInterval
+		}
+		return brightnessAnimationInterval
+	})
+	require.NoError(t, a.set("DP-1", 0.5, true))
+	require.NoError(t, a.set("DP-1", 1, false))
+	assert.Eventually(t, func() bool {
+		values := r.get()
+		return values[len(values)-1] == 1
+	}, 2*time.Second, brightnessAnimationInterval)
+	assert.Equal(t, []float64{0.5, 0.75, 1}, r.get())
+}
@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	signalChangesConfirmStarted = "ChangesConfirmStarted"
+	signalChangesConfirmed      = "ChangesConfirmed"
+	signalChangesReverted       = "ChangesReverted"
+
+	maxChangesConfirmTimeout = 600
+)
+
+// changesConfirm 记录等待用户确认的修改，超时未确认则恢复到 configs。
+type changesConfirm struct {
+	monitorsId monitorsId
+	configs    SysMonitorConfigs
+	timer      nightLightTimer
+}
+
+// changesConfirmer 管理等待确认的修改，同一时间最多有一个。
+type changesConfirmer struct {
+	mu      sync.Mutex
+	clock   nightLightClock
+	pending *changesConfirm
+	// 超时未确认时调用，恢复 cc 中的配置
+	revert func(cc *changesConfirm)
+}
+
+func newChangesConfirmer(clock nightLightClock, revert func(cc *changesConfirm)) *changesConfirmer {
+	return &changesConfirmer{
+		clock:  clock,
+		revert: revert,
+	}
+}
+
+// start 开始等待确认。显示器没有变化时多次调用只重新计时，保留第一次调用前的配置。
+func (c *changesConfirmer) start(monitorsId monitorsId, prevConfigs SysMonitorConfigs, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending != nil {
+		c.pending.timer.Stop()
+		if c.pending.monitorsId == monitorsId {
+			prevConfigs = c.pending.configs
+		}
+	}
+	cc := &changesConfirm{
+		monitorsId: monitorsId,
+		configs:    prevConfigs,
+	}
+	cc.timer = c.clock.AfterFunc(timeout, func() {
+		c.handleTimeout(cc)
+	})
+	c.pending = cc
+}
+
+func (c *changesConfirmer) handleTimeout(cc *changesConfirm) {
+	c.mu.Lock()
+	if c.pending != cc {
+		// 已经确认、恢复或重新计时
+		c.mu.Unlock()
+		return
+	}
+	c.pending = nil
+	c.mu.Unlock()
+
+	logger.Debug("confirm changes timeout, revert changes")
+	c.revert(cc)
+}
+
+// take 取出等待确认的修改并停止计时，没有时返回 nil。
+func (c *changesConfirmer) take() *changesConfirm {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cc := c.pending
+	c.pending = nil
+	if cc != nil {
+		cc.timer.Stop()
+	}
+	return cc
+}
+
+func (c *changesConfirmer) has() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending != nil
+}
+
+// applyChangesWithTimeout 应用修改，并在 seconds 秒内等待确认，超时未确认则恢复之前的配置。
+func (m *Manager) applyChangesWithTimeout(seconds int32) error {
+	if seconds <= 0 || seconds > maxChangesConfirmTimeout {
+		return fmt.Errorf("invalid timeout %d, the range is 1-%d", seconds, maxChangesConfirmTimeout)
+	}
+
+	m.PropsMu.RLock()
+	hasChanged := m.HasChanged
+	displayMode := m.DisplayMode
+	m.PropsMu.RUnlock()
+	if !hasChanged {
+		return errors.New("no changes to apply")
+	}
+
+	monitors := m.getConnectedMonitors()
+	monitorsId := monitors.getMonitorsId()
+	// 修改之前的配置，多次调用时保留第一次调用前的配置
+	prevConfigs := m.getSuitableSysMonitorConfigs(displayMode, monitorsId, monitors)
+	if len(prevConfigs) == 0 {
+		return errors.New("not found previous configs")
+	}
+
+	applied, err := m.applyChanges()
+	if err != nil {
+		return err
+	}
+	if !applied {
+		// 正在应用其他配置，修改没有被应用，不需要确认
+		return errors.New("changes not applied")
+	}
+
+	timeout := time.Duration(seconds) * time.Second
+	m.changesConfirmer.start(monitorsId, prevConfigs, timeout)
+
+	logger.Debugf("wait %v for confirming changes", timeout)
+	err = m.service.Emit(m, signalChangesConfirmStarted, seconds)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return nil
+}
+
+func (m *Manager) takeChangesConfirm() *changesConfirm {
+	return m.changesConfirmer.take()
+}
+
+func (m *Manager) hasChangesConfirm() bool {
+	return m.changesConfirmer.has()
+}
+
+// confirmChanges 确认修改并保存配置
+func (m *Manager) confirmChanges() error {
+	cc := m.takeChangesConfirm()
+	if cc == nil {
+		return errors.New("no changes waiting for confirmation")
+	}
+
+	err := m.save()
+	if err != nil {
+		logger.Warning("save changes failed:", err)
+		m.restoreChangesConfirm(cc)
+		return err
+	}
+
+	err = m.service.Emit(m, signalChangesConfirmed)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return nil
+}
+
+// revertChanges 恢复到等待确认之前的配置
+func (m *Manager) revertChanges() {
+	cc := m.takeChangesConfirm()
+	if cc == nil {
+		return
+	}
+	m.restoreChangesConfirm(cc)
+}
+
+func (m *Manager) restoreChangesConfirm(cc *changesConfirm) {
+	monitorMap := m.cloneMonitorMap()
+	monitorsId := getConnectedMonitors(monitorMap).getMonitorsId()
+	if monitorsId == cc.monitorsId {
+		// 与其他应用配置的操作互斥
+		m.applySaveMu.Lock()
+		err := m.applySysMonitorConfigs(DisplayModeInvalid, monitorsId, monitorMap, cc.configs, nil)
+		m.applySaveMu.Unlock()
+		if err != nil {
+			logger.Warning("revert changes failed:", err)
+		}
+	}
+	m.markClean()
+
+	err := m.service.Emit(m, signalChangesReverted)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// dropChangesConfirm 显示器插拔时丢弃等待确认的修改，此时会应用新的显示配置，无需再恢复。
+func (m *Manager) dropChangesConfirm() {
+	cc := m.takeChangesConfirm()
+	if cc == nil {
+		return
+	}
+	err := m.service.Emit(m, signalChangesReverted)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
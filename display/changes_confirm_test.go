@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestChangesConfirmer() (*changesConfirmer, *fakeClock, *[]*changesConfirm) {
+	clock := &fakeClock{now: time.Date(2023, 3, 1, 10, 0, 0, 0, zoneCST)}
+	var reverted []*changesConfirm
+	c := newChangesConfirmer(clock, func(cc *changesConfirm) {
+		reverted = append(reverted, cc)
+	})
+	return c, clock, &reverted
+}
+
+func TestChangesConfirmer_confirm(t *testing.T) {
+	c, clock, reverted := newTestChangesConfirmer()
+	monitorsId := monitorsId{v1: "a;b"}
+	c.start(monitorsId, SysMonitorConfigs{{UUID: "a", X: 0}}, 15*time.Second)
+	assert.True(t, c.has())
+
+	// 确认之后不再恢复
+	cc := c.take()
+	require.NotNil(t, cc)
+	assert.Equal(t, "a", cc.configs[0].UUID)
+	assert.False(t, c.has())
+	assert.Nil(t, c.take())
+	clock.Advance(time.Minute)
+	assert.Empty(t, *reverted)
+}
+
+func TestChangesConfirmer_revertOnTimeout(t *testing.T) {
+	c, clock, reverted := newTestChangesConfirmer()
+	monitorsId := monitorsId{v1: "a;b"}
+	c.start(monitorsId, SysMonitorConfigs{{UUID: "a", X: 0}}, 15*time.Second)
+
+	clock.Advance(14 * time.Second)
+	assert.Empty(t, *reverted)
+	clock.Advance(time.Second)
+	require.Len(t, *reverted, 1)
+	assert.Equal(t, monitorsId, (*reverted)[0].monitorsId)
+	assert.False(t, c.has())
+
+	// 只恢复一次
+	clock.Advance(time.Minute)
+	assert.Len(t, *reverted, 1)
+}
+
+func TestChangesConfirmer_revertOnSecondApply(t *testing.T) {
+	c, clock, reverted := newTestChangesConfirmer()
+	monitorsId := monitorsId{v1: "a;b"}
+	c.start(monitorsId, SysMonitorConfigs{{UUID: "a", X: 0}}, 15*time.Second)
+
+	// 等待确认时再次应用，重新计时，恢复到第一次应用前的配置
+	clock.Advance(10 * time.Second)
+	c.start(monitorsId, SysMonitorConfigs{{UUID: "a", X: 1920}}, 15*time.Second)
+	clock.Advance(10 * time.Second)
+	assert.Empty(t, *reverted)
+	clock.Advance(5 * time.Second)
+	require.Len(t, *reverted, 1)
+	assert.Equal(t, int16(0), (*reverted)[0].configs[0].X)
+
+	// 显示器变化后再次应用，恢复到这次应用前的配置
+	otherId := monitorsId
+	otherId.v1 = "a;c"
+	c.start(monitorsId, SysMonitorConfigs{{UUID: "a", X: 0}}, 15*time.Second)
+	c.start(otherId, SysMonitorConfigs{{UUID: "c", X: 0}}, 15*time.Second)
+	clock.Advance(15 * time.Second)
+	require.Len(t, *reverted, 2)
+	assert.Equal(t, otherId, (*reverted)[1].monitorsId)
+	assert.Equal(t, "c", (*reverted)[1].configs[0].UUID)
+}
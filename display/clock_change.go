@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"math"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchClockChange 在系统时间被修改时调用 cb。time 包的定时器用的是单调时钟，
+// 修改系统时间后按照墙上时间计算的定时器不会跟着改变，需要重新计算。
+func watchClockChange(cb func()) error {
+	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, unix.TFD_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	// 设置一个不会到期的定时器，系统时间被修改时 read 返回 ECANCELED
+	spec := &unix.ItimerSpec{Value: unix.Timespec{Sec: math.MaxInt32}}
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, 8)
+		for {
+			err := unix.TimerfdSettime(fd, unix.TFD_TIMER_ABSTIME|unix.TFD_TIMER_CANCEL_ON_SET, spec, nil)
+			if err != nil {
+				logger.Warning("failed to set timerfd:", err)
+				return
+			}
+			_, err = unix.Read(fd, buf)
+			switch err {
+			case unix.ECANCELED:
+				logger.Info("system clock changed")
+				cb()
+			case nil, unix.EINTR:
+			default:
+				logger.Warning("failed to read timerfd:", err)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// handleClockChanged 从待机唤醒或者修改系统时间后，重新计算色温和暂停调节色温的到期时间
+func (m *Manager) handleClockChanged() {
+	m.nightLight.update()
+	m.scheduleLight.update()
+	m.colorTempInhibitors.recheck()
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/linuxdeepin/startdde/display/icc"
+)
+
+// 显示器的 ICC 色彩配置文件，按显示器的 uuid 保存在 SysConfig.ColorProfiles 中。
+// 配置文件中 vcgt 标签的校准曲线和亮度、色温一起设置到 crtc 的 gamma 表中。
+
+// loadColorProfile 读取 ICC 文件中的校准曲线，文件中没有 vcgt 标签时返回 nil。
+func loadColorProfile(filename string) (*icc.VCGT, error) {
+	profile, err := icc.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	if profile.VCGT == nil {
+		logger.Debugf("icc profile %s (%s) has no vcgt tag", filename, profile.Description)
+	}
+	return profile.VCGT, nil
+}
+
+func (cfg *SysConfig) getColorProfile(uuid string) string {
+	return cfg.ColorProfiles[uuid]
+}
+
+// fixColorProfiles 删除不是绝对路径的 ICC 文件，与 setMonitorColorProfile 的检查相同。
+func (cfg *SysConfig) fixColorProfiles() {
+	for uuid, filename := range cfg.ColorProfiles {
+		if !filepath.IsAbs(filename) {
+			logger.Warningf("invalid icc profile %q of %s", filename, uuid)
+			delete(cfg.ColorProfiles, uuid)
+		}
+	}
+}
+
+// setColorProfile 设置显示器 uuid 的 ICC 文件，filename 为空时删除，返回配置是否改变。
+func (cfg *SysConfig) setColorProfile(uuid, filename string) bool {
+	if cfg.ColorProfiles[uuid] == filename {
+		return false
+	}
+	if filename == "" {
+		delete(cfg.ColorProfiles, uuid)
+		return true
+	}
+	if cfg.ColorProfiles == nil {
+		cfg.ColorProfiles = make(map[string]string)
+	}
+	cfg.ColorProfiles[uuid] = filename
+	return true
+}
+
+func (m *Manager) setMonitorColorProfile(monitor *Monitor, filename string) error {
+	var calibration *icc.VCGT
+	if filename != "" {
+		if !filepath.IsAbs(filename) {
+			return errors.New("the path of icc profile must be absolute")
+		}
+		var err error
+		calibration, err = loadColorProfile(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	monitor.PropsMu.Lock()
+	uuid := monitor.uuid
+	monitor.calibration = calibration
+	monitor.setPropColorProfile(filename)
+	brightnessValue := monitor.Brightness
+	enabled := monitor.Enabled
+	monitor.PropsMu.Unlock()
+
+	m.sysConfig.mu.Lock()
+	var err error
+	if m.sysConfig.Config.setColorProfile(uuid, filename) {
+		err = m.saveSysConfigNoLock("set color profile")
+	}
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	if enabled {
+		// 重新设置 gamma
+		err = m.applyMonitorBrightness(monitor.Name, brightnessValue)
+	}
+	return err
+}
+
+// restoreMonitorColorProfile 显示器连接或者 uuid 改变时，从配置中加载 uuid 对应的 ICC 文件。
+// 加载失败时仍然把 ColorProfile 设置为配置中的路径，只是没有校准曲线，这样不会在每次更新显示器时重试，
+// 需要重新调用 SetColorProfile 加载。
+func (m *Manager) restoreMonitorColorProfile(monitor *Monitor, uuid string) {
+	m.sysConfig.mu.Lock()
+	filename := m.sysConfig.Config.getColorProfile(uuid)
+	m.sysConfig.mu.Unlock()
+
+	monitor.PropsMu.RLock()
+	current := monitor.ColorProfile
+	monitor.PropsMu.RUnlock()
+	if filename == current {
+		return
+	}
+
+	var calibration *icc.VCGT
+	if filename != "" {
+		var err error
+		calibration, err = loadColorProfile(filename)
+		if err != nil {
+			logger.Warningf("failed to load icc profile %s for %v: %v", filename, monitor, err)
+		}
+	}
+
+	monitor.PropsMu.Lock()
+	monitor.calibration = calibration
+	monitor.setPropColorProfile(filename)
+	monitor.PropsMu.Unlock()
+}
@@ -5,17 +5,14 @@
 package display
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"io/ioutil"
 	"math"
-	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/godbus/dbus/v5"
 	geoclue2 "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.geoclue2"
@@ -29,20 +26,25 @@ const (
 	ColorTemperatureModeAuto
 	// ColorTemperatureModeManual 手动调整色温
 	ColorTemperatureModeManual
+	// ColorTemperatureModeSchedule 在设定的时间段内调整色温
+	ColorTemperatureModeSchedule
 )
 
+// ColorTemperatureModeGlobal 显示器跟随 Manager 的色温设置，只用于 Monitor 的色温模式
+const ColorTemperatureModeGlobal int32 = -1
+
 const (
 	timeZoneFile = "/usr/share/zoneinfo/zone1970.tab"
 )
 
 func isValidColorTempMode(mode int32) bool {
-	return mode >= ColorTemperatureModeNone && mode <= ColorTemperatureModeManual
+	return mode >= ColorTemperatureModeNone && mode <= ColorTemperatureModeSchedule
 }
 
 // dbus 上导出的方法
 func (m *Manager) setColorTempMode(mode int32) error {
 	if !isValidColorTempMode(mode) {
-		return errors.New("mode out of range, not 0 or 1 or 2")
+		return errors.New("mode out of range, not 0 or 1 or 2 or 3")
 	}
 	m.setPropColorTemperatureMode(mode)
 	m.setPropColorTemperatureEnabled(mode != 0)
@@ -55,23 +57,40 @@ func (m *Manager) setColorTempModeReal(mode int32) {
 	if _greeterMode {
 		return
 	}
-	switch mode {
-	case ColorTemperatureModeAuto: // 自动模式调节色温 启动服务
-		m.redshiftRunner.start()
-
-	case ColorTemperatureModeManual, ColorTemperatureModeNone:
-		// manual 手动调节色温
-		// none 恢复正常色温
-		m.redshiftRunner.stop()
-	}
-	// 对于自动模式，也要先把色温设置为正常。
+	m.updateColorTempEngines(mode)
 	m.setColorTempOneShot()
 }
 
-const (
-	redshiftStateRunning = iota + 1
-	redshiftStateStopped
-)
+// updateColorTempEngines 根据全局色温模式 globalMode 和各显示器的色温模式启动或停止自动模式和定时模式的计算。
+func (m *Manager) updateColorTempEngines(globalMode int32) {
+	needAuto := globalMode == ColorTemperatureModeAuto
+	needSchedule := globalMode == ColorTemperatureModeSchedule
+	m.userCfgMu.Lock()
+	for _, cfg := range m.userConfig.ColorTemperatures {
+		switch cfg.Mode {
+		case ColorTemperatureModeAuto:
+			needAuto = true
+		case ColorTemperatureModeSchedule:
+			needSchedule = true
+		}
+	}
+	m.userCfgMu.Unlock()
+
+	// 自动模式根据日出日落调节色温
+	if needAuto {
+		m.nightLight.start()
+	} else {
+		m.nightLight.stop()
+	}
+	m.updateGeoClueLocator()
+	// 定时模式在设定的时间段内调节色温
+	if needSchedule {
+		m.scheduleLight.setSchedule(m.getColorTempScheduleConfig().toSchedule())
+		m.scheduleLight.start()
+	} else {
+		m.scheduleLight.stop()
+	}
+}
 
 type zoneInfo struct {
 	country   string
@@ -80,19 +99,6 @@ type zoneInfo struct {
 	distance  float64
 }
 
-type redshiftRunner struct {
-	mu                 sync.Mutex
-	state              int
-	timer              *time.Timer
-	process            *os.Process
-	value              int
-	cb                 func(value int)
-	sysService         *dbusutil.Service
-	geoAgentRegistered bool
-
-	zoneInfoMap map[string]*zoneInfo
-}
-
 func convertPos(pos string, digits int32) float64 {
 	if len(pos) < 4 || digits > 9 {
 		return 0.0
@@ -109,13 +115,10 @@ func convertPos(pos string, digits int32) float64 {
 	}
 }
 
-func newRedshiftRunner() *redshiftRunner {
-	sysService, err := dbusutil.NewSystemService()
-	if err != nil {
-		logger.Warning("new sys service failed:", err)
-	}
+// loadZoneInfoMap 读取时区文件中各个时区的经纬度，键是时区名称
+func loadZoneInfoMap(filename string) map[string]*zoneInfo {
 	zoneInfoMap := make(map[string]*zoneInfo)
-	contents, err := ioutil.ReadFile(timeZoneFile)
+	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
 		logger.Warning("Red timezone file failed:", err)
 	}
@@ -142,79 +145,7 @@ func newRedshiftRunner() *redshiftRunner {
 			}
 		}
 	}
-	return &redshiftRunner{
-		sysService:  sysService,
-		zoneInfoMap: zoneInfoMap,
-	}
-}
-
-func (r *redshiftRunner) start() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	logger.Debugf("redshiftRunner.start")
-
-	if r.state == redshiftStateRunning {
-		return
-	}
-	r.state = redshiftStateRunning
-
-	latitude := r.zoneInfoMap[_timeZone].latitude
-	longitude := r.zoneInfoMap[_timeZone].longitude
-	geographicalPosition := strconv.FormatFloat(latitude, 'f', -1, 64) + ":" + strconv.FormatFloat(longitude, 'f', -1, 64)
-	logger.Info("Get geographicalPosition:", geographicalPosition)
-	cmd := exec.Command("redshift", "-m", "dummy", "-t", "6500:3500", "-r")
-	if geographicalPosition != "" {
-		cmd.Args = append(cmd.Args, "-l", geographicalPosition)
-	}
-	cmd.Env = append(os.Environ(), "LC_ALL=C")
-	var errBuf bytes.Buffer
-	cmd.Stderr = &errBuf
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		logger.Warning("get stdout pipe failed:", err)
-		r.state = redshiftStateStopped
-		return
-	}
-	err = cmd.Start()
-	if err != nil {
-		logger.Warning("start redshift failed:", err)
-		r.state = redshiftStateStopped
-		return
-	}
-
-	r.process = cmd.Process
-	r.value = 0
-
-	go func() {
-		logger.Debugf("redshift is running, pid: %v", r.process.Pid)
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			logger.Debugf("line: %s", line)
-			temp, ok := getTemperatureWithLine(line)
-			if ok {
-				logger.Debug("temp:", temp)
-				r.updateValue(temp)
-			}
-		}
-		err := scanner.Err()
-		if err != nil {
-			logger.Warning("scanner err:", err)
-		}
-
-		err = cmd.Wait()
-		if err != nil {
-			logger.Debugf("redshift cmd wait err: %v, errBuf: %s", err, errBuf.Bytes())
-		}
-		logger.Debug("redshift stopped")
-
-		r.mu.Lock()
-		r.state = redshiftStateStopped
-		r.process = nil
-		r.mu.Unlock()
-	}()
-
-	return
+	return zoneInfoMap
 }
 
 func (m *Manager) listenTimezone() {
@@ -254,67 +185,12 @@ func (m *Manager) listenTimezone() {
 				timezone, _ := v.Value().(string)
 				logger.Info("Timezone change to", timezone)
 				_timeZone = timezone
-				if m.redshiftRunner.state == redshiftStateRunning {
-					logger.Info("Redshift is Running")
-					m.redshiftRunner.stop()
-					time.AfterFunc(50*time.Millisecond, func() {
-						m.redshiftRunner.start()
-					})
-				}
+				m.updateTimezoneLocation()
 			}
 		}
 	}
 }
 
-func (r *redshiftRunner) stop() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.state == redshiftStateStopped {
-		return
-	}
-
-	logger.Debug("redshiftRunner.stop")
-	if r.process != nil {
-		err := r.process.Signal(os.Interrupt)
-		if err != nil {
-			logger.Warning("send signal interrupt to redshift process failed:", err)
-		}
-	}
-	r.value = 0
-}
-
-func (r *redshiftRunner) updateValue(value int) {
-	r.mu.Lock()
-	if r.value == value {
-		// no change
-		r.mu.Unlock()
-		return
-	}
-	r.value = value
-	r.mu.Unlock()
-
-	if r.cb != nil {
-		r.cb(value)
-	}
-}
-
-func (r *redshiftRunner) getValue() int {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	return r.value
-}
-
-func getTemperatureWithLine(line []byte) (int, bool) {
-	const prefix = "Temperature: "
-	if bytes.HasPrefix(line, []byte(prefix)) {
-		numStr := string(line[len(prefix):])
-		num, err := strconv.Atoi(numStr)
-		return num, err == nil
-	}
-	return 0, false
-}
-
 // dbus 上导出的方法
 func (m *Manager) setColorTempValue(value int32) error {
 	if m.ColorTemperatureMode != ColorTemperatureModeManual {
@@ -360,19 +236,26 @@ func (m *Manager) saveColorTempModeInCfg(mode int32) {
 	}
 }
 
+// getColorTemperatureValue 获取全局的色温值，用于跟随全局设置的显示器。
 func (m *Manager) getColorTemperatureValue() int {
 	m.PropsMu.RLock()
 	mode := m.ColorTemperatureMode
 	manual := m.ColorTemperatureManual
 	m.PropsMu.RUnlock()
 
+	return m.calcColorTemperatureValue(mode, manual)
+}
+
+func (m *Manager) calcColorTemperatureValue(mode, manual int32) int {
 	switch mode {
 	case ColorTemperatureModeNone:
 		return defaultTemperatureManual
 	case ColorTemperatureModeManual:
 		return int(manual)
 	case ColorTemperatureModeAuto:
-		return m.redshiftRunner.getValue()
+		return m.nightLight.getValue()
+	case ColorTemperatureModeSchedule:
+		return m.scheduleLight.getValue()
 	}
 
 	return defaultTemperatureManual
@@ -459,19 +342,16 @@ func (a *geoClueAgent) GetExportedMethods() dbusutil.ExportedMethods {
 	}
 }
 
-func (r *redshiftRunner) registerGeoClueAgent() error {
-	if r.geoAgentRegistered {
-		return nil
-	}
-	if r.sysService == nil {
+func registerGeoClueAgent(sysService *dbusutil.Service) error {
+	if sysService == nil {
 		return errors.New("sys service is nil")
 	}
 
-	sysBus := r.sysService.Conn()
+	sysBus := sysService.Conn()
 	agent := &geoClueAgent{
 		MaxAccuracyLevel: AccuracyLevelStreet,
 	}
-	err := r.sysService.Export(dbusPathGeoClueAgent, agent)
+	err := sysService.Export(dbusPathGeoClueAgent, agent)
 	if err != nil {
 		return err
 	}
@@ -479,9 +359,8 @@ func (r *redshiftRunner) registerGeoClueAgent() error {
 	geoManager := geoclue2.NewManager(sysBus)
 	err = geoManager.AddAgent(0, "geoclue-demo-agent")
 	if err != nil {
+		_ = sysService.StopExport(agent)
 		return err
 	}
-
-	r.geoAgentRegistered = true
 	return nil
 }
@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	sessiondbus "github.com/linuxdeepin/go-dbus-factory/session/org.freedesktop.dbus"
+)
+
+// 暂停调节色温：看图、看视频之类的应用运行时需要准确的颜色，可以临时暂停调节色温，
+// 不会修改保存的色温模式。应用退出或崩溃后由 NameOwnerChanged 信号自动取消。
+
+// ColorTemperatureInhibitor 暂停调节色温的请求
+type ColorTemperatureInhibitor struct {
+	Cookie uint32
+	// 请求者的 DBus 唯一名称，为空表示不随请求者退出而取消
+	Sender string
+	Reason string
+	// 自动取消的时间，unix 时间戳，为 0 表示不自动取消
+	Until int64
+}
+
+type colorTempInhibitors struct {
+	mu         sync.Mutex
+	clock      nightLightClock
+	nextCookie uint32
+	items      []ColorTemperatureInhibitor
+	timers     map[uint32]nightLightTimer
+	// 请求列表改变时调用
+	cb func(items []ColorTemperatureInhibitor)
+}
+
+func newColorTempInhibitors(clock nightLightClock) *colorTempInhibitors {
+	return &colorTempInhibitors{
+		clock:  clock,
+		timers: make(map[uint32]nightLightTimer),
+	}
+}
+
+// nextNoon 返回 t 之后的第一个中午 12 点，作为“暂停到明天”的结束时间，
+// 这样晚上暂停会持续到第二天白天，白天暂停会持续到第二天中午。
+func nextNoon(t time.Time) time.Time {
+	year, month, day := t.Date()
+	noon := time.Date(year, month, day, 12, 0, 0, 0, t.Location())
+	if !noon.After(t) {
+		noon = time.Date(year, month, day+1, 12, 0, 0, 0, t.Location())
+	}
+	return noon
+}
+
+// add 添加请求，until 不为零时到时间自动取消，返回 cookie。
+func (ci *colorTempInhibitors) add(sender, reason string, until time.Time) uint32 {
+	ci.mu.Lock()
+	ci.nextCookie++
+	cookie := ci.nextCookie
+	item := ColorTemperatureInhibitor{
+		Cookie: cookie,
+		Sender: sender,
+		Reason: reason,
+	}
+	if !until.IsZero() {
+		item.Until = until.Unix()
+		ci.resetTimerNoLock(item)
+	}
+	ci.items = append(ci.items, item)
+	items := ci.listNoLock()
+	ci.mu.Unlock()
+
+	ci.notify(items)
+	return cookie
+}
+
+// resetTimerNoLock 按照墙上时间重新设置自动取消的定时器，调用时需要持有 ci.mu。
+// 定时器用的是单调时钟，待机和修改系统时间后到期的时间不准确，到期时还要用 Until 判断。
+func (ci *colorTempInhibitors) resetTimerNoLock(item ColorTemperatureInhibitor) {
+	if timer := ci.timers[item.Cookie]; timer != nil {
+		timer.Stop()
+	}
+	d := time.Unix(item.Until, 0).Sub(ci.clock.Now())
+	ci.timers[item.Cookie] = ci.clock.AfterFunc(d, ci.recheck)
+}
+
+// recheck 取消已经过了 Until 的请求，并重新设置其他请求的定时器，
+// 定时器到期、从待机唤醒和修改系统时间时调用。
+func (ci *colorTempInhibitors) recheck() {
+	now := ci.clock.Now().Unix()
+	ci.removeIf(func(item *ColorTemperatureInhibitor) bool {
+		if item.Until != 0 && item.Until <= now {
+			logger.Debugf("color temperature inhibitor %d expired", item.Cookie)
+			return true
+		}
+		return false
+	})
+
+	ci.mu.Lock()
+	for _, item := range ci.items {
+		if item.Until != 0 {
+			ci.resetTimerNoLock(item)
+		}
+	}
+	ci.mu.Unlock()
+}
+
+// remove 取消 cookie 对应的请求，有请求者的只能由请求者自己取消。
+func (ci *colorTempInhibitors) remove(sender string, cookie uint32) error {
+	var found, denied bool
+	ci.removeIf(func(item *ColorTemperatureInhibitor) bool {
+		if item.Cookie != cookie {
+			return false
+		}
+		found = true
+		if item.Sender != "" && item.Sender != sender {
+			denied = true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return errors.New("invalid cookie")
+	}
+	if denied {
+		return errors.New("the inhibitor is not held by the caller")
+	}
+	return nil
+}
+
+// removeSender 请求者退出时取消它的所有请求
+func (ci *colorTempInhibitors) removeSender(sender string) {
+	ci.removeIf(func(item *ColorTemperatureInhibitor) bool {
+		return item.Sender == sender
+	})
+}
+
+func (ci *colorTempInhibitors) removeIf(fn func(item *ColorTemperatureInhibitor) bool) {
+	ci.mu.Lock()
+	var items []ColorTemperatureInhibitor
+	removed := false
+	for i := range ci.items {
+		item := &ci.items[i]
+		if !fn(item) {
+			items = append(items, *item)
+			continue
+		}
+		removed = true
+		if timer := ci.timers[item.Cookie]; timer != nil {
+			timer.Stop()
+			delete(ci.timers, item.Cookie)
+		}
+	}
+	ci.items = items
+	result := ci.listNoLock()
+	ci.mu.Unlock()
+
+	if removed {
+		ci.notify(result)
+	}
+}
+
+func (ci *colorTempInhibitors) listNoLock() []ColorTemperatureInhibitor {
+	result := make([]ColorTemperatureInhibitor, len(ci.items))
+	copy(result, ci.items)
+	return result
+}
+
+func (ci *colorTempInhibitors) list() []ColorTemperatureInhibitor {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	return ci.listNoLock()
+}
+
+func (ci *colorTempInhibitors) isInhibited() bool {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	return len(ci.items) > 0
+}
+
+func (ci *colorTempInhibitors) notify(items []ColorTemperatureInhibitor) {
+	if ci.cb != nil {
+		ci.cb(items)
+	}
+}
+
+func (m *Manager) handleColorTempInhibitorsChanged(items []ColorTemperatureInhibitor) {
+	m.PropsMu.Lock()
+	wasInhibited := len(m.Inhibitors) > 0
+	m.setPropInhibitors(items)
+	m.PropsMu.Unlock()
+
+	if wasInhibited != (len(items) > 0) {
+		logger.Info("color temperature inhibited:", len(items) > 0)
+		m.setColorTempOneShot()
+	}
+}
+
+// initColorTempInhibitors 监听请求者在会话总线上退出
+func (m *Manager) initColorTempInhibitors() {
+	m.sessionDBusDaemon = sessiondbus.NewDBus(m.service.Conn())
+	m.sessionDBusDaemon.InitSignalExt(m.sessionSigLoop, true)
+	_, err := m.sessionDBusDaemon.ConnectNameOwnerChanged(func(name, oldOwner, newOwner string) {
+		if strings.HasPrefix(name, ":") && newOwner == "" {
+			m.colorTempInhibitors.removeSender(name)
+		}
+	})
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+// dbus 上导出的方法
+func (m *Manager) inhibitColorTemp(sender dbus.Sender, reason string) (uint32, error) {
+	cookie := m.colorTempInhibitors.add(string(sender), reason, time.Time{})
+	logger.Infof("%s inhibit color temperature, reason: %q, cookie: %d", sender, reason, cookie)
+
+	// 请求者可能在添加之前已经退出
+	if m.sessionDBusDaemon != nil {
+		hasOwner, err := m.sessionDBusDaemon.NameHasOwner(0, string(sender))
+		if err == nil && !hasOwner {
+			m.colorTempInhibitors.removeSender(string(sender))
+			return 0, errors.New("the caller has exited")
+		}
+	}
+	return cookie, nil
+}
+
+// dbus 上导出的方法
+func (m *Manager) inhibitColorTempUntilTomorrow(reason string) uint32 {
+	until := nextNoon(m.colorTempInhibitors.clock.Now())
+	cookie := m.colorTempInhibitors.add("", reason, until)
+	logger.Infof("inhibit color temperature until %v, reason: %q, cookie: %d", until, reason, cookie)
+	return cookie
+}
+
+// dbus 上导出的方法
+func (m *Manager) uninhibitColorTemp(sender dbus.Sender, cookie uint32) error {
+	logger.Infof("%s uninhibit color temperature, cookie: %d", sender, cookie)
+	return m.colorTempInhibitors.remove(string(sender), cookie)
+}
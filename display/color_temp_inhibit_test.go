@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_nextNoon(t *testing.T) {
+	assert.Equal(t, time.Date(2023, 3, 2, 12, 0, 0, 0, zoneCST),
+		nextNoon(time.Date(2023, 3, 1, 22, 0, 0, 0, zoneCST)))
+	assert.Equal(t, time.Date(2023, 3, 1, 12, 0, 0, 0, zoneCST),
+		nextNoon(time.Date(2023, 3, 1, 2, 0, 0, 0, zoneCST)))
+	assert.Equal(t, time.Date(2023, 3, 2, 12, 0, 0, 0, zoneCST),
+		nextNoon(time.Date(2023, 3, 1, 12, 0, 0, 0, zoneCST)))
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, zoneCST),
+		nextNoon(time.Date(2023, 12, 31, 20, 0, 0, 0, zoneCST)))
+}
+
+func TestColorTempInhibitors(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 3, 1, 22, 0, 0, 0, zoneCST)}
+	ci := newColorTempInhibitors(clock)
+	var changes [][]ColorTemperatureInhibitor
+	ci.cb = func(items []ColorTemperatureInhibitor) {
+		changes = append(changes, items)
+	}
+	assert.False(t, ci.isInhibited())
+
+	cookie1 := ci.add(":1.10", "photo editing", time.Time{})
+	cookie2 := ci.add(":1.11", "video", time.Time{})
+	assert.NotEqual(t, cookie1, cookie2)
+	assert.True(t, ci.isInhibited())
+	require.Len(t, changes, 2)
+	assert.Equal(t, []ColorTemperatureInhibitor{
+		{Cookie: cookie1, Sender: ":1.10", Reason: "photo editing"},
+		{Cookie: cookie2, Sender: ":1.11", Reason: "video"},
+	}, ci.list())
+
+	// 只能由请求者取消
+	assert.Error(t, ci.remove(":1.11", cookie1))
+	assert.Error(t, ci.remove(":1.10", 100))
+	assert.Len(t, changes, 2)
+	assert.NoError(t, ci.remove(":1.10", cookie1))
+	assert.Len(t, ci.list(), 1)
+
+	// 请求者退出
+	ci.removeSender(":1.11")
+	assert.False(t, ci.isInhibited())
+	assert.Len(t, changes, 4)
+	assert.Empty(t, changes[3])
+	ci.removeSender(":1.11")
+	assert.Len(t, changes, 4)
+
+	// 暂停到明天
+	until := nextNoon(clock.Now())
+	cookie3 := ci.add("", "until tomorrow", until)
+	assert.Equal(t, until.Unix(), ci.list()[0].Until)
+	ci.removeSender(":1.12")
+	assert.True(t, ci.isInhibited())
+	clock.Advance(13 * time.Hour)
+	assert.True(t, ci.isInhibited())
+	clock.Advance(time.Hour)
+	assert.False(t, ci.isInhibited())
+	assert.Error(t, ci.remove("", cookie3))
+
+	// 修改系统时间后定时器没有到期，重新检查时取消
+	ci.add("", "until tomorrow", nextNoon(clock.Now()))
+	clock.mu.Lock()
+	clock.now = clock.now.Add(25 * time.Hour)
+	clock.mu.Unlock()
+	assert.True(t, ci.isInhibited())
+	ci.recheck()
+	assert.False(t, ci.isInhibited())
+	assert.Empty(t, ci.timers)
+
+	// 没有到 Until 时不取消，比如系统时间被调早后定时器先到期
+	until = nextNoon(clock.Now())
+	ci.add("", "until tomorrow", until)
+	ci.recheck()
+	assert.True(t, ci.isInhibited())
+	assert.Len(t, ci.timers, 1)
+	clock.Advance(until.Sub(clock.Now()))
+	assert.False(t, ci.isInhibited())
+
+	// 没有请求者的可以由任何人取消，取消后定时器停止
+	cookie4 := ci.add("", "until tomorrow", nextNoon(clock.Now()))
+	assert.NoError(t, ci.remove(":1.13", cookie4))
+	assert.Empty(t, ci.timers)
+}
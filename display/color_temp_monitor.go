@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+)
+
+// 显示器单独的色温设置，按显示器的 uuid 保存在 UserConfig.ColorTemperatures 中。
+// 没有单独设置的显示器跟随 Manager 的色温设置，Manager 上的色温接口作用于这些显示器。
+
+func isValidMonitorColorTempMode(mode int32) bool {
+	return mode == ColorTemperatureModeGlobal || isValidColorTempMode(mode)
+}
+
+func (cfg *UserConfig) getMonitorColorTemp(uuid string) *UserMonitorColorTempConfig {
+	return cfg.ColorTemperatures[uuid].clone()
+}
+
+// setMonitorColorTemp 设置显示器 uuid 的色温配置，colorTempCfg 为空时删除。
+func (cfg *UserConfig) setMonitorColorTemp(uuid string, colorTempCfg *UserMonitorColorTempConfig) {
+	if colorTempCfg == nil {
+		delete(cfg.ColorTemperatures, uuid)
+		return
+	}
+	if cfg.ColorTemperatures == nil {
+		cfg.ColorTemperatures = make(map[string]*UserMonitorColorTempConfig)
+	}
+	cfg.ColorTemperatures[uuid] = colorTempCfg.clone()
+}
+
+// getMonitorColorTemperatureValue 获取显示器当前应该使用的色温值
+func (m *Manager) getMonitorColorTemperatureValue(monitor *Monitor) int {
+	if m.colorTempInhibitors.isInhibited() {
+		return defaultTemperatureManual
+	}
+	monitor.PropsMu.RLock()
+	mode := monitor.ColorTemperatureMode
+	manual := monitor.ColorTemperatureManual
+	monitor.PropsMu.RUnlock()
+
+	if mode == ColorTemperatureModeGlobal {
+		return m.getColorTemperatureValue()
+	}
+	return m.calcColorTemperatureValue(mode, manual)
+}
+
+// saveMonitorColorTemp 保存显示器的色温设置，并根据需要启动或停止自动模式和定时模式的计算，然后重新设置 gamma。
+func (m *Manager) saveMonitorColorTemp(monitor *Monitor) error {
+	monitor.PropsMu.RLock()
+	uuid := monitor.uuid
+	mode := monitor.ColorTemperatureMode
+	manual := monitor.ColorTemperatureManual
+	brightnessValue := monitor.Brightness
+	enabled := monitor.Enabled
+	monitor.PropsMu.RUnlock()
+
+	var colorTempCfg *UserMonitorColorTempConfig
+	if mode != ColorTemperatureModeGlobal {
+		colorTempCfg = &UserMonitorColorTempConfig{
+			Mode:   mode,
+			Manual: manual,
+		}
+	}
+	m.userCfgMu.Lock()
+	m.userConfig.setMonitorColorTemp(uuid, colorTempCfg)
+	err := m.saveUserConfigNoLock()
+	m.userCfgMu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	if _greeterMode {
+		return nil
+	}
+	m.PropsMu.RLock()
+	globalMode := m.ColorTemperatureMode
+	m.PropsMu.RUnlock()
+	m.updateColorTempEngines(globalMode)
+
+	if enabled {
+		return m.applyMonitorBrightness(monitor.Name, brightnessValue)
+	}
+	return nil
+}
+
+func (m *Manager) setMonitorColorTempMode(monitor *Monitor, mode int32) error {
+	if !isValidMonitorColorTempMode(mode) {
+		return errors.New("mode out of range, not -1 or 0 or 1 or 2 or 3")
+	}
+	monitor.PropsMu.Lock()
+	changed := monitor.setPropColorTemperatureMode(mode)
+	monitor.PropsMu.Unlock()
+	if !changed {
+		return nil
+	}
+	return m.saveMonitorColorTemp(monitor)
+}
+
+func (m *Manager) setMonitorColorTempValue(monitor *Monitor, value int32) error {
+	if !isValidColorTempValue(value) {
+		return errors.New("value out of range")
+	}
+	monitor.PropsMu.Lock()
+	if monitor.ColorTemperatureMode != ColorTemperatureModeManual {
+		monitor.PropsMu.Unlock()
+		return errors.New("current not manual mode, can not adjust color temperature by manual")
+	}
+	changed := monitor.setPropColorTemperatureManual(value)
+	monitor.PropsMu.Unlock()
+	if !changed {
+		return nil
+	}
+	return m.saveMonitorColorTemp(monitor)
+}
+
+// restoreMonitorColorTemp 显示器连接或者 uuid 改变时，从配置中加载 uuid 对应的色温设置。
+func (m *Manager) restoreMonitorColorTemp(monitor *Monitor, uuid string) {
+	m.userCfgMu.Lock()
+	colorTempCfg := m.userConfig.getMonitorColorTemp(uuid)
+	m.userCfgMu.Unlock()
+
+	mode := ColorTemperatureModeGlobal
+	manual := int32(defaultTemperatureManual)
+	if colorTempCfg != nil {
+		mode = colorTempCfg.Mode
+		manual = colorTempCfg.Manual
+	}
+	monitor.PropsMu.Lock()
+	monitor.setPropColorTemperatureMode(mode)
+	monitor.setPropColorTemperatureManual(manual)
+	monitor.PropsMu.Unlock()
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 定时色温：每天在用户设置的开始时间和结束时间之间调整色温，开始后逐渐过渡到目标色温，
+// 在结束前逐渐恢复。时间按本地时间计算，每次更新都重新根据当前时间计算，
+// 所以修改系统时间、待机唤醒后最迟在 nightLightIdleInterval 后恢复正确的色温。
+
+const (
+	defaultScheduleStart = "21:00"
+	defaultScheduleEnd   = "07:00"
+	defaultScheduleFade  = 30
+	maxScheduleFade      = 180
+
+	clockTimeLayout = "15:04"
+)
+
+// parseClockTime 解析 15:04 格式的时间，返回距离 0 点的时长。
+func parseClockTime(str string) (time.Duration, error) {
+	t, err := time.Parse(clockTimeLayout, str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, the format should be HH:MM", str)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func getDefaultColorTempScheduleConfig() *UserColorTempScheduleConfig {
+	return &UserColorTempScheduleConfig{
+		Start:       defaultScheduleStart,
+		End:         defaultScheduleEnd,
+		Temperature: defaultNightTemperature,
+		Fade:        defaultScheduleFade,
+	}
+}
+
+func (c *UserColorTempScheduleConfig) check() error {
+	start, err := parseClockTime(c.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClockTime(c.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return errors.New("start time and end time are the same")
+	}
+	if !isValidColorTempValue(c.Temperature) {
+		return errors.New("temperature out of range")
+	}
+	if c.Fade < 0 || c.Fade > maxScheduleFade {
+		return fmt.Errorf("fade out of range [0, %d]", maxScheduleFade)
+	}
+	return nil
+}
+
+// toSchedule 转换为 nightLight 使用的定时配置，调用前需要 check。
+func (c *UserColorTempScheduleConfig) toSchedule() *colorTempSchedule {
+	start, _ := parseClockTime(c.Start)
+	end, _ := parseClockTime(c.End)
+	return &colorTempSchedule{
+		start:       start,
+		end:         end,
+		temperature: int(c.Temperature),
+		fade:        time.Duration(c.Fade) * time.Minute,
+	}
+}
+
+type colorTempSchedule struct {
+	// 距离 0 点的时长
+	start       time.Duration
+	end         time.Duration
+	temperature int
+	fade        time.Duration
+}
+
+// clockTimeOnDay 返回某一天的 clock 时刻，day 可以超出当月的天数。
+func clockTimeOnDay(year int, month time.Month, day int, clock time.Duration, loc *time.Location) time.Time {
+	hour := int(clock / time.Hour)
+	min := int(clock % time.Hour / time.Minute)
+	return time.Date(year, month, day, hour, min, 0, 0, loc)
+}
+
+// calcTemperature 计算 t 时刻的色温，并返回下次需要更新的时间。不在定时范围内时不调整色温。
+func (s *colorTempSchedule) calcTemperature(t time.Time) (int, time.Time) {
+	idleNext := t.Add(nightLightIdleInterval)
+	year, month, day := t.Date()
+	loc := t.Location()
+
+	// 跨过午夜时，当前时刻可能在前一天开始的范围内
+	for _, offset := range []int{-1, 0} {
+		start := clockTimeOnDay(year, month, day+offset, s.start, loc)
+		endDay := day + offset
+		if s.end <= s.start {
+			endDay++
+		}
+		end := clockTimeOnDay(year, month, endDay, s.end, loc)
+		if t.Before(start) || !t.Before(end) {
+			continue
+		}
+
+		fade := s.fade
+		if half := end.Sub(start) / 2; fade > half {
+			fade = half
+		}
+		fadeInEnd := start.Add(fade)
+		fadeOutStart := end.Add(-fade)
+		switch {
+		case t.Before(fadeInEnd):
+			return interpolateTemperature(defaultTemperatureManual, s.temperature, start, fadeInEnd, t),
+				minTime(t.Add(nightLightTransitionInterval), fadeInEnd)
+		case t.Before(fadeOutStart):
+			return s.temperature, minTime(fadeOutStart, idleNext)
+		default:
+			return interpolateTemperature(s.temperature, defaultTemperatureManual, fadeOutStart, end, t),
+				minTime(t.Add(nightLightTransitionInterval), end)
+		}
+	}
+
+	next := clockTimeOnDay(year, month, day, s.start, loc)
+	if !next.After(t) {
+		next = clockTimeOnDay(year, month, day+1, s.start, loc)
+	}
+	return defaultTemperatureManual, minTime(next, idleNext)
+}
+
+// getColorTempScheduleConfig 获取当前显示模式的定时色温配置
+func (m *Manager) getColorTempScheduleConfig() *UserColorTempScheduleConfig {
+	cfg := m.getSuitableUserMonitorModeConfig(m.DisplayMode)
+	if cfg == nil || cfg.ColorTemperatureSchedule == nil {
+		return getDefaultColorTempScheduleConfig()
+	}
+	return cfg.ColorTemperatureSchedule.clone()
+}
+
+// dbus 上导出的方法
+func (m *Manager) setColorTempSchedule(scheduleCfg *UserColorTempScheduleConfig) error {
+	err := scheduleCfg.check()
+	if err != nil {
+		return err
+	}
+	m.modifySuitableUserMonitorModeConfig(func(cfg *UserMonitorModeConfig) {
+		cfg.ColorTemperatureSchedule = scheduleCfg.clone()
+	})
+	err = m.saveUserConfig()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	if !_greeterMode {
+		m.scheduleLight.setSchedule(scheduleCfg.toSchedule())
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseClockTime(t *testing.T) {
+	d, err := parseClockTime("21:30")
+	require.NoError(t, err)
+	assert.Equal(t, 21*time.Hour+30*time.Minute, d)
+
+	d, err = parseClockTime("00:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), d)
+
+	for _, str := range []string{"", "24:00", "7", "07:60", "7:00pm"} {
+		_, err = parseClockTime(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestUserColorTempScheduleConfig_check(t *testing.T) {
+	assert.NoError(t, getDefaultColorTempScheduleConfig().check())
+
+	cfg := getDefaultColorTempScheduleConfig()
+	cfg.End = cfg.Start
+	assert.Error(t, cfg.check())
+
+	cfg = getDefaultColorTempScheduleConfig()
+	cfg.Temperature = 100
+	assert.Error(t, cfg.check())
+
+	cfg = getDefaultColorTempScheduleConfig()
+	cfg.Fade = maxScheduleFade + 1
+	assert.Error(t, cfg.check())
+
+	// 无效的配置在 fix 时删除
+	modeCfg := getDefaultUserMonitorModeConfig()
+	modeCfg.ColorTemperatureSchedule = &UserColorTempScheduleConfig{Start: "abc"}
+	modeCfg.fix()
+	assert.Nil(t, modeCfg.ColorTemperatureSchedule)
+
+	modeCfg.ColorTemperatureSchedule = getDefaultColorTempScheduleConfig()
+	modeCfgCp := modeCfg.clone()
+	modeCfgCp.ColorTemperatureSchedule.Start = "20:00"
+	assert.Equal(t, defaultScheduleStart, modeCfg.ColorTemperatureSchedule.Start)
+}
+
+func TestColorTempSchedule_calcTemperature(t *testing.T) {
+	s := (&UserColorTempScheduleConfig{
+		Start:       "21:00",
+		End:         "07:00",
+		Temperature: 3500,
+		Fade:        30,
+	}).toSchedule()
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2023, 3, day, hour, min, 0, 0, zoneCST)
+	}
+
+	value, next := s.calcTemperature(at(1, 12, 0))
+	assert.Equal(t, defaultTemperatureManual, value)
+	assert.Equal(t, at(1, 12, 10), next)
+
+	value, next = s.calcTemperature(at(1, 20, 55))
+	assert.Equal(t, defaultTemperatureManual, value)
+	assert.Equal(t, at(1, 21, 0), next)
+
+	// 开始后逐渐过渡
+	value, next = s.calcTemperature(at(1, 21, 0))
+	assert.Equal(t, defaultTemperatureManual, value)
+	assert.Equal(t, at(1, 21, 0).Add(nightLightTransitionInterval), next)
+	value, _ = s.calcTemperature(at(1, 21, 15))
+	assert.Equal(t, 5000, value)
+	value, next = s.calcTemperature(at(1, 21, 30))
+	assert.Equal(t, 3500, value)
+	assert.Equal(t, at(1, 21, 40), next)
+
+	// 跨过午夜
+	value, next = s.calcTemperature(at(2, 3, 0))
+	assert.Equal(t, 3500, value)
+	assert.Equal(t, at(2, 3, 10), next)
+	value, next = s.calcTemperature(at(2, 6, 25))
+	assert.Equal(t, 3500, value)
+	assert.Equal(t, at(2, 6, 30), next)
+	value, _ = s.calcTemperature(at(2, 6, 45))
+	assert.Equal(t, 5000, value)
+	value, _ = s.calcTemperature(at(2, 7, 0))
+	assert.Equal(t, defaultTemperatureManual, value)
+
+	// 月末跨过午夜
+	value, _ = s.calcTemperature(time.Date(2023, 3, 31, 23, 0, 0, 0, zoneCST))
+	assert.Equal(t, 3500, value)
+	value, _ = s.calcTemperature(time.Date(2023, 4, 1, 1, 0, 0, 0, zoneCST))
+	assert.Equal(t, 3500, value)
+
+	// 范围比两倍渐变时长短时，渐变时长减半
+	s = (&UserColorTempScheduleConfig{
+		Start:       "12:00",
+		End:         "12:40",
+		Temperature: 3500,
+		Fade:        30,
+	}).toSchedule()
+	value, _ = s.calcTemperature(at(1, 12, 20))
+	assert.Equal(t, 3500, value)
+	value, _ = s.calcTemperature(at(1, 12, 10))
+	assert.Equal(t, 5000, value)
+	value, _ = s.calcTemperature(at(1, 12, 30))
+	assert.Equal(t, 5000, value)
+}
+
+func TestNightLightSchedule(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 3, 1, 20, 0, 0, 0, zoneCST)}
+	n := newNightLight(clock, nightLightConfig{})
+	var values []int
+	n.cb = func(value int) {
+		values = append(values, value)
+	}
+	n.setSchedule((&UserColorTempScheduleConfig{
+		Start:       "21:00",
+		End:         "07:00",
+		Temperature: 4000,
+		Fade:        10,
+	}).toSchedule())
+	n.start()
+	assert.Equal(t, []int{defaultTemperatureManual}, values)
+
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, 4000, n.getValue())
+	clock.Advance(10 * time.Hour)
+	assert.Equal(t, defaultTemperatureManual, n.getValue())
+	assert.Equal(t, defaultTemperatureManual, values[len(values)-1])
+	assert.Contains(t, values, 4000)
+
+	// 修改系统时间后重新计算
+	clock.mu.Lock()
+	clock.now = time.Date(2023, 3, 2, 23, 0, 0, 0, zoneCST)
+	clock.mu.Unlock()
+	n.update()
+	assert.Equal(t, 4000, n.getValue())
+
+	// 切换回自动模式
+	n.setLocation(beijingLatitude, beijingLongitude)
+	n.setSchedule(nil)
+	assert.Equal(t, defaultNightTemperature, n.getValue())
+	n.stop()
+}
@@ -26,6 +26,8 @@ var (
 	configVersionFile string
 	// 用户级别配置文件 ~/.config/deepin/startdde/display-user.json
 	userConfigFile string
+	// 热插拔规则文件 ~/.config/deepin/startdde/display-rules.json
+	displayRulesFile string
 )
 
 func init() {
@@ -34,6 +36,7 @@ func init() {
 	configFileV5 = filepath.Join(cfgDir, "display_v5.json")
 	configVersionFile = filepath.Join(cfgDir, "config.version")
 	userConfigFile = filepath.Join(cfgDir, "display-user.json")
+	displayRulesFile = filepath.Join(cfgDir, "display-rules.json")
 }
 
 func getCfgDir() string {
@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const exportConfigVersion = "1.0"
+
+// 导入配置时，对于本机已存在的屏幕配置的处理策略
+const (
+	// 合并，导入的各显示模式配置覆盖本机的同名配置，本机其他配置保留
+	importPolicyMerge = "merge"
+	// 替换，导入的屏幕配置整个替换本机的屏幕配置
+	importPolicyReplace = "replace"
+)
+
+// ExportedConfig 导出的完整显示配置，可用于备份或者迁移到其他机器和用户。
+type ExportedConfig struct {
+	Version    string
+	SysVersion string
+	// SysConfig 中的 Cache 是本机相关的数据，不导出
+	SysConfig  SysConfig
+	UserConfig UserConfig
+}
+
+func (m *Manager) exportConfig() (string, error) {
+	var cfg ExportedConfig
+	cfg.Version = exportConfigVersion
+
+	m.sysConfig.mu.Lock()
+	cfg.SysVersion = m.sysConfig.Version
+	sysCfgJson := jsonMarshal(&m.sysConfig.Config)
+	m.sysConfig.mu.Unlock()
+	if cfg.SysVersion == "" {
+		cfg.SysVersion = sysConfigVersion
+	}
+	// 通过 json 做一次深拷贝
+	err := jsonUnmarshal(sysCfgJson, &cfg.SysConfig)
+	if err != nil {
+		return "", err
+	}
+	cfg.SysConfig.Cache = SysCache{}
+
+	m.userCfgMu.Lock()
+	userCfgJson := jsonMarshal(&m.userConfig)
+	m.userCfgMu.Unlock()
+	err = jsonUnmarshal(userCfgJson, &cfg.UserConfig)
+	if err != nil {
+		return "", err
+	}
+	cfg.UserConfig.Version = userConfigVersion
+
+	data, err := json.MarshalIndent(&cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func parseExportedConfig(data string) (*ExportedConfig, error) {
+	var cfg ExportedConfig
+	err := jsonUnmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Version != exportConfigVersion {
+		return nil, fmt.Errorf("unsupported config version %q", cfg.Version)
+	}
+	if cfg.SysVersion != sysConfigVersion {
+		return nil, fmt.Errorf("unsupported sys config version %q", cfg.SysVersion)
+	}
+	if cfg.UserConfig.Version != "" && cfg.UserConfig.Version != userConfigVersion {
+		return nil, fmt.Errorf("unsupported user config version %q", cfg.UserConfig.Version)
+	}
+
+	// 复用配置的修正方法
+	rootCfg := SysRootConfig{
+		Version: cfg.SysVersion,
+		Config:  cfg.SysConfig,
+	}
+	rootCfg.fix()
+	cfg.SysConfig = rootCfg.Config
+	cfg.SysConfig.Cache = SysCache{}
+	cfg.UserConfig.fix()
+	return &cfg, nil
+}
+
+func checkImportPolicy(policy string) error {
+	switch policy {
+	case importPolicyMerge, importPolicyReplace:
+		return nil
+	}
+	return fmt.Errorf("invalid merge policy %q", policy)
+}
+
+// mergeFrom 把导入的系统配置合并到 cfg 中，policy 决定本机已存在的屏幕配置怎么处理。
+func (cfg *SysConfig) mergeFrom(imported *SysConfig, policy string) {
+	cfg.DisplayMode = imported.DisplayMode
+	cfg.TransformScaling = imported.TransformScaling
+	if cfg.Screens == nil {
+		cfg.Screens = make(map[string]*SysScreenConfig)
+	}
+	for monitorsId, screenCfg := range imported.Screens {
+		current := cfg.Screens[monitorsId]
+		if current == nil || policy == importPolicyReplace {
+			cfg.Screens[monitorsId] = screenCfg.clone()
+			continue
+		}
+		current.mergeFrom(screenCfg)
+	}
+
+	if len(imported.ScaleFactors) > 0 && cfg.ScaleFactors == nil {
+		cfg.ScaleFactors = make(map[string]float64)
+	}
+	for key, value := range imported.ScaleFactors {
+		cfg.ScaleFactors[key] = value
+	}
+
+	for uuid, list := range imported.CustomResolutions {
+		for _, res := range list {
+			resCp := *res
+			cfg.addCustomResolution(uuid, &resCp)
+		}
+	}
+
+	for uuid, filename := range imported.ColorProfiles {
+		cfg.setColorProfile(uuid, filename)
+	}
+
+	for uuid, underscanCfg := range imported.Underscans {
+		if underscanCfg != nil {
+			cfg.setUnderscan(uuid, underscanCfg.Horizontal, underscanCfg.Vertical)
+		}
+	}
+
+	for uuid, props := range imported.OutputProperties {
+		for name, value := range props {
+			cfg.setOutputProperty(uuid, name, value)
+		}
+	}
+
+	if len(imported.FillModes) > 0 && cfg.FillModes == nil {
+		cfg.FillModes = make(map[string]string)
+	}
+	for key, value := range imported.FillModes {
+		cfg.FillModes[key] = value
+	}
+	// 与 TransformScaling 一样以导入的为准，为空时是默认的 common
+	cfg.MirrorStrategy = imported.MirrorStrategy
+}
+
+// mergeFrom 用导入的配置中存在的显示模式配置覆盖 c 中的配置
+func (c *SysScreenConfig) mergeFrom(imported *SysScreenConfig) {
+	if imported.Mirror != nil {
+		c.Mirror = imported.Mirror.clone()
+	}
+	if imported.Extend != nil {
+		c.Extend = imported.Extend.clone()
+	}
+	if imported.Single != nil {
+		c.Single = imported.Single.clone()
+	}
+	for uuid, config := range imported.OnlyOneMap {
+		if c.OnlyOneMap == nil {
+			c.OnlyOneMap = make(map[string]*SysMonitorModeConfig)
+		}
+		c.OnlyOneMap[uuid] = config.clone()
+	}
+	if imported.OnlyOneUuid != "" {
+		c.OnlyOneUuid = imported.OnlyOneUuid
+	}
+	for name, config := range imported.CustomMap {
+		c.setCustomConfig(name, config.clone())
+	}
+	// 显示模式配置可能已被覆盖，以导入的为准
+	c.CustomId = imported.CustomId
+}
+
+func (cfg *UserConfig) mergeFrom(imported *UserConfig, policy string) {
+	if cfg.Screens == nil {
+		cfg.Screens = make(map[string]UserScreenConfig)
+	}
+	for monitorsId, screenCfg := range imported.Screens {
+		current := cfg.Screens[monitorsId]
+		if current == nil || policy == importPolicyReplace {
+			cfg.Screens[monitorsId] = screenCfg.clone()
+			continue
+		}
+		for key, config := range screenCfg {
+			current[key] = config.clone()
+		}
+	}
+
+	if len(imported.ColorTemperatures) > 0 && cfg.ColorTemperatures == nil {
+		cfg.ColorTemperatures = make(map[string]*UserMonitorColorTempConfig)
+	}
+	for uuid, colorTempCfg := range imported.ColorTemperatures {
+		cfg.ColorTemperatures[uuid] = colorTempCfg.clone()
+	}
+
+	if imported.Location != nil {
+		locationCp := *imported.Location
+		cfg.Location = &locationCp
+	}
+}
+
+// importConfig 导入 exportConfig 导出的配置，保存并立即应用到当前连接的显示器上。
+func (m *Manager) importConfig(data, policy string) error {
+	if _greeterMode {
+		return errors.New("not allowed in greeter mode")
+	}
+	err := checkImportPolicy(policy)
+	if err != nil {
+		return err
+	}
+	imported, err := parseExportedConfig(data)
+	if err != nil {
+		return err
+	}
+
+	// 在当前配置的副本上合并
+	m.sysConfig.mu.Lock()
+	sysCfgJson := jsonMarshal(&m.sysConfig)
+	m.sysConfig.mu.Unlock()
+	var newSysCfg SysRootConfig
+	err = jsonUnmarshal(sysCfgJson, &newSysCfg)
+	if err != nil {
+		return err
+	}
+	newSysCfg.Config.mergeFrom(&imported.SysConfig, policy)
+	newSysCfg.fix()
+
+	m.userCfgMu.Lock()
+	m.userConfig.mergeFrom(&imported.UserConfig, policy)
+	m.userConfig.fix()
+	err = m.saveUserConfigNoLock()
+	m.userCfgMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 与系统配置被其他地方修改时的处理相同，按配置的改变应用
+	m.handleSysConfigUpdated(&newSysCfg)
+	err = m.saveSysConfig("import config")
+	if err != nil {
+		return err
+	}
+
+	for _, monitor := range m.getConnectedMonitors() {
+		monitor.PropsMu.RLock()
+		uuid := monitor.uuid
+		monitor.PropsMu.RUnlock()
+		m.restoreMonitorColorTemp(monitor, uuid)
+	}
+	m.applyColorTempConfig(newSysCfg.Config.DisplayMode)
+	return nil
+}
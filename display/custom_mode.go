@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 自定义布局方案，按 monitorsId 保存在 SysScreenConfig.CustomMap 中，
+// 只有方案中的显示器都连接时才能看到和应用该方案。
+
+func checkCustomModeName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("custom mode name is empty")
+	}
+	if name != strings.TrimSpace(name) {
+		return fmt.Errorf("invalid custom mode name %q", name)
+	}
+	return nil
+}
+
+// updatePropCustomMode 根据 monitorsId 对应的屏幕配置更新 CustomIdList 和 CurrentCustomId 属性。
+func (m *Manager) updatePropCustomMode(monitorsId monitorsId) {
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	idList := screenCfg.getCustomIdList()
+	if idList == nil {
+		idList = []string{}
+	}
+
+	m.PropsMu.Lock()
+	m.setPropCustomIdList(idList)
+	m.setPropCurrentCustomId(screenCfg.CustomId)
+	m.PropsMu.Unlock()
+}
+
+// saveCustomMode 把当前的显示布局保存为名为 name 的方案，同名方案会被覆盖。
+func (m *Manager) saveCustomMode(name string) error {
+	err := checkCustomModeName(name)
+	if err != nil {
+		return err
+	}
+
+	monitorMap := m.cloneMonitorMap()
+	monitors := getConnectedMonitors(monitorMap)
+	if len(monitors) == 0 {
+		return errors.New("no monitor connected")
+	}
+	monitorsId := monitors.getMonitorsId()
+
+	m.PropsMu.RLock()
+	displayMode := m.DisplayMode
+	primary := m.Primary
+	m.PropsMu.RUnlock()
+
+	configs := toSysMonitorConfigs(monitors, primary)
+	configs.sort()
+
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	screenCfg.setCustomConfig(name, &SysCustomModeConfig{
+		DisplayMode: displayMode,
+		Monitors:    configs,
+	})
+	screenCfg.CustomId = name
+	m.setSysScreenConfig(monitorsId, screenCfg)
+
+	err = m.saveSysConfig("save custom mode")
+	if err != nil {
+		return err
+	}
+	m.updatePropCustomMode(monitorsId)
+	return nil
+}
+
+func (m *Manager) renameCustomMode(name, newName string) error {
+	err := checkCustomModeName(newName)
+	if err != nil {
+		return err
+	}
+
+	monitorsId := m.getConnectedMonitors().getMonitorsId()
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	err = screenCfg.renameCustomConfig(name, newName)
+	if err != nil {
+		return err
+	}
+	m.setSysScreenConfig(monitorsId, screenCfg)
+
+	err = m.saveSysConfig("rename custom mode")
+	if err != nil {
+		return err
+	}
+	m.updatePropCustomMode(monitorsId)
+	return nil
+}
+
+func (m *Manager) deleteCustomMode(name string) error {
+	monitorsId := m.getConnectedMonitors().getMonitorsId()
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	err := screenCfg.deleteCustomConfig(name)
+	if err != nil {
+		return err
+	}
+	m.setSysScreenConfig(monitorsId, screenCfg)
+
+	err = m.saveSysConfig("delete custom mode")
+	if err != nil {
+		return err
+	}
+	m.updatePropCustomMode(monitorsId)
+	return nil
+}
+
+// switchCustomMode 应用名为 name 的方案，并把方案中的配置写入对应显示模式的配置中，
+// 之后显示器插拔时也会恢复到这个布局。
+func (m *Manager) switchCustomMode(name string) error {
+	monitorMap := m.cloneMonitorMap()
+	monitors := getConnectedMonitors(monitorMap)
+	if len(monitors) == 0 {
+		return errors.New("no monitor connected")
+	}
+	monitorsId := monitors.getMonitorsId()
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	customCfg := screenCfg.getCustomConfig(name)
+	if customCfg == nil {
+		return fmt.Errorf("custom mode %q not found", name)
+	}
+
+	m.PropsMu.RLock()
+	oldMode := m.DisplayMode
+	m.PropsMu.RUnlock()
+
+	single := len(monitors) == 1
+	mode := customCfg.DisplayMode
+	applyMode := mode
+	if single {
+		applyMode = DisplayModeInvalid
+	}
+	configs := customCfg.Monitors.clone()
+	options := applyOptions{
+		optionDisableCrtc: true,
+		// 按方案保存时的布局原样应用
+		optionNoNormalize: true,
+	}
+	err := m.applySysMonitorConfigs(applyMode, monitorsId, monitorMap, configs, options)
+	if err != nil {
+		return err
+	}
+	m.markClean()
+
+	if single {
+		screenCfg.setSingleMonitorConfigs(configs)
+	} else {
+		uuid := ""
+		if mode == DisplayModeOnlyOne {
+			for _, config := range configs {
+				if config.Enabled {
+					uuid = config.UUID
+					break
+				}
+			}
+			screenCfg.OnlyOneUuid = uuid
+		}
+		m.setScreenMonitorConfigs(screenCfg, mode, uuid, configs)
+	}
+	screenCfg.CustomId = name
+	m.setSysScreenConfig(monitorsId, screenCfg)
+
+	m.sysConfig.mu.Lock()
+	if !single {
+		m.sysConfig.Config.DisplayMode = mode
+	}
+	err = m.saveSysConfigNoLock("switch custom mode")
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if !single && mode != oldMode {
+		m.applyColorTempConfig(mode)
+	}
+	m.updatePropCustomMode(monitorsId)
+	return nil
+}
@@ -0,0 +1,342 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+)
+
+// 用户自定义的分辨率，按显示器的 uuid 保存在 SysConfig.CustomResolutions 中，
+// 用于 EDID 有问题的投影仪、采集卡等设备，显示器每次连接时都会重新添加。
+
+// SysCustomResolution 用户添加的一个自定义模式
+type SysCustomResolution struct {
+	Width           uint16
+	Height          uint16
+	RefreshRate     float64
+	ReducedBlanking bool `json:",omitempty"`
+}
+
+func (r *SysCustomResolution) equal(other *SysCustomResolution) bool {
+	return r.Width == other.Width && r.Height == other.Height &&
+		math.Abs(r.RefreshRate-other.RefreshRate) < 0.005 &&
+		r.ReducedBlanking == other.ReducedBlanking
+}
+
+func (r *SysCustomResolution) getModeInfo() (randr.ModeInfo, error) {
+	return calcCvtModeInfo(r.Width, r.Height, r.RefreshRate, r.ReducedBlanking)
+}
+
+// addCustomResolution 添加显示器 uuid 的自定义模式，已经存在时返回 false。
+func (cfg *SysConfig) addCustomResolution(uuid string, res *SysCustomResolution) bool {
+	for _, r := range cfg.CustomResolutions[uuid] {
+		if r.equal(res) {
+			return false
+		}
+	}
+	if cfg.CustomResolutions == nil {
+		cfg.CustomResolutions = make(map[string][]*SysCustomResolution)
+	}
+	cfg.CustomResolutions[uuid] = append(cfg.CustomResolutions[uuid], res)
+	return true
+}
+
+// removeCustomResolution 删除显示器 uuid 的自定义模式，不存在时返回 false。
+func (cfg *SysConfig) removeCustomResolution(uuid string, res *SysCustomResolution) bool {
+	list := cfg.CustomResolutions[uuid]
+	for i, r := range list {
+		if r.equal(res) {
+			list = append(list[:i:i], list[i+1:]...)
+			if len(list) == 0 {
+				delete(cfg.CustomResolutions, uuid)
+			} else {
+				cfg.CustomResolutions[uuid] = list
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// fixCustomResolutions 删除无效的自定义模式
+func (cfg *SysConfig) fixCustomResolutions() {
+	for uuid, list := range cfg.CustomResolutions {
+		var result []*SysCustomResolution
+		for _, res := range list {
+			if res == nil {
+				continue
+			}
+			_, err := res.getModeInfo()
+			if err != nil {
+				continue
+			}
+			result = append(result, res)
+		}
+		if len(result) == 0 {
+			delete(cfg.CustomResolutions, uuid)
+		} else {
+			cfg.CustomResolutions[uuid] = result
+		}
+	}
+}
+
+func (cfg *SysConfig) getCustomResolutions(uuid string) []*SysCustomResolution {
+	list := cfg.CustomResolutions[uuid]
+	result := make([]*SysCustomResolution, len(list))
+	for i, r := range list {
+		rCp := *r
+		result[i] = &rCp
+	}
+	return result
+}
+
+func (m *Manager) addCustomResolution(monitor *Monitor, res *SysCustomResolution) error {
+	if _useWayland {
+		return errors.New("custom mode is not supported on wayland")
+	}
+	modeInfo, err := res.getModeInfo()
+	if err != nil {
+		return err
+	}
+
+	monitor.PropsMu.RLock()
+	id := monitor.ID
+	uuid := monitor.uuid
+	monitor.PropsMu.RUnlock()
+
+	monitorInfo := m.mm.getMonitor(id)
+	if monitorInfo != nil && !hasModeName(monitorInfo.Modes, modeInfo.Name) &&
+		isNewModeFiltered(monitorInfo.Modes, monitorInfo.PreferredMode, modeInfo, m.filterModeInfos) {
+		// 添加后也不会出现在模式列表中
+		return fmt.Errorf("custom mode %s is the same as an existing mode", modeInfo.Name)
+	}
+
+	err = m.mm.addMonitorMode(id, modeInfo)
+	if err != nil {
+		return err
+	}
+
+	m.sysConfig.mu.Lock()
+	defer m.sysConfig.mu.Unlock()
+	if !m.sysConfig.Config.addCustomResolution(uuid, res) {
+		return nil
+	}
+	return m.saveSysConfigNoLock("add custom mode")
+}
+
+func (m *Manager) removeCustomResolution(monitor *Monitor, res *SysCustomResolution) error {
+	if _useWayland {
+		return errors.New("custom mode is not supported on wayland")
+	}
+	modeInfo, err := res.getModeInfo()
+	if err != nil {
+		return err
+	}
+
+	monitor.PropsMu.RLock()
+	id := monitor.ID
+	uuid := monitor.uuid
+	currentMode := monitor.CurrentMode
+	monitor.PropsMu.RUnlock()
+
+	if currentMode.name == modeInfo.Name {
+		return fmt.Errorf("custom mode %s is in use", modeInfo.Name)
+	}
+
+	m.sysConfig.mu.Lock()
+	removed := m.sysConfig.Config.removeCustomResolution(uuid, res)
+	if removed {
+		err = m.saveSysConfigNoLock("remove custom mode")
+	}
+	m.sysConfig.mu.Unlock()
+	if !removed {
+		return fmt.Errorf("custom mode %s not found", modeInfo.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.mm.removeMonitorMode(id, modeInfo.Name)
+}
+
+// restoreCustomResolutions 给已连接的显示器重新添加配置中的自定义模式，返回是否添加了模式。
+// 与已有模式重复、会被过滤掉的自定义模式不添加。
+func (m *Manager) restoreCustomResolutions(monitors Monitors) (added bool) {
+	if _useWayland {
+		return false
+	}
+	for _, monitor := range monitors {
+		m.sysConfig.mu.Lock()
+		list := m.sysConfig.Config.getCustomResolutions(monitor.uuid)
+		m.sysConfig.mu.Unlock()
+		if len(list) == 0 {
+			continue
+		}
+		// 要用没有过滤过的模式列表判断是否已经添加
+		monitorInfo := m.mm.getMonitor(monitor.ID)
+		if monitorInfo == nil {
+			continue
+		}
+
+		for _, res := range list {
+			modeInfo, err := res.getModeInfo()
+			if err != nil {
+				logger.Warning(err)
+				continue
+			}
+			if hasModeName(monitorInfo.Modes, modeInfo.Name) {
+				continue
+			}
+			if isNewModeFiltered(monitorInfo.Modes, monitorInfo.PreferredMode, modeInfo, m.filterModeInfos) {
+				logger.Debugf("skip custom mode %s for %v, it is the same as an existing mode", modeInfo.Name, monitor.Name)
+				continue
+			}
+			err = m.mm.addMonitorMode(monitor.ID, modeInfo)
+			if err != nil {
+				logger.Warningf("failed to add custom mode %s for %v: %v", modeInfo.Name, monitor.Name, err)
+				continue
+			}
+			added = true
+		}
+	}
+	return added
+}
+
+// isNewModeFiltered 判断还没有添加的模式 modeInfo 加入 modes 后是否会被 filter 过滤掉，比如已经有尺寸和刷新率相同的模式。
+func isNewModeFiltered(modes []ModeInfo, preferredMode ModeInfo, modeInfo randr.ModeInfo,
+	filter func(modes []ModeInfo, saveMode ModeInfo) []ModeInfo) bool {
+	newMode := toModeInfo(modeInfo)
+	// 还没有创建，没有 id，用一个不会与已有模式相同的值
+	newMode.Id = math.MaxUint32
+	modes = append(append([]ModeInfo(nil), modes...), newMode)
+	return !hasModeName(filter(modes, preferredMode), newMode.name)
+}
+
+func hasModeName(modes []ModeInfo, name string) bool {
+	for _, mode := range modes {
+		if mode.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func modeTimingsEqual(m1, m2 *randr.ModeInfo) bool {
+	return m1.Width == m2.Width && m1.Height == m2.Height &&
+		m1.DotClock == m2.DotClock &&
+		m1.HSyncStart == m2.HSyncStart && m1.HSyncEnd == m2.HSyncEnd &&
+		m1.HTotal == m2.HTotal && m1.HSkew == m2.HSkew &&
+		m1.VSyncStart == m2.VSyncStart && m1.VSyncEnd == m2.VSyncEnd &&
+		m1.VTotal == m2.VTotal && m1.ModeFlags == m2.ModeFlags
+}
+
+// addMonitorMode 创建模式并添加到 output 上，同名同时序的模式已经存在时直接使用。
+func (mm *xMonitorManager) addMonitorMode(monitorId uint32, modeInfo randr.ModeInfo) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	output := randr.Output(monitorId)
+	outputInfo := mm.outputs[output]
+	if outputInfo == nil {
+		return fmt.Errorf("output %d not found", monitorId)
+	}
+
+	var mode randr.Mode
+	for i := range mm.modes {
+		if mm.modes[i].Name == modeInfo.Name {
+			if !modeTimingsEqual(&mm.modes[i], &modeInfo) {
+				return fmt.Errorf("mode %s already exists", modeInfo.Name)
+			}
+			mode = randr.Mode(mm.modes[i].Id)
+			break
+		}
+	}
+	if mode != 0 {
+		for _, m := range outputInfo.Modes {
+			if m == mode {
+				// 已经添加过了
+				return nil
+			}
+		}
+	}
+
+	if mode == 0 {
+		root := mm.xConn.GetDefaultScreen().Root
+		reply, err := randr.CreateMode(mm.xConn, root, &modeInfo).Reply(mm.xConn)
+		if err != nil {
+			return err
+		}
+		mode = reply.Mode
+	}
+	logger.Debugf("add mode %v %s to output %v", mode, modeInfo.Name, output)
+	err := randr.AddOutputModeChecked(mm.xConn, output, mode).Check(mm.xConn)
+	if err != nil {
+		return err
+	}
+	return mm.refreshOutputModes(output)
+}
+
+// removeMonitorMode 从 output 上删除名为 name 的模式，没有其他 output 使用时销毁这个模式。
+func (mm *xMonitorManager) removeMonitorMode(monitorId uint32, name string) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	output := randr.Output(monitorId)
+	var mode randr.Mode
+	for _, modeInfo := range mm.modes {
+		if modeInfo.Name == name {
+			mode = randr.Mode(modeInfo.Id)
+			break
+		}
+	}
+	if mode == 0 {
+		return nil
+	}
+
+	logger.Debugf("delete mode %v %s from output %v", mode, name, output)
+	err := randr.DeleteOutputModeChecked(mm.xConn, output, mode).Check(mm.xConn)
+	if err != nil {
+		return err
+	}
+	inUse := false
+	for outputId, outputInfo := range mm.outputs {
+		if outputId == output {
+			continue
+		}
+		for _, m := range outputInfo.Modes {
+			if m == mode {
+				inUse = true
+			}
+		}
+	}
+	if !inUse {
+		err = randr.DestroyModeChecked(mm.xConn, mode).Check(mm.xConn)
+		if err != nil {
+			logger.Warningf("failed to destroy mode %v: %v", mode, err)
+		}
+	}
+	return mm.refreshOutputModes(output)
+}
+
+// refreshOutputModes 添加或删除模式不会改变配置的时间戳，需要主动更新模式列表和 output 的信息。
+func (mm *xMonitorManager) refreshOutputModes(output randr.Output) error {
+	// NOTE: 不要加锁
+	resources, err := mm.getScreenResourcesCurrent()
+	if err != nil {
+		return err
+	}
+	mm.modes = resources.Modes
+	reply, err := mm.getOutputInfo(output)
+	if err != nil {
+		return err
+	}
+	mm.outputs[output] = (*OutputInfo)(reply)
+	mm.doDiff()
+	return nil
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+)
+
+// 按 VESA CVT 1.1 标准计算模式的时序，与 cvt 命令和 libxcvt 的计算方法相同，不考虑 margin 和隔行扫描。
+const (
+	cvtHGranularity = 8
+	cvtMinVPorch    = 3
+	cvtMinVBPorch   = 6
+	cvtClockStep    = 250 // kHz
+
+	// 普通 blanking
+	cvtMinVSyncBP       = 550.0 // 微秒
+	cvtHSyncPercentage  = 8
+	cvtMinHBlankPercent = 20.0
+	cvtMPrime           = 600 * 128 / 256
+	cvtCPrime           = (40-20)*128/256 + 20
+
+	// reduced blanking
+	cvtRBMinVBlank = 460.0 // 微秒
+	cvtRBHSync     = 32
+	cvtRBHBlank    = 160
+	cvtRBVFPorch   = 3
+)
+
+// 自定义模式的限制
+const (
+	cvtMinModeWidth   = 320
+	cvtMinModeHeight  = 200
+	cvtMinModeRefresh = 20.0
+	cvtMaxModeRefresh = 500.0
+)
+
+// getCvtVSyncWidth 根据宽高比确定 vsync 的行数
+func getCvtVSyncWidth(width, height int) int {
+	switch {
+	case height%3 == 0 && height*4/3 == width:
+		return 4
+	case height%9 == 0 && height*16/9 == width:
+		return 5
+	case height%10 == 0 && height*16/10 == width:
+		return 6
+	case height%4 == 0 && height*5/4 == width:
+		return 7
+	case height%9 == 0 && height*15/9 == width:
+		return 7
+	}
+	// 其他宽高比
+	return 10
+}
+
+func getCvtModeName(width, height uint16, refreshRate float64, reducedBlanking bool) string {
+	suffix := ""
+	if reducedBlanking {
+		suffix = "R"
+	}
+	return fmt.Sprintf("%dx%d%s_%.2f", width, height, suffix, refreshRate)
+}
+
+// calcCvtModeInfo 计算 CVT 或者 CVT-RB（reduced blanking）模式，返回的 ModeInfo 可以用于 randr.CreateMode。
+func calcCvtModeInfo(width, height uint16, refreshRate float64, reducedBlanking bool) (randr.ModeInfo, error) {
+	if width < cvtMinModeWidth || height < cvtMinModeHeight {
+		return randr.ModeInfo{}, fmt.Errorf("invalid mode size %dx%d", width, height)
+	}
+	if refreshRate < cvtMinModeRefresh || refreshRate > cvtMaxModeRefresh {
+		return randr.ModeInfo{}, fmt.Errorf("invalid refresh rate %v", refreshRate)
+	}
+
+	hDisplay := int(width) - int(width)%cvtHGranularity
+	vDisplay := int(height)
+	vSync := getCvtVSyncWidth(hDisplay, vDisplay)
+
+	var hPeriod float64 // 微秒
+	var hTotal, hSyncStart, hSyncEnd, vTotal, vSyncStart int
+	var flags uint32
+	if reducedBlanking {
+		hPeriod = (1000000.0/refreshRate - cvtRBMinVBlank) / float64(vDisplay)
+		vBlank := int(cvtRBMinVBlank/hPeriod) + 1
+		if vBlank < cvtRBVFPorch+vSync+cvtMinVBPorch {
+			vBlank = cvtRBVFPorch + vSync + cvtMinVBPorch
+		}
+		vTotal = vDisplay + vBlank
+		hTotal = hDisplay + cvtRBHBlank
+		hSyncEnd = hDisplay + cvtRBHBlank/2
+		hSyncStart = hSyncEnd - cvtRBHSync
+		vSyncStart = vDisplay + cvtRBVFPorch
+		flags = randr.ModeFlagHsyncPositive | randr.ModeFlagVsyncNegative
+	} else {
+		hPeriod = (1000000.0/refreshRate - cvtMinVSyncBP) / float64(vDisplay+cvtMinVPorch)
+		vSyncAndBackPorch := int(cvtMinVSyncBP/hPeriod) + 1
+		if vSyncAndBackPorch < vSync+cvtMinVPorch {
+			vSyncAndBackPorch = vSync + cvtMinVPorch
+		}
+		vTotal = vDisplay + vSyncAndBackPorch + cvtMinVPorch
+
+		hBlankPercent := cvtCPrime - cvtMPrime*hPeriod/1000.0
+		if hBlankPercent < cvtMinHBlankPercent {
+			hBlankPercent = cvtMinHBlankPercent
+		}
+		hBlank := int(float64(hDisplay) * hBlankPercent / (100.0 - hBlankPercent))
+		hBlank -= hBlank % (2 * cvtHGranularity)
+		hTotal = hDisplay + hBlank
+		hSyncEnd = hDisplay + hBlank/2
+		hSyncWidth := hTotal * cvtHSyncPercentage / 100
+		hSyncWidth -= hSyncWidth % cvtHGranularity
+		hSyncStart = hSyncEnd - hSyncWidth
+		vSyncStart = vDisplay + cvtMinVPorch
+		flags = randr.ModeFlagHsyncNegative | randr.ModeFlagVsyncPositive
+	}
+	if hPeriod <= 0 {
+		return randr.ModeInfo{}, errors.New("invalid horizontal period")
+	}
+	if hTotal > math.MaxUint16 || vTotal > math.MaxUint16 {
+		return randr.ModeInfo{}, fmt.Errorf("mode %dx%d is too large", width, height)
+	}
+
+	// 单位是 kHz
+	clock := int(float64(hTotal) * 1000.0 / hPeriod)
+	clock -= clock % cvtClockStep
+
+	return randr.ModeInfo{
+		Width:      width,
+		Height:     height,
+		DotClock:   uint32(clock) * 1000,
+		HSyncStart: uint16(hSyncStart),
+		HSyncEnd:   uint16(hSyncEnd),
+		HTotal:     uint16(hTotal),
+		VSyncStart: uint16(vSyncStart),
+		VSyncEnd:   uint16(vSyncStart + vSync),
+		VTotal:     uint16(vTotal),
+		Name:       getCvtModeName(width, height, refreshRate, reducedBlanking),
+		ModeFlags:  flags,
+	}, nil
+}
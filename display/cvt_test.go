@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_calcCvtModeInfo(t *testing.T) {
+	// 与 cvt 1920 1080 60 的结果相同
+	// Modeline "1920x1080_60.00"  173.00  1920 2048 2248 2576  1080 1083 1088 1120 -hsync +vsync
+	mode, err := calcCvtModeInfo(1920, 1080, 60, false)
+	require.NoError(t, err)
+	assert.Equal(t, randr.ModeInfo{
+		Width:      1920,
+		Height:     1080,
+		DotClock:   173000000,
+		HSyncStart: 2048,
+		HSyncEnd:   2248,
+		HTotal:     2576,
+		VSyncStart: 1083,
+		VSyncEnd:   1088,
+		VTotal:     1120,
+		Name:       "1920x1080_60.00",
+		ModeFlags:  randr.ModeFlagHsyncNegative | randr.ModeFlagVsyncPositive,
+	}, mode)
+	assert.InDelta(t, 59.96, calcModeRate(mode), 0.01)
+
+	// 与 cvt -r 1920 1080 60 的结果相同
+	// Modeline "1920x1080R"  138.50  1920 1968 2000 2080  1080 1083 1088 1111 +hsync -vsync
+	mode, err = calcCvtModeInfo(1920, 1080, 60, true)
+	require.NoError(t, err)
+	assert.Equal(t, randr.ModeInfo{
+		Width:      1920,
+		Height:     1080,
+		DotClock:   138500000,
+		HSyncStart: 1968,
+		HSyncEnd:   2000,
+		HTotal:     2080,
+		VSyncStart: 1083,
+		VSyncEnd:   1088,
+		VTotal:     1111,
+		Name:       "1920x1080R_60.00",
+		ModeFlags:  randr.ModeFlagHsyncPositive | randr.ModeFlagVsyncNegative,
+	}, mode)
+
+	// 与 cvt 1024 768 75 的结果相同
+	// Modeline "1024x768_75.00"   82.00  1024 1088 1192 1360  768 771 775 805 -hsync +vsync
+	mode, err = calcCvtModeInfo(1024, 768, 75, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(82000000), mode.DotClock)
+	assert.Equal(t, []uint16{1088, 1192, 1360, 771, 775, 805},
+		[]uint16{mode.HSyncStart, mode.HSyncEnd, mode.HTotal, mode.VSyncStart, mode.VSyncEnd, mode.VTotal})
+
+	_, err = calcCvtModeInfo(100, 1080, 60, false)
+	assert.Error(t, err)
+	_, err = calcCvtModeInfo(1920, 1080, 0, false)
+	assert.Error(t, err)
+}
+
+func TestSysConfigCustomResolutions(t *testing.T) {
+	cfg := &SysConfig{}
+	res := &SysCustomResolution{Width: 1920, Height: 1080, RefreshRate: 60}
+	assert.True(t, cfg.addCustomResolution("a|v1", res))
+	assert.False(t, cfg.addCustomResolution("a|v1", &SysCustomResolution{Width: 1920, Height: 1080, RefreshRate: 60.001}))
+	assert.True(t, cfg.addCustomResolution("a|v1", &SysCustomResolution{Width: 1920, Height: 1080, RefreshRate: 60,
+		ReducedBlanking: true}))
+	assert.Len(t, cfg.getCustomResolutions("a|v1"), 2)
+
+	assert.True(t, cfg.removeCustomResolution("a|v1", res))
+	assert.False(t, cfg.removeCustomResolution("a|v1", res))
+	assert.Len(t, cfg.getCustomResolutions("a|v1"), 1)
+
+	cfg.CustomResolutions["b|v1"] = []*SysCustomResolution{nil, {Width: 1, Height: 1, RefreshRate: 60}}
+	cfg.fixCustomResolutions()
+	assert.NotContains(t, cfg.CustomResolutions, "b|v1")
+	assert.Contains(t, cfg.CustomResolutions, "a|v1")
+}
+
+func Test_isNewModeFiltered(t *testing.T) {
+	custom, err := calcCvtModeInfo(1920, 1080, 60, false)
+	require.NoError(t, err)
+	// 显示器已经有时序相同的模式，自定义模式会被过滤掉
+	native := custom
+	native.Id = 0x50
+	native.Name = "1920x1080"
+	modes := []ModeInfo{toModeInfo(native)}
+	assert.True(t, isNewModeFiltered(modes, modes[0], custom, filterModeInfos))
+
+	other, err := calcCvtModeInfo(1280, 720, 60, false)
+	require.NoError(t, err)
+	assert.False(t, isNewModeFiltered(modes, modes[0], other, filterModeInfos))
+	// 已有的模式不受影响
+	assert.Len(t, modes, 1)
+}
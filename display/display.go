@@ -70,6 +70,14 @@ func (h *scaleFactorsHelper) GetScaleFactors() (map[string]float64, error) {
 	return rootCfg.Config.ScaleFactors, nil
 }
 
+// GetRenderScaleFactor 返回变换缩放使用的渲染缩放比，ok 为 false 表示没有使用变换缩放。
+func (h *scaleFactorsHelper) GetRenderScaleFactor() (float64, bool) {
+	if _dpy == nil {
+		return 0, false
+	}
+	return _dpy.getRenderScaleFactor()
+}
+
 func (h *scaleFactorsHelper) SetChangedCb(fn func(factors map[string]float64) error) {
 	h.changedCb = fn
 }
@@ -87,6 +95,15 @@ func (m *Manager) setScaleFactors(factors map[string]float64) error {
 	if err != nil {
 		logger.Warning(err)
 	}
+	if m.sysConfig.Config.TransformScaling && !_useWayland {
+		// 各显示器的 transform 缩放比跟着改变
+		go func() {
+			err := m.reapplyDisplayConfig()
+			if err != nil {
+				logger.Warning(err)
+			}
+		}()
+	}
 	return err
 }
 
@@ -126,6 +143,22 @@ func Start(service *dbusutil.Service) error {
 				err = m.setColorTempMode(mode)
 				return dbusutil.ToError(err)
 			})
+			if err != nil {
+				logger.Warning(err)
+			}
+			err = so.SetWriteCallback(m, "AutoBrightness", func(write *dbusutil.PropertyWrite) *dbus.Error {
+				value, ok := write.Value.(bool)
+				if !ok {
+					err := errors.New("Type is not bool")
+					logger.Warning(err)
+					return dbusutil.ToError(err)
+				}
+				err := m.setAutoBrightness(value)
+				return dbusutil.ToError(err)
+			})
+			if err != nil {
+				logger.Warning(err)
+			}
 		}
 
 		err = service.RequestName(dbusServiceName)
@@ -147,7 +180,13 @@ func StartPart2() error {
 
 	if !_greeterMode {
 		controlRedshift("disable")
+		m.listenNightLightSettingsChanged()
+		m.initColorTempInhibitors()
+		m.initLocation()
 		m.applyColorTempConfig(m.DisplayMode)
+		if m.sysBus != nil {
+			go m.initAutoBrightness(m.sysBus)
+		}
 	}
 
 	return nil
@@ -222,6 +222,102 @@ func (v *Manager) emitPropChangedSupportColorTemperature(value bool) error {
 	return v.service.EmitPropertyChanged(v, "SupportColorTemperature", value)
 }
 
+func (v *Manager) setPropTransformScaling(value bool) (changed bool) {
+	if v.TransformScaling != value {
+		v.TransformScaling = value
+		v.emitPropChangedTransformScaling(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedTransformScaling(value bool) error {
+	return v.service.EmitPropertyChanged(v, "TransformScaling", value)
+}
+
+func (v *Manager) setPropMirrorStrategy(value string) (changed bool) {
+	if v.MirrorStrategy != value {
+		v.MirrorStrategy = value
+		v.emitPropChangedMirrorStrategy(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedMirrorStrategy(value string) error {
+	return v.service.EmitPropertyChanged(v, "MirrorStrategy", value)
+}
+
+func (v *Manager) setPropAutoBrightness(value bool) (changed bool) {
+	if v.AutoBrightness != value {
+		v.AutoBrightness = value
+		v.emitPropChangedAutoBrightness(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedAutoBrightness(value bool) error {
+	return v.service.EmitPropertyChanged(v, "AutoBrightness", value)
+}
+
+func (v *Manager) setPropHasAmbientLightSensor(value bool) (changed bool) {
+	if v.HasAmbientLightSensor != value {
+		v.HasAmbientLightSensor = value
+		v.emitPropChangedHasAmbientLightSensor(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedHasAmbientLightSensor(value bool) error {
+	return v.service.EmitPropertyChanged(v, "HasAmbientLightSensor", value)
+}
+
+func (v *Manager) setPropInhibitors(value []ColorTemperatureInhibitor) {
+	v.Inhibitors = value
+	v.emitPropChangedInhibitors(value)
+}
+
+func (v *Manager) emitPropChangedInhibitors(value []ColorTemperatureInhibitor) error {
+	return v.service.EmitPropertyChanged(v, "Inhibitors", value)
+}
+
+func (v *Manager) setPropLocationSource(value string) (changed bool) {
+	if v.LocationSource != value {
+		v.LocationSource = value
+		v.emitPropChangedLocationSource(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedLocationSource(value string) error {
+	return v.service.EmitPropertyChanged(v, "LocationSource", value)
+}
+
+func (v *Manager) setPropMatchedRule(value string) (changed bool) {
+	if v.MatchedRule != value {
+		v.MatchedRule = value
+		v.emitPropChangedMatchedRule(value)
+		return true
+	}
+	return false
+}
+
+func (v *Manager) emitPropChangedMatchedRule(value string) error {
+	return v.service.EmitPropertyChanged(v, "MatchedRule", value)
+}
+
+func (v *Manager) setPropProviders(value []ProviderInfo) {
+	v.Providers = value
+	v.emitPropChangedProviders(value)
+}
+
+func (v *Manager) emitPropChangedProviders(value []ProviderInfo) error {
+	return v.service.EmitPropertyChanged(v, "Providers", value)
+}
+
 func (v *Monitor) setPropID(value uint32) (changed bool) {
 	if v.ID != value {
 		v.ID = value
@@ -546,3 +642,146 @@ func (v *Monitor) setPropAvailableFillModes(value strv.Strv) (changed bool) {
 func (v *Monitor) emitPropChangedAvailableFillModes(value strv.Strv) error {
 	return v.service.EmitPropertyChanged(v, "AvailableFillModes", value)
 }
+
+func (v *Monitor) setPropColorProfile(value string) (changed bool) {
+	if v.ColorProfile != value {
+		v.ColorProfile = value
+		v.emitPropChangedColorProfile(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedColorProfile(value string) error {
+	return v.service.EmitPropertyChanged(v, "ColorProfile", value)
+}
+
+func (v *Monitor) setPropColorTemperatureMode(value int32) (changed bool) {
+	if v.ColorTemperatureMode != value {
+		v.ColorTemperatureMode = value
+		v.emitPropChangedColorTemperatureMode(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedColorTemperatureMode(value int32) error {
+	return v.service.EmitPropertyChanged(v, "ColorTemperatureMode", value)
+}
+
+func (v *Monitor) setPropColorTemperatureManual(value int32) (changed bool) {
+	if v.ColorTemperatureManual != value {
+		v.ColorTemperatureManual = value
+		v.emitPropChangedColorTemperatureManual(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedColorTemperatureManual(value int32) error {
+	return v.service.EmitPropertyChanged(v, "ColorTemperatureManual", value)
+}
+
+func (v *Monitor) setPropBroadcastRGB(value string) (changed bool) {
+	if v.BroadcastRGB != value {
+		v.BroadcastRGB = value
+		v.emitPropChangedBroadcastRGB(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedBroadcastRGB(value string) error {
+	return v.service.EmitPropertyChanged(v, "BroadcastRGB", value)
+}
+
+func (v *Monitor) setPropMaxBpc(value uint32) (changed bool) {
+	if v.MaxBpc != value {
+		v.MaxBpc = value
+		v.emitPropChangedMaxBpc(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedMaxBpc(value uint32) error {
+	return v.service.EmitPropertyChanged(v, "MaxBpc", value)
+}
+
+func (v *Monitor) setPropContentType(value string) (changed bool) {
+	if v.ContentType != value {
+		v.ContentType = value
+		v.emitPropChangedContentType(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedContentType(value string) error {
+	return v.service.EmitPropertyChanged(v, "ContentType", value)
+}
+
+func (v *Monitor) setPropVrrCapable(value bool) (changed bool) {
+	if v.VrrCapable != value {
+		v.VrrCapable = value
+		v.emitPropChangedVrrCapable(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedVrrCapable(value bool) error {
+	return v.service.EmitPropertyChanged(v, "VrrCapable", value)
+}
+
+func (v *Monitor) setPropUnderscanHorizontal(value uint32) (changed bool) {
+	if v.UnderscanHorizontal != value {
+		v.UnderscanHorizontal = value
+		v.emitPropChangedUnderscanHorizontal(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedUnderscanHorizontal(value uint32) error {
+	return v.service.EmitPropertyChanged(v, "UnderscanHorizontal", value)
+}
+
+func (v *Monitor) setPropUnderscanVertical(value uint32) (changed bool) {
+	if v.UnderscanVertical != value {
+		v.UnderscanVertical = value
+		v.emitPropChangedUnderscanVertical(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedUnderscanVertical(value uint32) error {
+	return v.service.EmitPropertyChanged(v, "UnderscanVertical", value)
+}
+
+func (v *Monitor) setPropUnderscanAppliedHorizontal(value uint32) (changed bool) {
+	if v.UnderscanAppliedHorizontal != value {
+		v.UnderscanAppliedHorizontal = value
+		v.emitPropChangedUnderscanAppliedHorizontal(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedUnderscanAppliedHorizontal(value uint32) error {
+	return v.service.EmitPropertyChanged(v, "UnderscanAppliedHorizontal", value)
+}
+
+func (v *Monitor) setPropUnderscanAppliedVertical(value uint32) (changed bool) {
+	if v.UnderscanAppliedVertical != value {
+		v.UnderscanAppliedVertical = value
+		v.emitPropChangedUnderscanAppliedVertical(value)
+		return true
+	}
+	return false
+}
+
+func (v *Monitor) emitPropChangedUnderscanAppliedVertical(value uint32) error {
+	return v.service.EmitPropertyChanged(v, "UnderscanAppliedVertical", value)
+}
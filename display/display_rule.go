@@ -0,0 +1,618 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/startdde/display/edid"
+)
+
+// 热插拔规则：类似 kanshi 的 profile，规则文件 displayRulesFile 中按顺序定义多条规则，
+// 连接的显示器改变时，在 applyDisplayConfig 中选择第一条匹配的规则，按规则生成显示配置并保存，
+// 没有匹配的规则时使用保存的配置或者默认配置。对于同一组显示器只选择一次，之后的修改按普通配置处理。
+
+// DisplayRules 规则文件的内容
+type DisplayRules struct {
+	Rules []*DisplayRule
+}
+
+// DisplayRule 一条热插拔规则，匹配条件都满足时规则匹配
+type DisplayRule struct {
+	Name string
+	// 内置显示器的盖子是否合上，为 nil 时不限
+	LidClosed *bool `json:",omitempty"`
+	// 连接的显示器数量的范围，为 0 时不限
+	MinMonitors int `json:",omitempty"`
+	MaxMonitors int `json:",omitempty"`
+	// 为 true 时每个连接的显示器都要与 Outputs 中的一项匹配
+	Exact bool `json:",omitempty"`
+	// 显示模式，mirror、extend 或者 onlyone，为空时使用当前的显示模式
+	DisplayMode string `json:",omitempty"`
+	// 每一项匹配一个不同的显示器
+	Outputs []*DisplayRuleOutput
+}
+
+// DisplayRuleOutput 匹配一个显示器的条件和对它的设置，匹配条件为空时不限，可以使用 * 和 ? 通配符。
+type DisplayRuleOutput struct {
+	// 接口类型或者名称，比如 HDMI、eDP、HDMI-1，不区分大小写
+	Connector string `json:",omitempty"`
+	// EDID 中的厂商 PNP ID，比如 DEL
+	Vendor string `json:",omitempty"`
+	// EDID 中的显示器名称
+	Model string `json:",omitempty"`
+	// EDID 中的序列号
+	Serial string `json:",omitempty"`
+
+	// 是否启用，为 nil 时启用
+	Enabled *bool `json:",omitempty"`
+	Primary bool  `json:",omitempty"`
+	// 模式的尺寸，不考虑旋转，为 0 时使用最佳模式，镜像模式下不使用
+	Width       uint16  `json:",omitempty"`
+	Height      uint16  `json:",omitempty"`
+	RefreshRate float64 `json:",omitempty"`
+	// 在屏幕上的位置，只在扩展模式下使用，为 nil 时排列在设置了位置的显示器右边
+	X *int16 `json:",omitempty"`
+	Y *int16 `json:",omitempty"`
+	// randr 的旋转值，为 0 时不旋转，镜像模式下不使用
+	Rotation uint16 `json:",omitempty"`
+}
+
+// DisplayRuleInfo 是 ListDisplayRules 返回的规则，MatchLidClosed 为 false 时不限盖子的状态。
+type DisplayRuleInfo struct {
+	Name           string
+	MatchLidClosed bool
+	LidClosed      bool
+	MinMonitors    int32
+	MaxMonitors    int32
+	Exact          bool
+	DisplayMode    string
+	Outputs        []DisplayRuleOutputInfo
+}
+
+// DisplayRuleOutputInfo 是 DisplayRuleInfo 中的一项，HasPosition 为 false 时没有设置位置。
+type DisplayRuleOutputInfo struct {
+	Connector   string
+	Vendor      string
+	Model       string
+	Serial      string
+	Enabled     bool
+	Primary     bool
+	Width       uint16
+	Height      uint16
+	RefreshRate float64
+	HasPosition bool
+	X           int16
+	Y           int16
+	Rotation    uint16
+}
+
+func (rule *DisplayRule) toInfo() DisplayRuleInfo {
+	info := DisplayRuleInfo{
+		Name:        rule.Name,
+		MinMonitors: int32(rule.MinMonitors),
+		MaxMonitors: int32(rule.MaxMonitors),
+		Exact:       rule.Exact,
+		DisplayMode: rule.DisplayMode,
+		Outputs:     make([]DisplayRuleOutputInfo, len(rule.Outputs)),
+	}
+	if rule.LidClosed != nil {
+		info.MatchLidClosed = true
+		info.LidClosed = *rule.LidClosed
+	}
+	for i, output := range rule.Outputs {
+		outputInfo := DisplayRuleOutputInfo{
+			Connector:   output.Connector,
+			Vendor:      output.Vendor,
+			Model:       output.Model,
+			Serial:      output.Serial,
+			Enabled:     output.isEnabled(),
+			Primary:     output.Primary,
+			Width:       output.Width,
+			Height:      output.Height,
+			RefreshRate: output.RefreshRate,
+			Rotation:    output.Rotation,
+		}
+		if output.X != nil && output.Y != nil {
+			outputInfo.HasPosition = true
+			outputInfo.X, outputInfo.Y = *output.X, *output.Y
+		}
+		info.Outputs[i] = outputInfo
+	}
+	return info
+}
+
+func loadDisplayRules(filename string) ([]*DisplayRule, error) {
+	// #nosec G304
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var rules DisplayRules
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range rules.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule %d is null", i)
+		}
+		err = rule.check()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d %q: %w", i, rule.Name, err)
+		}
+	}
+	return rules.Rules, nil
+}
+
+func (rule *DisplayRule) check() error {
+	if rule.Name == "" {
+		return errors.New("name is empty")
+	}
+	if len(rule.Outputs) == 0 {
+		return errors.New("outputs is empty")
+	}
+	if rule.MaxMonitors != 0 && rule.MaxMonitors < rule.MinMonitors {
+		return fmt.Errorf("invalid monitors range [%d, %d]", rule.MinMonitors, rule.MaxMonitors)
+	}
+	_, err := parseRuleDisplayMode(rule.DisplayMode)
+	if err != nil {
+		return err
+	}
+	numPrimary := 0
+	for i, output := range rule.Outputs {
+		if output == nil {
+			return fmt.Errorf("output %d is null", i)
+		}
+		if (output.Width == 0) != (output.Height == 0) {
+			return fmt.Errorf("output %d: width and height should be set together", i)
+		}
+		switch output.Rotation {
+		case 0, randr.RotationRotate0, randr.RotationRotate90, randr.RotationRotate180, randr.RotationRotate270:
+		default:
+			return fmt.Errorf("output %d: invalid rotation %d", i, output.Rotation)
+		}
+		if output.Primary {
+			if !output.isEnabled() {
+				return fmt.Errorf("output %d: primary output is disabled", i)
+			}
+			numPrimary++
+		}
+	}
+	if numPrimary > 1 {
+		return errors.New("more than one primary output")
+	}
+	return nil
+}
+
+// parseRuleDisplayMode 把规则中的显示模式转换为 DisplayModeMirror 等，为空时返回 DisplayModeInvalid。
+func parseRuleDisplayMode(mode string) (byte, error) {
+	switch strings.ToLower(mode) {
+	case "":
+		return DisplayModeInvalid, nil
+	case "mirror":
+		return DisplayModeMirror, nil
+	case "extend":
+		return DisplayModeExtend, nil
+	case "onlyone":
+		return DisplayModeOnlyOne, nil
+	}
+	return DisplayModeInvalid, fmt.Errorf("invalid display mode %q", mode)
+}
+
+func (o *DisplayRuleOutput) isEnabled() bool {
+	return o.Enabled == nil || *o.Enabled
+}
+
+// ruleOutputInfo 用于匹配规则的显示器信息
+type ruleOutputInfo struct {
+	name      string
+	connector string
+	vendor    string
+	model     string
+	serial    string
+}
+
+// getConnectorType 从 output 名称获取接口类型，比如 HDMI-A-0 的接口类型是 HDMI。
+func getConnectorType(name string) string {
+	connector, _, _ := strings.Cut(name, "-")
+	return connector
+}
+
+func getRuleOutputInfo(monitor *Monitor) ruleOutputInfo {
+	monitor.PropsMu.RLock()
+	info := ruleOutputInfo{
+		name:      monitor.Name,
+		connector: getConnectorType(monitor.Name),
+		vendor:    monitor.Manufacturer,
+		model:     monitor.Model,
+	}
+	edidData := monitor.edid
+	monitor.PropsMu.RUnlock()
+
+	if len(edidData) == 0 {
+		return info
+	}
+	edidInfo, err := edid.Decode(edidData)
+	if err != nil {
+		logger.Debugf("failed to decode edid of %s: %v", info.name, err)
+		return info
+	}
+	info.vendor = edidInfo.ManufacturerId
+	if edidInfo.MonitorName != "" {
+		info.model = edidInfo.MonitorName
+	}
+	if edidInfo.SerialNumberString != "" {
+		info.serial = edidInfo.SerialNumberString
+	} else if edidInfo.SerialNumber != 0 {
+		info.serial = strconv.FormatUint(uint64(edidInfo.SerialNumber), 10)
+	}
+	return info
+}
+
+func matchRulePattern(pattern, value string) bool {
+	if pattern == "" || pattern == value {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func (o *DisplayRuleOutput) match(info ruleOutputInfo) bool {
+	if o.Connector != "" {
+		connector := strings.ToLower(o.Connector)
+		if !matchRulePattern(connector, strings.ToLower(info.name)) &&
+			!matchRulePattern(connector, strings.ToLower(info.connector)) {
+			return false
+		}
+	}
+	return matchRulePattern(o.Vendor, info.vendor) &&
+		matchRulePattern(o.Model, info.model) &&
+		matchRulePattern(o.Serial, info.serial)
+}
+
+// match 检查规则是否与连接的显示器匹配，返回 Outputs 中每一项对应的显示器在 infos 中的序号。
+func (rule *DisplayRule) match(infos []ruleOutputInfo, lidClosed bool) ([]int, bool) {
+	if rule.LidClosed != nil && *rule.LidClosed != lidClosed {
+		return nil, false
+	}
+	if len(infos) < rule.MinMonitors || (rule.MaxMonitors != 0 && len(infos) > rule.MaxMonitors) {
+		return nil, false
+	}
+	if len(rule.Outputs) > len(infos) || (rule.Exact && len(rule.Outputs) != len(infos)) {
+		return nil, false
+	}
+
+	matched := make([]int, len(rule.Outputs))
+	used := make([]bool, len(infos))
+	// 按顺序为每一项找一个还没有用到的显示器，找不到时回溯
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(rule.Outputs) {
+			return true
+		}
+		for j, info := range infos {
+			if used[j] || !rule.Outputs[i].match(info) {
+				continue
+			}
+			used[j] = true
+			matched[i] = j
+			if assign(i + 1) {
+				return true
+			}
+			used[j] = false
+		}
+		return false
+	}
+	if !assign(0) {
+		return nil, false
+	}
+	return matched, true
+}
+
+// findDisplayRule 返回第一条匹配的规则
+func findDisplayRule(rules []*DisplayRule, infos []ruleOutputInfo, lidClosed bool) (*DisplayRule, []int) {
+	for _, rule := range rules {
+		matched, ok := rule.match(infos, lidClosed)
+		if ok {
+			return rule, matched
+		}
+	}
+	return nil, nil
+}
+
+// applyRuleOutputs 把规则中对每个显示器的设置应用到 configs 中，matched[i] 是与 outputs[i] 匹配的显示器。
+func applyRuleOutputs(configs SysMonitorConfigs, mode byte, outputs []*DisplayRuleOutput, matched Monitors) {
+	primaryUuid := ""
+	var positioned []*SysMonitorConfig
+	for i, output := range outputs {
+		monitor := matched[i]
+		cfg := configs.getByUuid(monitor.uuid)
+		if cfg == nil {
+			continue
+		}
+		if mode != DisplayModeOnlyOne {
+			cfg.Enabled = output.isEnabled()
+		}
+		if output.Primary {
+			primaryUuid = monitor.uuid
+		}
+
+		// 配置中的宽和高是经过旋转调整的
+		width, height := cfg.Width, cfg.Height
+		swapWidthHeightWithRotation(cfg.Rotation, &width, &height)
+		rate := cfg.RefreshRate
+		if mode != DisplayModeMirror {
+			if output.Width != 0 {
+				width, height = output.Width, output.Height
+			}
+			if output.Rotation != 0 {
+				cfg.Rotation = output.Rotation
+			}
+		}
+		if output.RefreshRate != 0 {
+			rate = output.RefreshRate
+		}
+		modeInfo := getFirstModeBySizeRate(monitor.Modes, width, height, rate)
+		if modeInfo.isZero() {
+			modeInfo = getFirstModeBySize(monitor.Modes, width, height)
+		}
+		if modeInfo.isZero() {
+			logger.Warningf("rule mode %dx%d@%v not found for %s", width, height, rate, monitor.Name)
+		} else {
+			width, height, rate = modeInfo.Width, modeInfo.Height, modeInfo.Rate
+		}
+		swapWidthHeightWithRotation(cfg.Rotation, &width, &height)
+		cfg.Width, cfg.Height, cfg.RefreshRate = width, height, rate
+
+		if mode == DisplayModeExtend && output.X != nil && output.Y != nil {
+			cfg.X, cfg.Y = *output.X, *output.Y
+			positioned = append(positioned, cfg)
+		}
+	}
+
+	// 主屏
+	if primaryUuid != "" {
+		configs.setPrimary(primaryUuid)
+	}
+	hasPrimary := false
+	for _, cfg := range configs {
+		if cfg.Primary && cfg.Enabled {
+			hasPrimary = true
+			break
+		}
+	}
+	if !hasPrimary {
+		for _, cfg := range configs {
+			if cfg.Enabled {
+				configs.setPrimary(cfg.UUID)
+				break
+			}
+		}
+	}
+
+	if mode != DisplayModeExtend {
+		return
+	}
+	// 没有设置位置的显示器按顺序排列在设置了位置的显示器右边
+	xOffset := 0
+	for _, cfg := range positioned {
+		if cfg.Enabled && int(cfg.X)+int(cfg.Width) > xOffset {
+			xOffset = int(cfg.X) + int(cfg.Width)
+		}
+	}
+	for _, cfg := range configs {
+		if !cfg.Enabled || sysMonitorConfigsContains(positioned, cfg) {
+			continue
+		}
+		cfg.X = int16(xOffset)
+		cfg.Y = 0
+		xOffset += int(cfg.Width)
+	}
+}
+
+func sysMonitorConfigsContains(configs []*SysMonitorConfig, cfg *SysMonitorConfig) bool {
+	for _, c := range configs {
+		if c == cfg {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDisplayRules 加载规则文件，文件不存在时没有规则。
+func (m *Manager) loadDisplayRules() error {
+	rules, err := loadDisplayRules(displayRulesFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	logger.Debugf("load %d display rules", len(rules))
+	m.displayRulesMu.Lock()
+	m.displayRules = rules
+	m.displayRulesMu.Unlock()
+	return nil
+}
+
+// reloadDisplayRules 重新加载规则文件，并对当前连接的显示器重新选择规则。
+func (m *Manager) reloadDisplayRules() error {
+	err := m.loadDisplayRules()
+	if err != nil {
+		return err
+	}
+	m.displayRulesMu.Lock()
+	m.displayRulesMonitorsId = monitorsId{}
+	m.displayRulesReloaded = true
+	m.displayRulesMu.Unlock()
+
+	m.applySaveMu.Lock()
+	m.applyConfig(false, nil)
+	m.applySaveMu.Unlock()
+	return nil
+}
+
+func (m *Manager) listDisplayRules() []DisplayRuleInfo {
+	m.displayRulesMu.Lock()
+	defer m.displayRulesMu.Unlock()
+	result := make([]DisplayRuleInfo, len(m.displayRules))
+	for i, rule := range m.displayRules {
+		result[i] = rule.toInfo()
+	}
+	return result
+}
+
+// matchDisplayRule 连接的显示器改变后第一次应用配置时选择规则，
+// 返回的 Monitors 中第 i 个显示器与规则 Outputs 中的第 i 项匹配。
+// 对于同一组显示器已经选择过规则，或者已经保存了这组显示器的配置时返回 nil，除非刚重新加载了规则。
+// 返回的规则应用成功后要调用 finishDisplayRule，失败时下次应用配置会再次选择。
+func (m *Manager) matchDisplayRule(monitorsId monitorsId, monitors Monitors) (*DisplayRule, Monitors) {
+	m.displayRulesMu.Lock()
+	if monitorsId == m.displayRulesMonitorsId {
+		m.displayRulesMu.Unlock()
+		return nil, nil
+	}
+	rules := m.displayRules
+	reloaded := m.displayRulesReloaded
+	m.displayRulesMu.Unlock()
+
+	var rule *DisplayRule
+	var matchedMonitors Monitors
+	if len(rules) > 0 && !reloaded && m.hasSysScreenConfig(monitorsId) {
+		logger.Debug("skip display rules, screen config exists:", monitorsId.v1)
+	} else if len(rules) > 0 {
+		// 按名称排序，使多个显示器都能匹配同一项时结果是确定的
+		monitors = append(Monitors(nil), monitors...)
+		sort.Slice(monitors, func(i, j int) bool {
+			return monitors[i].Name < monitors[j].Name
+		})
+		infos := make([]ruleOutputInfo, len(monitors))
+		for i, monitor := range monitors {
+			infos[i] = getRuleOutputInfo(monitor)
+		}
+		m.PropsMu.RLock()
+		lidClosed := m.lidClosed
+		m.PropsMu.RUnlock()
+
+		var matched []int
+		rule, matched = findDisplayRule(rules, infos, lidClosed)
+		for _, idx := range matched {
+			matchedMonitors = append(matchedMonitors, monitors[idx])
+		}
+	}
+
+	if rule == nil {
+		m.finishDisplayRule(monitorsId, "")
+		return nil, nil
+	}
+	logger.Infof("display rule %q matched, monitors id: %v", rule.Name, monitorsId.v1)
+	return rule, matchedMonitors
+}
+
+// finishDisplayRule 记录已经为这组显示器选择完规则，并更新 MatchedRule 属性。
+func (m *Manager) finishDisplayRule(monitorsId monitorsId, ruleName string) {
+	m.displayRulesMu.Lock()
+	m.displayRulesMonitorsId = monitorsId
+	m.displayRulesReloaded = false
+	m.displayRulesMu.Unlock()
+
+	m.PropsMu.Lock()
+	m.setPropMatchedRule(ruleName)
+	m.PropsMu.Unlock()
+}
+
+// applyDisplayRule 按规则生成显示配置并应用，成功后保存为对应显示模式的配置。
+func (m *Manager) applyDisplayRule(rule *DisplayRule, matched Monitors, monitorsId monitorsId, monitorMap map[uint32]*Monitor,
+	options applyOptions) error {
+	monitors := getConnectedMonitors(monitorMap)
+	mode, _ := parseRuleDisplayMode(rule.DisplayMode)
+	if mode == DisplayModeInvalid {
+		m.PropsMu.RLock()
+		mode = m.DisplayMode
+		m.PropsMu.RUnlock()
+	}
+
+	var configs SysMonitorConfigs
+	var err error
+	onlyOneUuid := ""
+	if len(monitors) == 1 {
+		configs = m.buildConfigForSingle(monitors[0])
+	} else {
+		switch mode {
+		case DisplayModeMirror:
+			configs, err = m.buildConfigForModeMirror(monitors)
+		case DisplayModeExtend:
+			configs, err = m.buildConfigForModeExtend(monitors)
+		case DisplayModeOnlyOne:
+			onlyOneUuid = getRuleOnlyOneUuid(rule, matched)
+			if onlyOneUuid == "" {
+				return fmt.Errorf("rule %q has no enabled output", rule.Name)
+			}
+			configs, err = m.buildConfigForModeOnlyOne(monitors, onlyOneUuid)
+		default:
+			return fmt.Errorf("invalid display mode %v", mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	applyMode := mode
+	if len(monitors) == 1 {
+		applyMode = DisplayModeInvalid
+	}
+	applyRuleOutputs(configs, applyMode, rule.Outputs, matched)
+
+	err = m.applySysMonitorConfigs(applyMode, monitorsId, monitorMap, configs, options)
+	if err != nil {
+		return err
+	}
+
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	if applyMode == DisplayModeInvalid {
+		screenCfg.setSingleMonitorConfigs(configs)
+	} else {
+		m.setScreenMonitorConfigs(screenCfg, mode, onlyOneUuid, configs)
+		if onlyOneUuid != "" {
+			screenCfg.OnlyOneUuid = onlyOneUuid
+		}
+	}
+	screenCfg.CustomId = ""
+	m.setSysScreenConfig(monitorsId, screenCfg)
+	m.sysConfig.mu.Lock()
+	if applyMode != DisplayModeInvalid {
+		m.sysConfig.Config.DisplayMode = mode
+	}
+	err = m.saveSysConfigNoLock("display rule")
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+	m.updatePropCustomMode(monitorsId)
+	return nil
+}
+
+// getRuleOnlyOneUuid 获取规则在 OnlyOne 模式下启用的显示器，优先使用主屏。
+func getRuleOnlyOneUuid(rule *DisplayRule, matched Monitors) string {
+	uuid := ""
+	for i, output := range rule.Outputs {
+		if !output.isEnabled() {
+			continue
+		}
+		if output.Primary {
+			return matched[i].uuid
+		}
+		if uuid == "" {
+			uuid = matched[i].uuid
+		}
+	}
+	return uuid
+}
@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadDisplayRules(t *testing.T) {
+	rules, err := loadDisplayRules("./testdata/display-rules.json")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, "projector", rules[0].Name)
+	require.NotNil(t, rules[1].LidClosed)
+	assert.False(t, *rules[1].LidClosed)
+	require.NotNil(t, rules[1].Outputs[2].X)
+	assert.Equal(t, int16(2560), *rules[1].Outputs[2].X)
+
+	_, err = loadDisplayRules("./testdata/not-exist.json")
+	assert.Error(t, err)
+}
+
+func TestDisplayRule_toInfo(t *testing.T) {
+	rules, err := loadDisplayRules("./testdata/display-rules.json")
+	require.NoError(t, err)
+
+	info := rules[0].toInfo()
+	assert.Equal(t, "projector", info.Name)
+	assert.False(t, info.MatchLidClosed)
+	assert.Equal(t, "mirror", info.DisplayMode)
+	require.Len(t, info.Outputs, 2)
+	assert.True(t, info.Outputs[1].Enabled)
+	assert.True(t, info.Outputs[1].Primary)
+	assert.False(t, info.Outputs[1].HasPosition)
+
+	info = rules[1].toInfo()
+	assert.True(t, info.MatchLidClosed)
+	assert.False(t, info.LidClosed)
+	assert.Equal(t, int32(3), info.MinMonitors)
+	assert.False(t, info.Outputs[0].Enabled)
+	assert.True(t, info.Outputs[2].HasPosition)
+	assert.Equal(t, int16(2560), info.Outputs[2].X)
+	assert.Equal(t, uint16(randr.RotationRotate90), info.Outputs[2].Rotation)
+
+	assert.Equal(t, "a(sbbiibsa(ssssbbqqdbnnq))", dbus.SignatureOf([]DisplayRuleInfo{info}).String())
+}
+
+func TestDisplayRule_check(t *testing.T) {
+	disabled := false
+	assert.NoError(t, (&DisplayRule{Name: "a", Outputs: []*DisplayRuleOutput{{}}}).check())
+	assert.Error(t, (&DisplayRule{Outputs: []*DisplayRuleOutput{{}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a"}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", DisplayMode: "clone", Outputs: []*DisplayRuleOutput{{}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", MinMonitors: 3, MaxMonitors: 2, Outputs: []*DisplayRuleOutput{{}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", Outputs: []*DisplayRuleOutput{{Width: 1920}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", Outputs: []*DisplayRuleOutput{{Rotation: 3}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", Outputs: []*DisplayRuleOutput{{Primary: true, Enabled: &disabled}}}).check())
+	assert.Error(t, (&DisplayRule{Name: "a", Outputs: []*DisplayRuleOutput{{Primary: true}, {Primary: true}}}).check())
+}
+
+func Test_getConnectorType(t *testing.T) {
+	assert.Equal(t, "HDMI", getConnectorType("HDMI-1"))
+	assert.Equal(t, "HDMI", getConnectorType("HDMI-A-0"))
+	assert.Equal(t, "DP", getConnectorType("DP-1-2"))
+	assert.Equal(t, "eDP", getConnectorType("eDP-1"))
+	assert.Equal(t, "default", getConnectorType("default"))
+}
+
+func Test_findDisplayRule(t *testing.T) {
+	rules, err := loadDisplayRules("./testdata/display-rules.json")
+	require.NoError(t, err)
+
+	edp := ruleOutputInfo{name: "eDP-1", connector: "eDP"}
+	hdmi := ruleOutputInfo{name: "HDMI-1", connector: "HDMI"}
+	projector := ruleOutputInfo{name: "HDMI-2", connector: "HDMI", vendor: "EPS", model: "EPSON PJ"}
+	dp1 := ruleOutputInfo{name: "DP-1", connector: "DP", vendor: "DEL", serial: "XYZ123"}
+	dp2 := ruleOutputInfo{name: "DP-2", connector: "DP", vendor: "DEL", serial: "ABC456"}
+
+	rule, matched := findDisplayRule(rules, []ruleOutputInfo{edp, projector}, false)
+	require.NotNil(t, rule)
+	assert.Equal(t, "projector", rule.Name)
+	assert.Equal(t, []int{1, 0}, matched)
+
+	// 序列号以 ABC 开头的显示器才能匹配第二项
+	rule, matched = findDisplayRule(rules, []ruleOutputInfo{edp, dp2, dp1}, false)
+	require.NotNil(t, rule)
+	assert.Equal(t, "docked", rule.Name)
+	assert.Equal(t, []int{0, 1, 2}, matched)
+	rule, matched = findDisplayRule(rules, []ruleOutputInfo{dp2, dp1, edp}, false)
+	require.NotNil(t, rule)
+	assert.Equal(t, []int{2, 0, 1}, matched)
+
+	// 合盖时不匹配
+	rule, _ = findDisplayRule(rules, []ruleOutputInfo{edp, dp1, dp2}, true)
+	assert.Nil(t, rule)
+	// 显示器数量不够
+	rule, _ = findDisplayRule(rules, []ruleOutputInfo{edp, dp2}, false)
+	assert.Nil(t, rule)
+
+	rule, _ = findDisplayRule(rules, []ruleOutputInfo{edp, hdmi}, false)
+	require.NotNil(t, rule)
+	assert.Equal(t, "home", rule.Name)
+	// Exact 时不能有多余的显示器
+	rule, _ = findDisplayRule(rules, []ruleOutputInfo{edp, hdmi, dp1}, false)
+	assert.Nil(t, rule)
+}
+
+func newTestRuleMonitor(name, uuid string, modes ...ModeInfo) *Monitor {
+	monitor := newTestMonitor(name, true, 0, 0, modes[0].Width, modes[0].Height)
+	monitor.uuid = uuid
+	monitor.Modes = modes
+	monitor.BestMode = modes[0]
+	return monitor
+}
+
+func Test_applyRuleOutputs(t *testing.T) {
+	mode2k := ModeInfo{Id: 1, Width: 2560, Height: 1440, Rate: 60}
+	mode1080 := ModeInfo{Id: 2, Width: 1920, Height: 1080, Rate: 60}
+	mode1080r75 := ModeInfo{Id: 3, Width: 1920, Height: 1080, Rate: 75}
+	edp := newTestRuleMonitor("eDP-1", "uuid-edp", mode1080)
+	dp1 := newTestRuleMonitor("DP-1", "uuid-dp1", mode2k, mode1080)
+	dp2 := newTestRuleMonitor("DP-2", "uuid-dp2", mode2k, mode1080, mode1080r75)
+
+	newConfigs := func() SysMonitorConfigs {
+		var configs SysMonitorConfigs
+		var x int16
+		for _, monitor := range []*Monitor{edp, dp1, dp2} {
+			configs = append(configs, &SysMonitorConfig{
+				UUID:        monitor.uuid,
+				Name:        monitor.Name,
+				Enabled:     true,
+				Primary:     monitor == edp,
+				X:           x,
+				Width:       monitor.BestMode.Width,
+				Height:      monitor.BestMode.Height,
+				RefreshRate: monitor.BestMode.Rate,
+				Rotation:    randr.RotationRotate0,
+			})
+			x += int16(monitor.BestMode.Width)
+		}
+		return configs
+	}
+
+	disabled := false
+	var zero int16
+	outputs := []*DisplayRuleOutput{
+		{Enabled: &disabled},
+		{Primary: true},
+		{Width: 1920, Height: 1080, RefreshRate: 75, Rotation: randr.RotationRotate90},
+	}
+	configs := newConfigs()
+	applyRuleOutputs(configs, DisplayModeExtend, outputs, Monitors{edp, dp1, dp2})
+	assert.False(t, configs[0].Enabled)
+	assert.False(t, configs[0].Primary)
+	assert.True(t, configs[1].Primary)
+	// 没有设置位置时从左到右排列启用的显示器
+	assert.Equal(t, int16(0), configs[1].X)
+	assert.Equal(t, int16(2560), configs[2].X)
+	// 配置中的宽和高是旋转后的
+	assert.Equal(t, uint16(1080), configs[2].Width)
+	assert.Equal(t, uint16(1920), configs[2].Height)
+	assert.Equal(t, 75.0, configs[2].RefreshRate)
+	assert.Equal(t, uint16(randr.RotationRotate90), configs[2].Rotation)
+
+	// 设置了位置的显示器不动，其他的排在右边
+	x := int16(1920)
+	outputs = []*DisplayRuleOutput{
+		{X: &x, Y: &zero},
+	}
+	configs = newConfigs()
+	applyRuleOutputs(configs, DisplayModeExtend, outputs, Monitors{dp2})
+	assert.Equal(t, int16(1920), configs[2].X)
+	assert.Equal(t, int16(4480), configs[0].X)
+	assert.Equal(t, int16(6400), configs[1].X)
+	assert.True(t, configs[0].Primary)
+
+	// 禁用主屏时第一个启用的显示器成为主屏，镜像模式下不改变尺寸
+	outputs = []*DisplayRuleOutput{
+		{Enabled: &disabled},
+		{Width: 1920, Height: 1080},
+	}
+	configs = newConfigs()
+	applyRuleOutputs(configs, DisplayModeMirror, outputs, Monitors{edp, dp1})
+	assert.False(t, configs[0].Enabled)
+	assert.True(t, configs[1].Primary)
+	assert.Equal(t, uint16(2560), configs[1].Width)
+}
+
+func Test_getRuleOnlyOneUuid(t *testing.T) {
+	disabled := false
+	edp := &Monitor{uuid: "uuid-edp"}
+	hdmi := &Monitor{uuid: "uuid-hdmi"}
+	rule := &DisplayRule{Outputs: []*DisplayRuleOutput{{Enabled: &disabled}, {}}}
+	assert.Equal(t, "uuid-hdmi", getRuleOnlyOneUuid(rule, Monitors{edp, hdmi}))
+	rule = &DisplayRule{Outputs: []*DisplayRuleOutput{{}, {Primary: true}}}
+	assert.Equal(t, "uuid-hdmi", getRuleOnlyOneUuid(rule, Monitors{edp, hdmi}))
+	rule = &DisplayRule{Outputs: []*DisplayRuleOutput{{Enabled: &disabled}}}
+	assert.Equal(t, "", getRuleOnlyOneUuid(rule, Monitors{edp}))
+}
@@ -5,6 +5,7 @@
 package display
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
@@ -40,24 +41,99 @@ type SysConfig struct {
 	Screens      map[string]*SysScreenConfig
 	ScaleFactors map[string]float64 // 缩放比例
 	FillModes    map[string]string  // key 是特殊的 fillMode Key
-	Cache        SysCache
+	// 复制模式的策略，为空表示使用公共分辨率，见 mirrorStrategyFit 等
+	MirrorStrategy string `json:",omitempty"`
+	// X 环境下用 crtc transform 实现各显示器不同的缩放比
+	TransformScaling bool `json:",omitempty"`
+	// 键是显示器的 uuid
+	CustomResolutions map[string][]*SysCustomResolution `json:",omitempty"`
+	// 键是显示器的 uuid，值是 ICC 文件的路径
+	ColorProfiles map[string]string `json:",omitempty"`
+	// 键是显示器的 uuid，值是 RandR output 属性名到属性值的映射
+	OutputProperties map[string]map[string]string `json:",omitempty"`
+	// 键是显示器的 uuid
+	Underscans map[string]*SysUnderscanConfig `json:",omitempty"`
+	Cache      SysCache
 }
 
 type SysCache struct {
 	BuiltinMonitor string
 	ConnectTime    map[string]time.Time
+	// 键是显示器的 uuid，值是 EDID 中的厂商、产品代码和序列号，用于识别连接到其他接口上的同一个显示器
+	Serials map[string]string `json:",omitempty"`
 }
 
 // UserConfig v1
 type UserConfig struct {
-	Version string
-	Screens map[string]UserScreenConfig
+	Version        string
+	Screens        map[string]UserScreenConfig
+	AutoBrightness *UserAutoBrightnessConfig `json:",omitempty"`
+	// 键是显示器的 uuid，没有设置的显示器跟随全局的色温设置
+	ColorTemperatures map[string]*UserMonitorColorTempConfig `json:",omitempty"`
+	// 手动设置的位置，用于自动色温
+	Location *UserLocationConfig `json:",omitempty"`
 }
 
 func (cfg *UserConfig) fix() {
 	for _, screenConfig := range cfg.Screens {
 		screenConfig.fix()
 	}
+	cfg.AutoBrightness.fix()
+	for uuid, colorTempCfg := range cfg.ColorTemperatures {
+		if colorTempCfg == nil || !isValidColorTempMode(colorTempCfg.Mode) {
+			delete(cfg.ColorTemperatures, uuid)
+			continue
+		}
+		if !isValidColorTempValue(colorTempCfg.Manual) {
+			colorTempCfg.Manual = defaultTemperatureManual
+		}
+	}
+	if cfg.Location != nil && !isValidLocation(cfg.Location.Latitude, cfg.Location.Longitude) {
+		cfg.Location = nil
+	}
+}
+
+type UserLocationConfig struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// UserMonitorColorTempConfig 单个显示器的色温配置
+type UserMonitorColorTempConfig struct {
+	Mode   int32
+	Manual int32
+}
+
+func (c *UserMonitorColorTempConfig) clone() *UserMonitorColorTempConfig {
+	if c == nil {
+		return nil
+	}
+	cfgCp := *c
+	return &cfgCp
+}
+
+// UserAutoBrightnessConfig 自动亮度配置，Curve 为空时使用默认曲线。
+type UserAutoBrightnessConfig struct {
+	Enabled bool
+	Curve   luxBrightnessCurve `json:",omitempty"`
+}
+
+func (c *UserAutoBrightnessConfig) fix() {
+	if c == nil {
+		return
+	}
+	if c.Curve != nil && c.Curve.check() != nil {
+		c.Curve = nil
+	}
+}
+
+func (c *UserAutoBrightnessConfig) clone() *UserAutoBrightnessConfig {
+	if c == nil {
+		return nil
+	}
+	cfgCp := *c
+	cfgCp.Curve = c.Curve.clone()
+	return &cfgCp
 }
 
 type UserScreenConfig map[string]*UserMonitorModeConfig
@@ -73,6 +149,8 @@ type UserMonitorModeConfig struct {
 	ColorTemperatureMode   int32
 	ColorTemperatureManual int32
 	ColorTemperatureModeOn int32 //记录用户开启色温时的模式
+	// 定时模式的配置，为空时使用默认值
+	ColorTemperatureSchedule *UserColorTempScheduleConfig `json:",omitempty"`
 	// 以后如果有必要
 	// Monitors UserMonitorConfigs
 }
@@ -87,9 +165,30 @@ func (c *UserMonitorModeConfig) fix() {
 	if c.ColorTemperatureMode != ColorTemperatureModeNone {
 		c.ColorTemperatureModeOn = c.ColorTemperatureMode
 	}
+	if c.ColorTemperatureSchedule != nil && c.ColorTemperatureSchedule.check() != nil {
+		c.ColorTemperatureSchedule = nil
+	}
 }
 
 func (c *UserMonitorModeConfig) clone() *UserMonitorModeConfig {
+	if c == nil {
+		return nil
+	}
+	cfgCp := *c
+	cfgCp.ColorTemperatureSchedule = c.ColorTemperatureSchedule.clone()
+	return &cfgCp
+}
+
+// UserColorTempScheduleConfig 定时色温配置，在 Start 到 End 之间把色温调整为 Temperature，
+// End 不晚于 Start 时表示跨过午夜。
+type UserColorTempScheduleConfig struct {
+	Start       string // 开始时间，格式是 15:04
+	End         string // 结束时间，格式是 15:04
+	Temperature int32
+	Fade        int32 // 渐变时长，单位是分钟
+}
+
+func (c *UserColorTempScheduleConfig) clone() *UserColorTempScheduleConfig {
 	if c == nil {
 		return nil
 	}
@@ -112,6 +211,10 @@ func (m *Manager) getUserScreenConfig(monitorsId monitorsId) UserScreenConfig {
 
 	screens := m.userConfig.Screens
 	screenCfg := screens[monitorsId.v1]
+	if screenCfg == nil && monitorsId.isLidClosed() {
+		// 合盖时没有单独的设置，使用开盖时的设置
+		screenCfg = screens[monitorsId.withoutLidClosed().v1]
+	}
 	if screenCfg != nil {
 		return screenCfg.clone()
 	}
@@ -132,7 +235,7 @@ func (m *Manager) setUserScreenConfig(monitorsId monitorsId, screenCfg UserScree
 }
 
 // getSysScreenConfig 根据 monitorsId 参数返回不同的屏幕配置，不同 monitorsId 则屏幕配置不同。
-// monitorsId 代表了已连接了哪些显示器。
+// monitorsId 代表了已连接了哪些显示器。没有对应的配置时，查找同一组显示器连接在其他接口上时的配置。
 func (m *Manager) getSysScreenConfig(monitorsId monitorsId) *SysScreenConfig {
 	m.sysConfig.mu.Lock()
 	defer m.sysConfig.mu.Unlock()
@@ -142,10 +245,23 @@ func (m *Manager) getSysScreenConfig(monitorsId monitorsId) *SysScreenConfig {
 	if screenCfg != nil {
 		return screenCfg.clone()
 	}
+	// 显示器连接到了其他接口
+	screenCfg = m.sysConfig.Config.findScreenConfigByIdentity(monitorsId)
+	if screenCfg != nil {
+		return screenCfg
+	}
 
 	return &SysScreenConfig{}
 }
 
+// hasSysScreenConfig 是否保存过 monitorsId 对应的屏幕配置，包括同一组显示器连接在其他接口上时的配置。
+func (m *Manager) hasSysScreenConfig(monitorsId monitorsId) bool {
+	m.sysConfig.mu.Lock()
+	defer m.sysConfig.mu.Unlock()
+	return m.sysConfig.Config.Screens[monitorsId.v1] != nil ||
+		m.sysConfig.Config.findScreenConfigByIdentity(monitorsId) != nil
+}
+
 func (m *Manager) updateConfigUuid(monitors Monitors) {
 	m.updateSysConfigUuid(monitors)
 	m.updateUserConfigUuid(monitors)
@@ -382,6 +498,103 @@ type SysScreenConfig struct {
 	Single      *SysMonitorModeConfig            `json:",omitempty"`
 	OnlyOneMap  map[string]*SysMonitorModeConfig `json:",omitempty"`
 	OnlyOneUuid string                           `json:",omitempty"`
+	// CustomMap 用户命名保存的布局方案，键是方案名称
+	CustomMap map[string]*SysCustomModeConfig `json:",omitempty"`
+	// CustomId 当前生效的布局方案名称，布局被修改后清空
+	CustomId string `json:",omitempty"`
+}
+
+// SysCustomModeConfig 用户命名保存的布局方案，记录保存时的显示模式和各显示器配置。
+type SysCustomModeConfig struct {
+	DisplayMode byte
+	Monitors    SysMonitorConfigs
+}
+
+func (c *SysCustomModeConfig) fix() {
+	for _, monitor := range c.Monitors {
+		monitor.fix()
+	}
+}
+
+func (c *SysCustomModeConfig) clone() *SysCustomModeConfig {
+	if c == nil {
+		return nil
+	}
+	return &SysCustomModeConfig{
+		DisplayMode: c.DisplayMode,
+		Monitors:    c.Monitors.clone(),
+	}
+}
+
+func (c *SysCustomModeConfig) updateUuid(monitors Monitors) (*SysCustomModeConfig, bool) {
+	if c == nil {
+		return nil, false
+	}
+	modeCfg, changed := (&SysMonitorModeConfig{Monitors: c.Monitors}).updateUuid(monitors)
+	return &SysCustomModeConfig{
+		DisplayMode: c.DisplayMode,
+		Monitors:    modeCfg.Monitors,
+	}, changed
+}
+
+// getCustomIdList 返回排好序的布局方案名称列表
+func (c *SysScreenConfig) getCustomIdList() []string {
+	if c == nil || len(c.CustomMap) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(c.CustomMap))
+	for name := range c.CustomMap {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (c *SysScreenConfig) getCustomConfig(name string) *SysCustomModeConfig {
+	if c == nil {
+		return nil
+	}
+	return c.CustomMap[name]
+}
+
+func (c *SysScreenConfig) setCustomConfig(name string, cfg *SysCustomModeConfig) {
+	if c.CustomMap == nil {
+		c.CustomMap = make(map[string]*SysCustomModeConfig)
+	}
+	c.CustomMap[name] = cfg
+}
+
+func (c *SysScreenConfig) renameCustomConfig(name, newName string) error {
+	cfg := c.getCustomConfig(name)
+	if cfg == nil {
+		return fmt.Errorf("custom mode %q not found", name)
+	}
+	if name == newName {
+		return nil
+	}
+	if c.getCustomConfig(newName) != nil {
+		return fmt.Errorf("custom mode %q already exists", newName)
+	}
+	delete(c.CustomMap, name)
+	c.CustomMap[newName] = cfg
+	if c.CustomId == name {
+		c.CustomId = newName
+	}
+	return nil
+}
+
+func (c *SysScreenConfig) deleteCustomConfig(name string) error {
+	if c.getCustomConfig(name) == nil {
+		return fmt.Errorf("custom mode %q not found", name)
+	}
+	delete(c.CustomMap, name)
+	if len(c.CustomMap) == 0 {
+		c.CustomMap = nil
+	}
+	if c.CustomId == name {
+		c.CustomId = ""
+	}
+	return nil
 }
 
 func isCurrentVersionUuid(uuid string) bool {
@@ -422,6 +635,16 @@ func (c *SysScreenConfig) updateUuid(monitors Monitors) (result *SysScreenConfig
 		}
 	}
 
+	if len(c.CustomMap) > 0 {
+		result.CustomMap = make(map[string]*SysCustomModeConfig, len(c.CustomMap))
+		for name, config := range c.CustomMap {
+			changed := false
+			result.CustomMap[name], changed = config.updateUuid(monitors)
+			overallChanged = overallChanged || changed
+		}
+	}
+	result.CustomId = c.CustomId
+
 	changed := false
 	result.OnlyOneUuid, changed = updateUuid(c.OnlyOneUuid, monitors)
 	overallChanged = overallChanged || changed
@@ -470,6 +693,17 @@ func (c *SysMonitorModeConfig) updateUuid(monitors Monitors) (*SysMonitorModeCon
 		// 放弃更新 uuid
 		result.Monitors = monitorConfigs
 	}
+	if len(c.MirrorGroups) > 0 {
+		result.MirrorGroups = make([][]string, len(c.MirrorGroups))
+		for i, group := range c.MirrorGroups {
+			result.MirrorGroups[i] = make([]string, len(group))
+			for j, uuid := range group {
+				changed := false
+				result.MirrorGroups[i][j], changed = updateUuid(uuid, monitors)
+				hasChanged = hasChanged || changed
+			}
+		}
+	}
 	return &result, hasChanged
 }
 
@@ -482,6 +716,7 @@ func (c *SysScreenConfig) clone() *SysScreenConfig {
 		Extend:      c.Extend.clone(),
 		Single:      c.Single.clone(),
 		OnlyOneUuid: c.OnlyOneUuid,
+		CustomId:    c.CustomId,
 	}
 	if len(c.OnlyOneMap) > 0 {
 		result.OnlyOneMap = make(map[string]*SysMonitorModeConfig, len(c.OnlyOneMap))
@@ -489,6 +724,12 @@ func (c *SysScreenConfig) clone() *SysScreenConfig {
 			result.OnlyOneMap[uuid] = config.clone()
 		}
 	}
+	if len(c.CustomMap) > 0 {
+		result.CustomMap = make(map[string]*SysCustomModeConfig, len(c.CustomMap))
+		for name, config := range c.CustomMap {
+			result.CustomMap[name] = config.clone()
+		}
+	}
 	return result
 }
 
@@ -509,10 +750,24 @@ func (c *SysScreenConfig) fix() {
 			delete(c.OnlyOneMap, uuid)
 		}
 	}
+	for name, config := range c.CustomMap {
+		if config != nil {
+			config.fix()
+		} else {
+			delete(c.CustomMap, name)
+		}
+	}
+	if c.CustomId != "" && c.CustomMap[c.CustomId] == nil {
+		c.CustomId = ""
+	}
 }
 
 type SysMonitorModeConfig struct {
 	Monitors SysMonitorConfigs
+	// 扩展模式下的镜像组，每一组是显示器的 uuid，只在扩展模式的配置中使用
+	MirrorGroups [][]string `json:",omitempty"`
+	// 选择各显示器的模式时复制模式的策略，为空是 common，只在复制模式的配置中使用
+	MirrorStrategy string `json:",omitempty"`
 }
 
 func (c *SysMonitorModeConfig) fix() {
@@ -526,7 +781,9 @@ func (c *SysMonitorModeConfig) clone() *SysMonitorModeConfig {
 		return nil
 	}
 	return &SysMonitorModeConfig{
-		Monitors: c.Monitors.clone(),
+		Monitors:       c.Monitors.clone(),
+		MirrorGroups:   cloneMirrorGroups(c.MirrorGroups),
+		MirrorStrategy: c.MirrorStrategy,
 	}
 }
 
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysScreenConfigCustomMode(t *testing.T) {
+	sc := &SysScreenConfig{}
+	assert.Nil(t, sc.getCustomIdList())
+
+	sc.setCustomConfig("presentation", &SysCustomModeConfig{
+		DisplayMode: DisplayModeMirror,
+		Monitors:    SysMonitorConfigs{{UUID: "a|v1", Enabled: true}},
+	})
+	sc.setCustomConfig("desk", &SysCustomModeConfig{
+		DisplayMode: DisplayModeExtend,
+		Monitors:    SysMonitorConfigs{{UUID: "a|v1", Enabled: true}, {UUID: "b|v1", Enabled: true, X: 1920}},
+	})
+	sc.CustomId = "desk"
+	assert.Equal(t, []string{"desk", "presentation"}, sc.getCustomIdList())
+
+	// clone 是深拷贝
+	scCp := sc.clone()
+	scCp.CustomMap["desk"].Monitors[1].X = 0
+	assert.Equal(t, int16(1920), sc.CustomMap["desk"].Monitors[1].X)
+	assert.Equal(t, "desk", scCp.CustomId)
+
+	require.Error(t, sc.renameCustomConfig("desk", "presentation"))
+	require.Error(t, sc.renameCustomConfig("none", "other"))
+	require.NoError(t, sc.renameCustomConfig("desk", "docked"))
+	assert.Equal(t, "docked", sc.CustomId)
+	assert.Equal(t, []string{"docked", "presentation"}, sc.getCustomIdList())
+
+	require.NoError(t, sc.deleteCustomConfig("docked"))
+	assert.Equal(t, "", sc.CustomId)
+	require.Error(t, sc.deleteCustomConfig("docked"))
+
+	sc.CustomId = "docked"
+	sc.CustomMap["nil"] = nil
+	sc.fix()
+	assert.Equal(t, "", sc.CustomId)
+	assert.Equal(t, []string{"presentation"}, sc.getCustomIdList())
+}
+
+func Test_checkCustomModeName(t *testing.T) {
+	assert.NoError(t, checkCustomModeName("docked vertical"))
+	assert.Error(t, checkCustomModeName(""))
+	assert.Error(t, checkCustomModeName("  "))
+	assert.Error(t, checkCustomModeName(" desk"))
+}
+
+func TestSysConfigMergeFrom(t *testing.T) {
+	newCfg := func() *SysConfig {
+		return &SysConfig{
+			DisplayMode: DisplayModeExtend,
+			Screens: map[string]*SysScreenConfig{
+				"a|v1,b|v1": {
+					Mirror: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{{UUID: "a|v1", Enabled: true}}},
+					Extend: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{{UUID: "a|v1", Enabled: true}}},
+				},
+			},
+			ScaleFactors: map[string]float64{"HDMI-1": 1},
+		}
+	}
+	imported := &SysConfig{
+		DisplayMode: DisplayModeMirror,
+		Screens: map[string]*SysScreenConfig{
+			"a|v1,b|v1": {
+				Extend: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{{UUID: "b|v1", Enabled: true}}},
+			},
+			"c|v1": {
+				Single: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{{UUID: "c|v1", Enabled: true}}},
+			},
+		},
+		ScaleFactors: map[string]float64{"eDP-1": 1.25},
+		FillModes:    map[string]string{"c|v1:1920x1080": "Full"},
+	}
+
+	cfg := newCfg()
+	cfg.mergeFrom(imported, importPolicyMerge)
+	assert.Equal(t, DisplayModeMirror, cfg.DisplayMode)
+	screenCfg := cfg.Screens["a|v1,b|v1"]
+	assert.NotNil(t, screenCfg.Mirror)
+	assert.Equal(t, "b|v1", screenCfg.Extend.Monitors[0].UUID)
+	assert.NotNil(t, cfg.Screens["c|v1"])
+	assert.Equal(t, map[string]float64{"HDMI-1": 1, "eDP-1": 1.25}, cfg.ScaleFactors)
+	assert.Equal(t, "Full", cfg.FillModes["c|v1:1920x1080"])
+
+	cfg = newCfg()
+	cfg.mergeFrom(imported, importPolicyReplace)
+	screenCfg = cfg.Screens["a|v1,b|v1"]
+	assert.Nil(t, screenCfg.Mirror)
+	assert.Equal(t, "b|v1", screenCfg.Extend.Monitors[0].UUID)
+
+	// 修改导入的配置不影响合并后的配置
+	imported.Screens["c|v1"].Single.Monitors[0].X = 100
+	assert.Equal(t, int16(0), cfg.Screens["c|v1"].Single.Monitors[0].X)
+
+	// 导入的默认策略 common 覆盖本地的策略
+	cfg = newCfg()
+	cfg.MirrorStrategy = mirrorStrategyFit
+	cfg.mergeFrom(imported, importPolicyMerge)
+	assert.Equal(t, "", cfg.MirrorStrategy)
+}
+
+func Test_parseExportedConfig(t *testing.T) {
+	_, err := parseExportedConfig(`{"Version":"2.0","SysVersion":"1.0"}`)
+	assert.Error(t, err)
+	_, err = parseExportedConfig(`{"Version":"1.0","SysVersion":"0.1"}`)
+	assert.Error(t, err)
+
+	cfg, err := parseExportedConfig(`{"Version":"1.0","SysVersion":"1.0",
+		"SysConfig":{"DisplayMode":0,"Cache":{"BuiltinMonitor":"eDP-1"}},
+		"UserConfig":{"Version":"1.0","Screens":{"a|v1":{"Single":{"ColorTemperatureMode":9,"ColorTemperatureManual":6000}}}}}`)
+	require.NoError(t, err)
+	// 经过 fix 修正
+	assert.Equal(t, DisplayModeMirror, cfg.SysConfig.DisplayMode)
+	assert.Equal(t, "", cfg.SysConfig.Cache.BuiltinMonitor)
+	assert.Equal(t, int32(defaultTemperatureMode), cfg.UserConfig.Screens["a|v1"][KeySingle].ColorTemperatureMode)
+	assert.Equal(t, int32(6000), cfg.UserConfig.Screens["a|v1"][KeySingle].ColorTemperatureManual)
+
+	// 不合法的条目被删除
+	cfg, err = parseExportedConfig(`{"Version":"1.0","SysVersion":"1.0",
+		"SysConfig":{"DisplayMode":1,"MirrorStrategy":"zoom",
+			"Underscans":{"a|v1":{"Horizontal":48,"Vertical":27},"b|v1":{"Horizontal":5000,"Vertical":0},"c|v1":null},
+			"ColorProfiles":{"a|v1":"/usr/share/color/icc/a.icc","b|v1":"b.icc","c|v1":""},
+			"OutputProperties":{"a|v1":{"max bpc":"10","Broadcast RGB":"Wrong","scaling mode":"Full"},"b|v1":{"max bpc":"-1"}}},
+		"UserConfig":{"Version":"1.0"}}`)
+	require.NoError(t, err)
+	sysCfg := cfg.SysConfig
+	assert.Equal(t, "", sysCfg.MirrorStrategy)
+	assert.Equal(t, map[string]*SysUnderscanConfig{"a|v1": {Horizontal: 48, Vertical: 27}}, sysCfg.Underscans)
+	assert.Equal(t, map[string]string{"a|v1": "/usr/share/color/icc/a.icc"}, sysCfg.ColorProfiles)
+	assert.Equal(t, map[string]map[string]string{"a|v1": {outputPropMaxBpc: "10"}}, sysCfg.OutputProperties)
+}
+
+func TestUserConfigMonitorColorTemp(t *testing.T) {
+	cfg := &UserConfig{}
+	assert.Nil(t, cfg.getMonitorColorTemp("a|v1"))
+
+	cfg.setMonitorColorTemp("a|v1", &UserMonitorColorTempConfig{Mode: ColorTemperatureModeNone, Manual: 6500})
+	cfg.setMonitorColorTemp("b|v1", &UserMonitorColorTempConfig{Mode: ColorTemperatureModeManual, Manual: 4000})
+	colorTempCfg := cfg.getMonitorColorTemp("b|v1")
+	require.NotNil(t, colorTempCfg)
+	assert.Equal(t, int32(4000), colorTempCfg.Manual)
+	// 返回的是副本
+	colorTempCfg.Manual = 5000
+	assert.Equal(t, int32(4000), cfg.ColorTemperatures["b|v1"].Manual)
+
+	cfg.setMonitorColorTemp("a|v1", nil)
+	assert.Nil(t, cfg.getMonitorColorTemp("a|v1"))
+
+	cfg.ColorTemperatures["c|v1"] = &UserMonitorColorTempConfig{Mode: ColorTemperatureModeGlobal}
+	cfg.ColorTemperatures["d|v1"] = &UserMonitorColorTempConfig{Mode: ColorTemperatureModeAuto, Manual: 1}
+	cfg.ColorTemperatures["e|v1"] = nil
+	cfg.fix()
+	assert.Len(t, cfg.ColorTemperatures, 2)
+	assert.Equal(t, int32(defaultTemperatureManual), cfg.ColorTemperatures["d|v1"].Manual)
+
+	current := &UserConfig{}
+	current.mergeFrom(cfg, importPolicyMerge)
+	assert.Equal(t, cfg.ColorTemperatures, current.ColorTemperatures)
+	cfg.ColorTemperatures["b|v1"].Manual = 3000
+	assert.Equal(t, int32(4000), current.ColorTemperatures["b|v1"].Manual)
+
+	assert.True(t, isValidMonitorColorTempMode(ColorTemperatureModeGlobal))
+	assert.True(t, isValidMonitorColorTempMode(ColorTemperatureModeSchedule))
+	assert.False(t, isValidMonitorColorTempMode(-2))
+}
@@ -0,0 +1,360 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package edid
+
+import (
+	"math"
+)
+
+// CEA-861 数据块的类型
+const (
+	ceaBlockAudio         = 1
+	ceaBlockVideo         = 2
+	ceaBlockVendor        = 3
+	ceaBlockSpeaker       = 4
+	ceaBlockExtended      = 7
+	ceaExtColorimetry     = 5
+	ceaExtHDRStatic       = 6
+	ceaExtYCbCr420Video   = 14
+	ouiHDMI               = 0x000c03
+	ouiHDMIForum          = 0xc45dd8
+	hdrLuminanceUnitScale = 50.0
+)
+
+// CEAInfo 是 CEA-861 扩展块的信息
+type CEAInfo struct {
+	Revision   int
+	Underscan  bool
+	BasicAudio bool
+	YCbCr444   bool
+	YCbCr422   bool
+	// 视频数据块中的短视频描述符
+	VideoModes []CEAVideoMode `json:",omitempty"`
+	// 只支持 YCbCr 4:2:0 的视频模式
+	YCbCr420VideoModes []CEAVideoMode `json:",omitempty"`
+	AudioFormats       []AudioFormat  `json:",omitempty"`
+	HDMI               bool
+	HDMIForum          bool
+	// 扩展的色域，比如 BT2020RGB、DCI-P3
+	Colorimetry       []string           `json:",omitempty"`
+	HDRStaticMetadata *HDRStaticMetadata `json:",omitempty"`
+	DetailedTimings   []DetailedTiming   `json:",omitempty"`
+}
+
+// CEAVideoMode 是短视频描述符，VIC 不在已知的表中时 Width 和 Height 为 0。
+type CEAVideoMode struct {
+	VIC         int
+	Native      bool `json:",omitempty"`
+	Width       int
+	Height      int
+	RefreshRate float64
+	Interlaced  bool `json:",omitempty"`
+}
+
+// AudioFormat 是短音频描述符，SampleRates 的单位是 Hz。
+type AudioFormat struct {
+	Format      string
+	MaxChannels int
+	SampleRates []int
+}
+
+// HDRStaticMetadata 是 HDR 静态元数据块，亮度的单位是 cd/m²，为 0 表示没有提供。
+type HDRStaticMetadata struct {
+	// 支持的 EOTF：traditional-sdr、traditional-hdr、smpte-st2084、hlg
+	EOTFs                []string
+	StaticMetadataTypes  []int
+	MaxLuminance         float64
+	MaxFrameAvgLuminance float64
+	MinLuminance         float64
+}
+
+func decodeCEA(block []byte) *CEAInfo {
+	cea := &CEAInfo{
+		Revision: int(block[1]),
+	}
+	dtdOffset := int(block[2])
+	if cea.Revision >= 2 {
+		flags := block[3]
+		cea.Underscan = flags&0x80 != 0
+		cea.BasicAudio = flags&0x40 != 0
+		cea.YCbCr444 = flags&0x20 != 0
+		cea.YCbCr422 = flags&0x10 != 0
+	}
+
+	// 数据块从第 4 个字节开始，到详细时序的偏移为止
+	if cea.Revision >= 3 && dtdOffset > 4 && dtdOffset <= blockSize-1 {
+		data := block[4:dtdOffset]
+		for len(data) > 0 {
+			tag := data[0] >> 5
+			length := int(data[0] & 0x1f)
+			if 1+length > len(data) {
+				break
+			}
+			cea.decodeDataBlock(tag, data[1:1+length])
+			data = data[1+length:]
+		}
+	}
+
+	if dtdOffset >= 4 {
+		for offset := dtdOffset; offset+descriptorSize <= blockSize-1; offset += descriptorSize {
+			timing, ok := decodeDetailedTiming(block[offset : offset+descriptorSize])
+			if !ok {
+				break
+			}
+			cea.DetailedTimings = append(cea.DetailedTimings, timing)
+		}
+	}
+	return cea
+}
+
+func (cea *CEAInfo) decodeDataBlock(tag byte, payload []byte) {
+	switch tag {
+	case ceaBlockAudio:
+		for i := 0; i+3 <= len(payload); i += 3 {
+			cea.AudioFormats = append(cea.AudioFormats, decodeShortAudioDescriptor(payload[i:i+3]))
+		}
+	case ceaBlockVideo:
+		for _, b := range payload {
+			cea.VideoModes = append(cea.VideoModes, decodeShortVideoDescriptor(b))
+		}
+	case ceaBlockVendor:
+		if len(payload) < 3 {
+			return
+		}
+		oui := int(payload[0]) | int(payload[1])<<8 | int(payload[2])<<16
+		switch oui {
+		case ouiHDMI:
+			cea.HDMI = true
+		case ouiHDMIForum:
+			cea.HDMIForum = true
+		}
+	case ceaBlockExtended:
+		if len(payload) < 1 {
+			return
+		}
+		cea.decodeExtendedDataBlock(payload[0], payload[1:])
+	}
+}
+
+func (cea *CEAInfo) decodeExtendedDataBlock(extTag byte, payload []byte) {
+	switch extTag {
+	case ceaExtColorimetry:
+		cea.Colorimetry = decodeColorimetry(payload)
+	case ceaExtHDRStatic:
+		cea.HDRStaticMetadata = decodeHDRStaticMetadata(payload)
+	case ceaExtYCbCr420Video:
+		for _, b := range payload {
+			cea.YCbCr420VideoModes = append(cea.YCbCr420VideoModes, decodeShortVideoDescriptor(b))
+		}
+	}
+}
+
+// mergeCEA 把后面的 CEA 扩展块的信息合并到第一个中
+func mergeCEA(dst, src *CEAInfo) {
+	dst.VideoModes = append(dst.VideoModes, src.VideoModes...)
+	dst.YCbCr420VideoModes = append(dst.YCbCr420VideoModes, src.YCbCr420VideoModes...)
+	dst.AudioFormats = append(dst.AudioFormats, src.AudioFormats...)
+	dst.DetailedTimings = append(dst.DetailedTimings, src.DetailedTimings...)
+	dst.HDMI = dst.HDMI || src.HDMI
+	dst.HDMIForum = dst.HDMIForum || src.HDMIForum
+	if dst.Colorimetry == nil {
+		dst.Colorimetry = src.Colorimetry
+	}
+	if dst.HDRStaticMetadata == nil {
+		dst.HDRStaticMetadata = src.HDRStaticMetadata
+	}
+}
+
+// decodeShortVideoDescriptor 解析短视频描述符，按 CEA-861-F，129~192 的最高位表示原生模式。
+func decodeShortVideoDescriptor(b byte) CEAVideoMode {
+	vic := int(b)
+	native := false
+	if b >= 129 && b <= 192 {
+		vic = int(b & 0x7f)
+		native = true
+	}
+	mode := CEAVideoMode{
+		VIC:    vic,
+		Native: native,
+	}
+	if t, ok := ceaVideoTimings[vic]; ok {
+		mode.Width = t.Width
+		mode.Height = t.Height
+		mode.RefreshRate = t.RefreshRate
+		mode.Interlaced = t.Interlaced
+	}
+	return mode
+}
+
+var audioFormatNames = map[byte]string{
+	1:  "LPCM",
+	2:  "AC-3",
+	3:  "MPEG-1",
+	4:  "MP3",
+	5:  "MPEG-2",
+	6:  "AAC LC",
+	7:  "DTS",
+	8:  "ATRAC",
+	9:  "DSD",
+	10: "E-AC-3",
+	11: "DTS-HD",
+	12: "MAT",
+	13: "DST",
+	14: "WMA Pro",
+}
+
+var audioSampleRates = []int{32000, 44100, 48000, 88200, 96000, 176400, 192000}
+
+func decodeShortAudioDescriptor(data []byte) AudioFormat {
+	code := (data[0] >> 3) & 0x0f
+	format, ok := audioFormatNames[code]
+	if !ok {
+		format = "unknown"
+	}
+	af := AudioFormat{
+		Format:      format,
+		MaxChannels: int(data[0]&0x07) + 1,
+	}
+	for i, rate := range audioSampleRates {
+		if data[1]&(1<<uint(i)) != 0 {
+			af.SampleRates = append(af.SampleRates, rate)
+		}
+	}
+	return af
+}
+
+var colorimetryNames = []string{
+	"xvYCC601", "xvYCC709", "sYCC601", "opYCC601",
+	"opRGB", "BT2020cYCC", "BT2020YCC", "BT2020RGB",
+}
+
+func decodeColorimetry(payload []byte) []string {
+	if len(payload) < 1 {
+		return nil
+	}
+	var result []string
+	for i, name := range colorimetryNames {
+		if payload[0]&(1<<uint(i)) != 0 {
+			result = append(result, name)
+		}
+	}
+	if len(payload) >= 2 && payload[1]&0x80 != 0 {
+		result = append(result, "DCI-P3")
+	}
+	return result
+}
+
+var eotfNames = []string{"traditional-sdr", "traditional-hdr", "smpte-st2084", "hlg"}
+
+// decodeHDRStaticMetadata 解析 HDR 静态元数据块，亮度按 CTA-861.3 的公式计算。
+func decodeHDRStaticMetadata(payload []byte) *HDRStaticMetadata {
+	if len(payload) < 2 {
+		return nil
+	}
+	md := &HDRStaticMetadata{}
+	for i, name := range eotfNames {
+		if payload[0]&(1<<uint(i)) != 0 {
+			md.EOTFs = append(md.EOTFs, name)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if payload[1]&(1<<uint(i)) != 0 {
+			md.StaticMetadataTypes = append(md.StaticMetadataTypes, i+1)
+		}
+	}
+	if len(payload) >= 3 && payload[2] != 0 {
+		md.MaxLuminance = hdrLuminance(payload[2])
+	}
+	if len(payload) >= 4 && payload[3] != 0 {
+		md.MaxFrameAvgLuminance = hdrLuminance(payload[3])
+	}
+	if len(payload) >= 5 && payload[4] != 0 && md.MaxLuminance > 0 {
+		cv := float64(payload[4]) / 255
+		md.MinLuminance = md.MaxLuminance * cv * cv / 100
+	}
+	return md
+}
+
+func hdrLuminance(cv byte) float64 {
+	return hdrLuminanceUnitScale * math.Pow(2, float64(cv)/32)
+}
+
+// CEA-861 中常用的 VIC 对应的模式
+var ceaVideoTimings = map[int]Timing{
+	1:   {640, 480, 60, false},
+	2:   {720, 480, 60, false},
+	3:   {720, 480, 60, false},
+	4:   {1280, 720, 60, false},
+	5:   {1920, 1080, 60, true},
+	6:   {1440, 480, 60, true},
+	7:   {1440, 480, 60, true},
+	8:   {1440, 240, 60, false},
+	9:   {1440, 240, 60, false},
+	10:  {2880, 480, 60, true},
+	11:  {2880, 480, 60, true},
+	12:  {2880, 240, 60, false},
+	13:  {2880, 240, 60, false},
+	14:  {1440, 480, 60, false},
+	15:  {1440, 480, 60, false},
+	16:  {1920, 1080, 60, false},
+	17:  {720, 576, 50, false},
+	18:  {720, 576, 50, false},
+	19:  {1280, 720, 50, false},
+	20:  {1920, 1080, 50, true},
+	21:  {1440, 576, 50, true},
+	22:  {1440, 576, 50, true},
+	23:  {1440, 288, 50, false},
+	24:  {1440, 288, 50, false},
+	25:  {2880, 576, 50, true},
+	26:  {2880, 576, 50, true},
+	27:  {2880, 288, 50, false},
+	28:  {2880, 288, 50, false},
+	29:  {1440, 576, 50, false},
+	30:  {1440, 576, 50, false},
+	31:  {1920, 1080, 50, false},
+	32:  {1920, 1080, 24, false},
+	33:  {1920, 1080, 25, false},
+	34:  {1920, 1080, 30, false},
+	35:  {2880, 480, 60, false},
+	36:  {2880, 480, 60, false},
+	37:  {2880, 576, 50, false},
+	38:  {2880, 576, 50, false},
+	39:  {1920, 1080, 50, true},
+	40:  {1920, 1080, 100, true},
+	41:  {1280, 720, 100, false},
+	42:  {720, 576, 100, false},
+	43:  {720, 576, 100, false},
+	44:  {1440, 576, 100, true},
+	45:  {1440, 576, 100, true},
+	46:  {1920, 1080, 120, true},
+	47:  {1280, 720, 120, false},
+	48:  {720, 480, 120, false},
+	49:  {720, 480, 120, false},
+	50:  {1440, 480, 120, true},
+	51:  {1440, 480, 120, true},
+	52:  {720, 576, 200, false},
+	53:  {720, 576, 200, false},
+	54:  {1440, 576, 200, true},
+	55:  {1440, 576, 200, true},
+	56:  {720, 480, 240, false},
+	57:  {720, 480, 240, false},
+	58:  {1440, 480, 240, true},
+	59:  {1440, 480, 240, true},
+	60:  {1280, 720, 24, false},
+	61:  {1280, 720, 25, false},
+	62:  {1280, 720, 30, false},
+	63:  {1920, 1080, 120, false},
+	64:  {1920, 1080, 100, false},
+	93:  {3840, 2160, 24, false},
+	94:  {3840, 2160, 25, false},
+	95:  {3840, 2160, 30, false},
+	96:  {3840, 2160, 50, false},
+	97:  {3840, 2160, 60, false},
+	98:  {4096, 2160, 24, false},
+	99:  {4096, 2160, 25, false},
+	100: {4096, 2160, 30, false},
+	101: {4096, 2160, 50, false},
+	102: {4096, 2160, 60, false},
+}
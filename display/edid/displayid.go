@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package edid
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DisplayID 数据块的类型，0x2x 是 DisplayID 2.0 的
+const (
+	displayIdBlockDisplayParams   = 0x01
+	displayIdBlockTypeITiming     = 0x03
+	displayIdBlockDisplayParamsV2 = 0x21
+	displayIdBlockTypeVIITiming   = 0x22
+
+	displayIdTimingSize = 20
+)
+
+// DisplayIDInfo 是 EDID 中 DisplayID 扩展块的信息
+type DisplayIDInfo struct {
+	Version     string
+	ProductType int
+	// 图像尺寸，单位是毫米
+	WidthMm  float64
+	HeightMm float64
+	// 原生分辨率
+	NativeWidth     int
+	NativeHeight    int
+	DetailedTimings []DetailedTiming `json:",omitempty"`
+}
+
+// decodeDisplayID 解析 DisplayID 段，data 从段的版本号开始。
+func decodeDisplayID(data []byte) *DisplayIDInfo {
+	if len(data) < 4 {
+		return nil
+	}
+	version := data[0]
+	length := int(data[1])
+	info := &DisplayIDInfo{
+		Version:     fmt.Sprintf("%d.%d", version>>4, version&0x0f),
+		ProductType: int(data[2]),
+	}
+
+	end := 4 + length
+	if end > len(data) {
+		end = len(data)
+	}
+	blocks := data[4:end]
+	for len(blocks) >= 3 {
+		tag := blocks[0]
+		payloadLen := int(blocks[2])
+		if tag == 0 && payloadLen == 0 {
+			// 填充
+			break
+		}
+		if 3+payloadLen > len(blocks) {
+			break
+		}
+		info.decodeDataBlock(tag, blocks[3:3+payloadLen])
+		blocks = blocks[3+payloadLen:]
+	}
+	return info
+}
+
+func (info *DisplayIDInfo) decodeDataBlock(tag byte, payload []byte) {
+	switch tag {
+	case displayIdBlockDisplayParams, displayIdBlockDisplayParamsV2:
+		if len(payload) < 8 {
+			return
+		}
+		// 尺寸单位是 0.1 毫米
+		info.WidthMm = float64(binary.LittleEndian.Uint16(payload[0:2])) / 10
+		info.HeightMm = float64(binary.LittleEndian.Uint16(payload[2:4])) / 10
+		info.NativeWidth = int(binary.LittleEndian.Uint16(payload[4:6]))
+		info.NativeHeight = int(binary.LittleEndian.Uint16(payload[6:8]))
+	case displayIdBlockTypeITiming, displayIdBlockTypeVIITiming:
+		// Type I 像素时钟的单位是 10 kHz，Type VII 是 1 kHz
+		clockUnit := uint32(10)
+		if tag == displayIdBlockTypeVIITiming {
+			clockUnit = 1
+		}
+		for i := 0; i+displayIdTimingSize <= len(payload); i += displayIdTimingSize {
+			timing := decodeDisplayIdTiming(payload[i:i+displayIdTimingSize], clockUnit)
+			info.DetailedTimings = append(info.DetailedTimings, timing)
+		}
+	}
+}
+
+// decodeDisplayIdTiming 解析 20 字节的详细时序，各个值都是实际值减 1。
+func decodeDisplayIdTiming(data []byte, clockUnit uint32) DetailedTiming {
+	get16 := func(offset int) int {
+		return int(binary.LittleEndian.Uint16(data[offset:offset+2])&0x7fff) + 1
+	}
+	clock := (uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16) + 1
+	t := DetailedTiming{
+		PixelClock:  clock * clockUnit,
+		Interlaced:  data[3]&0x10 != 0,
+		HActive:     get16(4),
+		HBlank:      get16(6),
+		HSyncOffset: get16(8),
+		HSyncWidth:  get16(10),
+		VActive:     get16(12),
+		VBlank:      get16(14),
+		VSyncOffset: get16(16),
+		VSyncWidth:  get16(18),
+	}
+	t.RefreshRate = calcRefreshRate(t.PixelClock, t.HActive+t.HBlank, t.VActive+t.VBlank, t.Interlaced)
+	return t
+}
@@ -0,0 +1,464 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package edid 解析显示器的 EDID 1.x 数据，包括 CEA-861 和 DisplayID 扩展块。
+package edid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	blockSize       = 128
+	descriptorSize  = 18
+	descriptorCount = 4
+	descriptorStart = 54
+
+	extTagCEA       = 0x02
+	extTagDisplayID = 0x70
+)
+
+var header = []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+var (
+	ErrTooShort  = errors.New("edid is too short")
+	ErrBadHeader = errors.New("invalid edid header")
+)
+
+// Info 是 EDID 解析的结果
+type Info struct {
+	Version string
+	// 三个字母的厂商 PNP ID，比如 DEL
+	ManufacturerId   string
+	ManufacturerName string
+	ProductCode      uint16
+	// 基本块中的数字序列号，0 表示没有
+	SerialNumber uint32
+	// 显示描述符中的序列号字符串
+	SerialNumberString string
+	MonitorName        string
+	// 显示描述符中的其他字符串
+	Texts []string `json:",omitempty"`
+
+	// 生产的周，0 表示未知
+	ManufactureWeek int
+	// 生产的年份，ModelYear 不为 0 时为 0
+	ManufactureYear int
+	ModelYear       int
+
+	Digital bool
+	// 数字接口每个颜色分量的位数，0 表示未定义
+	BitDepth int
+	// 数字接口类型，比如 HDMI-a、DisplayPort
+	VideoInterface string
+
+	// 物理尺寸，单位是厘米，为 0 时表示尺寸不确定，比如投影仪
+	WidthCm  int
+	HeightCm int
+	// 0 表示在扩展块中定义
+	Gamma float64
+
+	DPMSStandby   bool
+	DPMSSuspend   bool
+	DPMSActiveOff bool
+	SRGBDefault   bool
+	// 首选时序是否为原生分辨率
+	PreferredTimingIsNative bool
+	ContinuousFrequency     bool
+
+	ColorPrimaries ColorPrimaries
+
+	EstablishedTimings []Timing
+	StandardTimings    []Timing
+	// 基本块和扩展块中所有的详细时序
+	DetailedTimings []DetailedTiming
+	// 原生的时序，一般是第一个详细时序
+	NativeTiming *DetailedTiming `json:",omitempty"`
+	RangeLimits  *RangeLimits    `json:",omitempty"`
+
+	ExtensionCount int
+	CEA            *CEAInfo       `json:",omitempty"`
+	DisplayID      *DisplayIDInfo `json:",omitempty"`
+	// 校验和错误的块的序号，0 是基本块
+	BadChecksumBlocks []int `json:",omitempty"`
+}
+
+// Chromaticity 是 CIE 1931 xy 色度坐标
+type Chromaticity struct {
+	X float64
+	Y float64
+}
+
+type ColorPrimaries struct {
+	Red   Chromaticity
+	Green Chromaticity
+	Blue  Chromaticity
+	White Chromaticity
+}
+
+// Timing 是只有分辨率和刷新率的时序，来自固定时序和标准时序
+type Timing struct {
+	Width       int
+	Height      int
+	RefreshRate float64
+	Interlaced  bool `json:",omitempty"`
+}
+
+// DetailedTiming 是详细时序描述符，PixelClock 的单位是 kHz，WidthMm 和 HeightMm 是图像尺寸。
+type DetailedTiming struct {
+	PixelClock  uint32
+	HActive     int
+	HBlank      int
+	HSyncOffset int
+	HSyncWidth  int
+	VActive     int
+	VBlank      int
+	VSyncOffset int
+	VSyncWidth  int
+	WidthMm     int
+	HeightMm    int
+	Interlaced  bool
+	RefreshRate float64
+}
+
+// RangeLimits 是显示器范围限制描述符，刷新率单位是 Hz，行频单位是 kHz。
+type RangeLimits struct {
+	MinVRate      int
+	MaxVRate      int
+	MinHRate      int
+	MaxHRate      int
+	MaxPixelClock int // MHz
+}
+
+// Decode 解析 EDID 数据。头部错误或者数据不完整时返回错误，校验和错误只记录在 BadChecksumBlocks 中，
+// 因为不少显示器的 EDID 校验和是错的，但是内容可用。
+func Decode(data []byte) (*Info, error) {
+	if len(data) < blockSize {
+		return nil, ErrTooShort
+	}
+	if !bytes.Equal(data[:len(header)], header) {
+		return nil, ErrBadHeader
+	}
+
+	info := &Info{}
+	info.decodeBase(data[:blockSize])
+
+	blockCount := len(data) / blockSize
+	for i := 0; i < blockCount; i++ {
+		if !checksumValid(data[i*blockSize : (i+1)*blockSize]) {
+			info.BadChecksumBlocks = append(info.BadChecksumBlocks, i)
+		}
+	}
+
+	for i := 1; i < blockCount; i++ {
+		block := data[i*blockSize : (i+1)*blockSize]
+		switch block[0] {
+		case extTagCEA:
+			cea := decodeCEA(block)
+			if info.CEA == nil {
+				info.CEA = cea
+			} else {
+				mergeCEA(info.CEA, cea)
+			}
+			info.DetailedTimings = append(info.DetailedTimings, cea.DetailedTimings...)
+		case extTagDisplayID:
+			displayId := decodeDisplayID(block[1:])
+			if displayId == nil {
+				continue
+			}
+			if info.DisplayID == nil {
+				info.DisplayID = displayId
+			} else {
+				info.DisplayID.DetailedTimings = append(info.DisplayID.DetailedTimings,
+					displayId.DetailedTimings...)
+			}
+			info.DetailedTimings = append(info.DetailedTimings, displayId.DetailedTimings...)
+		}
+	}
+
+	if len(info.DetailedTimings) > 0 {
+		native := info.DetailedTimings[0]
+		info.NativeTiming = &native
+	}
+	return info, nil
+}
+
+func checksumValid(block []byte) bool {
+	var sum byte
+	for _, b := range block {
+		sum += b
+	}
+	return sum == 0
+}
+
+func (info *Info) decodeBase(block []byte) {
+	info.ManufacturerId = decodePnpId(block[8:10])
+	info.ManufacturerName = GetVendorName(info.ManufacturerId)
+	info.ProductCode = binary.LittleEndian.Uint16(block[10:12])
+	info.SerialNumber = binary.LittleEndian.Uint32(block[12:16])
+
+	week := int(block[16])
+	year := int(block[17]) + 1990
+	if week == 0xff {
+		info.ModelYear = year
+	} else {
+		info.ManufactureYear = year
+		if week <= 54 {
+			info.ManufactureWeek = week
+		}
+	}
+
+	major, minor := block[18], block[19]
+	info.Version = fmt.Sprintf("%d.%d", major, minor)
+	v14 := major > 1 || (major == 1 && minor >= 4)
+
+	input := block[20]
+	info.Digital = input&0x80 != 0
+	if info.Digital && v14 {
+		info.BitDepth = decodeBitDepth((input >> 4) & 0x07)
+		info.VideoInterface = decodeVideoInterface(input & 0x0f)
+	}
+
+	info.WidthCm = int(block[21])
+	info.HeightCm = int(block[22])
+	if block[23] != 0xff {
+		info.Gamma = float64(int(block[23])+100) / 100
+	}
+
+	features := block[24]
+	info.DPMSStandby = features&0x80 != 0
+	info.DPMSSuspend = features&0x40 != 0
+	info.DPMSActiveOff = features&0x20 != 0
+	info.SRGBDefault = features&0x04 != 0
+	info.PreferredTimingIsNative = features&0x02 != 0
+	info.ContinuousFrequency = features&0x01 != 0
+
+	info.ColorPrimaries = decodeColorPrimaries(block[25:35])
+	info.EstablishedTimings = decodeEstablishedTimings(block[35:38])
+	for i := 0; i < 8; i++ {
+		timing, ok := decodeStandardTiming(block[38+i*2:40+i*2], v14 || minor >= 3)
+		if ok {
+			info.StandardTimings = append(info.StandardTimings, timing)
+		}
+	}
+
+	for i := 0; i < descriptorCount; i++ {
+		offset := descriptorStart + i*descriptorSize
+		info.decodeDescriptor(block[offset:offset+descriptorSize], v14 || minor >= 3)
+	}
+	info.ExtensionCount = int(block[126])
+}
+
+// decodePnpId 解析厂商 ID，每个字母 5 位，1 表示 A。
+func decodePnpId(data []byte) string {
+	v := uint16(data[0])<<8 | uint16(data[1])
+	var id [3]byte
+	for i := 0; i < 3; i++ {
+		c := (v >> uint(10-5*i)) & 0x1f
+		if c < 1 || c > 26 {
+			return ""
+		}
+		id[i] = byte(c) + 'A' - 1
+	}
+	return string(id[:])
+}
+
+func decodeBitDepth(v byte) int {
+	if v == 0 || v == 7 {
+		return 0
+	}
+	// 1 -> 6, 2 -> 8 ... 6 -> 16
+	return 4 + int(v)*2
+}
+
+func decodeVideoInterface(v byte) string {
+	switch v {
+	case 1:
+		return "DVI"
+	case 2:
+		return "HDMI-a"
+	case 3:
+		return "HDMI-b"
+	case 4:
+		return "MDDI"
+	case 5:
+		return "DisplayPort"
+	}
+	return ""
+}
+
+// decodeColorPrimaries 解析 10 位的色度坐标，data[0] 和 data[1] 是各个坐标的低 2 位。
+func decodeColorPrimaries(data []byte) ColorPrimaries {
+	get := func(msb byte, lsb byte, shift uint) float64 {
+		v := int(msb)<<2 | int(lsb>>shift)&0x03
+		return float64(v) / 1024
+	}
+	lo1, lo2 := data[0], data[1]
+	return ColorPrimaries{
+		Red:   Chromaticity{X: get(data[2], lo1, 6), Y: get(data[3], lo1, 4)},
+		Green: Chromaticity{X: get(data[4], lo1, 2), Y: get(data[5], lo1, 0)},
+		Blue:  Chromaticity{X: get(data[6], lo2, 6), Y: get(data[7], lo2, 4)},
+		White: Chromaticity{X: get(data[8], lo2, 2), Y: get(data[9], lo2, 0)},
+	}
+}
+
+// 固定时序，按位从高到低排列
+var establishedTimings = [17]Timing{
+	{720, 400, 70, false},
+	{720, 400, 88, false},
+	{640, 480, 60, false},
+	{640, 480, 67, false},
+	{640, 480, 72, false},
+	{640, 480, 75, false},
+	{800, 600, 56, false},
+	{800, 600, 60, false},
+	{800, 600, 72, false},
+	{800, 600, 75, false},
+	{832, 624, 75, false},
+	{1024, 768, 87, true},
+	{1024, 768, 60, false},
+	{1024, 768, 70, false},
+	{1024, 768, 75, false},
+	{1280, 1024, 75, false},
+	{1152, 870, 75, false},
+}
+
+func decodeEstablishedTimings(data []byte) []Timing {
+	var result []Timing
+	for i := range establishedTimings {
+		if data[i/8]&(0x80>>uint(i%8)) != 0 {
+			result = append(result, establishedTimings[i])
+		}
+	}
+	return result
+}
+
+// decodeStandardTiming 解析标准时序，aspect16x10 为 false 时（EDID 1.3 之前）宽高比 0 表示 1:1。
+func decodeStandardTiming(data []byte, aspect16x10 bool) (Timing, bool) {
+	if (data[0] == 0x01 && data[1] == 0x01) || data[0] == 0x00 {
+		return Timing{}, false
+	}
+	width := (int(data[0]) + 31) * 8
+	var height int
+	switch data[1] >> 6 {
+	case 0:
+		if aspect16x10 {
+			height = width * 10 / 16
+		} else {
+			height = width
+		}
+	case 1:
+		height = width * 3 / 4
+	case 2:
+		height = width * 4 / 5
+	case 3:
+		height = width * 9 / 16
+	}
+	return Timing{
+		Width:       width,
+		Height:      height,
+		RefreshRate: float64(data[1]&0x3f) + 60,
+	}, true
+}
+
+func (info *Info) decodeDescriptor(data []byte, v13 bool) {
+	if data[0] != 0 || data[1] != 0 {
+		if timing, ok := decodeDetailedTiming(data); ok {
+			info.DetailedTimings = append(info.DetailedTimings, timing)
+		}
+		return
+	}
+
+	switch data[3] {
+	case 0xff:
+		info.SerialNumberString = decodeDescriptorText(data[5:])
+	case 0xfe:
+		text := decodeDescriptorText(data[5:])
+		if text != "" {
+			info.Texts = append(info.Texts, text)
+		}
+	case 0xfc:
+		info.MonitorName = decodeDescriptorText(data[5:])
+	case 0xfd:
+		info.RangeLimits = decodeRangeLimits(data)
+	case 0xfa:
+		for i := 0; i < 6; i++ {
+			timing, ok := decodeStandardTiming(data[5+i*2:7+i*2], v13)
+			if ok {
+				info.StandardTimings = append(info.StandardTimings, timing)
+			}
+		}
+	}
+}
+
+// decodeDescriptorText 解析描述符中的字符串，以 0x0a 结束，后面用空格填充。
+func decodeDescriptorText(data []byte) string {
+	if idx := bytes.IndexByte(data, 0x0a); idx >= 0 {
+		data = data[:idx]
+	}
+	var sb strings.Builder
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7f {
+			sb.WriteByte(b)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func decodeRangeLimits(data []byte) *RangeLimits {
+	flags := data[4]
+	offset := func(bit uint) int {
+		if flags&(1<<bit) != 0 {
+			return 255
+		}
+		return 0
+	}
+	return &RangeLimits{
+		MinVRate:      int(data[5]) + offset(0),
+		MaxVRate:      int(data[6]) + offset(1),
+		MinHRate:      int(data[7]) + offset(2),
+		MaxHRate:      int(data[8]) + offset(3),
+		MaxPixelClock: int(data[9]) * 10,
+	}
+}
+
+// decodeDetailedTiming 解析 18 字节的详细时序描述符
+func decodeDetailedTiming(data []byte) (DetailedTiming, bool) {
+	clock := uint32(binary.LittleEndian.Uint16(data[0:2])) * 10
+	if clock == 0 {
+		return DetailedTiming{}, false
+	}
+	t := DetailedTiming{
+		PixelClock:  clock,
+		HActive:     int(data[2]) | int(data[4]&0xf0)<<4,
+		HBlank:      int(data[3]) | int(data[4]&0x0f)<<8,
+		VActive:     int(data[5]) | int(data[7]&0xf0)<<4,
+		VBlank:      int(data[6]) | int(data[7]&0x0f)<<8,
+		HSyncOffset: int(data[8]) | int(data[11]&0xc0)<<2,
+		HSyncWidth:  int(data[9]) | int(data[11]&0x30)<<4,
+		VSyncOffset: int(data[10]>>4) | int(data[11]&0x0c)<<2,
+		VSyncWidth:  int(data[10]&0x0f) | int(data[11]&0x03)<<4,
+		WidthMm:     int(data[12]) | int(data[14]&0xf0)<<4,
+		HeightMm:    int(data[13]) | int(data[14]&0x0f)<<8,
+		Interlaced:  data[17]&0x80 != 0,
+	}
+	t.RefreshRate = calcRefreshRate(t.PixelClock, t.HActive+t.HBlank, t.VActive+t.VBlank, t.Interlaced)
+	return t, true
+}
+
+// calcRefreshRate 根据像素时钟（kHz）和总行列数计算刷新率。
+// 隔行扫描时 vTotal 是一场的行数，一帧有 2*vTotal+1 行，返回的是场频。
+func calcRefreshRate(clock uint32, hTotal, vTotal int, interlaced bool) float64 {
+	if hTotal == 0 || vTotal == 0 {
+		return 0
+	}
+	if interlaced {
+		return float64(clock) * 1000 * 2 / float64(hTotal*(2*vTotal+1))
+	}
+	return float64(clock) * 1000 / float64(hTotal*vTotal)
+}
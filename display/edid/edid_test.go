@@ -0,0 +1,202 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package edid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ViewSonic VA2478-H-2，带一个 CEA 扩展块
+var testEdidVA2478 = []byte{
+	0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x5a, 0x63, 0x35, 0x83, 0x45, 0xa9, 0x00, 0x00,
+	0x0f, 0x1e, 0x01, 0x03, 0x80, 0x35, 0x1e, 0x78, 0x2e, 0xdd, 0x75, 0xa5, 0x55, 0x4e, 0x9d, 0x27,
+	0x0b, 0x50, 0x54, 0xbf, 0xef, 0x80, 0xb3, 0x00, 0xa9, 0x40, 0xa9, 0xc0, 0x95, 0x00, 0x90, 0x40,
+	0x81, 0x80, 0x81, 0x40, 0x81, 0xc0, 0x02, 0x3a, 0x80, 0x18, 0x71, 0x38, 0x2d, 0x40, 0x58, 0x2c,
+	0x45, 0x00, 0x0f, 0x28, 0x21, 0x00, 0x00, 0x1e, 0x00, 0x00, 0x00, 0xfd, 0x00, 0x32, 0x4b, 0x18,
+	0x52, 0x12, 0x00, 0x0a, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x00, 0x00, 0x00, 0xfc, 0x00, 0x56,
+	0x41, 0x32, 0x34, 0x37, 0x38, 0x2d, 0x48, 0x2d, 0x32, 0x0a, 0x20, 0x20, 0x00, 0x00, 0x00, 0xff,
+	0x00, 0x56, 0x44, 0x57, 0x32, 0x30, 0x31, 0x35, 0x34, 0x33, 0x33, 0x33, 0x33, 0x0a, 0x01, 0x1f,
+	0x02, 0x03, 0x2b, 0xf1, 0x58, 0x90, 0x05, 0x04, 0x03, 0x02, 0x07, 0x06, 0x08, 0x09, 0x0e, 0x0f,
+	0x1f, 0x14, 0x13, 0x12, 0x11, 0x15, 0x16, 0x1d, 0x1e, 0x48, 0x49, 0x4a, 0x01, 0x23, 0x09, 0x7f,
+	0x07, 0x83, 0x01, 0x00, 0x00, 0x65, 0x03, 0x0c, 0x00, 0x10, 0x00, 0x02, 0x3a, 0x80, 0x18, 0x71,
+	0x38, 0x2d, 0x40, 0x58, 0x2c, 0x45, 0x00, 0x0f, 0x28, 0x21, 0x00, 0x00, 0x1e, 0x01, 0x1d, 0x80,
+	0x18, 0x71, 0x1c, 0x16, 0x20, 0x58, 0x2c, 0x25, 0x00, 0x0f, 0x28, 0x21, 0x00, 0x00, 0x9e, 0x01,
+	0x1d, 0x00, 0x72, 0x51, 0xd0, 0x1e, 0x20, 0x6e, 0x28, 0x55, 0x00, 0x0f, 0x28, 0x21, 0x00, 0x00,
+	0x1e, 0x8c, 0x0a, 0xd0, 0x8a, 0x20, 0xe0, 0x2d, 0x10, 0x10, 0x3e, 0x96, 0x00, 0x0f, 0x28, 0x21,
+	0x00, 0x00, 0x18, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x37,
+}
+
+// newTestEdid 用 VA2478-H-2 的基本块和 ext 扩展块拼成 EDID，并修正校验和。
+func newTestEdid(ext []byte) []byte {
+	data := make([]byte, 2*blockSize)
+	copy(data, testEdidVA2478[:blockSize])
+	copy(data[blockSize:], ext)
+	fixChecksum(data[blockSize:])
+	return data
+}
+
+func fixChecksum(block []byte) {
+	var sum byte
+	for _, b := range block[:blockSize-1] {
+		sum += b
+	}
+	block[blockSize-1] = -sum
+}
+
+func TestDecode(t *testing.T) {
+	info, err := Decode(testEdidVA2478)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.3", info.Version)
+	assert.Equal(t, "VSC", info.ManufacturerId)
+	assert.Equal(t, "ViewSonic", info.ManufacturerName)
+	assert.Equal(t, uint16(0x8335), info.ProductCode)
+	assert.Equal(t, uint32(0xa945), info.SerialNumber)
+	assert.Equal(t, "VDW201543333", info.SerialNumberString)
+	assert.Equal(t, "VA2478-H-2", info.MonitorName)
+	assert.Equal(t, 15, info.ManufactureWeek)
+	assert.Equal(t, 2020, info.ManufactureYear)
+	assert.Equal(t, 0, info.ModelYear)
+	assert.True(t, info.Digital)
+	assert.Equal(t, 53, info.WidthCm)
+	assert.Equal(t, 30, info.HeightCm)
+	assert.InDelta(t, 2.2, info.Gamma, 0.001)
+	assert.True(t, info.SRGBDefault)
+	assert.True(t, info.PreferredTimingIsNative)
+	assert.Equal(t, 1, info.ExtensionCount)
+	assert.Empty(t, info.BadChecksumBlocks)
+
+	assert.InDelta(t, 0.6475, info.ColorPrimaries.Red.X, 0.001)
+	assert.InDelta(t, 0.333, info.ColorPrimaries.Red.Y, 0.001)
+	assert.InDelta(t, 0.3135, info.ColorPrimaries.White.X, 0.001)
+	assert.InDelta(t, 0.329, info.ColorPrimaries.White.Y, 0.001)
+
+	assert.Len(t, info.EstablishedTimings, 15)
+	assert.Contains(t, info.EstablishedTimings, Timing{Width: 1152, Height: 870, RefreshRate: 75})
+	assert.NotContains(t, info.EstablishedTimings, Timing{Width: 1024, Height: 768, RefreshRate: 87, Interlaced: true})
+	assert.Equal(t, []Timing{
+		{Width: 1680, Height: 1050, RefreshRate: 60},
+		{Width: 1600, Height: 1200, RefreshRate: 60},
+		{Width: 1600, Height: 900, RefreshRate: 60},
+		{Width: 1440, Height: 900, RefreshRate: 60},
+		{Width: 1400, Height: 1050, RefreshRate: 60},
+		{Width: 1280, Height: 1024, RefreshRate: 60},
+		{Width: 1280, Height: 960, RefreshRate: 60},
+		{Width: 1280, Height: 720, RefreshRate: 60},
+	}, info.StandardTimings)
+
+	require.NotNil(t, info.RangeLimits)
+	assert.Equal(t, RangeLimits{MinVRate: 50, MaxVRate: 75, MinHRate: 24, MaxHRate: 82, MaxPixelClock: 180},
+		*info.RangeLimits)
+
+	// 基本块 1 个，CEA 扩展块 4 个
+	require.Len(t, info.DetailedTimings, 5)
+	require.NotNil(t, info.NativeTiming)
+	native := info.NativeTiming
+	assert.Equal(t, uint32(148500), native.PixelClock)
+	assert.Equal(t, 1920, native.HActive)
+	assert.Equal(t, 1080, native.VActive)
+	assert.Equal(t, 527, native.WidthMm)
+	assert.Equal(t, 296, native.HeightMm)
+	assert.InDelta(t, 60, native.RefreshRate, 0.001)
+	interlaced := info.DetailedTimings[2]
+	assert.True(t, interlaced.Interlaced)
+	assert.Equal(t, 540, interlaced.VActive)
+	assert.InDelta(t, 60, interlaced.RefreshRate, 0.01)
+
+	cea := info.CEA
+	require.NotNil(t, cea)
+	assert.Equal(t, 3, cea.Revision)
+	assert.True(t, cea.Underscan)
+	assert.True(t, cea.BasicAudio)
+	assert.True(t, cea.YCbCr444)
+	assert.True(t, cea.YCbCr422)
+	assert.True(t, cea.HDMI)
+	assert.False(t, cea.HDMIForum)
+	require.Len(t, cea.VideoModes, 24)
+	assert.Equal(t, CEAVideoMode{VIC: 16, Native: true, Width: 1920, Height: 1080, RefreshRate: 60}, cea.VideoModes[0])
+	assert.Equal(t, CEAVideoMode{VIC: 72}, cea.VideoModes[20])
+	assert.Equal(t, []AudioFormat{{
+		Format:      "LPCM",
+		MaxChannels: 2,
+		SampleRates: []int{32000, 44100, 48000, 88200, 96000, 176400, 192000},
+	}}, cea.AudioFormats)
+	assert.Nil(t, cea.HDRStaticMetadata)
+	assert.Nil(t, info.DisplayID)
+}
+
+func TestDecodeError(t *testing.T) {
+	_, err := Decode(testEdidVA2478[:100])
+	assert.Equal(t, ErrTooShort, err)
+
+	data := make([]byte, blockSize)
+	copy(data, testEdidVA2478)
+	data[0] = 0xff
+	_, err = Decode(data)
+	assert.Equal(t, ErrBadHeader, err)
+
+	// 校验和错误时仍然解析
+	copy(data, testEdidVA2478)
+	data[127]++
+	info, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, info.BadChecksumBlocks)
+	assert.Equal(t, "VA2478-H-2", info.MonitorName)
+	assert.Nil(t, info.CEA)
+}
+
+func TestDecodeHDRStaticMetadata(t *testing.T) {
+	ext := []byte{
+		0x02, 0x03, 0x0f, 0x00,
+		// 扩展数据块：HDR 静态元数据
+		0xe6, 0x06, 0x0d, 0x01, 0x78, 0x60, 0x10,
+		// 扩展数据块：色域
+		0xe3, 0x05, 0xc0, 0x80,
+	}
+	info, err := Decode(newTestEdid(ext))
+	require.NoError(t, err)
+	require.NotNil(t, info.CEA)
+	assert.Equal(t, []string{"BT2020YCC", "BT2020RGB", "DCI-P3"}, info.CEA.Colorimetry)
+
+	md := info.CEA.HDRStaticMetadata
+	require.NotNil(t, md)
+	assert.Equal(t, []string{"traditional-sdr", "smpte-st2084", "hlg"}, md.EOTFs)
+	assert.Equal(t, []int{1}, md.StaticMetadataTypes)
+	assert.InDelta(t, 672.7, md.MaxLuminance, 0.1)
+	assert.InDelta(t, 400, md.MaxFrameAvgLuminance, 0.1)
+	assert.InDelta(t, 0.0265, md.MinLuminance, 0.0001)
+}
+
+func TestDecodeDisplayID(t *testing.T) {
+	ext := []byte{
+		0x70,
+		// DisplayID 1.2，段长度 23
+		0x12, 0x17, 0x00, 0x00,
+		// Type I 详细时序，3840x2160@60
+		0x03, 0x00, 0x14,
+		0x4c, 0xd0, 0x00, 0x80,
+		0xff, 0x0e, 0x9f, 0x00, 0x2f, 0x00, 0x1f, 0x00,
+		0x6f, 0x08, 0x3d, 0x00, 0x02, 0x00, 0x04, 0x00,
+	}
+	info, err := Decode(newTestEdid(ext))
+	require.NoError(t, err)
+	require.NotNil(t, info.DisplayID)
+	assert.Equal(t, "1.2", info.DisplayID.Version)
+	require.Len(t, info.DisplayID.DetailedTimings, 1)
+
+	timing := info.DisplayID.DetailedTimings[0]
+	assert.Equal(t, uint32(533250), timing.PixelClock)
+	assert.Equal(t, 3840, timing.HActive)
+	assert.Equal(t, 160, timing.HBlank)
+	assert.Equal(t, 2160, timing.VActive)
+	assert.Equal(t, 62, timing.VBlank)
+	assert.InDelta(t, 60, timing.RefreshRate, 0.01)
+	// 基本块中有详细时序，原生时序还是基本块的第一个
+	assert.Len(t, info.DetailedTimings, 2)
+	assert.Equal(t, 1920, info.NativeTiming.HActive)
+}
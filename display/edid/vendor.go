@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package edid
+
+// 常见显示器和面板厂商的 PNP ID，完整的列表见 hwdata 的 pnp.ids，这里只收录常见的。
+var vendorNames = map[string]string{
+	"AAC": "AcerView",
+	"ACI": "Ancor Communications",
+	"ACR": "Acer",
+	"AOC": "AOC",
+	"APP": "Apple",
+	"AUO": "AU Optronics",
+	"AUS": "ASUSTek",
+	"BNQ": "BenQ",
+	"BOE": "BOE",
+	"CMN": "Chimei Innolux",
+	"CMO": "Chi Mei Optoelectronics",
+	"CPQ": "Compaq",
+	"DEL": "Dell",
+	"DON": "Denon",
+	"DWE": "Daewoo",
+	"ELO": "Elo TouchSystems",
+	"ENC": "EIZO",
+	"EPI": "Envision Peripherals",
+	"FCM": "Funai",
+	"FUJ": "Fujitsu",
+	"FUS": "Fujitsu Siemens",
+	"GBT": "GIGA-BYTE",
+	"GGL": "Google",
+	"GSM": "LG Electronics",
+	"GWY": "Gateway",
+	"HEI": "Hyundai",
+	"HPN": "HP",
+	"HSD": "HannStar",
+	"HSL": "Hansol",
+	"HTC": "Hitachi",
+	"HWP": "HP",
+	"HWV": "Huawei",
+	"IBM": "IBM",
+	"INL": "InnoLux",
+	"IVM": "Iiyama",
+	"LEN": "Lenovo",
+	"LGD": "LG Display",
+	"LPL": "LG Philips",
+	"MAG": "MAG InnoVision",
+	"MAX": "Maxdata",
+	"MEI": "Panasonic",
+	"MEL": "Mitsubishi",
+	"MJI": "Marantz",
+	"MSF": "Microsoft",
+	"MSI": "Micro-Star",
+	"NEC": "NEC",
+	"NOK": "Nokia",
+	"NVD": "NVIDIA",
+	"ONK": "Onkyo",
+	"OTM": "Optoma",
+	"PGS": "Princeton Graphic Systems",
+	"PHL": "Philips",
+	"PIO": "Pioneer",
+	"PNR": "Planar",
+	"QDS": "Quanta Display",
+	"RHT": "Red Hat",
+	"SAM": "Samsung",
+	"SAN": "Sanyo",
+	"SDC": "Samsung Display",
+	"SEC": "Seiko Epson",
+	"SGI": "Silicon Graphics",
+	"SHP": "Sharp",
+	"SNY": "Sony",
+	"STN": "Samsung",
+	"TMX": "Tianma",
+	"TOS": "Toshiba",
+	"TPV": "Top Victory",
+	"TSB": "Toshiba",
+	"VES": "Vestel",
+	"VIZ": "VIZIO",
+	"VSC": "ViewSonic",
+	"WAC": "Wacom",
+	"YMH": "Yamaha",
+	"ZCM": "Zenith",
+}
+
+// GetVendorName 根据 PNP ID 获取厂商名称，未知时返回空字符串。
+func GetVendorName(pnpId string) string {
+	return vendorNames[pnpId]
+}
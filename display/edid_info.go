@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/startdde/display/edid"
+)
+
+// toEdidInfoMap 把 EDID 信息转换为 GetEdidInfo 返回的 a{sv}，键是 edid.Info 的字段名，没有的部分不包含在结果中。
+func toEdidInfoMap(info *edid.Info) map[string]dbus.Variant {
+	result := map[string]dbus.Variant{
+		"Version":                 dbus.MakeVariant(info.Version),
+		"ManufacturerId":          dbus.MakeVariant(info.ManufacturerId),
+		"ManufacturerName":        dbus.MakeVariant(info.ManufacturerName),
+		"ProductCode":             dbus.MakeVariant(info.ProductCode),
+		"SerialNumber":            dbus.MakeVariant(info.SerialNumber),
+		"SerialNumberString":      dbus.MakeVariant(info.SerialNumberString),
+		"MonitorName":             dbus.MakeVariant(info.MonitorName),
+		"ManufactureWeek":         dbus.MakeVariant(int32(info.ManufactureWeek)),
+		"ManufactureYear":         dbus.MakeVariant(int32(info.ManufactureYear)),
+		"ModelYear":               dbus.MakeVariant(int32(info.ModelYear)),
+		"Digital":                 dbus.MakeVariant(info.Digital),
+		"BitDepth":                dbus.MakeVariant(int32(info.BitDepth)),
+		"VideoInterface":          dbus.MakeVariant(info.VideoInterface),
+		"WidthCm":                 dbus.MakeVariant(int32(info.WidthCm)),
+		"HeightCm":                dbus.MakeVariant(int32(info.HeightCm)),
+		"Gamma":                   dbus.MakeVariant(info.Gamma),
+		"DPMSStandby":             dbus.MakeVariant(info.DPMSStandby),
+		"DPMSSuspend":             dbus.MakeVariant(info.DPMSSuspend),
+		"DPMSActiveOff":           dbus.MakeVariant(info.DPMSActiveOff),
+		"SRGBDefault":             dbus.MakeVariant(info.SRGBDefault),
+		"PreferredTimingIsNative": dbus.MakeVariant(info.PreferredTimingIsNative),
+		"ContinuousFrequency":     dbus.MakeVariant(info.ContinuousFrequency),
+		"ColorPrimaries":          dbus.MakeVariant(info.ColorPrimaries),
+		"EstablishedTimings":      dbus.MakeVariant(toEdidTimings(info.EstablishedTimings)),
+		"StandardTimings":         dbus.MakeVariant(toEdidTimings(info.StandardTimings)),
+		"DetailedTimings":         dbus.MakeVariant(toEdidDetailedTimings(info.DetailedTimings)),
+		"ExtensionCount":          dbus.MakeVariant(int32(info.ExtensionCount)),
+	}
+	if len(info.Texts) > 0 {
+		result["Texts"] = dbus.MakeVariant(info.Texts)
+	}
+	if info.NativeTiming != nil {
+		result["NativeTiming"] = dbus.MakeVariant(toEdidDetailedTiming(*info.NativeTiming))
+	}
+	if info.RangeLimits != nil {
+		limits := info.RangeLimits
+		result["RangeLimits"] = dbus.MakeVariant(map[string]dbus.Variant{
+			"MinVRate":      dbus.MakeVariant(int32(limits.MinVRate)),
+			"MaxVRate":      dbus.MakeVariant(int32(limits.MaxVRate)),
+			"MinHRate":      dbus.MakeVariant(int32(limits.MinHRate)),
+			"MaxHRate":      dbus.MakeVariant(int32(limits.MaxHRate)),
+			"MaxPixelClock": dbus.MakeVariant(int32(limits.MaxPixelClock)),
+		})
+	}
+	if info.CEA != nil {
+		result["CEA"] = dbus.MakeVariant(toCEAInfoMap(info.CEA))
+	}
+	if info.DisplayID != nil {
+		displayID := info.DisplayID
+		result["DisplayID"] = dbus.MakeVariant(map[string]dbus.Variant{
+			"Version":         dbus.MakeVariant(displayID.Version),
+			"ProductType":     dbus.MakeVariant(int32(displayID.ProductType)),
+			"WidthMm":         dbus.MakeVariant(displayID.WidthMm),
+			"HeightMm":        dbus.MakeVariant(displayID.HeightMm),
+			"NativeWidth":     dbus.MakeVariant(int32(displayID.NativeWidth)),
+			"NativeHeight":    dbus.MakeVariant(int32(displayID.NativeHeight)),
+			"DetailedTimings": dbus.MakeVariant(toEdidDetailedTimings(displayID.DetailedTimings)),
+		})
+	}
+	if len(info.BadChecksumBlocks) > 0 {
+		blocks := make([]int32, len(info.BadChecksumBlocks))
+		for i, block := range info.BadChecksumBlocks {
+			blocks[i] = int32(block)
+		}
+		result["BadChecksumBlocks"] = dbus.MakeVariant(blocks)
+	}
+	return result
+}
+
+func toCEAInfoMap(cea *edid.CEAInfo) map[string]dbus.Variant {
+	result := map[string]dbus.Variant{
+		"Revision":           dbus.MakeVariant(int32(cea.Revision)),
+		"Underscan":          dbus.MakeVariant(cea.Underscan),
+		"BasicAudio":         dbus.MakeVariant(cea.BasicAudio),
+		"YCbCr444":           dbus.MakeVariant(cea.YCbCr444),
+		"YCbCr422":           dbus.MakeVariant(cea.YCbCr422),
+		"VideoModes":         dbus.MakeVariant(toEdidCEAVideoModes(cea.VideoModes)),
+		"YCbCr420VideoModes": dbus.MakeVariant(toEdidCEAVideoModes(cea.YCbCr420VideoModes)),
+		"HDMI":               dbus.MakeVariant(cea.HDMI),
+		"HDMIForum":          dbus.MakeVariant(cea.HDMIForum),
+		"Colorimetry":        dbus.MakeVariant(append([]string{}, cea.Colorimetry...)),
+		"DetailedTimings":    dbus.MakeVariant(toEdidDetailedTimings(cea.DetailedTimings)),
+	}
+	audioFormats := make([]EdidAudioFormat, len(cea.AudioFormats))
+	for i, format := range cea.AudioFormats {
+		sampleRates := make([]int32, len(format.SampleRates))
+		for j, rate := range format.SampleRates {
+			sampleRates[j] = int32(rate)
+		}
+		audioFormats[i] = EdidAudioFormat{
+			Format:      format.Format,
+			MaxChannels: int32(format.MaxChannels),
+			SampleRates: sampleRates,
+		}
+	}
+	result["AudioFormats"] = dbus.MakeVariant(audioFormats)
+	if hdr := cea.HDRStaticMetadata; hdr != nil {
+		metadataTypes := make([]int32, len(hdr.StaticMetadataTypes))
+		for i, t := range hdr.StaticMetadataTypes {
+			metadataTypes[i] = int32(t)
+		}
+		result["HDRStaticMetadata"] = dbus.MakeVariant(map[string]dbus.Variant{
+			"EOTFs":                dbus.MakeVariant(append([]string{}, hdr.EOTFs...)),
+			"StaticMetadataTypes":  dbus.MakeVariant(metadataTypes),
+			"MaxLuminance":         dbus.MakeVariant(hdr.MaxLuminance),
+			"MaxFrameAvgLuminance": dbus.MakeVariant(hdr.MaxFrameAvgLuminance),
+			"MinLuminance":         dbus.MakeVariant(hdr.MinLuminance),
+		})
+	}
+	return result
+}
+
+// EdidTiming 是 GetEdidInfo 返回的固定时序和标准时序
+type EdidTiming struct {
+	Width       int32
+	Height      int32
+	RefreshRate float64
+	Interlaced  bool
+}
+
+// EdidDetailedTiming 是 GetEdidInfo 返回的详细时序，PixelClock 的单位是 kHz。
+type EdidDetailedTiming struct {
+	PixelClock  uint32
+	HActive     int32
+	HBlank      int32
+	HSyncOffset int32
+	HSyncWidth  int32
+	VActive     int32
+	VBlank      int32
+	VSyncOffset int32
+	VSyncWidth  int32
+	WidthMm     int32
+	HeightMm    int32
+	Interlaced  bool
+	RefreshRate float64
+}
+
+// EdidCEAVideoMode 是 GetEdidInfo 返回的 CEA 短视频描述符
+type EdidCEAVideoMode struct {
+	VIC         int32
+	Native      bool
+	Width       int32
+	Height      int32
+	RefreshRate float64
+	Interlaced  bool
+}
+
+// EdidAudioFormat 是 GetEdidInfo 返回的 CEA 短音频描述符，SampleRates 的单位是 Hz。
+type EdidAudioFormat struct {
+	Format      string
+	MaxChannels int32
+	SampleRates []int32
+}
+
+func toEdidTimings(timings []edid.Timing) []EdidTiming {
+	result := make([]EdidTiming, len(timings))
+	for i, timing := range timings {
+		result[i] = EdidTiming{
+			Width:       int32(timing.Width),
+			Height:      int32(timing.Height),
+			RefreshRate: timing.RefreshRate,
+			Interlaced:  timing.Interlaced,
+		}
+	}
+	return result
+}
+
+func toEdidDetailedTiming(timing edid.DetailedTiming) EdidDetailedTiming {
+	return EdidDetailedTiming{
+		PixelClock:  timing.PixelClock,
+		HActive:     int32(timing.HActive),
+		HBlank:      int32(timing.HBlank),
+		HSyncOffset: int32(timing.HSyncOffset),
+		HSyncWidth:  int32(timing.HSyncWidth),
+		VActive:     int32(timing.VActive),
+		VBlank:      int32(timing.VBlank),
+		VSyncOffset: int32(timing.VSyncOffset),
+		VSyncWidth:  int32(timing.VSyncWidth),
+		WidthMm:     int32(timing.WidthMm),
+		HeightMm:    int32(timing.HeightMm),
+		Interlaced:  timing.Interlaced,
+		RefreshRate: timing.RefreshRate,
+	}
+}
+
+func toEdidDetailedTimings(timings []edid.DetailedTiming) []EdidDetailedTiming {
+	result := make([]EdidDetailedTiming, len(timings))
+	for i, timing := range timings {
+		result[i] = toEdidDetailedTiming(timing)
+	}
+	return result
+}
+
+func toEdidCEAVideoModes(modes []edid.CEAVideoMode) []EdidCEAVideoMode {
+	result := make([]EdidCEAVideoMode, len(modes))
+	for i, mode := range modes {
+		result[i] = EdidCEAVideoMode{
+			VIC:         int32(mode.VIC),
+			Native:      mode.Native,
+			Width:       int32(mode.Width),
+			Height:      int32(mode.Height),
+			RefreshRate: mode.RefreshRate,
+			Interlaced:  mode.Interlaced,
+		}
+	}
+	return result
+}
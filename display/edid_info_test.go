@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/startdde/display/edid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_toEdidInfoMap(t *testing.T) {
+	info := &edid.Info{
+		Version:          "1.3",
+		ManufacturerId:   "DEL",
+		ManufacturerName: "Dell",
+		SerialNumber:     12345,
+		WidthCm:          53,
+		HeightCm:         30,
+		DetailedTimings: []edid.DetailedTiming{
+			{PixelClock: 148500, HActive: 1920, VActive: 1080, RefreshRate: 60},
+		},
+		CEA: &edid.CEAInfo{
+			HDMI: true,
+			HDRStaticMetadata: &edid.HDRStaticMetadata{
+				EOTFs:        []string{"traditional-sdr", "smpte-st2084"},
+				MaxLuminance: 400,
+			},
+		},
+	}
+	info.NativeTiming = &info.DetailedTimings[0]
+
+	result := toEdidInfoMap(info)
+	assert.Equal(t, "DEL", result["ManufacturerId"].Value())
+	assert.Equal(t, uint32(12345), result["SerialNumber"].Value())
+	assert.Equal(t, int32(53), result["WidthCm"].Value())
+	assert.Equal(t, int32(1920), result["NativeTiming"].Value().(EdidDetailedTiming).HActive)
+	// 没有的部分不包含在结果中
+	assert.NotContains(t, result, "RangeLimits")
+	assert.NotContains(t, result, "DisplayID")
+
+	cea := result["CEA"].Value().(map[string]dbus.Variant)
+	assert.Equal(t, true, cea["HDMI"].Value())
+	hdr := cea["HDRStaticMetadata"].Value().(map[string]dbus.Variant)
+	assert.Equal(t, 400.0, hdr["MaxLuminance"].Value())
+
+	// 可以通过 DBus 发送
+	msg := &dbus.Message{
+		Type: dbus.TypeSignal,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldPath:      dbus.MakeVariant(dbus.ObjectPath("/test")),
+			dbus.FieldInterface: dbus.MakeVariant("test.Test"),
+			dbus.FieldMember:    dbus.MakeVariant("Test"),
+			dbus.FieldSignature: dbus.MakeVariant(dbus.SignatureOf(result)),
+		},
+		Body: []interface{}{result},
+	}
+	assert.Equal(t, "a{sv}", dbus.SignatureOf(result).String())
+	var buf bytes.Buffer
+	require.NoError(t, msg.EncodeTo(&buf, binary.LittleEndian))
+}
@@ -12,6 +12,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "ApplyChanges",
 			Fn:   v.ApplyChanges,
 		},
+		{
+			Name:   "ApplyChangesWithTimeout",
+			Fn:     v.ApplyChangesWithTimeout,
+			InArgs: []string{"seconds"},
+		},
 		{
 			Name:   "AssociateTouch",
 			Fn:     v.AssociateTouch,
@@ -38,11 +43,29 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.ChangeBrightness,
 			InArgs: []string{"raised"},
 		},
+		{
+			Name: "ClearLocation",
+			Fn:   v.ClearLocation,
+		},
+		{
+			Name: "ConfirmChanges",
+			Fn:   v.ConfirmChanges,
+		},
 		{
 			Name:   "DeleteCustomMode",
 			Fn:     v.DeleteCustomMode,
 			InArgs: []string{"name"},
 		},
+		{
+			Name:    "ExportConfig",
+			Fn:      v.ExportConfig,
+			OutArgs: []string{"outArg0"},
+		},
+		{
+			Name:    "GetAutoBrightnessCurve",
+			Fn:      v.GetAutoBrightnessCurve,
+			OutArgs: []string{"outArg0"},
+		},
 		{
 			Name:    "GetBrightness",
 			Fn:      v.GetBrightness,
@@ -53,11 +76,48 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetBuiltinMonitor,
 			OutArgs: []string{"outArg0", "outArg1"},
 		},
+		{
+			Name:    "GetColorTemperatureSchedule",
+			Fn:      v.GetColorTemperatureSchedule,
+			OutArgs: []string{"start", "end", "temperature", "fade"},
+		},
+		{
+			Name:    "GetLocation",
+			Fn:      v.GetLocation,
+			OutArgs: []string{"latitude", "longitude", "source"},
+		},
+		{
+			Name:    "GetMirrorGroups",
+			Fn:      v.GetMirrorGroups,
+			OutArgs: []string{"outArg0"},
+		},
 		{
 			Name:    "GetRealDisplayMode",
 			Fn:      v.GetRealDisplayMode,
 			OutArgs: []string{"outArg0"},
 		},
+		{
+			Name:   "ImportConfig",
+			Fn:     v.ImportConfig,
+			InArgs: []string{"cfgJson", "mergePolicy"},
+		},
+		{
+			Name:    "InhibitColorTemperature",
+			Fn:      v.InhibitColorTemperature,
+			InArgs:  []string{"reason"},
+			OutArgs: []string{"cookie"},
+		},
+		{
+			Name:    "InhibitColorTemperatureUntilTomorrow",
+			Fn:      v.InhibitColorTemperatureUntilTomorrow,
+			InArgs:  []string{"reason"},
+			OutArgs: []string{"cookie"},
+		},
+		{
+			Name:    "ListDisplayRules",
+			Fn:      v.ListDisplayRules,
+			OutArgs: []string{"outArg0"},
+		},
 		{
 			Name:    "ListOutputNames",
 			Fn:      v.ListOutputNames,
@@ -77,6 +137,10 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "RefreshBrightness",
 			Fn:   v.RefreshBrightness,
 		},
+		{
+			Name: "ReloadDisplayRules",
+			Fn:   v.ReloadDisplayRules,
+		},
 		{
 			Name: "Reset",
 			Fn:   v.Reset,
@@ -89,50 +153,170 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "Save",
 			Fn:   v.Save,
 		},
+		{
+			Name:   "SaveCustomMode",
+			Fn:     v.SaveCustomMode,
+			InArgs: []string{"name"},
+		},
 		{
 			Name:   "SetAndSaveBrightness",
 			Fn:     v.SetAndSaveBrightness,
 			InArgs: []string{"outputName", "value"},
 		},
+		{
+			Name:   "SetAutoBrightness",
+			Fn:     v.SetAutoBrightness,
+			InArgs: []string{"enabled"},
+		},
+		{
+			Name:   "SetAutoBrightnessCurve",
+			Fn:     v.SetAutoBrightnessCurve,
+			InArgs: []string{"points"},
+		},
 		{
 			Name:   "SetBrightness",
 			Fn:     v.SetBrightness,
 			InArgs: []string{"outputName", "value"},
 		},
+		{
+			Name:   "SetBrightnessWithOptions",
+			Fn:     v.SetBrightnessWithOptions,
+			InArgs: []string{"outputName", "value", "instant"},
+		},
 		{
 			Name:   "SetColorTemperature",
 			Fn:     v.SetColorTemperature,
 			InArgs: []string{"value"},
 		},
+		{
+			Name:   "SetColorTemperatureSchedule",
+			Fn:     v.SetColorTemperatureSchedule,
+			InArgs: []string{"start", "end", "temperature", "fade"},
+		},
+		{
+			Name:   "SetLocation",
+			Fn:     v.SetLocation,
+			InArgs: []string{"latitude", "longitude"},
+		},
 		{
 			Name:   "SetMethodAdjustCCT",
 			Fn:     v.SetMethodAdjustCCT,
 			InArgs: []string{"adjustMethod"},
 		},
+		{
+			Name:   "SetMirrorGroups",
+			Fn:     v.SetMirrorGroups,
+			InArgs: []string{"groups"},
+		},
+		{
+			Name:   "SetMirrorStrategy",
+			Fn:     v.SetMirrorStrategy,
+			InArgs: []string{"strategy"},
+		},
 		{
 			Name:   "SetPrimary",
 			Fn:     v.SetPrimary,
 			InArgs: []string{"outputName"},
 		},
+		{
+			Name:   "SetTransformScaling",
+			Fn:     v.SetTransformScaling,
+			InArgs: []string{"enabled"},
+		},
 		{
 			Name:    "SupportSetColorTemperature",
 			Fn:      v.SupportSetColorTemperature,
 			OutArgs: []string{"outArg0"},
 		},
+		{
+			Name:   "SwitchCustomMode",
+			Fn:     v.SwitchCustomMode,
+			InArgs: []string{"name"},
+		},
 		{
 			Name:   "SwitchMode",
 			Fn:     v.SwitchMode,
 			InArgs: []string{"mode", "name"},
 		},
+		{
+			Name:   "UninhibitColorTemperature",
+			Fn:     v.UninhibitColorTemperature,
+			InArgs: []string{"cookie"},
+		},
+		{
+			Name:    "ValidateChanges",
+			Fn:      v.ValidateChanges,
+			OutArgs: []string{"outArg0"},
+		},
 	}
 }
 func (v *Monitor) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "AddCustomMode",
+			Fn:     v.AddCustomMode,
+			InArgs: []string{"width", "height", "refreshRate", "reducedBlanking"},
+		},
 		{
 			Name:   "Enable",
 			Fn:     v.Enable,
 			InArgs: []string{"enabled"},
 		},
+		{
+			Name:    "GetContrast",
+			Fn:      v.GetContrast,
+			OutArgs: []string{"outArg0"},
+		},
+		{
+			Name:    "GetEdidInfo",
+			Fn:      v.GetEdidInfo,
+			OutArgs: []string{"outArg0"},
+		},
+		{
+			Name:    "ListOutputProperties",
+			Fn:      v.ListOutputProperties,
+			OutArgs: []string{"outArg0"},
+		},
+		{
+			Name:   "RemoveCustomMode",
+			Fn:     v.RemoveCustomMode,
+			InArgs: []string{"width", "height", "refreshRate", "reducedBlanking"},
+		},
+		{
+			Name:   "SetBroadcastRGB",
+			Fn:     v.SetBroadcastRGB,
+			InArgs: []string{"value"},
+		},
+		{
+			Name:   "SetColorProfile",
+			Fn:     v.SetColorProfile,
+			InArgs: []string{"path"},
+		},
+		{
+			Name:   "SetColorTemperature",
+			Fn:     v.SetColorTemperature,
+			InArgs: []string{"value"},
+		},
+		{
+			Name:   "SetColorTemperatureMode",
+			Fn:     v.SetColorTemperatureMode,
+			InArgs: []string{"mode"},
+		},
+		{
+			Name:   "SetContentType",
+			Fn:     v.SetContentType,
+			InArgs: []string{"value"},
+		},
+		{
+			Name:   "SetContrast",
+			Fn:     v.SetContrast,
+			InArgs: []string{"value"},
+		},
+		{
+			Name:   "SetMaxBpc",
+			Fn:     v.SetMaxBpc,
+			InArgs: []string{"value"},
+		},
 		{
 			Name:   "SetMode",
 			Fn:     v.SetMode,
@@ -163,5 +347,10 @@ func (v *Monitor) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetRotation,
 			InArgs: []string{"value"},
 		},
+		{
+			Name:   "SetUnderscan",
+			Fn:     v.SetUnderscan,
+			InArgs: []string{"horizontalPx", "verticalPx"},
+		},
 	}
 }
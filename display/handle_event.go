@@ -45,7 +45,7 @@ func (m *Manager) handleMonitorChanged(monitorInfo *MonitorInfo) {
 	m.updateMonitorsId(options)
 }
 
-// wayland 下连接显示器
+// wayland 下连接显示器，X 下关联其他显卡后出现新的接口
 func (m *Manager) handleMonitorAdded(monitorInfo *MonitorInfo) {
 	err := m.addMonitor(monitorInfo)
 	if err != nil {
@@ -56,7 +56,7 @@ func (m *Manager) handleMonitorAdded(monitorInfo *MonitorInfo) {
 	m.updateMonitorsId(nil)
 }
 
-// wayland 下断开显示器
+// wayland 下断开显示器，X 下显卡被移除后接口消失
 func (m *Manager) handleMonitorRemoved(monitorId uint32) {
 	logger.Debug("monitor removed", monitorId)
 	monitor := m.removeMonitor(monitorId)
@@ -72,6 +72,21 @@ func (m *Manager) handleMonitorRemoved(monitorId uint32) {
 
 func (m *Manager) handleOutputPropertyChanged(ev *randr.OutputPropertyNotifyEvent) {
 	logger.Debug("output property changed", ev.Output, ev.Atom)
+	name, err := m.xConn.GetAtomName(ev.Atom)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	switch name {
+	case outputPropBroadcastRGB, outputPropMaxBpc, outputPropContentType,
+		outputPropVrrCapable:
+		m.monitorMapMu.Lock()
+		monitor, ok := m.monitorMap[uint32(ev.Output)]
+		m.monitorMapMu.Unlock()
+		if ok {
+			m.updateMonitorOutputProperties(monitor)
+		}
+	}
 }
 
 func (m *Manager) handleScreenChanged(ev *randr.ScreenChangeNotifyEvent, cfgTsChanged bool) {
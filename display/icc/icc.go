@@ -0,0 +1,279 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package icc 读取 ICC 色彩配置文件，主要是其中 vcgt 标签的显卡校准曲线。
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	headerSize   = 128
+	tagEntrySize = 12
+	// 公式类型的 vcgt 转换成表时的点数
+	formulaTableSize = 1024
+	maxFileSize      = 64 << 20
+)
+
+const (
+	sigAcsp = "acsp"
+	sigVcgt = "vcgt"
+	sigDesc = "desc"
+	sigMluc = "mluc"
+)
+
+const (
+	vcgtTypeTable   = 0
+	vcgtTypeFormula = 1
+)
+
+var (
+	ErrTooShort     = errors.New("icc profile is too short")
+	ErrBadSignature = errors.New("invalid icc profile signature")
+)
+
+// Profile 是 ICC 配置文件解析的结果
+type Profile struct {
+	Version     string
+	DeviceClass string
+	ColorSpace  string
+	Description string
+	// 没有 vcgt 标签时为 nil
+	VCGT *VCGT
+}
+
+// VCGT 是显卡的校准曲线，顺序为红、绿、蓝，每个点的值范围为 0-1，在 0-1 上均匀分布。
+type VCGT struct {
+	Channels [3][]float64
+}
+
+type tagEntry struct {
+	offset uint32
+	size   uint32
+}
+
+// Load 读取并解析 ICC 配置文件
+func Load(filename string) (*Profile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("icc profile %s is too large", filename)
+	}
+	return Parse(data)
+}
+
+// Parse 解析 ICC 配置文件的数据
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < headerSize+4 {
+		return nil, ErrTooShort
+	}
+	if string(data[36:40]) != sigAcsp {
+		return nil, ErrBadSignature
+	}
+
+	profile := &Profile{
+		Version:     fmt.Sprintf("%d.%d", data[8], data[9]>>4),
+		DeviceClass: strings.TrimSpace(string(data[12:16])),
+		ColorSpace:  strings.TrimSpace(string(data[16:20])),
+	}
+
+	tags, err := parseTagTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry, ok := tags[sigDesc]; ok {
+		profile.Description = parseDescription(data[entry.offset : entry.offset+entry.size])
+	}
+	if entry, ok := tags[sigVcgt]; ok {
+		profile.VCGT, err = parseVCGT(data[entry.offset : entry.offset+entry.size])
+		if err != nil {
+			return nil, fmt.Errorf("invalid vcgt tag: %w", err)
+		}
+	}
+	return profile, nil
+}
+
+func parseTagTable(data []byte) (map[string]tagEntry, error) {
+	count := binary.BigEndian.Uint32(data[headerSize:])
+	if uint64(headerSize+4)+uint64(count)*tagEntrySize > uint64(len(data)) {
+		return nil, ErrTooShort
+	}
+	tags := make(map[string]tagEntry, count)
+	for i := uint32(0); i < count; i++ {
+		p := data[headerSize+4+i*tagEntrySize:]
+		sig := string(p[0:4])
+		entry := tagEntry{
+			offset: binary.BigEndian.Uint32(p[4:]),
+			size:   binary.BigEndian.Uint32(p[8:]),
+		}
+		if uint64(entry.offset)+uint64(entry.size) > uint64(len(data)) {
+			return nil, fmt.Errorf("tag %q is out of range", sig)
+		}
+		tags[sig] = entry
+	}
+	return tags, nil
+}
+
+// parseDescription 解析 v2 的 textDescriptionType 或 v4 的 multiLocalizedUnicodeType，取第一个字符串。
+func parseDescription(data []byte) string {
+	if len(data) < 12 {
+		return ""
+	}
+	switch string(data[0:4]) {
+	case sigDesc:
+		n := binary.BigEndian.Uint32(data[8:])
+		if uint64(n) > uint64(len(data)-12) {
+			return ""
+		}
+		return strings.TrimRight(string(data[12:12+n]), "\x00")
+
+	case sigMluc:
+		if len(data) < 28 || binary.BigEndian.Uint32(data[8:]) == 0 {
+			return ""
+		}
+		length := binary.BigEndian.Uint32(data[20:])
+		offset := binary.BigEndian.Uint32(data[24:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return ""
+		}
+		str := data[offset : offset+length]
+		u16 := make([]uint16, len(str)/2)
+		for i := range u16 {
+			u16[i] = binary.BigEndian.Uint16(str[i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(u16)), "\x00")
+	}
+	return ""
+}
+
+func parseVCGT(data []byte) (*VCGT, error) {
+	if len(data) < 12 || string(data[0:4]) != sigVcgt {
+		return nil, ErrTooShort
+	}
+	switch gammaType := binary.BigEndian.Uint32(data[8:]); gammaType {
+	case vcgtTypeTable:
+		return parseVCGTTable(data)
+	case vcgtTypeFormula:
+		return parseVCGTFormula(data)
+	default:
+		return nil, fmt.Errorf("unknown gamma type %d", gammaType)
+	}
+}
+
+func parseVCGTTable(data []byte) (*VCGT, error) {
+	if len(data) < 18 {
+		return nil, ErrTooShort
+	}
+	channels := int(binary.BigEndian.Uint16(data[12:]))
+	count := int(binary.BigEndian.Uint16(data[14:]))
+	entrySize := int(binary.BigEndian.Uint16(data[16:]))
+	if channels != 1 && channels != 3 {
+		return nil, fmt.Errorf("invalid channel count %d", channels)
+	}
+	if count < 2 {
+		return nil, fmt.Errorf("invalid entry count %d", count)
+	}
+	if entrySize != 1 && entrySize != 2 {
+		return nil, fmt.Errorf("invalid entry size %d", entrySize)
+	}
+	if len(data) < 18+channels*count*entrySize {
+		return nil, ErrTooShort
+	}
+
+	maxValue := float64(math.MaxUint8)
+	if entrySize == 2 {
+		maxValue = math.MaxUint16
+	}
+	var v VCGT
+	p := data[18:]
+	for c := 0; c < channels; c++ {
+		values := make([]float64, count)
+		for i := range values {
+			if entrySize == 2 {
+				values[i] = float64(binary.BigEndian.Uint16(p)) / maxValue
+			} else {
+				values[i] = float64(p[0]) / maxValue
+			}
+			p = p[entrySize:]
+		}
+		v.Channels[c] = values
+	}
+	if channels == 1 {
+		v.Channels[1] = v.Channels[0]
+		v.Channels[2] = v.Channels[0]
+	}
+	return &v, nil
+}
+
+func s15Fixed16(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536
+}
+
+// parseVCGTFormula 每个通道是 gamma、min、max 三个值，y = min + (max - min) * x^gamma
+func parseVCGTFormula(data []byte) (*VCGT, error) {
+	if len(data) < 12+9*4 {
+		return nil, ErrTooShort
+	}
+	var v VCGT
+	for c := 0; c < 3; c++ {
+		p := data[12+c*12:]
+		gamma := s15Fixed16(p)
+		min := s15Fixed16(p[4:])
+		max := s15Fixed16(p[8:])
+		if gamma <= 0 {
+			return nil, fmt.Errorf("invalid gamma %v", gamma)
+		}
+		values := make([]float64, formulaTableSize)
+		for i := range values {
+			x := float64(i) / (formulaTableSize - 1)
+			values[i] = clamp(min + (max-min)*math.Pow(x, gamma))
+		}
+		v.Channels[c] = values
+	}
+	return &v, nil
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Eval 计算通道 channel 在 x 处的值，x 的范围为 0-1，在表中的点之间线性插值。
+func (v *VCGT) Eval(channel int, x float64) float64 {
+	values := v.Channels[channel]
+	if len(values) == 0 {
+		return x
+	}
+	pos := clamp(x) * float64(len(values)-1)
+	i := int(pos)
+	if i >= len(values)-1 {
+		return values[len(values)-1]
+	}
+	frac := pos - float64(i)
+	return values[i]*(1-frac) + values[i+1]*frac
+}
+
+// Apply 用校准曲线修改 gamma 表，red、green、blue 中的值为 0-65535。
+func (v *VCGT) Apply(red, green, blue []uint16) {
+	for c, ramp := range [3][]uint16{red, green, blue} {
+		for i, value := range ramp {
+			y := v.Eval(c, float64(value)/math.MaxUint16)
+			ramp[i] = uint16(math.Round(y * math.MaxUint16))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package icc
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTag struct {
+	sig  string
+	data []byte
+}
+
+// buildProfile 生成只有头部和标签的 ICC 配置文件
+func buildProfile(tags ...testTag) []byte {
+	data := make([]byte, headerSize+4+len(tags)*tagEntrySize)
+	data[8], data[9] = 2, 0x10
+	copy(data[12:], "mntr")
+	copy(data[16:], "RGB ")
+	copy(data[36:], sigAcsp)
+	binary.BigEndian.PutUint32(data[headerSize:], uint32(len(tags)))
+	for i, tag := range tags {
+		p := data[headerSize+4+i*tagEntrySize:]
+		copy(p, tag.sig)
+		binary.BigEndian.PutUint32(p[4:], uint32(len(data)))
+		binary.BigEndian.PutUint32(p[8:], uint32(len(tag.data)))
+		data = append(data, tag.data...)
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+func descTag(text string) testTag {
+	data := make([]byte, 12)
+	copy(data, sigDesc)
+	binary.BigEndian.PutUint32(data[8:], uint32(len(text)+1))
+	data = append(data, text...)
+	data = append(data, 0)
+	return testTag{sig: sigDesc, data: data}
+}
+
+func mlucTag(text string) testTag {
+	data := make([]byte, 28)
+	copy(data, sigMluc)
+	binary.BigEndian.PutUint32(data[8:], 1)
+	binary.BigEndian.PutUint32(data[12:], 12)
+	copy(data[16:], "enUS")
+	binary.BigEndian.PutUint32(data[20:], uint32(len(text)*2))
+	binary.BigEndian.PutUint32(data[24:], 28)
+	for _, r := range text {
+		data = append(data, 0, byte(r))
+	}
+	return testTag{sig: sigDesc, data: data}
+}
+
+func vcgtTableTag(channels, entrySize int, values [][]uint16) testTag {
+	data := make([]byte, 18)
+	copy(data, sigVcgt)
+	binary.BigEndian.PutUint32(data[8:], vcgtTypeTable)
+	binary.BigEndian.PutUint16(data[12:], uint16(channels))
+	binary.BigEndian.PutUint16(data[14:], uint16(len(values[0])))
+	binary.BigEndian.PutUint16(data[16:], uint16(entrySize))
+	for _, channel := range values {
+		for _, value := range channel {
+			if entrySize == 2 {
+				data = append(data, byte(value>>8), byte(value))
+			} else {
+				data = append(data, byte(value))
+			}
+		}
+	}
+	return testTag{sig: sigVcgt, data: data}
+}
+
+func vcgtFormulaTag(params [9]float64) testTag {
+	data := make([]byte, 12+9*4)
+	copy(data, sigVcgt)
+	binary.BigEndian.PutUint32(data[8:], vcgtTypeFormula)
+	for i, param := range params {
+		binary.BigEndian.PutUint32(data[12+i*4:], uint32(int32(param*65536)))
+	}
+	return testTag{sig: sigVcgt, data: data}
+}
+
+func TestParseTable(t *testing.T) {
+	data := buildProfile(descTag("Calibrated Monitor"), vcgtTableTag(3, 2, [][]uint16{
+		{0, 32768, 65535},
+		{0, 16384, 32768},
+		{65535, 65535, 65535},
+	}))
+	profile, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "2.1", profile.Version)
+	assert.Equal(t, "mntr", profile.DeviceClass)
+	assert.Equal(t, "RGB", profile.ColorSpace)
+	assert.Equal(t, "Calibrated Monitor", profile.Description)
+	require.NotNil(t, profile.VCGT)
+
+	v := profile.VCGT
+	assert.InDelta(t, 0.5, v.Eval(0, 0.5), 0.001)
+	assert.InDelta(t, 0.25, v.Eval(0, 0.25), 0.001)
+	assert.InDelta(t, 0.25, v.Eval(1, 0.5), 0.001)
+	assert.InDelta(t, 0.375, v.Eval(1, 0.75), 0.001)
+	assert.Equal(t, 1.0, v.Eval(2, 0))
+	assert.Equal(t, 1.0, v.Eval(0, 2))
+
+	red := []uint16{0, 32768, 65535}
+	green := []uint16{0, 32768, 65535}
+	blue := []uint16{0, 32768, 65535}
+	v.Apply(red, green, blue)
+	assert.Equal(t, []uint16{0, 32768, 65535}, red)
+	assert.InDelta(t, 16384, float64(green[1]), 2)
+	assert.Equal(t, uint16(32768), green[2])
+	assert.Equal(t, []uint16{65535, 65535, 65535}, blue)
+}
+
+func TestParseTableOneChannel(t *testing.T) {
+	data := buildProfile(mlucTag("Display"), vcgtTableTag(1, 1, [][]uint16{{0, 128, 255}}))
+	profile, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Display", profile.Description)
+	require.NotNil(t, profile.VCGT)
+	for c := 0; c < 3; c++ {
+		assert.InDelta(t, 128.0/255, profile.VCGT.Eval(c, 0.5), 0.0001)
+	}
+}
+
+func TestParseFormula(t *testing.T) {
+	data := buildProfile(vcgtFormulaTag([9]float64{
+		1, 0, 1,
+		2, 0, 1,
+		1, 0.1, 0.9,
+	}))
+	profile, err := Parse(data)
+	require.NoError(t, err)
+	require.NotNil(t, profile.VCGT)
+	v := profile.VCGT
+	assert.InDelta(t, 0.5, v.Eval(0, 0.5), 0.001)
+	assert.InDelta(t, 0.25, v.Eval(1, 0.5), 0.001)
+	assert.InDelta(t, 0.1, v.Eval(2, 0), 0.001)
+	assert.InDelta(t, 0.9, v.Eval(2, 1), 0.001)
+}
+
+func TestParseNoVCGT(t *testing.T) {
+	profile, err := Parse(buildProfile(descTag("sRGB")))
+	require.NoError(t, err)
+	assert.Nil(t, profile.VCGT)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(make([]byte, 10))
+	assert.Equal(t, ErrTooShort, err)
+
+	data := buildProfile()
+	data[36] = 'x'
+	_, err = Parse(data)
+	assert.Equal(t, ErrBadSignature, err)
+
+	// 标签超出文件范围
+	data = buildProfile(descTag("sRGB"))
+	_, err = Parse(data[:len(data)-4])
+	assert.Error(t, err)
+
+	tag := vcgtTableTag(2, 2, [][]uint16{{0, 65535}, {0, 65535}})
+	_, err = Parse(buildProfile(tag))
+	assert.Error(t, err)
+
+	tag = vcgtFormulaTag([9]float64{0, 0, 1, 1, 0, 1, 1, 0, 1})
+	_, err = Parse(buildProfile(tag))
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.icc")
+	require.NoError(t, ioutil.WriteFile(filename, buildProfile(vcgtTableTag(1, 2, [][]uint16{{0, math.MaxUint16}})), 0644))
+	profile, err := Load(filename)
+	require.NoError(t, err)
+	require.NotNil(t, profile.VCGT)
+	assert.InDelta(t, 0.3, profile.VCGT.Eval(0, 0.3), 0.0001)
+
+	_, err = Load(filename + ".missing")
+	assert.Error(t, err)
+}
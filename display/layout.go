@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"math"
+	"sort"
+
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+// 边缘距离小于这个值时对齐
+const layoutSnapDistance = 32
+
+// layoutItem 是布局整理中的一个显示器
+type layoutItem struct {
+	monitor *Monitor
+	// mirrorLeader 是 monitor 的其他显示器，与它组成镜像组
+	mirrors []*Monitor
+	rect    x.Rectangle
+	placed  bool
+}
+
+func (item *layoutItem) hasMonitor(id uint32) bool {
+	if item.monitor.ID == id {
+		return true
+	}
+	for _, monitor := range item.mirrors {
+		if monitor.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (item *layoutItem) center() (float64, float64) {
+	return float64(item.rect.X) + float64(item.rect.Width)/2,
+		float64(item.rect.Y) + float64(item.rect.Height)/2
+}
+
+func (item *layoutItem) left() int   { return int(item.rect.X) }
+func (item *layoutItem) top() int    { return int(item.rect.Y) }
+func (item *layoutItem) right() int  { return int(item.rect.X) + int(item.rect.Width) }
+func (item *layoutItem) bottom() int { return int(item.rect.Y) + int(item.rect.Height) }
+
+func (item *layoutItem) setPos(x, y int) {
+	item.rect.X = clampInt16(x)
+	item.rect.Y = clampInt16(y)
+}
+
+func clampInt16(v int) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// snapRange 在 [lo, hi] 范围内调整 v，如果与 targets 中的某个值足够接近则对齐。
+func snapRange(v, lo, hi int, targets ...int) int {
+	if v < lo {
+		v = lo
+	} else if v > hi {
+		v = hi
+	}
+	for _, target := range targets {
+		if target >= lo && target <= hi && abs(v-target) <= layoutSnapDistance {
+			return target
+		}
+	}
+	return v
+}
+
+// inSameMirrorGroup 两个显示器是否在同一个镜像组中，只由 setMonitorsMirrorGroups 和 setMonitorsMirrorLeader
+// 设置的 mirrorLeader 决定，左上角相同但不在镜像组中的显示器是重叠的。
+func inSameMirrorGroup(m1, m2 *Monitor) bool {
+	if m1.mirrorLeader != 0 && (m1.mirrorLeader == m2.ID || m1.mirrorLeader == m2.mirrorLeader) {
+		return true
+	}
+	return m2.mirrorLeader != 0 && m2.mirrorLeader == m1.ID
+}
+
+// normalizeMonitorsLayout 整理扩展模式下显示器的布局，保持显示器之间的相对位置（左右、上下），
+// 让相邻显示器的边缘贴合并消除重叠，最后让整个布局的左上角位于原点。anchor 的相对位置保持不变。
+// 镜像组作为一个整体处理，组内的其他显示器跟随 mirrorLeader 的显示器。
+// 直接修改 monitors 中显示器的 X 和 Y，返回是否有修改。
+func normalizeMonitorsLayout(monitors []*Monitor, anchor *Monitor) (changed bool) {
+	if len(monitors) < 2 {
+		return false
+	}
+	monitors = append([]*Monitor(nil), monitors...)
+	// 按名称排序，让结果稳定
+	sort.Slice(monitors, func(i, j int) bool {
+		return monitors[i].Name < monitors[j].Name
+	})
+	var items []*layoutItem
+	var followers []*Monitor
+	for _, monitor := range monitors {
+		if monitor.mirrorLeader != 0 && Monitors(monitors).GetById(monitor.mirrorLeader) != nil {
+			followers = append(followers, monitor)
+			continue
+		}
+		items = append(items, &layoutItem{
+			monitor: monitor,
+			rect:    getMonitorRect(monitor),
+		})
+	}
+	for _, monitor := range followers {
+		for _, item := range items {
+			if item.monitor.ID != monitor.mirrorLeader {
+				continue
+			}
+			// 镜像组占的区域按最大的显示器算
+			rect := getMonitorRect(monitor)
+			item.mirrors = append(item.mirrors, monitor)
+			if rect.Width > item.rect.Width {
+				item.rect.Width = rect.Width
+			}
+			if rect.Height > item.rect.Height {
+				item.rect.Height = rect.Height
+			}
+			break
+		}
+	}
+	if len(items) < 2 {
+		return false
+	}
+
+	anchorIdx := 0
+	for i, item := range items {
+		if anchor != nil && item.hasMonitor(anchor.ID) {
+			anchorIdx = i
+			break
+		}
+	}
+	items[anchorIdx].placed = true
+	placed := []*layoutItem{items[anchorIdx]}
+
+	for len(placed) < len(items) {
+		// 找出离已放置的显示器最近的显示器，以最近的那个已放置的显示器为参照放置。
+		var next, ref *layoutItem
+		minDist := math.MaxFloat64
+		for _, item := range items {
+			if item.placed {
+				continue
+			}
+			cx, cy := item.center()
+			for _, p := range placed {
+				px, py := p.center()
+				dist := (cx-px)*(cx-px) + (cy-py)*(cy-py)
+				if dist < minDist {
+					minDist = dist
+					next = item
+					ref = p
+				}
+			}
+		}
+
+		placeNextTo(next, ref, placed)
+		next.placed = true
+		placed = append(placed, next)
+	}
+
+	// 平移到原点
+	minX, minY := math.MaxInt32, math.MaxInt32
+	for _, item := range items {
+		if item.left() < minX {
+			minX = item.left()
+		}
+		if item.top() < minY {
+			minY = item.top()
+		}
+	}
+	for _, item := range items {
+		item.setPos(item.left()-minX, item.top()-minY)
+		for _, monitor := range append([]*Monitor{item.monitor}, item.mirrors...) {
+			if monitor.X != item.rect.X || monitor.Y != item.rect.Y {
+				logger.Debugf("normalize layout, move monitor %v from %d,%d to %d,%d", monitor.Name,
+					monitor.X, monitor.Y, item.rect.X, item.rect.Y)
+				monitor.X = item.rect.X
+				monitor.Y = item.rect.Y
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// placeNextTo 根据 item 与 ref 的相对方向，把 item 贴到 ref 的对应边上，再沿这个方向移开与 placed 的重叠。
+func placeNextTo(item, ref *layoutItem, placed []*layoutItem) {
+	cx, cy := item.center()
+	rx, ry := ref.center()
+	// 用两者尺寸之和归一化，判断主要是左右关系还是上下关系
+	dx := (cx - rx) / float64(int(item.rect.Width)+int(ref.rect.Width))
+	dy := (cy - ry) / float64(int(item.rect.Height)+int(ref.rect.Height))
+	horizontal := math.Abs(dx) >= math.Abs(dy)
+
+	w := int(item.rect.Width)
+	h := int(item.rect.Height)
+	if horizontal {
+		newX := ref.right()
+		if dx < 0 {
+			newX = ref.left() - w
+		}
+		// 垂直方向至少要与 ref 有公共部分，顶边或底边接近时对齐
+		newY := snapRange(item.top(), ref.top()-h+1, ref.bottom()-1, ref.top(), ref.bottom()-h)
+		item.setPos(newX, newY)
+	} else {
+		newY := ref.bottom()
+		if dy < 0 {
+			newY = ref.top() - h
+		}
+		newX := snapRange(item.left(), ref.left()-w+1, ref.right()-1, ref.left(), ref.right()-w)
+		item.setPos(newX, newY)
+	}
+
+	// 消除与其他已放置显示器的重叠
+	for {
+		var overlapped *layoutItem
+		for _, p := range placed {
+			if rectsOverlap(item.rect, p.rect) {
+				overlapped = p
+				break
+			}
+		}
+		if overlapped == nil {
+			return
+		}
+		if horizontal {
+			if dx < 0 {
+				item.setPos(overlapped.left()-w, item.top())
+			} else {
+				item.setPos(overlapped.right(), item.top())
+			}
+		} else {
+			if dy < 0 {
+				item.setPos(item.left(), overlapped.top()-h)
+			} else {
+				item.setPos(item.left(), overlapped.bottom())
+			}
+		}
+	}
+}
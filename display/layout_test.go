@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_normalizeMonitorsLayout(t *testing.T) {
+	type pos struct {
+		x, y int16
+	}
+	getPos := func(monitors []*Monitor) []pos {
+		result := make([]pos, len(monitors))
+		for i, monitor := range monitors {
+			result[i] = pos{monitor.X, monitor.Y}
+		}
+		return result
+	}
+
+	// 左屏分辨率变小，右屏贴过来
+	a := newTestMonitor("HDMI-1", true, 0, 0, 1280, 720)
+	b := newTestMonitor("eDP-1", true, 1920, 0, 1920, 1080)
+	monitors := []*Monitor{a, b}
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, []pos{{0, 0}, {1280, 0}}, getPos(monitors))
+
+	// 左屏分辨率变大，消除重叠，以右屏为参照时也保持左右关系
+	a = newTestMonitor("HDMI-1", true, 0, 0, 2560, 1440)
+	a.ID = 1
+	b = newTestMonitor("eDP-1", true, 1920, 0, 1920, 1080)
+	b.ID = 2
+	monitors = []*Monitor{a, b}
+	assert.True(t, normalizeMonitorsLayout(monitors, b))
+	assert.Equal(t, []pos{{0, 0}, {2560, 0}}, getPos(monitors))
+
+	// 上下摆放，保持水平方向的偏移
+	a = newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b = newTestMonitor("eDP-1", true, 100, 1200, 1366, 768)
+	monitors = []*Monitor{a, b}
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, []pos{{0, 0}, {100, 1080}}, getPos(monitors))
+
+	// 三屏一排，中间的变大
+	a = newTestMonitor("DP-1", true, 0, 0, 1920, 1080)
+	b = newTestMonitor("DP-2", true, 1920, 0, 2560, 1440)
+	c := newTestMonitor("DP-3", true, 3840, 0, 1920, 1080)
+	monitors = []*Monitor{a, b, c}
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, []pos{{0, 0}, {1920, 0}, {4480, 0}}, getPos(monitors))
+
+	// 边缘接近时对齐
+	a = newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b = newTestMonitor("eDP-1", true, 1920, 20, 1920, 1080)
+	monitors = []*Monitor{a, b}
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, []pos{{0, 0}, {1920, 0}}, getPos(monitors))
+
+	// 布局本来就没有问题
+	a = newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b = newTestMonitor("eDP-1", true, 1920, 150, 1366, 768)
+	monitors = []*Monitor{a, b}
+	assert.False(t, normalizeMonitorsLayout(monitors, a))
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"strings"
+
+	login1 "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.login1"
+	"github.com/linuxdeepin/go-lib/dbusutil/proxy"
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+)
+
+// 合上笔记本的盖子时，如果还连接了其他显示器，就关闭内置显示器，并把主屏移到其他显示器上，打开盖子后恢复之前的布局。
+// 合盖状态是 monitorsId 的一部分，合盖和开盖时的布局分别保存在不同的配置中。
+
+// 内置显示器合盖时加在 monitorsId.v1 最后的标记
+const monitorsIdLidClosed = "lid-closed"
+
+// isLidClosed monitorsId 是否是内置显示器合盖时的
+func (id monitorsId) isLidClosed() bool {
+	return strings.HasSuffix(id.v1, monitorsIdDelimiter+monitorsIdLidClosed)
+}
+
+// withoutLidClosed 返回同样的显示器在开盖时的 monitorsId
+func (id monitorsId) withoutLidClosed() monitorsId {
+	if !id.isLidClosed() {
+		return id
+	}
+	return monitorsId{
+		v0: id.v0,
+		v1: strings.TrimSuffix(id.v1, monitorsIdDelimiter+monitorsIdLidClosed),
+	}
+}
+
+// watchLidSwitch 获取 logind 的 LidClosed 属性并监听它的改变，go-dbus-factory 中没有这个属性。
+func watchLidSwitch(loginManager login1.Manager, cb func(closed bool)) (closed bool, err error) {
+	impl, ok := loginManager.(proxy.Implementer)
+	if !ok {
+		return false, errors.New("invalid login1 manager")
+	}
+	lidClosedProp := proxy.ImplPropBool{
+		Impl: impl,
+		Name: "LidClosed",
+	}
+	err = lidClosedProp.ConnectChanged(func(hasValue bool, value bool) {
+		if hasValue {
+			cb(value)
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return lidClosedProp.Get(0)
+}
+
+// fixSysMonitorConfigsForLidClosed 禁用合盖的内置显示器，它是主屏时把主屏移到其他启用的显示器上。
+// 只剩一个启用的显示器时把它放到原点，多个时由扩展模式的布局整理处理。
+// 没有其他启用的显示器时（比如只显示内置显示器），用最佳模式启用 fallback 作为主屏，没有它的配置时加上一个。
+func fixSysMonitorConfigsForLidClosed(configs SysMonitorConfigs, builtinUuid string, fallback *Monitor) SysMonitorConfigs {
+	builtinCfg := configs.getByUuid(builtinUuid)
+	if builtinCfg == nil || !builtinCfg.Enabled {
+		return configs
+	}
+	wasPrimary := builtinCfg.Primary
+	builtinCfg.Enabled = false
+	builtinCfg.Primary = false
+
+	var enabledCfgs SysMonitorConfigs
+	for _, cfg := range configs {
+		if cfg.Enabled {
+			enabledCfgs = append(enabledCfgs, cfg)
+		}
+	}
+	if len(enabledCfgs) == 0 {
+		if fallback == nil {
+			return configs
+		}
+		cfg := configs.getByUuid(fallback.uuid)
+		if cfg == nil {
+			cfg = fallback.toBasicSysConfig()
+			cfg.Brightness = 1
+			configs = append(configs, cfg)
+		}
+		if cfg.Rotation == 0 {
+			cfg.Rotation = randr.RotationRotate0
+		}
+		mode := fallback.BestMode
+		cfg.Width, cfg.Height = mode.Width, mode.Height
+		swapWidthHeightWithRotation(cfg.Rotation, &cfg.Width, &cfg.Height)
+		cfg.RefreshRate = mode.Rate
+		cfg.Enabled = true
+		cfg.Primary = true
+		cfg.X = 0
+		cfg.Y = 0
+		return configs
+	}
+	if wasPrimary {
+		enabledCfgs[0].Primary = true
+	}
+	if len(enabledCfgs) == 1 {
+		enabledCfgs[0].X = 0
+		enabledCfgs[0].Y = 0
+	}
+	return configs
+}
+
+// initLidSwitch 监听笔记本的盖子
+func (m *Manager) initLidSwitch(loginManager login1.Manager) {
+	if _useWayland || !m.hasBuiltinMonitor {
+		return
+	}
+	closed, err := watchLidSwitch(loginManager, m.handleLidClosedChanged)
+	if err != nil {
+		logger.Warning("failed to watch lid switch:", err)
+		return
+	}
+	m.PropsMu.Lock()
+	m.lidClosed = closed
+	m.PropsMu.Unlock()
+}
+
+func (m *Manager) handleLidClosedChanged(closed bool) {
+	m.PropsMu.Lock()
+	changed := m.lidClosed != closed
+	m.lidClosed = closed
+	m.PropsMu.Unlock()
+	if !changed {
+		return
+	}
+	logger.Info("lid closed changed:", closed)
+	m.updateMonitorsId(nil)
+}
+
+// updateLidClosedMonitor 设置内置显示器是否处于合盖状态，只有合盖并且连接了其他显示器时才算。
+func (m *Manager) updateLidClosedMonitor() {
+	m.PropsMu.RLock()
+	lidClosed := m.lidClosed
+	m.PropsMu.RUnlock()
+
+	builtinMonitor := m.getBuiltinMonitor()
+	monitors := m.getConnectedMonitors()
+	lidClosed = lidClosed && builtinMonitor != nil && len(monitors) > 1 &&
+		monitors.GetById(builtinMonitor.ID) != nil
+
+	m.monitorMapMu.Lock()
+	for _, monitor := range m.monitorMap {
+		monitor.PropsMu.Lock()
+		monitor.lidClosed = lidClosed && monitor.ID == builtinMonitor.ID
+		monitor.PropsMu.Unlock()
+	}
+	m.monitorMapMu.Unlock()
+}
+
+// getLidClosedMonitor 返回 monitors 中合盖的内置显示器
+func getLidClosedMonitor(monitors []*Monitor) *Monitor {
+	for _, monitor := range monitors {
+		monitor.PropsMu.RLock()
+		lidClosed := monitor.lidClosed
+		monitor.PropsMu.RUnlock()
+		if lidClosed {
+			return monitor
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_monitorsIdLidClosed(t *testing.T) {
+	builtin := newTestMonitor("eDP-1", true, 0, 0, 1920, 1080)
+	builtin.uuid = "uuid-edp"
+	builtin.uuidV0 = "v0-edp"
+	external := newTestMonitor("HDMI-1", true, 1920, 0, 1920, 1080)
+	external.uuid = "uuid-hdmi"
+	external.uuidV0 = "v0-hdmi"
+	monitors := Monitors{builtin, external}
+
+	id := monitors.getMonitorsId()
+	assert.False(t, id.isLidClosed())
+	assert.Equal(t, id, id.withoutLidClosed())
+
+	builtin.lidClosed = true
+	lidClosedId := monitors.getMonitorsId()
+	assert.True(t, lidClosedId.isLidClosed())
+	assert.Equal(t, "uuid-edp,uuid-hdmi,lid-closed", lidClosedId.v1)
+	// v0 不变，合盖时不会用旧版本的配置
+	assert.Equal(t, id.v0, lidClosedId.v0)
+	assert.Equal(t, id, lidClosedId.withoutLidClosed())
+	assert.Equal(t, builtin, getLidClosedMonitor(monitors))
+}
+
+func Test_fixSysMonitorConfigsForLidClosed(t *testing.T) {
+	// 内置显示器是主屏，只剩一个启用的显示器
+	configs := SysMonitorConfigs{
+		{UUID: "uuid-edp", Enabled: true, Primary: true, X: 0, Y: 0, Width: 1920, Height: 1080},
+		{UUID: "uuid-hdmi", Enabled: true, X: 1920, Y: 0, Width: 1920, Height: 1080},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", nil)
+	assert.False(t, configs[0].Enabled)
+	assert.False(t, configs[0].Primary)
+	assert.True(t, configs[1].Primary)
+	assert.Equal(t, int16(0), configs[1].X)
+	assert.Equal(t, int16(0), configs[1].Y)
+
+	// 多个启用的显示器时不改变它们的位置和主屏
+	configs = SysMonitorConfigs{
+		{UUID: "uuid-dp", Enabled: true, X: 0, Y: 0, Width: 1920, Height: 1080},
+		{UUID: "uuid-edp", Enabled: true, X: 1920, Y: 0, Width: 1920, Height: 1080},
+		{UUID: "uuid-hdmi", Enabled: true, Primary: true, X: 3840, Y: 0, Width: 1920, Height: 1080},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", nil)
+	assert.False(t, configs[1].Enabled)
+	assert.False(t, configs[0].Primary)
+	assert.True(t, configs[2].Primary)
+	assert.Equal(t, int16(3840), configs[2].X)
+
+	// 只显示内置显示器时，用最佳模式启用外接显示器作为主屏
+	external := newTestMonitor("HDMI-1", false, 0, 0, 0, 0)
+	external.uuid = "uuid-hdmi"
+	external.BestMode = ModeInfo{Width: 2560, Height: 1440, Rate: 60}
+	configs = SysMonitorConfigs{
+		{UUID: "uuid-edp", Enabled: true, Primary: true, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0},
+		{UUID: "uuid-hdmi", Enabled: false, X: 1920, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", external)
+	require.Len(t, configs, 2)
+	assert.False(t, configs[0].Enabled)
+	assert.False(t, configs[0].Primary)
+	assert.True(t, configs[1].Enabled)
+	assert.True(t, configs[1].Primary)
+	assert.Equal(t, int16(0), configs[1].X)
+	assert.Equal(t, int16(0), configs[1].Y)
+	assert.Equal(t, uint16(2560), configs[1].Width)
+	assert.Equal(t, uint16(1440), configs[1].Height)
+	assert.Equal(t, 60.0, configs[1].RefreshRate)
+
+	// 外接显示器没有配置时加上它的配置
+	configs = SysMonitorConfigs{
+		{UUID: "uuid-edp", Enabled: true, Primary: true, Width: 1920, Height: 1080, Rotation: randr.RotationRotate0},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", external)
+	require.Len(t, configs, 2)
+	assert.Equal(t, "uuid-hdmi", configs[1].UUID)
+	assert.True(t, configs[1].Enabled)
+	assert.True(t, configs[1].Primary)
+	assert.Equal(t, uint16(randr.RotationRotate0), configs[1].Rotation)
+	assert.Equal(t, uint16(2560), configs[1].Width)
+	assert.Equal(t, 1.0, configs[1].Brightness)
+
+	// 没有可以启用的显示器
+	configs = SysMonitorConfigs{
+		{UUID: "uuid-edp", Enabled: true, Primary: true, Width: 1920, Height: 1080},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", nil)
+	require.Len(t, configs, 1)
+	assert.False(t, configs[0].Enabled)
+
+	// 没有内置显示器的配置
+	configs = SysMonitorConfigs{
+		{UUID: "uuid-hdmi", Enabled: true, Primary: true, X: 100, Width: 1920, Height: 1080},
+	}
+	configs = fixSysMonitorConfigsForLidClosed(configs, "uuid-edp", nil)
+	assert.True(t, configs[0].Enabled)
+	assert.Equal(t, int16(100), configs[0].X)
+}
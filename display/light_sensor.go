@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// 环境光传感器，优先用 com.deepin.SensorProxy，没有时用 iio-sensor-proxy 提供的 net.hadess.SensorProxy。
+
+const (
+	sensorProxyGetLightLevel   = sensorProxyInterface + ".GetLightLevel"
+	sensorProxyLightSignalName = "LightLevelChanged"
+	sensorProxyLightSignal     = sensorProxyInterface + "." + sensorProxyLightSignalName
+
+	hadessSensorProxyService   = "net.hadess.SensorProxy"
+	hadessSensorProxyPath      = "/net/hadess/SensorProxy"
+	hadessSensorProxyInterface = "net.hadess.SensorProxy"
+
+	dbusPropertiesInterface = "org.freedesktop.DBus.Properties"
+	dbusPropertiesChanged   = dbusPropertiesInterface + ".PropertiesChanged"
+)
+
+// lightSensor 提供环境光的亮度，单位是 lux。
+type lightSensor interface {
+	getLightLevel() (float64, error)
+	// start 开始监听，光线亮度改变时在另外的 goroutine 中调用 cb
+	start(cb func(lux float64)) error
+	stop()
+}
+
+// findLightSensor 在 conn 所在的总线上查找可用的环境光传感器
+func findLightSensor(conn *dbus.Conn) (lightSensor, error) {
+	deepinSensor := &deepinLightSensor{
+		conn: conn,
+		obj:  conn.Object(sensorProxyInterface, sensorProxyPath),
+	}
+	_, err := deepinSensor.getLightLevel()
+	if err == nil {
+		return deepinSensor, nil
+	}
+	logger.Debug("com.deepin.SensorProxy light sensor is not available:", err)
+
+	hadessSensor := &hadessLightSensor{
+		conn: conn,
+		obj:  conn.Object(hadessSensorProxyService, hadessSensorProxyPath),
+	}
+	has, err := hadessSensor.hasAmbientLight()
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New("no ambient light sensor")
+	}
+	return hadessSensor, nil
+}
+
+// signalWatcher 监听 conn 上的某个信号
+type signalWatcher struct {
+	mu      sync.Mutex
+	conn    *dbus.Conn
+	options []dbus.MatchOption
+	ch      chan *dbus.Signal
+}
+
+func (w *signalWatcher) start(options []dbus.MatchOption, handle func(sig *dbus.Signal)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ch != nil {
+		return nil
+	}
+	err := w.conn.AddMatchSignal(options...)
+	if err != nil {
+		return err
+	}
+	w.options = options
+	w.ch = make(chan *dbus.Signal, 10)
+	w.conn.Signal(w.ch)
+	go func(ch chan *dbus.Signal) {
+		for sig := range ch {
+			handle(sig)
+		}
+	}(w.ch)
+	return nil
+}
+
+func (w *signalWatcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ch == nil {
+		return
+	}
+	err := w.conn.RemoveMatchSignal(w.options...)
+	if err != nil {
+		logger.Warning(err)
+	}
+	w.conn.RemoveSignal(w.ch)
+	close(w.ch)
+	w.ch = nil
+}
+
+type deepinLightSensor struct {
+	conn    *dbus.Conn
+	obj     dbus.BusObject
+	watcher signalWatcher
+}
+
+func (s *deepinLightSensor) getLightLevel() (float64, error) {
+	var lux float64
+	err := s.obj.Call(sensorProxyGetLightLevel, 0).Store(&lux)
+	return lux, err
+}
+
+func (s *deepinLightSensor) start(cb func(lux float64)) error {
+	s.watcher.conn = s.conn
+	return s.watcher.start([]dbus.MatchOption{
+		dbus.WithMatchInterface(sensorProxyInterface),
+		dbus.WithMatchMember(sensorProxyLightSignalName),
+		dbus.WithMatchObjectPath(sensorProxyPath),
+	}, func(sig *dbus.Signal) {
+		if sig.Path != sensorProxyPath || sig.Name != sensorProxyLightSignal {
+			return
+		}
+		var lux float64
+		err := dbus.Store(sig.Body, &lux)
+		if err != nil {
+			logger.Warning(err)
+			return
+		}
+		cb(lux)
+	})
+}
+
+func (s *deepinLightSensor) stop() {
+	s.watcher.stop()
+}
+
+type hadessLightSensor struct {
+	conn    *dbus.Conn
+	obj     dbus.BusObject
+	watcher signalWatcher
+}
+
+func (s *hadessLightSensor) hasAmbientLight() (bool, error) {
+	v, err := s.obj.GetProperty(hadessSensorProxyInterface + ".HasAmbientLight")
+	if err != nil {
+		return false, err
+	}
+	has, _ := v.Value().(bool)
+	return has, nil
+}
+
+func (s *hadessLightSensor) getLightLevel() (float64, error) {
+	v, err := s.obj.GetProperty(hadessSensorProxyInterface + ".LightLevel")
+	if err != nil {
+		return 0, err
+	}
+	lux, ok := v.Value().(float64)
+	if !ok {
+		return 0, errors.New("invalid type of LightLevel")
+	}
+	return lux, nil
+}
+
+// start 需要先调用 ClaimLight，iio-sensor-proxy 才会读取传感器并更新 LightLevel 属性。
+func (s *hadessLightSensor) start(cb func(lux float64)) error {
+	s.watcher.conn = s.conn
+	err := s.watcher.start([]dbus.MatchOption{
+		dbus.WithMatchInterface(dbusPropertiesInterface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchObjectPath(hadessSensorProxyPath),
+	}, func(sig *dbus.Signal) {
+		if sig.Path != hadessSensorProxyPath || sig.Name != dbusPropertiesChanged {
+			return
+		}
+		var iface string
+		var changed map[string]dbus.Variant
+		var invalidated []string
+		err := dbus.Store(sig.Body, &iface, &changed, &invalidated)
+		if err != nil || iface != hadessSensorProxyInterface {
+			return
+		}
+		v, ok := changed["LightLevel"]
+		if !ok {
+			return
+		}
+		if lux, ok := v.Value().(float64); ok {
+			cb(lux)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	err = s.obj.Call(hadessSensorProxyInterface+".ClaimLight", 0).Err
+	if err != nil {
+		s.watcher.stop()
+		return err
+	}
+	return nil
+}
+
+func (s *hadessLightSensor) stop() {
+	err := s.obj.Call(hadessSensorProxyInterface+".ReleaseLight", 0).Err
+	if err != nil {
+		logger.Warning(err)
+	}
+	s.watcher.stop()
+}
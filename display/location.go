@@ -0,0 +1,388 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	geoclue2 "github.com/linuxdeepin/go-dbus-factory/system/org.freedesktop.geoclue2"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+)
+
+// 自动色温使用的位置，按优先级依次来自：用户手动设置的经纬度、GeoClue2 定位、当前时区的代表城市。
+
+const (
+	LocationSourceManual   = "manual"
+	LocationSourceGeoClue  = "geoclue"
+	LocationSourceTimezone = "timezone"
+)
+
+const (
+	geoClueDesktopId = "startdde"
+	// 位置变化超过 10 公里才通知
+	geoClueDistanceThreshold = 10000
+	// GeoClue2 客户端启动失败后重试的间隔
+	geoClueRetryMinDelay = 30 * time.Second
+	geoClueRetryMaxDelay = 30 * time.Minute
+)
+
+type geoLocation struct {
+	latitude  float64
+	longitude float64
+}
+
+func isValidLocation(latitude, longitude float64) bool {
+	return !math.IsNaN(latitude) && !math.IsNaN(longitude) &&
+		latitude >= -90 && latitude <= 90 &&
+		longitude >= -180 && longitude <= 180
+}
+
+// getTimezoneLocation 获取时区的代表城市的位置，未知的时区用 UTC 偏移估算经度。
+func getTimezoneLocation(zoneInfoMap map[string]*zoneInfo, timezone string) geoLocation {
+	info := zoneInfoMap[timezone]
+	if info != nil {
+		return geoLocation{latitude: info.latitude, longitude: info.longitude}
+	}
+	_, offset := time.Now().Zone()
+	longitude := float64(offset) / 3600 * 15
+	logger.Warningf("unknown timezone %q, guess longitude %v", timezone, longitude)
+	return geoLocation{longitude: longitude}
+}
+
+// locationProvider 从各个来源中选出优先级最高的位置，改变时调用 cb。
+type locationProvider struct {
+	mu       sync.Mutex
+	manual   *geoLocation
+	geoClue  *geoLocation
+	timezone geoLocation
+	current  geoLocation
+	source   string
+	cb       func(location geoLocation, source string)
+}
+
+func newLocationProvider() *locationProvider {
+	return &locationProvider{
+		source: LocationSourceTimezone,
+	}
+}
+
+func (p *locationProvider) get() (geoLocation, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current, p.source
+}
+
+func (p *locationProvider) hasManual() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.manual != nil
+}
+
+// update 修改某个来源的位置后重新选择位置
+func (p *locationProvider) update(fn func()) {
+	p.mu.Lock()
+	fn()
+	location, source := p.timezone, LocationSourceTimezone
+	if p.manual != nil {
+		location, source = *p.manual, LocationSourceManual
+	} else if p.geoClue != nil {
+		location, source = *p.geoClue, LocationSourceGeoClue
+	}
+	changed := location != p.current || source != p.source
+	p.current = location
+	p.source = source
+	p.mu.Unlock()
+
+	if changed {
+		logger.Infof("location changed to %v, %v, source: %s", location.latitude, location.longitude, source)
+		if p.cb != nil {
+			p.cb(location, source)
+		}
+	}
+}
+
+// setManual 设置手动指定的位置，为空时取消
+func (p *locationProvider) setManual(location *geoLocation) {
+	p.update(func() {
+		p.manual = location
+	})
+}
+
+func (p *locationProvider) setGeoClue(location *geoLocation) {
+	p.update(func() {
+		p.geoClue = location
+	})
+}
+
+func (p *locationProvider) setTimezone(location geoLocation) {
+	p.update(func() {
+		p.timezone = location
+	})
+}
+
+// geoClueLocator 是 GeoClue2 的客户端，收到新位置时调用 cb。
+type geoClueLocator struct {
+	conn   *dbus.Conn
+	client geoclue2.Client
+	cb     func(location geoLocation)
+}
+
+func startGeoClueLocator(conn *dbus.Conn, sigLoop *dbusutil.SignalLoop,
+	cb func(location geoLocation)) (*geoClueLocator, error) {
+	manager := geoclue2.NewManager(conn)
+	clientPath, err := manager.GetClient(0)
+	if err != nil {
+		return nil, err
+	}
+	client, err := geoclue2.NewClient(conn, clientPath)
+	if err != nil {
+		return nil, err
+	}
+	err = client.DesktopId().Set(0, geoClueDesktopId)
+	if err != nil {
+		return nil, err
+	}
+	err = client.RequestedAccuracyLevel().Set(0, AccuracyLevelCity)
+	if err != nil {
+		return nil, err
+	}
+	err = client.DistanceThreshold().Set(0, geoClueDistanceThreshold)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	l := &geoClueLocator{
+		conn:   conn,
+		client: client,
+		cb:     cb,
+	}
+	client.InitSignalExt(sigLoop, true)
+	_, err = client.ConnectLocationUpdated(func(old dbus.ObjectPath, new dbus.ObjectPath) {
+		l.handleLocationUpdated(new)
+	})
+	if err != nil {
+		client.RemoveAllHandlers()
+		return nil, err
+	}
+	err = client.Start(0)
+	if err != nil {
+		client.RemoveAllHandlers()
+		return nil, err
+	}
+
+	// 可能在连接信号之前就已经有位置了
+	locationPath, err := client.Location().Get(0)
+	if err == nil && locationPath != "/" {
+		l.handleLocationUpdated(locationPath)
+	}
+	return l, nil
+}
+
+func (l *geoClueLocator) handleLocationUpdated(path dbus.ObjectPath) {
+	location, err := geoclue2.NewLocation(l.conn, path)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	latitude, err := location.Latitude().Get(0)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	longitude, err := location.Longitude().Get(0)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	if !isValidLocation(latitude, longitude) {
+		logger.Warningf("invalid location from geoclue: %v, %v", latitude, longitude)
+		return
+	}
+	logger.Debugf("geoclue location updated: %v, %v", latitude, longitude)
+	l.cb(geoLocation{latitude: latitude, longitude: longitude})
+}
+
+func (l *geoClueLocator) stop() {
+	l.client.RemoveAllHandlers()
+	err := l.client.Stop(0)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
+
+func (m *Manager) handleLocationChanged(location geoLocation, source string) {
+	m.PropsMu.Lock()
+	m.setPropLocationSource(source)
+	m.PropsMu.Unlock()
+	m.nightLight.setLocation(location.latitude, location.longitude)
+}
+
+// updateTimezoneLocation 时区改变时更新时区对应的位置
+func (m *Manager) updateTimezoneLocation() {
+	m.location.setTimezone(getTimezoneLocation(m.zoneInfoMap, _timeZone))
+}
+
+// initLocation 加载手动设置的位置
+func (m *Manager) initLocation() {
+	m.userCfgMu.Lock()
+	cfg := m.userConfig.Location
+	m.userCfgMu.Unlock()
+	if cfg != nil {
+		m.location.setManual(&geoLocation{latitude: cfg.Latitude, longitude: cfg.Longitude})
+	}
+}
+
+// geoClueStopper 是正在运行的 GeoClue2 客户端
+type geoClueStopper interface {
+	stop()
+}
+
+// geoClueRunner 根据是否需要定位启动或停止 GeoClue2 客户端，启动失败时逐渐延长间隔重试。
+type geoClueRunner struct {
+	mu         sync.Mutex
+	clock      nightLightClock
+	start      func() (geoClueStopper, error)
+	wanted     bool
+	starting   bool
+	locator    geoClueStopper
+	retryTimer nightLightTimer
+	retryDelay time.Duration
+}
+
+func newGeoClueRunner(clock nightLightClock, start func() (geoClueStopper, error)) *geoClueRunner {
+	return &geoClueRunner{
+		clock: clock,
+		start: start,
+	}
+}
+
+func (r *geoClueRunner) setWanted(wanted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wanted = wanted
+	if !wanted {
+		if r.retryTimer != nil {
+			r.retryTimer.Stop()
+			r.retryTimer = nil
+		}
+		r.retryDelay = 0
+		if r.locator != nil {
+			r.locator.stop()
+			r.locator = nil
+		}
+		return
+	}
+	if r.locator != nil || r.starting || r.retryTimer != nil {
+		return
+	}
+	r.starting = true
+	go r.startLocator()
+}
+
+func (r *geoClueRunner) startLocator() {
+	locator, err := r.start()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starting = false
+	if err != nil {
+		if !r.wanted {
+			return
+		}
+		r.retryDelay *= 2
+		if r.retryDelay < geoClueRetryMinDelay {
+			r.retryDelay = geoClueRetryMinDelay
+		} else if r.retryDelay > geoClueRetryMaxDelay {
+			r.retryDelay = geoClueRetryMaxDelay
+		}
+		logger.Warningf("failed to start geoclue client: %v, retry after %v", err, r.retryDelay)
+		r.retryTimer = r.clock.AfterFunc(r.retryDelay, r.retry)
+		return
+	}
+	r.retryDelay = 0
+	// 启动期间已经不需要定位
+	if !r.wanted {
+		locator.stop()
+		return
+	}
+	r.locator = locator
+}
+
+func (r *geoClueRunner) retry() {
+	r.mu.Lock()
+	r.retryTimer = nil
+	wanted := r.wanted
+	r.mu.Unlock()
+	if wanted {
+		r.setWanted(true)
+	}
+}
+
+// startGeoClue 注册 GeoClue2 的 agent 并启动客户端，没有其他 agent 时 GeoClue2 不允许客户端定位。
+func (m *Manager) startGeoClue() (geoClueStopper, error) {
+	if !m.geoClueAgentRegistered {
+		err := registerGeoClueAgent(dbusutil.NewService(m.sysBus))
+		if err != nil {
+			// 可能已经有其他 agent，继续启动客户端
+			logger.Warning("failed to register geoclue agent:", err)
+		} else {
+			m.geoClueAgentRegistered = true
+		}
+	}
+	locator, err := startGeoClueLocator(m.sysBus, m.sysSigLoop, func(location geoLocation) {
+		m.location.setGeoClue(&location)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locator, nil
+}
+
+// updateGeoClueLocator 只在自动色温模式下并且没有手动设置位置时使用 GeoClue2 定位
+func (m *Manager) updateGeoClueLocator() {
+	if m.sysBus == nil {
+		return
+	}
+	m.geoClue.setWanted(m.nightLight.isRunning() && !m.location.hasManual())
+}
+
+// dbus 上导出的方法
+func (m *Manager) setLocation(latitude, longitude float64) error {
+	if !isValidLocation(latitude, longitude) {
+		return errors.New("invalid latitude or longitude")
+	}
+	m.userCfgMu.Lock()
+	m.userConfig.Location = &UserLocationConfig{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+	err := m.saveUserConfigNoLock()
+	m.userCfgMu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	m.location.setManual(&geoLocation{latitude: latitude, longitude: longitude})
+	m.updateGeoClueLocator()
+	return nil
+}
+
+// dbus 上导出的方法
+func (m *Manager) clearLocation() {
+	m.userCfgMu.Lock()
+	m.userConfig.Location = nil
+	err := m.saveUserConfigNoLock()
+	m.userCfgMu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	m.location.setManual(nil)
+	m.updateGeoClueLocator()
+}
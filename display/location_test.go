@@ -0,0 +1,320 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/linuxdeepin/go-lib/dbusutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isValidLocation(t *testing.T) {
+	assert.True(t, isValidLocation(beijingLatitude, beijingLongitude))
+	assert.True(t, isValidLocation(-90, 180))
+	assert.False(t, isValidLocation(91, 0))
+	assert.False(t, isValidLocation(0, -181))
+	assert.False(t, isValidLocation(math.NaN(), 0))
+}
+
+func Test_getTimezoneLocation(t *testing.T) {
+	zoneInfoMap := map[string]*zoneInfo{
+		"Asia/Shanghai": {country: "CN", latitude: 31.2333, longitude: 121.4666},
+	}
+	assert.Equal(t, geoLocation{latitude: 31.2333, longitude: 121.4666},
+		getTimezoneLocation(zoneInfoMap, "Asia/Shanghai"))
+	location := getTimezoneLocation(zoneInfoMap, "Unknown/Zone")
+	assert.Equal(t, 0.0, location.latitude)
+}
+
+func TestLocationProvider(t *testing.T) {
+	p := newLocationProvider()
+	type change struct {
+		location geoLocation
+		source   string
+	}
+	var changes []change
+	p.cb = func(location geoLocation, source string) {
+		changes = append(changes, change{location, source})
+	}
+
+	tz := geoLocation{latitude: 31.2, longitude: 121.5}
+	p.setTimezone(tz)
+	location, source := p.get()
+	assert.Equal(t, tz, location)
+	assert.Equal(t, LocationSourceTimezone, source)
+	assert.Len(t, changes, 1)
+
+	// GeoClue 优先于时区
+	beijing := geoLocation{latitude: beijingLatitude, longitude: beijingLongitude}
+	p.setGeoClue(&beijing)
+	assert.Equal(t, change{beijing, LocationSourceGeoClue}, changes[1])
+	p.setTimezone(geoLocation{latitude: 30, longitude: 120})
+	assert.Len(t, changes, 2)
+
+	// 手动设置的优先级最高
+	manual := geoLocation{latitude: 22.5, longitude: 114}
+	p.setManual(&manual)
+	assert.True(t, p.hasManual())
+	assert.Equal(t, change{manual, LocationSourceManual}, changes[2])
+	p.setGeoClue(&geoLocation{latitude: 40, longitude: 116})
+	assert.Len(t, changes, 3)
+
+	p.setManual(nil)
+	assert.False(t, p.hasManual())
+	assert.Equal(t, change{geoLocation{latitude: 40, longitude: 116}, LocationSourceGeoClue}, changes[3])
+	p.setGeoClue(nil)
+	assert.Equal(t, change{geoLocation{latitude: 30, longitude: 120}, LocationSourceTimezone}, changes[4])
+}
+
+const (
+	geoClueService     = "org.freedesktop.GeoClue2"
+	geoClueManagerPath = "/org/freedesktop/GeoClue2/Manager"
+	geoClueClientPath  = "/org/freedesktop/GeoClue2/Client/1"
+)
+
+// mockGeoClueManager 模拟 GeoClue2 服务的 Manager 对象
+type mockGeoClueManager struct {
+	PropsMu                sync.RWMutex
+	InUse                  bool
+	AvailableAccuracyLevel uint32
+}
+
+func (m *mockGeoClueManager) GetInterfaceName() string {
+	return "org.freedesktop.GeoClue2.Manager"
+}
+
+func (m *mockGeoClueManager) GetExportedMethods() dbusutil.ExportedMethods {
+	return dbusutil.ExportedMethods{
+		{Name: "GetClient", Fn: m.GetClient, OutArgs: []string{"client"}},
+	}
+}
+
+func (m *mockGeoClueManager) GetClient() (dbus.ObjectPath, *dbus.Error) {
+	return geoClueClientPath, nil
+}
+
+type mockGeoClueClient struct {
+	service *dbusutil.Service
+	PropsMu sync.RWMutex
+
+	Location               dbus.ObjectPath
+	DistanceThreshold      uint32 `prop:"access:rw"`
+	TimeThreshold          uint32 `prop:"access:rw"`
+	DesktopId              string `prop:"access:rw"`
+	RequestedAccuracyLevel uint32 `prop:"access:rw"`
+	Active                 bool
+
+	locations []*mockGeoClueLocation
+
+	//nolint
+	signals *struct {
+		LocationUpdated struct {
+			old dbus.ObjectPath
+			new dbus.ObjectPath
+		}
+	}
+}
+
+func (c *mockGeoClueClient) GetInterfaceName() string {
+	return "org.freedesktop.GeoClue2.Client"
+}
+
+func (c *mockGeoClueClient) GetExportedMethods() dbusutil.ExportedMethods {
+	return dbusutil.ExportedMethods{
+		{Name: "Start", Fn: c.Start},
+		{Name: "Stop", Fn: c.Stop},
+	}
+}
+
+func (c *mockGeoClueClient) Start() *dbus.Error {
+	c.PropsMu.Lock()
+	c.Active = true
+	c.PropsMu.Unlock()
+	return nil
+}
+
+func (c *mockGeoClueClient) Stop() *dbus.Error {
+	c.PropsMu.Lock()
+	c.Active = false
+	c.PropsMu.Unlock()
+	return nil
+}
+
+func (c *mockGeoClueClient) getProps() (desktopId string, accuracyLevel uint32, active bool) {
+	c.PropsMu.RLock()
+	defer c.PropsMu.RUnlock()
+	return c.DesktopId, c.RequestedAccuracyLevel, c.Active
+}
+
+// setLocation 导出新的 Location 对象并发送 LocationUpdated 信号
+func (c *mockGeoClueClient) setLocation(latitude, longitude float64) error {
+	c.PropsMu.Lock()
+	location := &mockGeoClueLocation{Latitude: latitude, Longitude: longitude, Accuracy: 1000}
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/GeoClue2/Client/1/Location/%d", len(c.locations)))
+	err := c.service.Export(path, location)
+	if err != nil {
+		c.PropsMu.Unlock()
+		return err
+	}
+	c.locations = append(c.locations, location)
+	old := c.Location
+	c.Location = path
+	c.PropsMu.Unlock()
+	return c.service.Emit(c, "LocationUpdated", old, path)
+}
+
+type mockGeoClueLocation struct {
+	PropsMu   sync.RWMutex
+	Latitude  float64
+	Longitude float64
+	Accuracy  float64
+}
+
+func (l *mockGeoClueLocation) GetInterfaceName() string {
+	return "org.freedesktop.GeoClue2.Location"
+}
+
+func TestGeoClueLocator(t *testing.T) {
+	service, err := dbusutil.NewSessionService()
+	if err != nil {
+		t.Skip("failed to get session service:", err)
+	}
+	manager := &mockGeoClueManager{AvailableAccuracyLevel: AccuracyLevelExact}
+	client := &mockGeoClueClient{service: service, Location: "/"}
+	require.NoError(t, service.Export(geoClueManagerPath, manager))
+	require.NoError(t, service.Export(geoClueClientPath, client))
+	require.NoError(t, service.RequestName(geoClueService))
+	defer func() {
+		_ = service.ReleaseName(geoClueService)
+		_ = service.StopExport(client)
+		_ = service.StopExport(manager)
+		for _, location := range client.locations {
+			_ = service.StopExport(location)
+		}
+	}()
+
+	conn, err := dbus.SessionBusPrivate()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Auth(nil))
+	require.NoError(t, conn.Hello())
+	sigLoop := dbusutil.NewSignalLoop(conn, 10)
+	sigLoop.Start()
+	defer sigLoop.Stop()
+
+	// 启动前已有的位置
+	require.NoError(t, client.setLocation(31.2, 121.5))
+	locationCh := make(chan geoLocation, 10)
+	locator, err := startGeoClueLocator(conn, sigLoop, func(location geoLocation) {
+		locationCh <- location
+	})
+	require.NoError(t, err)
+	desktopId, accuracyLevel, active := client.getProps()
+	assert.Equal(t, geoClueDesktopId, desktopId)
+	assert.Equal(t, uint32(AccuracyLevelCity), accuracyLevel)
+	assert.True(t, active)
+
+	receive := func() geoLocation {
+		select {
+		case location := <-locationCh:
+			return location
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for location")
+		}
+		return geoLocation{}
+	}
+	assert.Equal(t, geoLocation{latitude: 31.2, longitude: 121.5}, receive())
+
+	require.NoError(t, client.setLocation(beijingLatitude, beijingLongitude))
+	assert.Equal(t, geoLocation{latitude: beijingLatitude, longitude: beijingLongitude}, receive())
+
+	locator.stop()
+	_, _, active = client.getProps()
+	assert.False(t, active)
+}
+
+type fakeGeoClueLocator struct {
+	stopped bool
+}
+
+func (l *fakeGeoClueLocator) stop() {
+	l.stopped = true
+}
+
+func TestGeoClueRunner(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 3, 1, 10, 0, 0, 0, zoneCST)}
+	var mu sync.Mutex
+	var starts int
+	var startErr error
+	var locators []*fakeGeoClueLocator
+	r := newGeoClueRunner(clock, func() (geoClueStopper, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		starts++
+		if startErr != nil {
+			return nil, startErr
+		}
+		locator := &fakeGeoClueLocator{}
+		locators = append(locators, locator)
+		return locator, nil
+	})
+	getStarts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return starts
+	}
+	waitStarted := func() {
+		assert.Eventually(t, func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return !r.starting
+		}, time.Second, time.Millisecond)
+	}
+
+	// 启动失败后重试，间隔逐渐变长
+	startErr = errors.New("geoclue is not available")
+	r.setWanted(true)
+	waitStarted()
+	assert.Equal(t, 1, getStarts())
+	r.setWanted(true)
+	assert.Equal(t, 1, getStarts())
+	clock.Advance(geoClueRetryMinDelay)
+	waitStarted()
+	assert.Equal(t, 2, getStarts())
+	clock.Advance(geoClueRetryMinDelay)
+	waitStarted()
+	assert.Equal(t, 2, getStarts())
+
+	mu.Lock()
+	startErr = nil
+	mu.Unlock()
+	clock.Advance(geoClueRetryMinDelay)
+	waitStarted()
+	assert.Equal(t, 3, getStarts())
+	require.Len(t, locators, 1)
+	r.setWanted(true)
+	assert.Equal(t, 3, getStarts())
+
+	r.setWanted(false)
+	assert.True(t, locators[0].stopped)
+
+	// 不需要定位时取消重试
+	mu.Lock()
+	startErr = errors.New("geoclue is not available")
+	mu.Unlock()
+	r.setWanted(true)
+	waitStarted()
+	r.setWanted(false)
+	clock.Advance(geoClueRetryMaxDelay)
+	assert.Equal(t, 4, getStarts())
+}
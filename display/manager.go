@@ -24,6 +24,7 @@ import (
 	"github.com/godbus/dbus/v5"
 	"github.com/linuxdeepin/dde-api/dxinput"
 	dxutil "github.com/linuxdeepin/dde-api/dxinput/utils"
+	sessiondbus "github.com/linuxdeepin/go-dbus-factory/session/org.freedesktop.dbus"
 	sysdisplay "github.com/linuxdeepin/go-dbus-factory/system/org.deepin.dde.display1"
 	dgesture "github.com/linuxdeepin/go-dbus-factory/system/org.deepin.dde.gesture1"
 	inputdevices "github.com/linuxdeepin/go-dbus-factory/system/org.deepin.dde.inputdevices1"
@@ -47,6 +48,8 @@ const (
 	DisplayModeExtend
 	DisplayModeOnlyOne
 	DisplayModeUnknown
+	// 扩展模式中有镜像组，只作为 GetRealDisplayMode 的返回值
+	DisplayModeHybrid
 )
 
 // DisplayModeInvalid 无效的模式
@@ -76,15 +79,18 @@ const (
 	gsKeyMapOutput   = "map-output"
 	gsKeyRateFilter  = "rate-filter"
 	//gsKeyPrimary     = "primary"
-	gsKeyCustomMode              = "current-custom-mode"
-	gsKeyColorTemperatureMode    = "color-temperature-mode"
-	gsKeyColorTemperatureManual  = "color-temperature-manual"
-	gsKeyRotateScreenTimeDelay   = "rotate-screen-time-delay"
-	customModeDelim              = "+"
-	monitorsIdDelimiter          = ","
-	defaultTemperatureMode       = ColorTemperatureModeNone
-	defaultTemperatureManual     = 6500
-	defaultRotateScreenTimeDelay = 500
+	gsKeyColorTemperatureMode       = "color-temperature-mode"
+	gsKeyColorTemperatureManual     = "color-temperature-manual"
+	gsKeyRotateScreenTimeDelay      = "rotate-screen-time-delay"
+	gsKeyBrightnessAnimation        = "brightness-animation-duration"
+	gsKeyColorTemperatureDay        = "color-temperature-day"
+	gsKeyColorTemperatureNight      = "color-temperature-night"
+	gsKeyColorTemperatureTransition = "color-temperature-transition"
+	customModeDelim                 = "+"
+	monitorsIdDelimiter             = ","
+	defaultTemperatureMode          = ColorTemperatureModeNone
+	defaultTemperatureManual        = 6500
+	defaultRotateScreenTimeDelay    = 500
 
 	cmdTouchscreenDialogBin = "/usr/lib/deepin-daemon/dde-touchscreen-dialog"
 )
@@ -147,15 +153,27 @@ type Manager struct {
 	builtinMonitorMu         sync.Mutex
 	candidateBuiltinMonitors []*Monitor // 候补的
 
-	monitorMap     map[uint32]*Monitor
-	monitorMapMu   sync.Mutex
-	mm             monitorManager
-	debugOpts      debugOptions
-	redshiftRunner *redshiftRunner
+	monitorMap   map[uint32]*Monitor
+	monitorMapMu sync.Mutex
+	mm           monitorManager
+	debugOpts    debugOptions
+	nightLight   *nightLight
+	// 定时模式的色温计算
+	scheduleLight       *nightLight
+	colorTempInhibitors *colorTempInhibitors
+	sessionDBusDaemon   sessiondbus.DBus
+	// 各个时区的经纬度
+	zoneInfoMap map[string]*zoneInfo
+	location    *locationProvider
+	geoClue     *geoClueRunner
+	// 只在 geoClue 启动客户端时访问
+	geoClueAgentRegistered bool
 
 	sessionActive bool
-	newSysCfg     *SysRootConfig
-	cursorShowed  bool
+	// 笔记本的盖子是否合上，用 PropsMu 保护
+	lidClosed    bool
+	newSysCfg    *SysRootConfig
+	cursorShowed bool
 
 	// gsettings com.deepin.dde.display
 	settings                 *gio.Settings
@@ -163,6 +181,8 @@ type Manager struct {
 	monitorsIdMu             sync.Mutex
 	hasBuiltinMonitor        bool
 	rotateScreenTimeDelay    int32
+	brightnessAnimator       *brightnessAnimator
+	autoBrightness           *autoBrightnessController // 没有环境光传感器时为 nil，用 PropsMu 保护
 	setFillModeMu            sync.Mutex
 	delayApplyTimer          *time.Timer
 	delayApplyOptions        applyOptions
@@ -174,6 +194,14 @@ type Manager struct {
 	applySaveMu              sync.Mutex
 	inApply                  bool
 	futureConfig             monitorsFutureConfig
+	changesConfirmer         *changesConfirmer
+	// 变换缩放的渲染缩放比，为 0 表示没有使用，用 PropsMu 保护
+	renderScale float64
+	// 热插拔规则和上次选择完规则时的 monitorsId，displayRulesReloaded 为 true 时即使有保存的配置也重新选择规则
+	displayRules           []*DisplayRule
+	displayRulesMonitorsId monitorsId
+	displayRulesReloaded   bool
+	displayRulesMu         sync.Mutex
 
 	// dbusutil-gen: equal=objPathsEqual
 	Monitors []dbus.ObjectPath
@@ -217,6 +245,28 @@ type Manager struct {
 
 	ColorTemperatureEnabled bool `prop:"access:rw"`
 	SupportColorTemperature bool
+	TransformScaling        bool
+	// 复制模式的策略，common、fit、fill 或 stretch
+	MirrorStrategy string
+	// 是否根据环境光自动调节内置显示器的亮度
+	AutoBrightness        bool `prop:"access:rw"`
+	HasAmbientLightSensor bool
+	// dbusutil-gen: equal=nil
+	Inhibitors []ColorTemperatureInhibitor // 暂停调节色温的请求
+	// 自动色温使用的位置的来源
+	LocationSource string
+	// 当前连接的显示器匹配的热插拔规则的名称，没有匹配时为空
+	MatchedRule string
+	// dbusutil-gen: equal=nil
+	Providers []ProviderInfo // 显卡的信息，randr 1.4 以下和 wayland 下为空
+
+	//nolint
+	signals *struct {
+		ChangesConfirmStarted struct {
+			timeout int32
+		}
+		ChangesConfirmed, ChangesReverted struct{}
+	}
 }
 
 type monitorSizeInfo struct {
@@ -229,17 +279,14 @@ var _timeZone string
 
 func newManager(service *dbusutil.Service) *Manager {
 	m := &Manager{
-		service:        service,
-		monitorMap:     make(map[uint32]*Monitor),
-		Brightness:     make(map[string]float64),
-		redshiftRunner: newRedshiftRunner(),
+		service:     service,
+		monitorMap:  make(map[uint32]*Monitor),
+		Brightness:  make(map[string]float64),
+		zoneInfoMap: loadZoneInfoMap(timeZoneFile),
 		unsupportGammaDrmList: []string{
 			"Loongson",
 		},
 	}
-	m.redshiftRunner.cb = func(value int) {
-		m.setColorTempOneShot()
-	}
 
 	chassis, err := getComputeChassis()
 	if err != nil {
@@ -249,9 +296,28 @@ func newManager(service *dbusutil.Service) *Manager {
 		m.hasBuiltinMonitor = true
 	}
 
+	m.changesConfirmer = newChangesConfirmer(realClock{}, m.restoreChangesConfirm)
+
 	m.settings = gio.NewSettings(gsSchemaDisplay)
-	m.CurrentCustomId = m.settings.GetString(gsKeyCustomMode)
 	m.rotateScreenTimeDelay = m.settings.GetInt(gsKeyRotateScreenTimeDelay)
+	m.brightnessAnimator = newBrightnessAnimator(
+		time.Duration(m.settings.GetInt(gsKeyBrightnessAnimation))*time.Millisecond, m.applyMonitorBrightness,
+		m.getBrightnessAnimationInterval)
+	m.nightLight = newNightLight(realClock{}, m.getNightLightConfig())
+	m.nightLight.cb = func(value int) {
+		m.setColorTempOneShot()
+	}
+	m.scheduleLight = newNightLight(realClock{}, nightLightConfig{})
+	m.scheduleLight.setSchedule(getDefaultColorTempScheduleConfig().toSchedule())
+	m.scheduleLight.cb = func(value int) {
+		m.setColorTempOneShot()
+	}
+	m.location = newLocationProvider()
+	m.location.cb = m.handleLocationChanged
+	m.LocationSource = LocationSourceTimezone
+	m.geoClue = newGeoClueRunner(realClock{}, m.startGeoClue)
+	m.colorTempInhibitors = newColorTempInhibitors(realClock{})
+	m.colorTempInhibitors.cb = m.handleColorTempInhibitorsChanged
 	m.ColorTemperatureManual = defaultTemperatureManual
 	m.ColorTemperatureMode = defaultTemperatureMode
 
@@ -272,6 +338,7 @@ func newManager(service *dbusutil.Service) *Manager {
 	}
 
 	m.mm.setHooks(m)
+	m.Providers = m.mm.getProviders()
 
 	m.setPropMaxBacklightBrightness(uint32(brightness.GetMaxBacklightBrightness()))
 
@@ -297,6 +364,7 @@ func newManager(service *dbusutil.Service) *Manager {
 		logger.Warning(err)
 		_timeZone = "Asia/Beijing"
 	}
+	m.updateTimezoneLocation()
 	go func() {
 		m.listenTimezone()
 	}()
@@ -308,6 +376,8 @@ func newManager(service *dbusutil.Service) *Manager {
 		if !isSleep {
 			logger.Info("system Wakeup, need reacquire screen status", isSleep)
 			m.initScreenRotation()
+			// 待机期间定时器不计时，需要立即重新计算色温
+			m.handleClockChanged()
 
 			logger.Info("Cancel wm blackscreen effect")
 			cmd := exec.Command("/bin/bash", "-c", "dbus-send --print-reply --dest=org.kde.KWin /BlackScreen org.kde.kwin.BlackScreen.setActive boolean:false")
@@ -321,6 +391,12 @@ func newManager(service *dbusutil.Service) *Manager {
 	if err != nil {
 		logger.Warning("failed to connect signal PrepareForSleep:", err)
 	}
+	m.initLidSwitch(loginManager)
+
+	err = watchClockChange(m.handleClockChanged)
+	if err != nil {
+		logger.Warning("failed to watch clock change:", err)
+	}
 
 	userPath, err := loginManager.GetUser(0, uint32(os.Getuid()))
 	if err != nil {
@@ -446,8 +522,15 @@ func (m *Manager) handleSysConfigUpdated(newSysConfig *SysRootConfig) {
 	newCfg.updateUuid(monitors)
 
 	fillModesEq := reflect.DeepEqual(currentCfg.FillModes, newCfg.FillModes)
+	colorProfilesEq := reflect.DeepEqual(currentCfg.ColorProfiles, newCfg.ColorProfiles)
+	outputPropertiesEq := reflect.DeepEqual(currentCfg.OutputProperties, newCfg.OutputProperties)
+	underscansEq := reflect.DeepEqual(currentCfg.Underscans, newCfg.Underscans)
 	displayModeEq := currentCfg.DisplayMode == newCfg.DisplayMode
 	scaleFactorsEq := reflect.DeepEqual(currentCfg.ScaleFactors, newCfg.ScaleFactors)
+	// 开启变换缩放时，缩放比改变也要重新设置 crtc
+	transformScalingEq := currentCfg.TransformScaling == newCfg.TransformScaling &&
+		(scaleFactorsEq || !newCfg.TransformScaling)
+	mirrorStrategyEq := currentCfg.MirrorStrategy == newCfg.MirrorStrategy
 	single := len(monitors) == 1
 	monitorsId := monitors.getMonitorsId()
 	currentMonitorCfgs := currentCfg.getMonitorConfigs(monitorsId, currentCfg.DisplayMode, single)
@@ -463,6 +546,12 @@ func (m *Manager) handleSysConfigUpdated(newSysConfig *SysRootConfig) {
 	}
 
 	setCfg()
+	m.updatePropCustomMode(monitorsId)
+	mirrorStrategy := m.getMirrorStrategy()
+	m.PropsMu.Lock()
+	m.setPropTransformScaling(!_useWayland && newCfg.TransformScaling)
+	m.setPropMirrorStrategy(mirrorStrategy)
+	m.PropsMu.Unlock()
 
 	if !scaleFactorsEq {
 		// scale factors 改变了
@@ -479,6 +568,45 @@ func (m *Manager) handleSysConfigUpdated(newSysConfig *SysRootConfig) {
 		}
 	}
 
+	if !colorProfilesEq {
+		logger.Debug("color profiles changed")
+		go func() {
+			for _, monitor := range m.getConnectedMonitors() {
+				monitor.PropsMu.RLock()
+				uuid := monitor.uuid
+				monitor.PropsMu.RUnlock()
+				m.restoreMonitorColorProfile(monitor, uuid)
+			}
+			m.RefreshBrightness()
+		}()
+	}
+
+	if !outputPropertiesEq {
+		logger.Debug("output properties changed")
+		go func() {
+			for _, monitor := range m.getConnectedMonitors() {
+				monitor.PropsMu.RLock()
+				uuid := monitor.uuid
+				monitor.PropsMu.RUnlock()
+				m.restoreMonitorOutputProperties(monitor, uuid)
+			}
+		}()
+	}
+
+	if !underscansEq {
+		logger.Debug("underscans changed")
+		for _, monitor := range m.getConnectedMonitors() {
+			monitor.PropsMu.RLock()
+			uuid := monitor.uuid
+			monitor.PropsMu.RUnlock()
+			m.restoreMonitorUnderscan(monitor, uuid)
+		}
+		// monitorMap 中是副本，也要更新，以便下面应用时使用
+		for _, monitor := range monitors {
+			monitor.UnderscanHorizontal, monitor.UnderscanVertical = newCfg.getUnderscan(monitor.uuid)
+		}
+	}
+
 	if !displayModeEq {
 		// displayMode 改变了
 		logger.Debug("displayMode changed")
@@ -525,6 +653,19 @@ func (m *Manager) handleSysConfigUpdated(newSysConfig *SysRootConfig) {
 		}
 	}
 
+	if (!transformScalingEq || !underscansEq || !mirrorStrategyEq) && !doApply && !_useWayland {
+		// 变换缩放开关或者缩放比改变了，或者 underscan、复制模式的策略改变了
+		logger.Debug("transform scaling, underscans or mirror strategy changed")
+		doApply = true
+		go func() {
+			err := m.applySysMonitorConfigs(newCfg.DisplayMode, monitorsId, monitorMap, newMonitorCfgs, nil)
+			if err != nil {
+				logger.Warning(err)
+				return
+			}
+		}()
+	}
+
 	if !fillModesEq {
 		// fillModes 改变了
 		if !doApply {
@@ -678,6 +819,7 @@ func (m *Manager) applyDisplayConfig(mode byte, monitorsId monitorsId, monitorMa
 		return nil
 	}
 	m.updateConfigUuid(monitors)
+	m.updatePropCustomMode(monitorsId)
 
 	if setColorTemp {
 		m.applyColorTempConfig(mode)
@@ -688,6 +830,18 @@ func (m *Manager) applyDisplayConfig(mode byte, monitorsId monitorsId, monitorMa
 			m.updateScreenSize()
 		}
 	}()
+	if rule, matched := m.matchDisplayRule(monitorsId, monitors); rule != nil {
+		err := m.applyDisplayRule(rule, matched, monitorsId, monitorMap, options)
+		if err == nil {
+			m.finishDisplayRule(monitorsId, rule.Name)
+			return nil
+		}
+		// 没有记录 monitorsId，下次应用配置时再尝试这条规则
+		logger.Warningf("failed to apply display rule %q: %v", rule.Name, err)
+		m.PropsMu.Lock()
+		m.setPropMatchedRule("")
+		m.PropsMu.Unlock()
+	}
 	var err error
 	if len(monitors) == 1 {
 		// 单屏情况
@@ -742,11 +896,14 @@ func (m *Manager) updateMonitorsId(options applyOptions) (changed bool) {
 	// NOTE: 加锁为了保护 monitorsId 和 delayApplyTimer
 
 	oldMonitorsId := m.monitorsId
+	m.updateLidClosedMonitor()
 	monitorMap := m.cloneMonitorMap()
 	newMonitorsId := getConnectedMonitors(monitorMap).getMonitorsId()
 	if newMonitorsId != oldMonitorsId && newMonitorsId.v1 != "" {
 		m.monitorsId = newMonitorsId
 		logger.Debugf("monitors id changed, old monitors id: %v, new monitors id: %v", oldMonitorsId.v1, newMonitorsId.v1)
+		// 等待确认的修改没有保存，会随新的显示配置一起被丢弃
+		m.dropChangesConfirm()
 		m.markClean()
 
 		const delayApplyDuration = 1 * time.Second
@@ -822,6 +979,11 @@ func (m *Manager) setDelayApplyOptions(options applyOptions) {
 func (m *Manager) applyConfig(setColorTemp bool, options applyOptions) (paths []dbus.ObjectPath) {
 	monitorMap := m.cloneMonitorMap()
 	monitors := getConnectedMonitors(monitorMap)
+	if m.restoreCustomResolutions(monitors) {
+		// 显示器的模式列表改变了
+		monitorMap = m.cloneMonitorMap()
+		monitors = getConnectedMonitors(monitorMap)
+	}
 	monitorsId := monitors.getMonitorsId()
 	logger.Debugf("applyConfig monitorsId: %v, options: %v", monitorsId, options)
 
@@ -960,6 +1122,7 @@ func (m *Manager) init() {
 		}
 
 		m.initBuiltinMonitor()
+		m.updateLidClosedMonitor()
 		m.monitorsId = m.getMonitorsId()
 		m.updatePropMonitors()
 
@@ -987,20 +1150,26 @@ func (m *Manager) init() {
 	}
 
 	m.DisplayMode = m.sysConfig.Config.DisplayMode
+	m.TransformScaling = !_useWayland && m.sysConfig.Config.TransformScaling
+	m.MirrorStrategy = m.getMirrorStrategy()
 
 	err := m.loadUserConfig()
 	if err != nil {
 		logger.Warning("loadUserConfig err:", err)
 	}
+	err = m.loadDisplayRules()
+	if err != nil {
+		logger.Warning("loadDisplayRules err:", err)
+	}
 
 	// NOTE: m.listenXEvents 应该在 m.applyDisplayConfig 之前，否则会造成它里面的 m.apply 函数的等待超时。
 	m.listenXEvents()
 	// 此时不需要设置色温，在 StartPart2 中做。为性能考虑。
 	m.applyConfig(false, nil)
+	m.listenSettingsChanged() // 监听旋转屏幕延时值和亮度动画时长
 	if m.builtinMonitor != nil {
-		m.listenSettingsChanged() // 监听旋转屏幕延时值
-		m.initScreenRotation()    // 获取初始屏幕的状态（屏幕方向）
-		m.listenRotateSignal()    // 监听屏幕旋转信号
+		m.initScreenRotation() // 获取初始屏幕的状态（屏幕方向）
+		m.listenRotateSignal() // 监听屏幕旋转信号
 	} else {
 		// 没有内建屏,不监听内核信号
 		logger.Info("built-in screen does not exist")
@@ -1193,6 +1362,7 @@ func (m *Manager) addMonitor(monitorInfo *MonitorInfo) error {
 		Enabled:            monitorInfo.Enabled,
 		uuid:               monitorInfo.UUID,
 		uuidV0:             monitorInfo.UuidV0,
+		edid:               monitorInfo.EDID,
 		Manufacturer:       monitorInfo.Manufacturer,
 		Model:              monitorInfo.Model,
 		AvailableFillModes: monitorInfo.AvailableFillModes,
@@ -1215,6 +1385,11 @@ func (m *Manager) addMonitor(monitorInfo *MonitorInfo) error {
 	monitor.RefreshRate = monitorInfo.CurrentMode.Rate
 
 	monitor.oldRotation = monitor.Rotation
+	m.restoreMonitorColorProfile(monitor, monitorInfo.UUID)
+	m.restoreMonitorColorTemp(monitor, monitorInfo.UUID)
+	m.restoreMonitorOutputProperties(monitor, monitorInfo.UUID)
+	m.restoreMonitorUnderscan(monitor, monitorInfo.UUID)
+	m.recordMonitorSerial(monitorInfo.UUID, monitorInfo.EDID)
 
 	m.handleMonitorConnectedChanged(monitor, monitorInfo.Connected)
 
@@ -1255,6 +1430,11 @@ func (m *Manager) updateMonitor(monitorInfo *MonitorInfo) {
 	monitorInfo.dumpForDebug()
 
 	m.handleMonitorConnectedChanged(monitor, monitorInfo.Connected)
+	m.restoreMonitorColorProfile(monitor, monitorInfo.UUID)
+	m.restoreMonitorColorTemp(monitor, monitorInfo.UUID)
+	m.restoreMonitorOutputProperties(monitor, monitorInfo.UUID)
+	m.restoreMonitorUnderscan(monitor, monitorInfo.UUID)
+	m.recordMonitorSerial(monitorInfo.UUID, monitorInfo.EDID)
 	monitor.PropsMu.Lock()
 
 	if monitor.uuid != monitorInfo.UUID {
@@ -1262,6 +1442,7 @@ func (m *Manager) updateMonitor(monitorInfo *MonitorInfo) {
 	}
 	monitor.uuid = monitorInfo.UUID
 	monitor.uuidV0 = monitorInfo.UuidV0
+	monitor.edid = monitorInfo.EDID
 	monitor.realConnected = monitorInfo.Connected
 	monitor.setPropAvailableFillModes(monitorInfo.AvailableFillModes)
 	monitor.setPropManufacturer(monitorInfo.Manufacturer)
@@ -1312,6 +1493,9 @@ func (m *Manager) handleMonitorConnectedChanged(monitor *Monitor, connected bool
 
 func (m *Manager) buildConfigForModeMirror(monitors Monitors) (monitorCfgs SysMonitorConfigs, err error) {
 	logger.Debug("switch mode mirror")
+	if m.getMirrorStrategy() != mirrorStrategyCommon {
+		return m.buildConfigForModeMirrorScaling(monitors), nil
+	}
 	commonSizes := getMonitorsCommonSizes(monitors)
 	if len(commonSizes) == 0 {
 		err = errors.New("not found common size")
@@ -1347,6 +1531,11 @@ func (m *Manager) applyModeMirror(monitorsId monitorsId, monitorMap map[uint32]*
 	needSaveCfg := false
 
 	configs := screenCfg.getMonitorConfigs(DisplayModeMirror, "")
+	if len(configs) > 0 && screenCfg.Mirror.getMirrorStrategy() != m.getMirrorStrategy() {
+		// 配置中的模式是按之前的策略选择的
+		logger.Debug("mirror strategy changed, rebuild mirror configs")
+		configs = nil
+	}
 
 	if len(configs) == 0 {
 		needSaveCfg = true
@@ -1362,7 +1551,7 @@ func (m *Manager) applyModeMirror(monitorsId monitorsId, monitorMap map[uint32]*
 	}
 
 	if needSaveCfg {
-		screenCfg.setMonitorConfigs(DisplayModeMirror, "", configs)
+		m.setScreenMonitorConfigs(screenCfg, DisplayModeMirror, "", configs)
 		m.setSysScreenConfig(monitorsId, screenCfg)
 		return m.saveSysConfig("mode mirror")
 	}
@@ -1466,6 +1655,9 @@ func (m *Manager) apply(monitorsId monitorsId, monitorMap map[uint32]*Monitor, o
 	prevScreenSize := screenSize{width: m.ScreenWidth, height: m.ScreenHeight}
 	m.PropsMu.RUnlock()
 	m.setInApply(true)
+	renderScale := m.updateTransformScales(monitorMap)
+	m.updateUnderscanTransforms(monitorMap)
+	updateMirrorTransforms(monitorMap, m.getMirrorScaling())
 
 	// NOTE: 应该限制只有 Manager.apply 才能调用 mm.apply
 	m.applyMu.Lock()
@@ -1473,6 +1665,10 @@ func (m *Manager) apply(monitorsId monitorsId, monitorMap map[uint32]*Monitor, o
 	m.applyMu.Unlock()
 
 	m.setInApply(false)
+	if err == nil {
+		m.setRenderScaleFactor(renderScale)
+		m.updateAppliedUnderscans(monitorMap)
+	}
 	return err
 }
 
@@ -1552,11 +1748,13 @@ func (m *Manager) setPrimary(name string) error {
 		}
 
 		screenCfg.setMonitorConfigs(DisplayModeExtend, "", configs)
+		screenCfg.CustomId = ""
 		m.setSysScreenConfig(monitorsId, screenCfg)
 		err = m.saveSysConfig("primary changed")
 		if err != nil {
 			return err
 		}
+		m.updatePropCustomMode(monitorsId)
 
 	default:
 		return fmt.Errorf("invalid display mode %v", m.DisplayMode)
@@ -1729,6 +1927,10 @@ func (m *Manager) switchModeAux(mode, oldMode byte, monitorsId monitorsId, monit
 		// 保存设置
 		m.sysConfig.mu.Lock()
 		m.sysConfig.Config.DisplayMode = mode
+		// 切换了显示模式，当前布局不再是之前的布局方案
+		if screenCfg := m.sysConfig.Config.Screens[monitorsId.v1]; screenCfg != nil {
+			screenCfg.CustomId = ""
+		}
 		err = m.saveSysConfigNoLock("switch mode")
 		m.sysConfig.mu.Unlock()
 
@@ -1736,6 +1938,7 @@ func (m *Manager) switchModeAux(mode, oldMode byte, monitorsId monitorsId, monit
 			logger.Warning(err)
 			return err
 		}
+		m.updatePropCustomMode(monitorsId)
 	}
 
 	return nil
@@ -1839,8 +2042,10 @@ func (m *Manager) save() (err error) {
 		screenCfg.setSingleMonitorConfigs(configs)
 	} else {
 		uuid := getOnlyOneMonitorUuid(m.DisplayMode, monitors)
-		screenCfg.setMonitorConfigs(m.DisplayMode, uuid, configs)
+		m.setScreenMonitorConfigs(screenCfg, m.DisplayMode, uuid, configs)
 	}
+	// 布局被修改，当前布局不再是之前的布局方案
+	screenCfg.CustomId = ""
 	m.setSysScreenConfig(monitorsId, screenCfg)
 
 	err = m.saveSysConfig("save")
@@ -1848,6 +2053,7 @@ func (m *Manager) save() (err error) {
 		return err
 	}
 	m.markClean()
+	m.updatePropCustomMode(monitorsId)
 	return nil
 }
 
@@ -1902,17 +2108,18 @@ func (mfc *monitorsFutureConfig) getConfigs(monitorsId monitorsId) SysMonitorCon
 	return mfc.configs.clone()
 }
 
-func (m *Manager) applyChanges() error {
+// applyChanges 应用修改，applied 表示修改是否真的被应用了。
+func (m *Manager) applyChanges() (applied bool, err error) {
 	if m.getInApply() {
 		logger.Debug("no apply changes, in apply")
-		return nil
+		return false, nil
 	}
 
 	m.PropsMu.RLock()
 	if !m.HasChanged {
 		m.PropsMu.RUnlock()
 		logger.Debug("no apply changes, no changed")
-		return nil
+		return false, nil
 	}
 	m.PropsMu.RUnlock()
 
@@ -1929,14 +2136,16 @@ func (m *Manager) applyChanges() error {
 		config.modify(monitor.changes)
 	}
 
-	err := m.applySysMonitorConfigs(DisplayModeInvalid, monitorsId, monitorMap, configs, nil)
+	m.applySaveMu.Lock()
+	err = m.applySysMonitorConfigs(DisplayModeInvalid, monitorsId, monitorMap, configs, nil)
+	m.applySaveMu.Unlock()
 	if err != nil {
 		logger.Warning("[applyChanges] apply sys monitor configs failed:", err)
 		m.futureConfig.clear()
-	} else {
-		m.futureConfig.setConfigs(monitorsId, configs)
+		return false, err
 	}
-	return err
+	m.futureConfig.setConfigs(monitorsId, configs)
+	return true, nil
 }
 
 func (m *Manager) resetChangesWithoutApply() {
@@ -1981,6 +2190,12 @@ func (m *Manager) applySysMonitorConfigs(mode byte, monitorsId monitorsId, monit
 		logger.Debugf("applySysMonitorConfigs configs: %s, options: %v", spew.Sdump(configs), options)
 	}
 
+	connectedMonitors := getConnectedMonitors(monitorMap)
+	if lidClosedMonitor := getLidClosedMonitor(connectedMonitors); lidClosedMonitor != nil {
+		fallback := m.getPriorMonitor(monitorsRemove(connectedMonitors, lidClosedMonitor.ID))
+		configs = fixSysMonitorConfigsForLidClosed(configs, lidClosedMonitor.uuid, fallback)
+	}
+
 	// 验证配置
 	enabledCount := 0
 	for _, config := range configs {
@@ -1992,40 +2207,7 @@ func (m *Manager) applySysMonitorConfigs(mode byte, monitorsId monitorsId, monit
 		return errors.New("invalid configs: no enabled monitor")
 	}
 
-	var primaryMonitorID uint32
-	var enabledMonitors []*Monitor
-	for _, monitor := range monitorMap {
-		monitorCfg := configs.getByUuid(monitor.uuid)
-		if monitorCfg == nil {
-			logger.Debug("disable monitor", monitor)
-			monitor.Enabled = false
-		} else {
-			if monitorCfg.Enabled {
-				logger.Debug("enable monitor", monitor)
-				if monitorCfg.Primary {
-					primaryMonitorID = monitor.ID
-				}
-				enabledMonitors = append(enabledMonitors, monitor)
-				//所有可设置的值都设置为配置文件中的值
-				monitor.X = monitorCfg.X
-				monitor.Y = monitorCfg.Y
-				monitor.Rotation = monitorCfg.Rotation
-				monitor.Reflect = monitorCfg.Reflect
-
-				// monitorCfg 中的宽和高是经过 rotation 调整的
-				width := monitorCfg.Width
-				height := monitorCfg.Height
-				swapWidthHeightWithRotation(monitorCfg.Rotation, &width, &height)
-				mode := monitor.selectMode(width, height, monitorCfg.RefreshRate)
-				monitor.setModeNoEmitChanged(mode)
-				monitor.Enabled = true
-			} else {
-				logger.Debug("disable monitor", monitor)
-				monitor.Enabled = false
-			}
-		}
-	}
-
+	primaryMonitorID, enabledMonitors := setMonitorsBySysConfigs(monitorMap, configs)
 	if primaryMonitorID == 0 {
 		primaryMonitor := m.getDefaultPrimaryMonitor(enabledMonitors)
 		if primaryMonitor != nil {
@@ -2035,6 +2217,40 @@ func (m *Manager) applySysMonitorConfigs(mode byte, monitorsId monitorsId, monit
 		}
 	}
 
+	// 扩展模式下的镜像组，把修改同步到配置中，以便保存
+	for _, monitor := range setMonitorsMirrorGroups(monitorMap, m.getMirrorGroups(mode, monitorsId, options)) {
+		monitorCfg := configs.getByUuid(monitor.uuid)
+		if monitorCfg != nil {
+			monitorCfg.X = monitor.X
+			monitorCfg.Y = monitor.Y
+			monitorCfg.Rotation = monitor.Rotation
+			monitorCfg.Width = monitor.Width
+			monitorCfg.Height = monitor.Height
+			monitorCfg.RefreshRate = monitor.RefreshRate
+		}
+	}
+	// 复制模式下按缩放的策略时，各显示器缩放到与主屏相同
+	if m.shouldMirrorScale(mode, enabledMonitors) {
+		setMonitorsMirrorLeader(enabledMonitors, primaryMonitorID)
+	}
+
+	if m.shouldNormalizeLayout(mode, enabledMonitors, options) {
+		// 整理布局要用到显示器缩放后的尺寸
+		m.updateTransformScales(monitorMap)
+		updateMirrorTransforms(monitorMap, m.getMirrorScaling())
+		normalized := normalizeMonitorsLayout(enabledMonitors, monitorMap[primaryMonitorID])
+		if normalized {
+			// 把整理后的位置同步到配置中，以便保存
+			for _, monitor := range enabledMonitors {
+				monitorCfg := configs.getByUuid(monitor.uuid)
+				if monitorCfg != nil {
+					monitorCfg.X = monitor.X
+					monitorCfg.Y = monitor.Y
+				}
+			}
+		}
+	}
+
 	// 对于 X 来说，这里是处理 crtc 设置
 	err := m.apply(monitorsId, monitorMap, options, primaryMonitorID, mode)
 	if err != nil {
@@ -2086,6 +2302,60 @@ func (m *Manager) applySysMonitorConfigs(mode byte, monitorsId monitorsId, monit
 	return nil
 }
 
+// setMonitorsBySysConfigs 把 configs 中的配置设置到 monitorMap 中的显示器上，不发送属性改变信号，
+// 返回配置中主屏的 ID 和启用的显示器。
+func setMonitorsBySysConfigs(monitorMap map[uint32]*Monitor, configs SysMonitorConfigs) (primaryMonitorID uint32, enabledMonitors []*Monitor) {
+	for _, monitor := range monitorMap {
+		monitorCfg := configs.getByUuid(monitor.uuid)
+		if monitorCfg == nil {
+			logger.Debug("disable monitor", monitor)
+			monitor.Enabled = false
+		} else {
+			if monitorCfg.Enabled {
+				logger.Debug("enable monitor", monitor)
+				if monitorCfg.Primary {
+					primaryMonitorID = monitor.ID
+				}
+				enabledMonitors = append(enabledMonitors, monitor)
+				//所有可设置的值都设置为配置文件中的值
+				monitor.X = monitorCfg.X
+				monitor.Y = monitorCfg.Y
+				monitor.Rotation = monitorCfg.Rotation
+				monitor.Reflect = monitorCfg.Reflect
+
+				// monitorCfg 中的宽和高是经过 rotation 调整的
+				width := monitorCfg.Width
+				height := monitorCfg.Height
+				swapWidthHeightWithRotation(monitorCfg.Rotation, &width, &height)
+				mode := monitor.selectMode(width, height, monitorCfg.RefreshRate)
+				monitor.setModeNoEmitChanged(mode)
+				monitor.Enabled = true
+			} else {
+				logger.Debug("disable monitor", monitor)
+				monitor.Enabled = false
+			}
+		}
+	}
+	return
+}
+
+// shouldNormalizeLayout X 环境下扩展模式应用配置时，需要整理显示器的布局，可以用 optionNoNormalize 选项关闭。
+func (m *Manager) shouldNormalizeLayout(mode byte, enabledMonitors []*Monitor, options applyOptions) bool {
+	if _useWayland || len(enabledMonitors) < 2 {
+		return false
+	}
+	if noNormalize, _ := options[optionNoNormalize].(bool); noNormalize {
+		return false
+	}
+	if mode == DisplayModeInvalid {
+		// 应用修改时不指定显示模式
+		m.PropsMu.RLock()
+		mode = m.DisplayMode
+		m.PropsMu.RUnlock()
+	}
+	return mode == DisplayModeExtend
+}
+
 type applyFailed struct {
 	reason   string
 	err      error
@@ -2106,7 +2376,11 @@ func (m *Manager) getDefaultPrimaryMonitor(monitors []*Monitor) *Monitor {
 	}
 	builtinMonitor := m.getBuiltinMonitor()
 	if builtinMonitor != nil && Monitors(monitors).GetById(builtinMonitor.ID) != nil {
-		return builtinMonitor
+		if getLidClosedMonitor(monitors) == nil {
+			return builtinMonitor
+		}
+		// 合盖的内置显示器不能作为主屏
+		monitors = monitorsRemove(monitors, builtinMonitor.ID)
 	}
 
 	monitor := m.getPriorMonitor(monitors)
@@ -2560,6 +2834,12 @@ func (c *SysRootConfig) fix() {
 	for _, screenConfig := range cfg.Screens {
 		screenConfig.fix()
 	}
+	cfg.fixCustomResolutions()
+	// 配置可能是导入的，与对应的 DBus 接口一样检查
+	cfg.fixColorProfiles()
+	cfg.fixUnderscans()
+	cfg.fixOutputProperties()
+	cfg.fixMirrorStrategy()
 }
 
 // 无需对结果再次地调用 fix 方法
@@ -2936,6 +3216,9 @@ func (m *Manager) listenSettingsChanged() {
 		case gsKeyRotateScreenTimeDelay:
 			m.rotateScreenTimeDelay = m.settings.GetInt(key)
 			return
+		case gsKeyBrightnessAnimation:
+			m.brightnessAnimator.setDuration(time.Duration(m.settings.GetInt(key)) * time.Millisecond)
+			return
 		default:
 			return
 		}
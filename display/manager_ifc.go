@@ -11,7 +11,6 @@ import (
 
 	"github.com/godbus/dbus/v5"
 	"github.com/linuxdeepin/go-lib/dbusutil"
-	"github.com/linuxdeepin/go-lib/strv"
 )
 
 func (m *Manager) GetInterfaceName() string {
@@ -20,12 +19,41 @@ func (m *Manager) GetInterfaceName() string {
 
 func (m *Manager) ApplyChanges() *dbus.Error {
 	logger.Debug("dbus call ApplyChanges")
-	err := m.applyChanges()
+	_, err := m.applyChanges()
 	return dbusutil.ToError(err)
 }
 
+// ApplyChangesWithTimeout 应用修改，需要在 seconds 秒内调用 ConfirmChanges 确认，否则恢复之前的配置。
+func (m *Manager) ApplyChangesWithTimeout(seconds int32) *dbus.Error {
+	logger.Debug("dbus call ApplyChangesWithTimeout", seconds)
+	err := m.applyChangesWithTimeout(seconds)
+	return dbusutil.ToError(err)
+}
+
+// ConfirmChanges 确认 ApplyChangesWithTimeout 应用的修改，并保存配置。
+func (m *Manager) ConfirmChanges() *dbus.Error {
+	logger.Debug("dbus call ConfirmChanges")
+	err := m.confirmChanges()
+	return dbusutil.ToError(err)
+}
+
+// ValidateChanges 检查未应用的修改，返回检查出的问题，不会应用修改。
+func (m *Manager) ValidateChanges() ([]ValidateProblem, *dbus.Error) {
+	logger.Debug("dbus call ValidateChanges")
+	problems := m.validateChanges()
+	if problems == nil {
+		problems = []ValidateProblem{}
+	}
+	return problems, nil
+}
+
 func (m *Manager) ResetChanges() *dbus.Error {
 	logger.Debug("dbus call ResetChanges")
+	if m.hasChangesConfirm() {
+		// 正在等待确认，直接恢复之前的配置
+		m.revertChanges()
+		return nil
+	}
 	m.PropsMu.Lock()
 	if !m.HasChanged {
 		m.PropsMu.Unlock()
@@ -59,6 +87,11 @@ func (m *Manager) SwitchMode(mode byte, name string) *dbus.Error {
 
 func (m *Manager) Save() *dbus.Error {
 	logger.Debug("dbus call Save")
+	if m.hasChangesConfirm() {
+		// 正在等待确认，保存即确认
+		err := m.confirmChanges()
+		return dbusutil.ToError(err)
+	}
 	err := m.save()
 	return dbusutil.ToError(err)
 }
@@ -146,14 +179,78 @@ func (m *Manager) ListOutputsCommonModes() ([]ModeInfo, *dbus.Error) {
 	return result, nil
 }
 
-// ModifyConfigName 废弃方法
+// SaveCustomMode 把当前的显示布局保存为名为 name 的方案，同名方案会被覆盖。
+func (m *Manager) SaveCustomMode(name string) *dbus.Error {
+	logger.Debug("dbus call SaveCustomMode", name)
+	err := m.saveCustomMode(name)
+	return dbusutil.ToError(err)
+}
+
+// SwitchCustomMode 应用名为 name 的布局方案
+func (m *Manager) SwitchCustomMode(name string) *dbus.Error {
+	logger.Debug("dbus call SwitchCustomMode", name)
+	err := m.switchCustomMode(name)
+	return dbusutil.ToError(err)
+}
+
+// ModifyConfigName 重命名布局方案
 func (m *Manager) ModifyConfigName(name, newName string) *dbus.Error {
-	return dbusutil.ToError(errors.New("obsoleted method"))
+	logger.Debug("dbus call ModifyConfigName", name, newName)
+	err := m.renameCustomMode(name, newName)
+	return dbusutil.ToError(err)
 }
 
-// DeleteCustomMode 废弃方法
+// DeleteCustomMode 删除布局方案
 func (m *Manager) DeleteCustomMode(name string) *dbus.Error {
-	return dbusutil.ToError(errors.New("obsoleted method"))
+	logger.Debug("dbus call DeleteCustomMode", name)
+	err := m.deleteCustomMode(name)
+	return dbusutil.ToError(err)
+}
+
+// ExportConfig 导出完整的显示配置，包括系统级配置和用户级的色温配置。
+func (m *Manager) ExportConfig() (string, *dbus.Error) {
+	logger.Debug("dbus call ExportConfig")
+	cfg, err := m.exportConfig()
+	return cfg, dbusutil.ToError(err)
+}
+
+// ImportConfig 导入 ExportConfig 导出的配置，mergePolicy 为 merge 或 replace，决定本机已存在的屏幕配置是合并还是被替换。
+func (m *Manager) ImportConfig(cfgJson string, mergePolicy string) *dbus.Error {
+	logger.Debug("dbus call ImportConfig", mergePolicy)
+	err := m.importConfig(cfgJson, mergePolicy)
+	return dbusutil.ToError(err)
+}
+
+// ListDisplayRules 返回规则文件中的热插拔规则
+func (m *Manager) ListDisplayRules() ([]DisplayRuleInfo, *dbus.Error) {
+	logger.Debug("dbus call ListDisplayRules")
+	return m.listDisplayRules(), nil
+}
+
+// ReloadDisplayRules 重新加载规则文件，并对当前连接的显示器重新选择规则。
+func (m *Manager) ReloadDisplayRules() *dbus.Error {
+	logger.Debug("dbus call ReloadDisplayRules")
+	err := m.reloadDisplayRules()
+	return dbusutil.ToError(err)
+}
+
+// SetMirrorStrategy 设置复制模式的策略，common 使用公共分辨率，fit、fill、stretch 各显示器使用自己的最佳模式再缩放画面。
+func (m *Manager) SetMirrorStrategy(strategy string) *dbus.Error {
+	logger.Debug("dbus call SetMirrorStrategy", strategy)
+	err := m.setMirrorStrategy(strategy)
+	return dbusutil.ToError(err)
+}
+
+// SetMirrorGroups 设置扩展模式下的镜像组，每一组是显示器名称，为空时取消所有镜像组。
+func (m *Manager) SetMirrorGroups(groups [][]string) *dbus.Error {
+	logger.Debug("dbus call SetMirrorGroups", groups)
+	err := m.setMirrorGroups(groups)
+	return dbusutil.ToError(err)
+}
+
+// GetMirrorGroups 返回当前连接的显示器在扩展模式下的镜像组
+func (m *Manager) GetMirrorGroups() ([][]string, *dbus.Error) {
+	return m.getMirrorGroupNames(), nil
 }
 
 // RefreshBrightness 重置亮度，主要被 session/power 模块调用。从配置恢复亮度。
@@ -174,6 +271,13 @@ func (m *Manager) RefreshBrightness() *dbus.Error {
 	return nil
 }
 
+// SetTransformScaling 开启或关闭变换缩放，开启后 X 环境下各显示器用 crtc transform 实现各自的缩放比。
+func (m *Manager) SetTransformScaling(enabled bool) *dbus.Error {
+	logger.Debug("dbus call SetTransformScaling", enabled)
+	err := m.setTransformScaling(enabled)
+	return dbusutil.ToError(err)
+}
+
 func (m *Manager) Reset() *dbus.Error {
 	// TODO
 	return nil
@@ -186,7 +290,7 @@ func (m *Manager) SetAndSaveBrightness(outputName string, value float64) *dbus.E
 	if !can {
 		return dbusutil.ToError(fmt.Errorf("the port %s cannot set brightness", outputName))
 	}
-	err := m.setBrightnessAndSync(outputName, value)
+	err := m.setBrightnessAndSync(outputName, value, false)
 	if err != nil {
 		logger.Warning(err)
 		return dbusutil.ToError(err)
@@ -199,12 +303,23 @@ func (m *Manager) SetAndSaveBrightness(outputName string, value float64) *dbus.E
 		logger.Warning(err)
 		return dbusutil.ToError(err)
 	}
+	m.learnAutoBrightness(outputName, value)
 	return nil
 }
 
 // SetBrightness 设置亮度但是不保存, 主要被 session/power 模块调用。
 func (m *Manager) SetBrightness(outputName string, value float64) *dbus.Error {
 	logger.Debug("dbus call SetBrightness", outputName, value)
+	return m.setBrightnessNoSave(outputName, value, false)
+}
+
+// SetBrightnessWithOptions 与 SetBrightness 相同，instant 为 true 时不使用动画直接设置，比如空闲变暗之后恢复亮度。
+func (m *Manager) SetBrightnessWithOptions(outputName string, value float64, instant bool) *dbus.Error {
+	logger.Debug("dbus call SetBrightnessWithOptions", outputName, value, instant)
+	return m.setBrightnessNoSave(outputName, value, instant)
+}
+
+func (m *Manager) setBrightnessNoSave(outputName string, value float64, instant bool) *dbus.Error {
 	if value > 1 || value < 0 {
 		return dbusutil.ToError(fmt.Errorf("the brightness value range is 0-1"))
 	}
@@ -214,7 +329,7 @@ func (m *Manager) SetBrightness(outputName string, value float64) *dbus.Error {
 		return dbusutil.ToError(fmt.Errorf("the port %s cannot set brightness", outputName))
 	}
 
-	err := m.setBrightnessAndSync(outputName, value)
+	err := m.setBrightnessAndSync(outputName, value, instant)
 	if err != nil {
 		logger.Warning(err)
 		return dbusutil.ToError(err)
@@ -222,6 +337,29 @@ func (m *Manager) SetBrightness(outputName string, value float64) *dbus.Error {
 	return nil
 }
 
+// SetAutoBrightness 开启或关闭自动亮度，需要有环境光传感器。
+func (m *Manager) SetAutoBrightness(enabled bool) *dbus.Error {
+	logger.Debug("dbus call SetAutoBrightness", enabled)
+	err := m.setAutoBrightness(enabled)
+	return dbusutil.ToError(err)
+}
+
+// GetAutoBrightnessCurve 获取自动亮度使用的 lux-亮度曲线
+func (m *Manager) GetAutoBrightnessCurve() ([]LuxBrightnessPoint, *dbus.Error) {
+	controller := m.getAutoBrightnessController()
+	if controller == nil {
+		return nil, dbusutil.ToError(errors.New("no ambient light sensor"))
+	}
+	return controller.getCurve(), nil
+}
+
+// SetAutoBrightnessCurve 设置自动亮度使用的 lux-亮度曲线，lux 要严格递增，亮度范围为 0-1 且不递减。
+func (m *Manager) SetAutoBrightnessCurve(points []LuxBrightnessPoint) *dbus.Error {
+	logger.Debug("dbus call SetAutoBrightnessCurve", points)
+	err := m.setAutoBrightnessCurve(points)
+	return dbusutil.ToError(err)
+}
+
 func (m *Manager) SetPrimary(outputName string) *dbus.Error {
 	logger.Debug("dbus call SetPrimary", outputName)
 	err := m.setPrimary(outputName)
@@ -281,35 +419,58 @@ func (m *Manager) SetColorTemperature(value int32) *dbus.Error {
 	return dbusutil.ToError(err)
 }
 
-func (m *Manager) GetRealDisplayMode() (uint8, *dbus.Error) {
-	monitors := m.getConnectedMonitors()
+// SetColorTemperatureSchedule 设置定时色温，start 和 end 的格式是 15:04，fade 是渐变的分钟数
+func (m *Manager) SetColorTemperatureSchedule(start, end string, temperature, fade int32) *dbus.Error {
+	err := m.setColorTempSchedule(&UserColorTempScheduleConfig{
+		Start:       start,
+		End:         end,
+		Temperature: temperature,
+		Fade:        fade,
+	})
+	return dbusutil.ToError(err)
+}
 
-	mode := DisplayModeUnknown
-	var pairs strv.Strv
-	for _, m := range monitors {
-		if !m.Enabled {
-			continue
-		}
+func (m *Manager) GetColorTemperatureSchedule() (start, end string, temperature, fade int32, busErr *dbus.Error) {
+	cfg := m.getColorTempScheduleConfig()
+	return cfg.Start, cfg.End, cfg.Temperature, cfg.Fade, nil
+}
 
-		pair := fmt.Sprintf("%d,%d", m.X, m.Y)
+// InhibitColorTemperature 暂停调节色温，不修改保存的色温模式，调用者退出时自动取消
+func (m *Manager) InhibitColorTemperature(sender dbus.Sender, reason string) (cookie uint32, busErr *dbus.Error) {
+	cookie, err := m.inhibitColorTemp(sender, reason)
+	return cookie, dbusutil.ToError(err)
+}
 
-		// 左上角座标相同，是复制
-		if pairs.Contains(pair) {
-			mode = DisplayModeMirror
-		}
+// InhibitColorTemperatureUntilTomorrow 暂停调节色温到第二天，不随调用者退出而取消
+func (m *Manager) InhibitColorTemperatureUntilTomorrow(reason string) (cookie uint32, busErr *dbus.Error) {
+	return m.inhibitColorTempUntilTomorrow(reason), nil
+}
 
-		pairs = append(pairs, pair)
-	}
+func (m *Manager) UninhibitColorTemperature(sender dbus.Sender, cookie uint32) *dbus.Error {
+	err := m.uninhibitColorTemp(sender, cookie)
+	return dbusutil.ToError(err)
+}
 
-	if mode == DisplayModeUnknown && len(pairs) != 0 {
-		if len(pairs) == 1 {
-			mode = DisplayModeOnlyOne
-		} else {
-			mode = DisplayModeExtend
-		}
-	}
+// SetLocation 手动设置自动色温使用的位置，优先于 GeoClue2 定位和时区
+func (m *Manager) SetLocation(latitude, longitude float64) *dbus.Error {
+	err := m.setLocation(latitude, longitude)
+	return dbusutil.ToError(err)
+}
+
+// ClearLocation 取消手动设置的位置
+func (m *Manager) ClearLocation() *dbus.Error {
+	m.clearLocation()
+	return nil
+}
 
-	return mode, nil
+func (m *Manager) GetLocation() (latitude, longitude float64, source string, busErr *dbus.Error) {
+	location, source := m.location.get()
+	return location.latitude, location.longitude, source, nil
+}
+
+func (m *Manager) GetRealDisplayMode() (uint8, *dbus.Error) {
+	monitors := m.getConnectedMonitors()
+	return getRealDisplayMode(monitors), nil
 }
 
 func (m *Manager) SupportSetColorTemperature() (bool, *dbus.Error) {
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+// 镜像组：扩展模式下让一部分显示器显示相同的内容，比如会议室中笔记本和投影仪复制，另一个显示器扩展显示备注。
+// 镜像组保存在扩展模式的配置中，组内的显示器使用第一个启用的显示器的位置和旋转，有相同尺寸的模式时使用这个模式，
+// 没有时保留自己的模式，X 环境下再用 crtc transform 按复制模式的策略缩放到第一个显示器在屏幕上的区域。
+// fit 的黑边会显示旁边其他显示器的画面时改用 fill。
+
+// 应用时使用的镜像组，值为 [][]string，没有这个选项时使用配置中的镜像组
+const optionMirrorGroups = "mirrorGroups"
+
+func cloneMirrorGroups(groups [][]string) [][]string {
+	if len(groups) == 0 {
+		return nil
+	}
+	result := make([][]string, len(groups))
+	for i, group := range groups {
+		result[i] = append([]string(nil), group...)
+	}
+	return result
+}
+
+// toMirrorGroupUuids 把由显示器名称组成的镜像组转换为 uuid，并检查每组至少有两个显示器，一个显示器只能在一个组中。
+func toMirrorGroupUuids(monitors Monitors, groups [][]string) ([][]string, error) {
+	var result [][]string
+	used := make(map[string]bool)
+	for _, group := range groups {
+		if len(group) < 2 {
+			return nil, errors.New("mirror group needs at least two monitors")
+		}
+		uuids := make([]string, 0, len(group))
+		for _, name := range group {
+			monitor := monitors.GetByName(name)
+			if monitor == nil {
+				return nil, InvalidOutputNameError{Name: name}
+			}
+			if used[monitor.uuid] {
+				return nil, fmt.Errorf("monitor %s is in more than one mirror group", name)
+			}
+			used[monitor.uuid] = true
+			uuids = append(uuids, monitor.uuid)
+		}
+		result = append(result, uuids)
+	}
+	return result, nil
+}
+
+// setMonitorsMirrorGroups 按镜像组设置 monitorMap 中启用的显示器的位置、旋转和模式，返回被修改的显示器。
+// 组内启用的显示器少于两个时不起作用。
+func setMonitorsMirrorGroups(monitorMap map[uint32]*Monitor, groups [][]string) (changed []*Monitor) {
+	for _, monitor := range monitorMap {
+		monitor.mirrorLeader = 0
+	}
+	monitors := getConnectedMonitors(monitorMap)
+	for _, group := range groups {
+		var leader *Monitor
+		for _, uuid := range group {
+			monitor := monitors.GetByUuid(uuid)
+			if monitor == nil || !monitor.Enabled {
+				continue
+			}
+			if leader == nil {
+				leader = monitor
+				continue
+			}
+			monitor.mirrorLeader = leader.ID
+			monitor.X = leader.X
+			monitor.Y = leader.Y
+			monitor.Rotation = leader.Rotation
+			mode := getFirstModeBySizeRate(monitor.Modes, leader.CurrentMode.Width, leader.CurrentMode.Height,
+				monitor.RefreshRate)
+			if mode.isZero() {
+				mode = getFirstModeBySize(monitor.Modes, leader.CurrentMode.Width, leader.CurrentMode.Height)
+			}
+			if mode.isZero() {
+				// 没有相同尺寸的模式，用 transform 缩放
+				mode = monitor.CurrentMode
+			}
+			monitor.setModeNoEmitChanged(mode)
+			changed = append(changed, monitor)
+		}
+	}
+	return changed
+}
+
+// updateMirrorTransforms 设置镜像中尺寸与第一个显示器不同的显示器用 transform 缩放到的尺寸和缩放方式，在应用前调用。
+func updateMirrorTransforms(monitorMap map[uint32]*Monitor, scaling string) {
+	for _, monitor := range monitorMap {
+		monitor.mirrorWidth = 0
+		monitor.mirrorHeight = 0
+		monitor.mirrorScaling = ""
+	}
+	if _useWayland {
+		return
+	}
+	var enabledMonitors []*Monitor
+	for _, monitor := range monitorMap {
+		if monitor.realConnected && monitor.Enabled {
+			enabledMonitors = append(enabledMonitors, monitor)
+		}
+	}
+	for _, monitor := range enabledMonitors {
+		if monitor.mirrorLeader == 0 {
+			continue
+		}
+		leader := monitorMap[monitor.mirrorLeader]
+		if leader == nil || !leader.Enabled {
+			continue
+		}
+		leaderRect := getMonitorRect(leader)
+		rect := getMonitorRect(monitor)
+		if rect.Width != leaderRect.Width || rect.Height != leaderRect.Height {
+			monitor.mirrorScaling = scaling
+			if scaling == mirrorStrategyFit && mirrorFitOverflows(monitor, leaderRect, leader, enabledMonitors) {
+				logger.Debugf("mirror fit of %s overlaps other monitors, use fill", monitor.Name)
+				monitor.mirrorScaling = mirrorStrategyFill
+			}
+			monitor.mirrorWidth = leaderRect.Width
+			monitor.mirrorHeight = leaderRect.Height
+		}
+	}
+}
+
+// mirrorFitOverflows 镜像组中的显示器按 fit 缩放时，黑边显示的是 leaderRect 外面的画面，
+// 扩展模式下超出到其他显示器的区域时会显示它们的画面，monitors 是启用的显示器。
+func mirrorFitOverflows(monitor *Monitor, leaderRect x.Rectangle, leader *Monitor, monitors []*Monitor) bool {
+	width := monitor.CurrentMode.Width
+	height := monitor.CurrentMode.Height
+	swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
+	if width == 0 || height == 0 {
+		return false
+	}
+	sx, sy := getMirrorScales(width, height, leaderRect.Width, leaderRect.Height, mirrorStrategyFit)
+	hOverflow := (sx*float64(width) - float64(leaderRect.Width)) / 2
+	vOverflow := (sy*float64(height) - float64(leaderRect.Height)) / 2
+	hFree, vFree := getMonitorFreeSpace(leader, monitors)
+	return hOverflow > hFree || vOverflow > vFree
+}
+
+type monitorPosition struct {
+	x int16
+	y int16
+}
+
+// getRealDisplayMode 根据启用的显示器的位置判断实际的显示模式，左上角坐标相同的显示器是复制。
+// 所有显示器位置都相同时是复制模式，都不同时是扩展模式，有一部分相同时是扩展模式中有镜像组。
+func getRealDisplayMode(monitors Monitors) uint8 {
+	positions := make(map[monitorPosition]bool)
+	enabledCount := 0
+	for _, monitor := range monitors {
+		if !monitor.Enabled {
+			continue
+		}
+		enabledCount++
+		positions[monitorPosition{x: monitor.X, y: monitor.Y}] = true
+	}
+
+	switch {
+	case enabledCount == 0:
+		return DisplayModeUnknown
+	case enabledCount == 1:
+		return DisplayModeOnlyOne
+	case len(positions) == 1:
+		return DisplayModeMirror
+	case len(positions) == enabledCount:
+		return DisplayModeExtend
+	default:
+		return DisplayModeHybrid
+	}
+}
+
+// getMirrorGroups 获取应用配置时要使用的镜像组，只有扩展模式下才有镜像组。
+func (m *Manager) getMirrorGroups(mode byte, monitorsId monitorsId, options applyOptions) [][]string {
+	if groups, ok := options[optionMirrorGroups].([][]string); ok {
+		return groups
+	}
+	if mode == DisplayModeInvalid {
+		m.PropsMu.RLock()
+		mode = m.DisplayMode
+		m.PropsMu.RUnlock()
+	}
+	if mode != DisplayModeExtend {
+		return nil
+	}
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	if screenCfg.Extend == nil {
+		return nil
+	}
+	return screenCfg.Extend.MirrorGroups
+}
+
+// setMirrorGroups 设置并应用当前连接的显示器在扩展模式下的镜像组，groups 中是显示器名称，为空时取消所有镜像组。
+func (m *Manager) setMirrorGroups(groups [][]string) error {
+	m.PropsMu.RLock()
+	displayMode := m.DisplayMode
+	m.PropsMu.RUnlock()
+	if displayMode != DisplayModeExtend {
+		return errors.New("mirror groups are only supported in extend mode")
+	}
+
+	monitorMap := m.cloneMonitorMap()
+	monitors := getConnectedMonitors(monitorMap)
+	uuidGroups, err := toMirrorGroupUuids(monitors, groups)
+	if err != nil {
+		return err
+	}
+	monitorsId := monitors.getMonitorsId()
+	screenCfg := m.getSysScreenConfig(monitorsId)
+	configs := screenCfg.getMonitorConfigs(DisplayModeExtend, "")
+	if len(configs) == 0 {
+		configs, err = m.buildConfigForModeExtend(monitors)
+		if err != nil {
+			return err
+		}
+	}
+	// 镜像组中的显示器都要启用
+	for _, group := range uuidGroups {
+		for _, uuid := range group {
+			monitorCfg := configs.getByUuid(uuid)
+			if monitorCfg != nil {
+				monitorCfg.Enabled = true
+			}
+		}
+	}
+
+	options := applyOptions{optionMirrorGroups: uuidGroups}
+	err = m.applySysMonitorConfigs(DisplayModeExtend, monitorsId, monitorMap, configs, options)
+	if err != nil {
+		return err
+	}
+
+	screenCfg.setMonitorConfigs(DisplayModeExtend, "", configs)
+	screenCfg.Extend.MirrorGroups = uuidGroups
+	m.setSysScreenConfig(monitorsId, screenCfg)
+	return m.saveSysConfig("mirror groups")
+}
+
+// getMirrorGroupNames 返回当前连接的显示器在扩展模式下的镜像组，组中是显示器名称。
+func (m *Manager) getMirrorGroupNames() [][]string {
+	monitors := m.getConnectedMonitors()
+	screenCfg := m.getSysScreenConfig(monitors.getMonitorsId())
+	if screenCfg.Extend == nil {
+		return nil
+	}
+	result := make([][]string, 0, len(screenCfg.Extend.MirrorGroups))
+	for _, group := range screenCfg.Extend.MirrorGroups {
+		var names []string
+		for _, uuid := range group {
+			monitor := monitors.GetByUuid(uuid)
+			if monitor != nil {
+				names = append(names, monitor.Name)
+			}
+		}
+		if len(names) > 0 {
+			result = append(result, names)
+		}
+	}
+	return result
+}
@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMirrorMonitor(id uint32, name string, x, y int16, modes ...ModeInfo) *Monitor {
+	monitor := newTestMonitor(name, true, x, y, modes[0].Width, modes[0].Height)
+	monitor.ID = id
+	monitor.uuid = name + "||v1"
+	monitor.realConnected = true
+	monitor.Modes = modes
+	monitor.CurrentMode = modes[0]
+	monitor.RefreshRate = modes[0].Rate
+	return monitor
+}
+
+func Test_toMirrorGroupUuids(t *testing.T) {
+	monitors := Monitors{
+		newTestMirrorMonitor(1, "eDP-1", 0, 0, ModeInfo{Width: 1920, Height: 1080}),
+		newTestMirrorMonitor(2, "HDMI-1", 1920, 0, ModeInfo{Width: 1920, Height: 1080}),
+		newTestMirrorMonitor(3, "DP-1", 3840, 0, ModeInfo{Width: 1920, Height: 1080}),
+	}
+
+	groups, err := toMirrorGroupUuids(monitors, [][]string{{"eDP-1", "HDMI-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"eDP-1||v1", "HDMI-1||v1"}}, groups)
+
+	groups, err = toMirrorGroupUuids(monitors, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, groups)
+
+	_, err = toMirrorGroupUuids(monitors, [][]string{{"eDP-1"}})
+	assert.Error(t, err)
+	_, err = toMirrorGroupUuids(monitors, [][]string{{"eDP-1", "VGA-1"}})
+	assert.Error(t, err)
+	_, err = toMirrorGroupUuids(monitors, [][]string{{"eDP-1", "HDMI-1"}, {"DP-1", "eDP-1"}})
+	assert.Error(t, err)
+}
+
+func Test_setMonitorsMirrorGroups(t *testing.T) {
+	builtin := newTestMirrorMonitor(1, "eDP-1", 0, 0,
+		ModeInfo{Id: 11, Width: 1920, Height: 1080, Rate: 60})
+	// 投影仪有相同尺寸的模式
+	projector := newTestMirrorMonitor(2, "HDMI-1", 1920, 0,
+		ModeInfo{Id: 21, Width: 1280, Height: 720, Rate: 60},
+		ModeInfo{Id: 22, Width: 1920, Height: 1080, Rate: 50},
+		ModeInfo{Id: 23, Width: 1920, Height: 1080, Rate: 60})
+	// 没有相同尺寸的模式
+	tv := newTestMirrorMonitor(3, "DP-1", 3840, 0,
+		ModeInfo{Id: 31, Width: 3840, Height: 2160, Rate: 60})
+	notes := newTestMirrorMonitor(4, "DP-2", 1920, 0,
+		ModeInfo{Id: 41, Width: 2560, Height: 1440, Rate: 60})
+	monitorMap := map[uint32]*Monitor{1: builtin, 2: projector, 3: tv, 4: notes}
+
+	changed := setMonitorsMirrorGroups(monitorMap, [][]string{{"eDP-1||v1", "HDMI-1||v1", "DP-1||v1"}})
+	assert.Len(t, changed, 2)
+	assert.Equal(t, uint32(0), builtin.mirrorLeader)
+	assert.Equal(t, uint32(1), projector.mirrorLeader)
+	assert.Equal(t, uint32(1), tv.mirrorLeader)
+	assert.Equal(t, uint32(0), notes.mirrorLeader)
+	assert.Equal(t, int16(0), projector.X)
+	assert.Equal(t, uint32(23), projector.CurrentMode.Id)
+	assert.Equal(t, int16(0), tv.X)
+	assert.Equal(t, uint32(31), tv.CurrentMode.Id)
+	assert.Equal(t, int16(1920), notes.X)
+
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, uint16(0), projector.mirrorWidth)
+	assert.Equal(t, uint16(1920), tv.mirrorWidth)
+	assert.Equal(t, uint16(1080), tv.mirrorHeight)
+	assert.Equal(t, mirrorStrategyFit, tv.mirrorScaling)
+	rect := getMonitorRect(tv)
+	assert.Equal(t, uint16(1920), rect.Width)
+	assert.Equal(t, uint16(1080), rect.Height)
+
+	// 第一个显示器禁用时由下一个启用的显示器带领
+	builtin.Enabled = false
+	changed = setMonitorsMirrorGroups(monitorMap, [][]string{{"eDP-1||v1", "HDMI-1||v1", "DP-1||v1"}})
+	assert.Equal(t, []*Monitor{tv}, changed)
+	assert.Equal(t, uint32(0), projector.mirrorLeader)
+	assert.Equal(t, uint32(2), tv.mirrorLeader)
+
+	// 没有镜像组时清除之前的设置
+	assert.Empty(t, setMonitorsMirrorGroups(monitorMap, nil))
+	assert.Equal(t, uint32(0), tv.mirrorLeader)
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, uint16(0), tv.mirrorWidth)
+}
+
+func Test_updateMirrorTransformsNeighbour(t *testing.T) {
+	// 16:10 的笔记本带领 16:9 的投影仪，右边是显示备注的显示器
+	builtin := newTestMirrorMonitor(1, "eDP-1", 0, 0, ModeInfo{Id: 11, Width: 1920, Height: 1200})
+	projector := newTestMirrorMonitor(2, "HDMI-1", 0, 0, ModeInfo{Id: 21, Width: 1920, Height: 1080})
+	notes := newTestMirrorMonitor(3, "DP-1", 1920, 0, ModeInfo{Id: 31, Width: 1920, Height: 1080})
+	monitorMap := map[uint32]*Monitor{1: builtin, 2: projector, 3: notes}
+	group := [][]string{{"eDP-1||v1", "HDMI-1||v1"}}
+	setMonitorsMirrorGroups(monitorMap, group)
+	require.Equal(t, uint32(1), projector.mirrorLeader)
+
+	// fit 的黑边会显示备注显示器的画面，改用 fill
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, uint16(1920), projector.mirrorWidth)
+	assert.Equal(t, uint16(1200), projector.mirrorHeight)
+	assert.Equal(t, mirrorStrategyFill, projector.mirrorScaling)
+
+	// 旁边有空隙时不影响
+	notes.X = 2200
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, mirrorStrategyFit, projector.mirrorScaling)
+
+	// 下面的显示器
+	notes.X = 0
+	notes.Y = 1200
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, mirrorStrategyFit, projector.mirrorScaling)
+	tv := newTestMirrorMonitor(4, "DP-2", 0, 0, ModeInfo{Id: 41, Width: 1024, Height: 768})
+	monitorMap[4] = tv
+	setMonitorsMirrorGroups(monitorMap, [][]string{{"eDP-1||v1", "HDMI-1||v1", "DP-2||v1"}})
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, mirrorStrategyFill, tv.mirrorScaling)
+	assert.Equal(t, mirrorStrategyFit, projector.mirrorScaling)
+
+	// 只有镜像组时黑边在屏幕外面
+	delete(monitorMap, 3)
+	updateMirrorTransforms(monitorMap, mirrorStrategyFit)
+	assert.Equal(t, mirrorStrategyFit, tv.mirrorScaling)
+}
+
+func Test_getRealDisplayMode(t *testing.T) {
+	assert.Equal(t, DisplayModeUnknown, getRealDisplayMode(Monitors{
+		newTestMonitor("eDP-1", false, 0, 0, 1920, 1080),
+	}))
+	assert.Equal(t, DisplayModeOnlyOne, getRealDisplayMode(Monitors{
+		newTestMonitor("eDP-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("HDMI-1", false, 0, 0, 1920, 1080),
+	}))
+	assert.Equal(t, DisplayModeMirror, getRealDisplayMode(Monitors{
+		newTestMonitor("eDP-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+	}))
+	assert.Equal(t, DisplayModeExtend, getRealDisplayMode(Monitors{
+		newTestMonitor("eDP-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("HDMI-1", true, 1920, 0, 1920, 1080),
+	}))
+	assert.Equal(t, DisplayModeHybrid, getRealDisplayMode(Monitors{
+		newTestMonitor("eDP-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("DP-1", true, 1920, 0, 2560, 1440),
+	}))
+}
+
+func Test_mirrorGroupLayout(t *testing.T) {
+	// 镜像组作为一个整体整理布局
+	a := newTestMonitor("eDP-1", true, 0, 0, 1920, 1080)
+	a.ID = 1
+	b := newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b.ID = 2
+	b.mirrorLeader = a.ID
+	c := newTestMonitor("DP-1", true, 2000, 0, 2560, 1440)
+	c.ID = 3
+	monitors := []*Monitor{a, b, c}
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, int16(0), b.X)
+	assert.Equal(t, int16(1920), c.X)
+
+	// 镜像组不算重叠
+	assert.Empty(t, checkMonitorsLayout(Monitors{a, b, c}, DisplayModeExtend))
+
+	// 左上角相同但不在镜像组中的显示器是重叠的，整理时分开
+	b.mirrorLeader = 0
+	problems := checkMonitorsLayout(Monitors{a, b, c}, DisplayModeExtend)
+	require.Len(t, problems, 1)
+	assert.Equal(t, problemOverlap, problems[0].Code)
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Equal(t, int16(0), a.X)
+	assert.NotEqual(t, a.X, b.X)
+	assert.Empty(t, checkMonitorsLayout(Monitors{a, b, c}, DisplayModeExtend))
+}
+
+func Test_inSameMirrorGroup(t *testing.T) {
+	a := newTestMonitor("eDP-1", true, 0, 0, 1920, 1080)
+	a.ID = 1
+	b := newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b.ID = 2
+	c := newTestMonitor("DP-1", true, 0, 0, 1920, 1080)
+	c.ID = 3
+	assert.False(t, inSameMirrorGroup(a, b))
+
+	b.mirrorLeader = a.ID
+	c.mirrorLeader = a.ID
+	assert.True(t, inSameMirrorGroup(a, b))
+	assert.True(t, inSameMirrorGroup(b, a))
+	assert.True(t, inSameMirrorGroup(b, c))
+
+	c.mirrorLeader = 0
+	assert.False(t, inSameMirrorGroup(b, c))
+}
+
+func TestSysMonitorModeConfig_MirrorGroups(t *testing.T) {
+	cfg := &SysMonitorModeConfig{
+		MirrorGroups: [][]string{{"eDP-1|a|v1", "HDMI-1|b|v1"}},
+	}
+	clone := cfg.clone()
+	clone.MirrorGroups[0][0] = "DP-1|c|v1"
+	assert.Equal(t, "eDP-1|a|v1", cfg.MirrorGroups[0][0])
+
+	// 没有镜像组时不保存这个字段
+	data, err := json.Marshal(&SysMonitorModeConfig{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "MirrorGroups")
+
+	screenCfg := &SysScreenConfig{Extend: cfg}
+	screenCfg = screenCfg.replaceUuids(map[string]string{"HDMI-1|b|v1": "DP-2|b|v1"})
+	assert.Equal(t, [][]string{{"eDP-1|a|v1", "DP-2|b|v1"}}, screenCfg.Extend.MirrorGroups)
+}
@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/go-x11-client/ext/render"
+)
+
+// 复制模式的策略：默认所有显示器使用公共分辨率中最大的，分辨率相差大时画面会变得模糊。
+// 按缩放的策略时，各显示器使用自己的最佳模式，共享的画面与主屏的尺寸相同，其他显示器用 crtc transform 缩放显示：
+// fit 保持宽高比完整显示，留出黑边；fill 保持宽高比铺满显示器，裁掉多出的部分；stretch 拉伸铺满显示器。
+// 扩展模式下的镜像组也按这个策略缩放，使用公共分辨率时镜像组按 fit 缩放。
+
+const (
+	mirrorStrategyCommon  = "common"
+	mirrorStrategyFit     = "fit"
+	mirrorStrategyFill    = "fill"
+	mirrorStrategyStretch = "stretch"
+)
+
+func checkMirrorStrategy(strategy string) error {
+	switch strategy {
+	case mirrorStrategyCommon, mirrorStrategyFit, mirrorStrategyFill, mirrorStrategyStretch:
+		return nil
+	}
+	return fmt.Errorf("invalid mirror strategy %q", strategy)
+}
+
+// getMirrorScales 返回把 width x height 的画面按 scaling 缩放到 mirrorWidth x mirrorHeight 时水平和垂直方向的缩放比，
+// 缩放比是画面上的尺寸与显示器上的尺寸之比。
+func getMirrorScales(width, height, mirrorWidth, mirrorHeight uint16, scaling string) (sx, sy float64) {
+	sx = float64(mirrorWidth) / float64(width)
+	sy = float64(mirrorHeight) / float64(height)
+	switch scaling {
+	case mirrorStrategyFit:
+		s := math.Max(sx, sy)
+		return s, s
+	case mirrorStrategyFill:
+		s := math.Min(sx, sy)
+		return s, s
+	}
+	return sx, sy
+}
+
+// getMirrorTransform 返回把显示器上 width x height 的画面按 scaling 对应到屏幕上 mirrorWidth x mirrorHeight 区域的 transform，
+// 尺寸都是旋转后的，画面居中。fit 时平移是负的，黑边显示的是 mirrorWidth x mirrorHeight 区域外面的画面，
+// 屏幕的尺寸不用增大；扩展模式的镜像组中这部分有其他显示器时改用 fill，见 updateMirrorTransforms。
+func getMirrorTransform(width, height, mirrorWidth, mirrorHeight uint16, scaling string) *render.Transform {
+	sx, sy := getMirrorScales(width, height, mirrorWidth, mirrorHeight, scaling)
+	return &render.Transform{
+		Matrix11: render.ToFixed(sx),
+		Matrix13: render.ToFixed((float64(mirrorWidth) - sx*float64(width)) / 2),
+		Matrix22: render.ToFixed(sy),
+		Matrix23: render.ToFixed((float64(mirrorHeight) - sy*float64(height)) / 2),
+		Matrix33: render.ToFixed(1),
+	}
+}
+
+// setMonitorsMirrorLeader 复制模式下按缩放的策略时，让 monitors 中的其他显示器都缩放到与 leaderID 的显示器相同的尺寸。
+func setMonitorsMirrorLeader(monitors []*Monitor, leaderID uint32) {
+	if leaderID == 0 {
+		return
+	}
+	for _, monitor := range monitors {
+		if monitor.ID != leaderID {
+			monitor.mirrorLeader = leaderID
+		}
+	}
+}
+
+// fixMirrorStrategy 默认值 common 在配置中为空，不合法的策略也改为默认值。
+func (cfg *SysConfig) fixMirrorStrategy() {
+	if cfg.MirrorStrategy == "" {
+		return
+	}
+	if cfg.MirrorStrategy == mirrorStrategyCommon || checkMirrorStrategy(cfg.MirrorStrategy) != nil {
+		if cfg.MirrorStrategy != mirrorStrategyCommon {
+			logger.Warningf("invalid mirror strategy %q", cfg.MirrorStrategy)
+		}
+		cfg.MirrorStrategy = ""
+	}
+}
+
+// getMirrorStrategy 返回配置中的复制模式策略
+func (m *Manager) getMirrorStrategy() string {
+	m.sysConfig.mu.Lock()
+	strategy := m.sysConfig.Config.MirrorStrategy
+	m.sysConfig.mu.Unlock()
+	if strategy == "" || _useWayland {
+		return mirrorStrategyCommon
+	}
+	return strategy
+}
+
+// getMirrorScaling 返回镜像缩放使用的方式，使用公共分辨率时镜像组按 fit 缩放。
+func (m *Manager) getMirrorScaling() string {
+	strategy := m.getMirrorStrategy()
+	if strategy == mirrorStrategyCommon {
+		return mirrorStrategyFit
+	}
+	return strategy
+}
+
+// shouldMirrorScale 复制模式下是否让各显示器使用自己的模式，再用 transform 缩放
+func (m *Manager) shouldMirrorScale(mode byte, enabledMonitors []*Monitor) bool {
+	if len(enabledMonitors) < 2 || m.getMirrorStrategy() == mirrorStrategyCommon {
+		return false
+	}
+	if mode == DisplayModeInvalid {
+		m.PropsMu.RLock()
+		mode = m.DisplayMode
+		m.PropsMu.RUnlock()
+	}
+	return mode == DisplayModeMirror
+}
+
+// buildConfigForModeMirrorScaling 按缩放的策略生成复制模式的配置，各显示器使用自己的最佳模式。
+func (m *Manager) buildConfigForModeMirrorScaling(monitors Monitors) SysMonitorConfigs {
+	primaryMonitor := m.getDefaultPrimaryMonitor(monitors)
+	var monitorCfgs SysMonitorConfigs
+	for _, monitor := range monitors {
+		cfg := monitor.toBasicSysConfig()
+		cfg.Enabled = true
+		if primaryMonitor != nil && monitor.ID == primaryMonitor.ID {
+			cfg.Primary = true
+		}
+		cfg.Width = monitor.BestMode.Width
+		cfg.Height = monitor.BestMode.Height
+		cfg.RefreshRate = monitor.BestMode.Rate
+		cfg.X = 0
+		cfg.Y = 0
+		cfg.Rotation = randr.RotationRotate0
+		cfg.Reflect = 0
+		cfg.Brightness = 1
+		monitorCfgs = append(monitorCfgs, cfg)
+	}
+	return monitorCfgs
+}
+
+// getMirrorStrategy 返回选择复制模式配置中的模式时的策略
+func (c *SysMonitorModeConfig) getMirrorStrategy() string {
+	if c.MirrorStrategy == "" {
+		return mirrorStrategyCommon
+	}
+	return c.MirrorStrategy
+}
+
+// setScreenMonitorConfigs 保存应用过的配置，复制模式时还记录当前的策略，策略改变后 applyModeMirror 会重新生成配置。
+func (m *Manager) setScreenMonitorConfigs(screenCfg *SysScreenConfig, mode byte, uuid string, configs SysMonitorConfigs) {
+	screenCfg.setMonitorConfigs(mode, uuid, configs)
+	if mode != DisplayModeMirror || screenCfg.Mirror == nil {
+		return
+	}
+	strategy := m.getMirrorStrategy()
+	if strategy == mirrorStrategyCommon {
+		strategy = ""
+	}
+	screenCfg.Mirror.MirrorStrategy = strategy
+}
+
+// setMirrorStrategy 设置复制模式的策略，当前是复制模式时重新应用。
+// 已保存的复制模式配置中的模式是按之前的策略选择的，下次应用复制模式时再重新生成，见 applyModeMirror。
+func (m *Manager) setMirrorStrategy(strategy string) error {
+	err := checkMirrorStrategy(strategy)
+	if err != nil {
+		return err
+	}
+	if _useWayland && strategy != mirrorStrategyCommon {
+		return errors.New("mirror scaling is not supported on wayland")
+	}
+	if strategy == mirrorStrategyCommon {
+		// 默认值不写入配置
+		strategy = ""
+	}
+
+	m.sysConfig.mu.Lock()
+	if m.sysConfig.Config.MirrorStrategy == strategy {
+		m.sysConfig.mu.Unlock()
+		return nil
+	}
+	m.sysConfig.Config.MirrorStrategy = strategy
+	err = m.saveSysConfigNoLock("mirror strategy changed")
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	strategy = m.getMirrorStrategy()
+	m.PropsMu.Lock()
+	m.setPropMirrorStrategy(strategy)
+	displayMode := m.DisplayMode
+	m.PropsMu.Unlock()
+	if displayMode != DisplayModeMirror {
+		return nil
+	}
+	return m.reapplyDisplayConfig()
+}
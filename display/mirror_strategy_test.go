@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/ext/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkMirrorStrategy(t *testing.T) {
+	for _, strategy := range []string{mirrorStrategyCommon, mirrorStrategyFit, mirrorStrategyFill, mirrorStrategyStretch} {
+		assert.NoError(t, checkMirrorStrategy(strategy))
+	}
+	assert.Error(t, checkMirrorStrategy(""))
+	assert.Error(t, checkMirrorStrategy("zoom"))
+}
+
+func Test_getMirrorTransform(t *testing.T) {
+	// 4:3 的投影仪显示 16:9 的画面
+	assert.Equal(t, &render.Transform{
+		Matrix11: render.ToFixed(3.75),
+		Matrix22: render.ToFixed(3.75),
+		Matrix23: render.ToFixed(-360),
+		Matrix33: render.ToFixed(1),
+	}, getMirrorTransform(1024, 768, 3840, 2160, mirrorStrategyFit))
+
+	assert.Equal(t, &render.Transform{
+		Matrix11: render.ToFixed(2.8125),
+		Matrix13: render.ToFixed(480),
+		Matrix22: render.ToFixed(2.8125),
+		Matrix33: render.ToFixed(1),
+	}, getMirrorTransform(1024, 768, 3840, 2160, mirrorStrategyFill))
+
+	assert.Equal(t, &render.Transform{
+		Matrix11: render.ToFixed(3.75),
+		Matrix22: render.ToFixed(2.8125),
+		Matrix33: render.ToFixed(1),
+	}, getMirrorTransform(1024, 768, 3840, 2160, mirrorStrategyStretch))
+
+	// 宽高比相同时都一样
+	assert.Equal(t, getMirrorTransform(3840, 2160, 1920, 1080, mirrorStrategyStretch),
+		getMirrorTransform(3840, 2160, 1920, 1080, mirrorStrategyFit))
+}
+
+func Test_mirrorScalingScreenSize(t *testing.T) {
+	primary := newTestMirrorMonitor(1, "eDP-1", 0, 0, ModeInfo{Width: 3840, Height: 2160})
+	projector := newTestMirrorMonitor(2, "HDMI-1", 0, 0, ModeInfo{Width: 1024, Height: 768})
+	monitorMap := map[uint32]*Monitor{1: primary, 2: projector}
+
+	setMonitorsMirrorLeader([]*Monitor{primary, projector}, primary.ID)
+	assert.Equal(t, uint32(0), primary.mirrorLeader)
+	assert.Equal(t, uint32(1), projector.mirrorLeader)
+
+	// 屏幕与主屏的尺寸相同，fit 时的黑边在屏幕外面
+	for _, scaling := range []string{mirrorStrategyFit, mirrorStrategyFill, mirrorStrategyStretch} {
+		updateMirrorTransforms(monitorMap, scaling)
+		rect := getMonitorRect(projector)
+		assert.Equal(t, uint16(3840), rect.Width, scaling)
+		assert.Equal(t, uint16(2160), rect.Height, scaling)
+		sw, sh := getScreenWidthHeight(monitorMap)
+		assert.Equal(t, uint16(3840), sw, scaling)
+		assert.Equal(t, uint16(2160), sh, scaling)
+	}
+}
+
+func TestSysMonitorModeConfig_getMirrorStrategy(t *testing.T) {
+	// 没有记录策略的配置是按公共分辨率选择的模式
+	cfg := &SysMonitorModeConfig{}
+	assert.Equal(t, mirrorStrategyCommon, cfg.getMirrorStrategy())
+	cfg.MirrorStrategy = mirrorStrategyFit
+	assert.Equal(t, mirrorStrategyFit, cfg.getMirrorStrategy())
+	assert.Equal(t, mirrorStrategyFit, cfg.clone().getMirrorStrategy())
+}
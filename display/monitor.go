@@ -18,6 +18,9 @@ import (
 	"github.com/linuxdeepin/go-lib/strv"
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/startdde/display/brightness"
+	"github.com/linuxdeepin/startdde/display/edid"
+	"github.com/linuxdeepin/startdde/display/icc"
 )
 
 const (
@@ -36,6 +39,7 @@ type Monitor struct {
 	service *dbusutil.Service
 	uuid    string // uuid v1
 	uuidV0  string
+	edid    []byte
 	PropsMu sync.RWMutex
 
 	ID            uint32
@@ -73,8 +77,46 @@ type Monitor struct {
 	CurrentFillMode string `prop:"access:rw"`
 	// dbusutil-gen: equal=method:Equal
 	AvailableFillModes strv.Strv
+	// ICC 色彩配置文件的路径
+	ColorProfile string
+	// 色温模式，为 ColorTemperatureModeGlobal 时跟随 Manager 的色温设置
+	ColorTemperatureMode int32
+	// 手动模式的色温值
+	ColorTemperatureManual int32
+	// RGB 范围，Automatic、Full 或者 Limited，为空表示不支持
+	BroadcastRGB string
+	// 最大色深，为 0 表示不支持
+	MaxBpc uint32
+	// 内容类型，为空表示不支持
+	ContentType string
+	// 是否支持可变刷新率，X 下可变刷新率由驱动的 VariableRefresh 选项控制，不能通过 output 属性开关
+	VrrCapable bool
+	// underscan 左右和上下边框的宽度
+	UnderscanHorizontal uint32
+	UnderscanVertical   uint32
+	// 上次应用后实际的边框宽度，用 transform 实现时会被旁边的显示器和当前模式限制，见 updateUnderscanBorders
+	UnderscanAppliedHorizontal uint32
+	UnderscanAppliedVertical   uint32
 
 	backup *MonitorBackup
+	// crtc transform 的缩放比，由 Manager.updateTransformScales 在应用前设置
+	transformScale float64
+	// 是否用 crtc transform 实现 underscan，由 Manager.updateUnderscanTransforms 在应用前设置
+	underscanByTransform bool
+	// 用 transform 实现 underscan 时限制后的边框宽度，见 updateUnderscanBorders
+	underscanHBorder uint16
+	underscanVBorder uint16
+	// 所在镜像组中第一个显示器的 ID，为 0 表示不是镜像组中跟随其他显示器的，由 setMonitorsMirrorGroups 在应用前设置
+	mirrorLeader uint32
+	// 镜像组中用 crtc transform 缩放后在屏幕上的尺寸，为 0 表示不需要，由 updateMirrorTransforms 在应用前设置
+	mirrorWidth  uint16
+	mirrorHeight uint16
+	// 镜像缩放的方式，见 getMirrorScales
+	mirrorScaling string
+	// 是否是合盖的内置显示器，由 Manager.updateLidClosedMonitor 设置
+	lidClosed bool
+	// ColorProfile 中的校准曲线，文件加载失败时为 nil
+	calibration *icc.VCGT
 	// changes 记录 DBus 接口对显示器对象做的设置，也用 PropsMu 保护。
 	changes monitorChanges
 }
@@ -132,39 +174,61 @@ func (m *Monitor) clone() *Monitor {
 	defer m.PropsMu.RUnlock()
 
 	monitorCp := Monitor{
-		m:                  m.m,
-		service:            m.service,
-		uuid:               m.uuid,
-		uuidV0:             m.uuidV0,
-		ID:                 m.ID,
-		Name:               m.Name,
-		Connected:          m.Connected,
-		realConnected:      m.realConnected,
-		Manufacturer:       m.Manufacturer,
-		Model:              m.Model,
-		Rotations:          m.Rotations,
-		Reflects:           m.Reflects,
-		BestMode:           m.BestMode,
-		Modes:              m.Modes,
-		PreferredModes:     m.PreferredModes,
-		MmWidth:            m.MmWidth,
-		MmHeight:           m.MmHeight,
-		Enabled:            m.Enabled,
-		X:                  m.X,
-		Y:                  m.Y,
-		Width:              m.Width,
-		Height:             m.Height,
-		Rotation:           m.Rotation,
-		Reflect:            m.Reflect,
-		RefreshRate:        m.RefreshRate,
-		Brightness:         m.Brightness,
-		CurrentRotateMode:  m.CurrentRotateMode,
-		oldRotation:        m.oldRotation,
-		CurrentMode:        m.CurrentMode,
-		CurrentFillMode:    m.CurrentFillMode,
-		AvailableFillModes: m.AvailableFillModes,
-		backup:             nil,
-		changes:            m.changes.clone(),
+		m:                          m.m,
+		service:                    m.service,
+		uuid:                       m.uuid,
+		uuidV0:                     m.uuidV0,
+		edid:                       m.edid,
+		ID:                         m.ID,
+		Name:                       m.Name,
+		Connected:                  m.Connected,
+		realConnected:              m.realConnected,
+		Manufacturer:               m.Manufacturer,
+		Model:                      m.Model,
+		Rotations:                  m.Rotations,
+		Reflects:                   m.Reflects,
+		BestMode:                   m.BestMode,
+		Modes:                      m.Modes,
+		PreferredModes:             m.PreferredModes,
+		MmWidth:                    m.MmWidth,
+		MmHeight:                   m.MmHeight,
+		Enabled:                    m.Enabled,
+		X:                          m.X,
+		Y:                          m.Y,
+		Width:                      m.Width,
+		Height:                     m.Height,
+		Rotation:                   m.Rotation,
+		Reflect:                    m.Reflect,
+		RefreshRate:                m.RefreshRate,
+		Brightness:                 m.Brightness,
+		CurrentRotateMode:          m.CurrentRotateMode,
+		oldRotation:                m.oldRotation,
+		CurrentMode:                m.CurrentMode,
+		CurrentFillMode:            m.CurrentFillMode,
+		AvailableFillModes:         m.AvailableFillModes,
+		ColorProfile:               m.ColorProfile,
+		ColorTemperatureMode:       m.ColorTemperatureMode,
+		ColorTemperatureManual:     m.ColorTemperatureManual,
+		BroadcastRGB:               m.BroadcastRGB,
+		MaxBpc:                     m.MaxBpc,
+		ContentType:                m.ContentType,
+		VrrCapable:                 m.VrrCapable,
+		UnderscanHorizontal:        m.UnderscanHorizontal,
+		UnderscanVertical:          m.UnderscanVertical,
+		UnderscanAppliedHorizontal: m.UnderscanAppliedHorizontal,
+		UnderscanAppliedVertical:   m.UnderscanAppliedVertical,
+		backup:                     nil,
+		transformScale:             m.transformScale,
+		underscanByTransform:       m.underscanByTransform,
+		underscanHBorder:           m.underscanHBorder,
+		underscanVBorder:           m.underscanVBorder,
+		mirrorLeader:               m.mirrorLeader,
+		mirrorWidth:                m.mirrorWidth,
+		mirrorHeight:               m.mirrorHeight,
+		mirrorScaling:              m.mirrorScaling,
+		lidClosed:                  m.lidClosed,
+		calibration:                m.calibration,
+		changes:                    m.changes.clone(),
 	}
 
 	return &monitorCp
@@ -300,16 +364,134 @@ func (m *Monitor) SetRefreshRate(value float64) *dbus.Error {
 	defer m.PropsMu.Unlock()
 
 	logger.Debugf("monitor %v %v dbus call SetRefreshRate %v", m.ID, m.Name, value)
-	if m.Width == 0 || m.Height == 0 {
+	if m.CurrentMode.Width == 0 || m.CurrentMode.Height == 0 {
 		return dbusutil.ToError(errors.New("width or height is 0"))
 	}
-	mode := getFirstModeBySizeRate(m.Modes, m.Width, m.Height, value)
+	mode := getFirstModeBySizeRate(m.Modes, m.CurrentMode.Width, m.CurrentMode.Height, value)
 	if mode.isZero() {
 		return dbusutil.ToError(errors.New("not found match mode"))
 	}
 	return m.setModeNoLock(mode.Id)
 }
 
+// AddCustomMode 按 CVT（reducedBlanking 为 true 时是 CVT-RB）标准生成模式并添加到显示器上，会保存到配置中。
+func (m *Monitor) AddCustomMode(width, height uint16, refreshRate float64, reducedBlanking bool) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call AddCustomMode %v %v %v %v", m.ID, m.Name, width, height,
+		refreshRate, reducedBlanking)
+	err := m.m.addCustomResolution(m, &SysCustomResolution{
+		Width:           width,
+		Height:          height,
+		RefreshRate:     refreshRate,
+		ReducedBlanking: reducedBlanking,
+	})
+	return dbusutil.ToError(err)
+}
+
+// RemoveCustomMode 删除用 AddCustomMode 添加的模式，参数与添加时的相同。
+func (m *Monitor) RemoveCustomMode(width, height uint16, refreshRate float64, reducedBlanking bool) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call RemoveCustomMode %v %v %v %v", m.ID, m.Name, width, height,
+		refreshRate, reducedBlanking)
+	err := m.m.removeCustomResolution(m, &SysCustomResolution{
+		Width:           width,
+		Height:          height,
+		RefreshRate:     refreshRate,
+		ReducedBlanking: reducedBlanking,
+	})
+	return dbusutil.ToError(err)
+}
+
+// GetEdidInfo 返回解析后的 EDID 信息，包括序列号、生产日期、物理尺寸、支持的时序、色域和 HDR 等，
+// 键是 edid.Info 的字段名，扩展块中的信息在 CEA 和 DisplayID 中。
+func (m *Monitor) GetEdidInfo() (map[string]dbus.Variant, *dbus.Error) {
+	m.PropsMu.RLock()
+	edidData := m.edid
+	m.PropsMu.RUnlock()
+	if len(edidData) == 0 {
+		return nil, dbusutil.ToError(fmt.Errorf("monitor %v has no edid", m.Name))
+	}
+
+	info, err := edid.Decode(edidData)
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	return toEdidInfoMap(info), nil
+}
+
+// GetContrast 通过 DDC/CI 获取显示器的对比度，范围为 0~1，显示器不支持时返回错误。
+func (m *Monitor) GetContrast() (float64, *dbus.Error) {
+	m.PropsMu.RLock()
+	edidData := m.edid
+	m.PropsMu.RUnlock()
+	value, err := brightness.GetContrast(m.Name, edidData)
+	return value, dbusutil.ToError(err)
+}
+
+// SetContrast 通过 DDC/CI 设置显示器的对比度，范围为 0~1。
+func (m *Monitor) SetContrast(value float64) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetContrast %v", m.ID, m.Name, value)
+	m.PropsMu.RLock()
+	edidData := m.edid
+	m.PropsMu.RUnlock()
+	err := brightness.SetContrast(m.Name, edidData, value)
+	return dbusutil.ToError(err)
+}
+
+// SetColorProfile 设置显示器使用的 ICC 色彩配置文件，其中的 vcgt 校准曲线会用于 gamma 表，path 为空时取消。
+func (m *Monitor) SetColorProfile(path string) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetColorProfile %q", m.ID, m.Name, path)
+	err := m.m.setMonitorColorProfile(m, path)
+	return dbusutil.ToError(err)
+}
+
+// SetColorTemperatureMode 单独设置显示器的色温模式，mode 为 ColorTemperatureModeGlobal 时跟随 Manager 的色温设置。
+func (m *Monitor) SetColorTemperatureMode(mode int32) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetColorTemperatureMode %v", m.ID, m.Name, mode)
+	err := m.m.setMonitorColorTempMode(m, mode)
+	return dbusutil.ToError(err)
+}
+
+// SetColorTemperature 设置显示器手动模式的色温值
+func (m *Monitor) SetColorTemperature(value int32) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetColorTemperature %v", m.ID, m.Name, value)
+	err := m.m.setMonitorColorTempValue(m, value)
+	return dbusutil.ToError(err)
+}
+
+// ListOutputProperties 列出显示器支持的 RandR output 属性和可选的值
+func (m *Monitor) ListOutputProperties() ([]OutputProperty, *dbus.Error) {
+	props, err := m.m.mm.listOutputProperties(m.ID)
+	return props, dbusutil.ToError(err)
+}
+
+// SetBroadcastRGB 设置 RGB 范围，value 为 Automatic、Full 或者 Limited。
+func (m *Monitor) SetBroadcastRGB(value string) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetBroadcastRGB %q", m.ID, m.Name, value)
+	err := m.m.setMonitorBroadcastRGB(m, value)
+	return dbusutil.ToError(err)
+}
+
+// SetMaxBpc 设置最大色深
+func (m *Monitor) SetMaxBpc(value uint32) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetMaxBpc %v", m.ID, m.Name, value)
+	err := m.m.setMonitorMaxBpc(m, value)
+	return dbusutil.ToError(err)
+}
+
+// SetContentType 设置内容类型，可选的值见 ListOutputProperties 中的 content type 属性。
+func (m *Monitor) SetContentType(value string) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetContentType %q", m.ID, m.Name, value)
+	err := m.m.setMonitorContentType(m, value)
+	return dbusutil.ToError(err)
+}
+
+// SetUnderscan 设置 underscan 左右和上下边框的宽度，单位是像素，都为 0 时取消。
+// 用 transform 实现时边框可能被限制，应用后实际的宽度见 UnderscanAppliedHorizontal 和 UnderscanAppliedVertical。
+func (m *Monitor) SetUnderscan(horizontalPx, verticalPx uint32) *dbus.Error {
+	logger.Debugf("monitor %v %v dbus call SetUnderscan %v %v", m.ID, m.Name, horizontalPx, verticalPx)
+	err := m.m.setMonitorUnderscan(m, horizontalPx, verticalPx)
+	return dbusutil.ToError(err)
+}
+
 func (m *Monitor) SetPosition(X, y int16) *dbus.Error {
 	logger.Debugf("monitor %v %v dbus call SetPosition %v %v", m.ID, m.Name, X, y)
 	if _dpy == nil {
@@ -451,14 +633,20 @@ func (m *Monitor) toBasicSysConfig() *SysMonitorConfig {
 }
 
 func (m *Monitor) toSysConfig() *SysMonitorConfig {
+	// 使用变换缩放时 Width 和 Height 是在屏幕上占的尺寸，配置中要保存模式的尺寸
+	width, height := m.Width, m.Height
+	if !m.CurrentMode.isZero() {
+		width, height = m.CurrentMode.Width, m.CurrentMode.Height
+		swapWidthHeightWithRotation(m.Rotation, &width, &height)
+	}
 	return &SysMonitorConfig{
 		UUID:        m.uuid,
 		Name:        m.Name,
 		Enabled:     m.Enabled,
 		X:           m.X,
 		Y:           m.Y,
-		Width:       m.Width,
-		Height:      m.Height,
+		Width:       width,
+		Height:      height,
 		Rotation:    m.Rotation,
 		Reflect:     m.Reflect,
 		RefreshRate: m.RefreshRate,
@@ -489,16 +677,23 @@ func (monitors Monitors) getMonitorsId() monitorsId {
 	}
 	var idsV0 []string
 	var idsV1 []string
+	lidClosed := false
 	for _, monitor := range monitors {
 		monitor.PropsMu.RLock()
 		uuidV1 := monitor.uuid
 		uuidV0 := monitor.uuidV0
+		if monitor.lidClosed {
+			lidClosed = true
+		}
 		monitor.PropsMu.RUnlock()
 		idsV0 = append(idsV0, uuidV0)
 		idsV1 = append(idsV1, uuidV1)
 	}
 	sort.Strings(idsV0)
 	sort.Strings(idsV1)
+	if lidClosed {
+		idsV1 = append(idsV1, monitorsIdLidClosed)
+	}
 	return monitorsId{
 		v0: strings.Join(idsV0, monitorsIdDelimiter),
 		v1: strings.Join(idsV1, monitorsIdDelimiter),
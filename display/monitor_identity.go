@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/linuxdeepin/startdde/display/edid"
+)
+
+// 按显示器本身识别显示器：uuid 中包含接口名称，同一个显示器通过扩展坞或 KVM 连接到不同的接口时 uuid 会改变。
+// 查找屏幕配置时，如果没有 monitorsId 对应的配置，就按 EDID 的哈希和序列号查找同一组显示器连接在其他接口上时保存的配置，
+// 并把其中的 uuid 替换为现在的 uuid。序列号按 uuid 记录在 SysCache.Serials 中。
+
+const uuidDelimiter = "|"
+
+// getUuidEdidHash 返回 uuid 中 EDID 的哈希，没有 EDID 时返回空
+func getUuidEdidHash(uuid string) string {
+	fields := strings.Split(uuid, uuidDelimiter)
+	if len(fields) != 3 {
+		return ""
+	}
+	return fields[1]
+}
+
+// getUuidName 返回 uuid 中的 output 名称，uuid 中是标准名称时返回空
+func getUuidName(uuid string) string {
+	name, _, _ := strings.Cut(uuid, uuidDelimiter)
+	if strings.HasPrefix(name, "@") {
+		return ""
+	}
+	return name
+}
+
+// getEdidSerialId 返回由 EDID 中的厂商、产品代码和序列号组成的字符串，没有序列号时返回空。
+func getEdidSerialId(edidData []byte) string {
+	if len(edidData) == 0 {
+		return ""
+	}
+	info, err := edid.Decode(edidData)
+	if err != nil {
+		return ""
+	}
+	serial := info.SerialNumberString
+	if serial == "" && info.SerialNumber != 0 {
+		serial = fmt.Sprintf("%d", info.SerialNumber)
+	}
+	if serial == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%04x-%s", info.ManufacturerId, info.ProductCode, serial)
+}
+
+// isSameMonitorUuid 判断配置中的 uuid 和现在的 uuid 是否是同一个显示器，EDID 的哈希或者序列号相同时是同一个显示器。
+func isSameMonitorUuid(savedUuid, uuid string, serials map[string]string) bool {
+	if savedUuid == uuid {
+		return true
+	}
+	hash := getUuidEdidHash(savedUuid)
+	if hash != "" && hash == getUuidEdidHash(uuid) {
+		return true
+	}
+	serial := serials[savedUuid]
+	return serial != "" && serial == serials[uuid]
+}
+
+// matchUuidsByIdentity 把 savedUuids 与 uuids 一一对应，返回 savedUuid 到 uuid 的映射和 uuid 相同的数量，不能全部对应时返回 nil。
+// uuid 相同的优先对应；同型号的多个显示器 EDID 相同，都能对应时两边按 uuid 排序后依次对应，这样结果是确定的。
+func matchUuidsByIdentity(savedUuids, uuids []string, serials map[string]string) (mapping map[string]string, numSame int) {
+	if len(savedUuids) != len(uuids) {
+		return nil, 0
+	}
+	savedUuids = append([]string(nil), savedUuids...)
+	uuids = append([]string(nil), uuids...)
+	sort.Strings(savedUuids)
+	sort.Strings(uuids)
+
+	mapping = make(map[string]string, len(uuids))
+	used := make([]bool, len(uuids))
+	for _, savedUuid := range savedUuids {
+		for i, uuid := range uuids {
+			if !used[i] && uuid == savedUuid {
+				mapping[savedUuid] = uuid
+				used[i] = true
+				numSame++
+				break
+			}
+		}
+	}
+	for _, savedUuid := range savedUuids {
+		if _, ok := mapping[savedUuid]; ok {
+			continue
+		}
+		for i, uuid := range uuids {
+			if !used[i] && isSameMonitorUuid(savedUuid, uuid, serials) {
+				mapping[savedUuid] = uuid
+				used[i] = true
+				break
+			}
+		}
+		if _, ok := mapping[savedUuid]; !ok {
+			return nil, 0
+		}
+	}
+	return mapping, numSame
+}
+
+// findScreenConfigByIdentity 在没有 id 对应的屏幕配置时，查找同一组显示器连接在其他接口上时的屏幕配置，返回替换了 uuid 的副本。
+// 有多个时选择 uuid 相同的显示器最多的，再按 monitorsId 的顺序选择第一个。
+func (cfg *SysConfig) findScreenConfigByIdentity(id monitorsId) *SysScreenConfig {
+	if id.v1 == "" {
+		return nil
+	}
+	uuids := strings.Split(id.withoutLidClosed().v1, monitorsIdDelimiter)
+	keys := make([]string, 0, len(cfg.Screens))
+	for key := range cfg.Screens {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var bestKey string
+	var bestMapping map[string]string
+	bestNumSame := -1
+	for _, key := range keys {
+		keyId := monitorsId{v1: key}
+		if key == id.v1 || keyId.isLidClosed() != id.isLidClosed() {
+			continue
+		}
+		savedUuids := strings.Split(keyId.withoutLidClosed().v1, monitorsIdDelimiter)
+		mapping, numSame := matchUuidsByIdentity(savedUuids, uuids, cfg.Cache.Serials)
+		if mapping == nil || numSame <= bestNumSame {
+			continue
+		}
+		bestKey, bestMapping, bestNumSame = key, mapping, numSame
+	}
+	if bestMapping == nil {
+		return nil
+	}
+	logger.Debugf("use screen config %q for monitors id %q", bestKey, id.v1)
+	return cfg.Screens[bestKey].replaceUuids(bestMapping)
+}
+
+// replaceUuids 返回把 uuid 按 mapping 替换后的副本
+func (c *SysScreenConfig) replaceUuids(mapping map[string]string) *SysScreenConfig {
+	result := c.clone()
+	if result == nil {
+		return nil
+	}
+	replace := func(uuid string) string {
+		if newUuid, ok := mapping[uuid]; ok {
+			return newUuid
+		}
+		return uuid
+	}
+	for _, modeCfg := range []*SysMonitorModeConfig{result.Mirror, result.Extend, result.Single} {
+		if modeCfg != nil {
+			modeCfg.Monitors.replaceUuids(mapping)
+			for _, group := range modeCfg.MirrorGroups {
+				for i, uuid := range group {
+					group[i] = replace(uuid)
+				}
+			}
+		}
+	}
+	if len(result.OnlyOneMap) > 0 {
+		onlyOneMap := make(map[string]*SysMonitorModeConfig, len(result.OnlyOneMap))
+		for uuid, modeCfg := range result.OnlyOneMap {
+			if modeCfg != nil {
+				modeCfg.Monitors.replaceUuids(mapping)
+			}
+			onlyOneMap[replace(uuid)] = modeCfg
+		}
+		result.OnlyOneMap = onlyOneMap
+	}
+	for _, customCfg := range result.CustomMap {
+		if customCfg != nil {
+			customCfg.Monitors.replaceUuids(mapping)
+		}
+	}
+	result.OnlyOneUuid = replace(result.OnlyOneUuid)
+	return result
+}
+
+// replaceUuids 按 mapping 替换显示器配置的 uuid，并把名称改为新 uuid 中的名称。
+func (cfgs SysMonitorConfigs) replaceUuids(mapping map[string]string) {
+	for _, monitorCfg := range cfgs {
+		newUuid, ok := mapping[monitorCfg.UUID]
+		if !ok {
+			continue
+		}
+		monitorCfg.UUID = newUuid
+		if name := getUuidName(newUuid); name != "" {
+			monitorCfg.Name = name
+		}
+	}
+}
+
+// recordMonitorSerial 记录 uuid 对应的显示器序列号
+func (m *Manager) recordMonitorSerial(uuid string, edidData []byte) {
+	serial := getEdidSerialId(edidData)
+	if serial == "" {
+		return
+	}
+	m.sysConfig.mu.Lock()
+	defer m.sysConfig.mu.Unlock()
+	cache := &m.sysConfig.Config.Cache
+	if cache.Serials[uuid] == serial {
+		return
+	}
+	if cache.Serials == nil {
+		cache.Serials = make(map[string]string)
+	}
+	cache.Serials[uuid] = serial
+	err := m.saveSysConfigNoLock("monitor serial")
+	if err != nil {
+		logger.Warning(err)
+	}
+}
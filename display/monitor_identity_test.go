@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSerialEdid 生成只有基本块的 EDID，厂商是 DEL
+func newTestSerialEdid(productCode uint16, serial uint32) []byte {
+	data := make([]byte, 128)
+	copy(data, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x10, 0xac})
+	data[10], data[11] = byte(productCode), byte(productCode>>8)
+	data[12], data[13], data[14], data[15] = byte(serial), byte(serial>>8), byte(serial>>16), byte(serial>>24)
+	data[18], data[19] = 1, 3
+	var sum byte
+	for _, b := range data[:127] {
+		sum += b
+	}
+	data[127] = -sum
+	return data
+}
+
+func Test_getEdidSerialId(t *testing.T) {
+	assert.Equal(t, "DEL-a0c4-12345", getEdidSerialId(newTestSerialEdid(0xa0c4, 12345)))
+	assert.Equal(t, "", getEdidSerialId(newTestSerialEdid(0xa0c4, 0)))
+	assert.Equal(t, "", getEdidSerialId(nil))
+}
+
+func Test_getUuidEdidHash(t *testing.T) {
+	assert.Equal(t, "abc", getUuidEdidHash("DP-1|abc|v1"))
+	assert.Equal(t, "", getUuidEdidHash("DP-1||v1"))
+	assert.Equal(t, "", getUuidEdidHash("DP-1"))
+	assert.Equal(t, "DP-1", getUuidName("DP-1|abc|v1"))
+	assert.Equal(t, "", getUuidName("@std|abc|v1"))
+}
+
+func Test_matchUuidsByIdentity(t *testing.T) {
+	// 同一个显示器换了接口
+	mapping, numSame := matchUuidsByIdentity([]string{"eDP-1|e|v1", "DP-1|a|v1"},
+		[]string{"DP-3|a|v1", "eDP-1|e|v1"}, nil)
+	assert.Equal(t, map[string]string{"eDP-1|e|v1": "eDP-1|e|v1", "DP-1|a|v1": "DP-3|a|v1"}, mapping)
+	assert.Equal(t, 1, numSame)
+
+	// 没有 EDID 的显示器只能按 uuid 对应
+	mapping, _ = matchUuidsByIdentity([]string{"DP-1||v1"}, []string{"DP-3||v1"}, nil)
+	assert.Nil(t, mapping)
+	// 数量不同
+	mapping, _ = matchUuidsByIdentity([]string{"DP-1|a|v1"}, []string{"DP-3|a|v1", "DP-4|b|v1"}, nil)
+	assert.Nil(t, mapping)
+
+	// EDID 的哈希不同，序列号相同
+	serials := map[string]string{"DP-1|a|v1": "DEL-a0c4-1", "HDMI-1|a2|v1": "DEL-a0c4-1"}
+	mapping, _ = matchUuidsByIdentity([]string{"DP-1|a|v1"}, []string{"HDMI-1|a2|v1"}, serials)
+	assert.Equal(t, map[string]string{"DP-1|a|v1": "HDMI-1|a2|v1"}, mapping)
+
+	// 两个相同的显示器按 uuid 排序后依次对应，与顺序无关
+	for _, uuids := range [][]string{
+		{"DP-5|t|v1", "DP-3|t|v1"},
+		{"DP-3|t|v1", "DP-5|t|v1"},
+	} {
+		mapping, _ = matchUuidsByIdentity([]string{"DP-2|t|v1", "DP-1|t|v1"}, uuids, nil)
+		assert.Equal(t, map[string]string{"DP-1|t|v1": "DP-3|t|v1", "DP-2|t|v1": "DP-5|t|v1"}, mapping)
+	}
+	// 其中一个接口没变时它优先对应
+	mapping, numSame = matchUuidsByIdentity([]string{"DP-1|t|v1", "DP-2|t|v1"},
+		[]string{"DP-2|t|v1", "DP-3|t|v1"}, nil)
+	assert.Equal(t, map[string]string{"DP-2|t|v1": "DP-2|t|v1", "DP-1|t|v1": "DP-3|t|v1"}, mapping)
+	assert.Equal(t, 1, numSame)
+}
+
+func TestSysConfig_findScreenConfigByIdentity(t *testing.T) {
+	cfg := &SysConfig{
+		Screens: map[string]*SysScreenConfig{
+			"DP-1|a|v1,eDP-1|e|v1": {
+				Extend: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{
+					{UUID: "eDP-1|e|v1", Name: "eDP-1", Enabled: true, Width: 1920, Height: 1080},
+					{UUID: "DP-1|a|v1", Name: "DP-1", Enabled: true, Primary: true, X: 1920, Width: 2560, Height: 1440},
+				}},
+				OnlyOneMap: map[string]*SysMonitorModeConfig{
+					"DP-1|a|v1": {Monitors: SysMonitorConfigs{
+						{UUID: "DP-1|a|v1", Name: "DP-1", Enabled: true, Primary: true, Width: 2560, Height: 1440},
+					}},
+				},
+				OnlyOneUuid: "DP-1|a|v1",
+			},
+			"DP-1|a|v1,eDP-1|e|v1,lid-closed": {
+				Extend: &SysMonitorModeConfig{Monitors: SysMonitorConfigs{
+					{UUID: "DP-1|a|v1", Name: "DP-1", Enabled: true, Primary: true, Width: 2560, Height: 1440},
+				}},
+			},
+		},
+	}
+
+	screenCfg := cfg.findScreenConfigByIdentity(monitorsId{v1: "DP-3|a|v1,eDP-1|e|v1"})
+	require.NotNil(t, screenCfg)
+	configs := screenCfg.getMonitorConfigs(DisplayModeExtend, "")
+	require.Len(t, configs, 2)
+	dp := configs.getByUuid("DP-3|a|v1")
+	require.NotNil(t, dp)
+	assert.Equal(t, "DP-3", dp.Name)
+	assert.Equal(t, int16(1920), dp.X)
+	assert.True(t, dp.Primary)
+	assert.Equal(t, "DP-3|a|v1", screenCfg.OnlyOneUuid)
+	assert.NotNil(t, screenCfg.getMonitorConfigs(DisplayModeOnlyOne, "DP-3|a|v1"))
+	// 原来的配置不变
+	assert.Equal(t, "DP-1|a|v1", cfg.Screens["DP-1|a|v1,eDP-1|e|v1"].Extend.Monitors[1].UUID)
+
+	// 合盖时只使用合盖时的配置
+	screenCfg = cfg.findScreenConfigByIdentity(monitorsId{v1: "DP-3|a|v1,eDP-1|e|v1,lid-closed"})
+	require.NotNil(t, screenCfg)
+	assert.Len(t, screenCfg.getMonitorConfigs(DisplayModeExtend, ""), 1)
+
+	assert.Nil(t, cfg.findScreenConfigByIdentity(monitorsId{v1: "DP-3|b|v1,eDP-1|e|v1"}))
+	assert.Nil(t, cfg.findScreenConfigByIdentity(monitorsId{v1: "DP-3|a|v1"}))
+}
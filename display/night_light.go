@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/linuxdeepin/go-lib/gsettings"
+)
+
+// 自动色温：根据所在位置的日出日落时间在白天色温和夜间色温之间切换，
+// 早上在日出前、晚上在日落后逐渐过渡，过渡时长默认是民用晨昏蒙影的时长。
+
+const (
+	defaultDayTemperature   = 6500
+	defaultNightTemperature = 3500
+	maxTransitionDuration   = 3 * time.Hour
+
+	// 过渡期间更新色温的间隔
+	nightLightTransitionInterval = 30 * time.Second
+	// 非过渡期间最长的检查间隔，用于处理待机唤醒、修改系统时间等情况
+	nightLightIdleInterval = 10 * time.Minute
+	// 晨昏蒙影不存在时（比如高纬度的夏天）使用的过渡时长
+	fallbackTransitionDuration = 30 * time.Minute
+)
+
+type nightLightTimer interface {
+	Stop() bool
+}
+
+// nightLightClock 提供当前时间和定时器，测试时可以替换。
+type nightLightClock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) nightLightTimer
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) nightLightTimer {
+	return time.AfterFunc(d, f)
+}
+
+type nightLightConfig struct {
+	dayTemperature   int
+	nightTemperature int
+	// 过渡时长，为 0 时使用晨昏蒙影的时长
+	transition time.Duration
+}
+
+func (c *nightLightConfig) fix() {
+	if !isValidColorTempValue(int32(c.dayTemperature)) {
+		c.dayTemperature = defaultDayTemperature
+	}
+	if !isValidColorTempValue(int32(c.nightTemperature)) {
+		c.nightTemperature = defaultNightTemperature
+	}
+	if c.transition < 0 {
+		c.transition = 0
+	} else if c.transition > maxTransitionDuration {
+		c.transition = maxTransitionDuration
+	}
+}
+
+// nightLightSchedule 是某一天的色温变化时间，早上在 [morningStart, morningEnd] 从夜间色温过渡到白天色温，
+// 晚上在 [eveningStart, eveningEnd] 从白天色温过渡到夜间色温。
+type nightLightSchedule struct {
+	morningStart time.Time
+	morningEnd   time.Time
+	eveningStart time.Time
+	eveningEnd   time.Time
+	// 极昼或极夜
+	status solarEventStatus
+}
+
+func calcNightLightSchedule(date time.Time, latitude, longitude float64, transition time.Duration) nightLightSchedule {
+	sunrise, sunset, status := solarEventTimes(date, latitude, longitude, solarElevationSunrise)
+	if status != solarEventNormal {
+		return nightLightSchedule{status: status}
+	}
+
+	morning, evening := transition, transition
+	if transition == 0 {
+		dawn, dusk, status := solarEventTimes(date, latitude, longitude, solarElevationCivilTwilight)
+		if status == solarEventNormal {
+			morning = sunrise.Sub(dawn)
+			evening = dusk.Sub(sunset)
+		} else {
+			morning = fallbackTransitionDuration
+			evening = fallbackTransitionDuration
+		}
+	}
+	return nightLightSchedule{
+		morningStart: sunrise.Add(-morning),
+		morningEnd:   sunrise,
+		eveningStart: sunset,
+		eveningEnd:   sunset.Add(evening),
+	}
+}
+
+func interpolateTemperature(from, to int, start, end, t time.Time) int {
+	total := end.Sub(start)
+	if total <= 0 {
+		return to
+	}
+	progress := float64(t.Sub(start)) / float64(total)
+	return int(math.Round(float64(from) + float64(to-from)*progress))
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// calcTemperature 计算 t 时刻的色温，并返回下次需要更新的时间。
+func (c *nightLightConfig) calcTemperature(t time.Time, latitude, longitude float64) (int, time.Time) {
+	idleNext := t.Add(nightLightIdleInterval)
+	transitionNext := func(end time.Time) time.Time {
+		return minTime(t.Add(nightLightTransitionInterval), end)
+	}
+
+	s := calcNightLightSchedule(t, latitude, longitude, c.transition)
+	switch s.status {
+	case solarEventAlwaysAbove:
+		return c.dayTemperature, idleNext
+	case solarEventAlwaysBelow:
+		return c.nightTemperature, idleNext
+	}
+
+	switch {
+	case t.Before(s.morningStart):
+		return c.nightTemperature, minTime(s.morningStart, idleNext)
+	case t.Before(s.morningEnd):
+		return interpolateTemperature(c.nightTemperature, c.dayTemperature, s.morningStart, s.morningEnd, t),
+			transitionNext(s.morningEnd)
+	case t.Before(s.eveningStart):
+		return c.dayTemperature, minTime(s.eveningStart, idleNext)
+	case t.Before(s.eveningEnd):
+		return interpolateTemperature(c.dayTemperature, c.nightTemperature, s.eveningStart, s.eveningEnd, t),
+			transitionNext(s.eveningEnd)
+	default:
+		return c.nightTemperature, idleNext
+	}
+}
+
+// nightLight 在自动色温模式和定时模式下定时计算色温，改变时调用 cb。
+type nightLight struct {
+	mu        sync.Mutex
+	clock     nightLightClock
+	running   bool
+	timer     nightLightTimer
+	value     int
+	cfg       nightLightConfig
+	latitude  float64
+	longitude float64
+	// 不为空时按定时模式计算色温，否则根据日出日落计算
+	schedule *colorTempSchedule
+	cb       func(value int)
+}
+
+func newNightLight(clock nightLightClock, cfg nightLightConfig) *nightLight {
+	cfg.fix()
+	return &nightLight{
+		clock: clock,
+		cfg:   cfg,
+	}
+}
+
+func (n *nightLight) start() {
+	n.mu.Lock()
+	if n.running {
+		n.mu.Unlock()
+		return
+	}
+	logger.Debug("nightLight.start")
+	n.running = true
+	n.mu.Unlock()
+	n.update()
+}
+
+func (n *nightLight) stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.running {
+		return
+	}
+	logger.Debug("nightLight.stop")
+	n.running = false
+	n.value = 0
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+}
+
+func (n *nightLight) isRunning() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.running
+}
+
+// update 重新计算色温，并设置下次更新的定时器。
+func (n *nightLight) update() {
+	n.mu.Lock()
+	if !n.running {
+		n.mu.Unlock()
+		return
+	}
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+	now := n.clock.Now()
+	var value int
+	var next time.Time
+	if n.schedule != nil {
+		value, next = n.schedule.calcTemperature(now)
+	} else {
+		value, next = n.cfg.calcTemperature(now, n.latitude, n.longitude)
+	}
+	n.timer = n.clock.AfterFunc(next.Sub(now), n.update)
+	changed := n.value != value
+	n.value = value
+	n.mu.Unlock()
+
+	if changed {
+		logger.Debugf("night light temperature: %d, next update: %v", value, next)
+		if n.cb != nil {
+			n.cb(value)
+		}
+	}
+}
+
+func (n *nightLight) setLocation(latitude, longitude float64) {
+	n.mu.Lock()
+	n.latitude = latitude
+	n.longitude = longitude
+	n.mu.Unlock()
+	n.update()
+}
+
+func (n *nightLight) setConfig(cfg nightLightConfig) {
+	cfg.fix()
+	n.mu.Lock()
+	n.cfg = cfg
+	n.mu.Unlock()
+	n.update()
+}
+
+// setSchedule 设置定时模式的配置，schedule 为空时根据日出日落计算色温。
+func (n *nightLight) setSchedule(schedule *colorTempSchedule) {
+	n.mu.Lock()
+	n.schedule = schedule
+	n.mu.Unlock()
+	n.update()
+}
+
+func (n *nightLight) getValue() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.value
+}
+
+// getNightLightConfig 从 gsettings 读取自动色温的配置
+func (m *Manager) getNightLightConfig() nightLightConfig {
+	cfg := nightLightConfig{
+		dayTemperature:   defaultDayTemperature,
+		nightTemperature: defaultNightTemperature,
+	}
+	if m.settings != nil {
+		cfg.dayTemperature = int(m.settings.GetInt(gsKeyColorTemperatureDay))
+		cfg.nightTemperature = int(m.settings.GetInt(gsKeyColorTemperatureNight))
+		cfg.transition = time.Duration(m.settings.GetInt(gsKeyColorTemperatureTransition)) * time.Minute
+	}
+	return cfg
+}
+
+func (m *Manager) listenNightLightSettingsChanged() {
+	if m.settings == nil {
+		return
+	}
+	gsettings.ConnectChanged(gsSchemaDisplay, "*", func(key string) {
+		switch key {
+		case gsKeyColorTemperatureDay, gsKeyColorTemperatureNight, gsKeyColorTemperatureTransition:
+			m.nightLight.setConfig(m.getNightLightConfig())
+		}
+	})
+}
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	zoneCST = time.FixedZone("CST", 8*3600)
+	zoneGMT = time.FixedZone("GMT", 0)
+)
+
+const (
+	beijingLatitude  = 39.9042
+	beijingLongitude = 116.4074
+)
+
+func assertTimeNear(t *testing.T, expected, actual time.Time) {
+	t.Helper()
+	diff := expected.Sub(actual)
+	if diff < 0 {
+		diff = -diff
+	}
+	assert.LessOrEqual(t, diff, 3*time.Minute, "expected %v, actual %v", expected, actual)
+}
+
+func Test_solarEventTimes(t *testing.T) {
+	date := time.Date(2023, 6, 21, 0, 0, 0, 0, zoneCST)
+	sunrise, sunset, status := solarEventTimes(date, beijingLatitude, beijingLongitude, solarElevationSunrise)
+	require.Equal(t, solarEventNormal, status)
+	assertTimeNear(t, time.Date(2023, 6, 21, 4, 46, 0, 0, zoneCST), sunrise)
+	assertTimeNear(t, time.Date(2023, 6, 21, 19, 46, 0, 0, zoneCST), sunset)
+
+	dawn, dusk, status := solarEventTimes(date, beijingLatitude, beijingLongitude, solarElevationCivilTwilight)
+	require.Equal(t, solarEventNormal, status)
+	assertTimeNear(t, time.Date(2023, 6, 21, 4, 12, 0, 0, zoneCST), dawn)
+	assertTimeNear(t, time.Date(2023, 6, 21, 20, 20, 0, 0, zoneCST), dusk)
+
+	// 伦敦冬至
+	date = time.Date(2023, 12, 21, 12, 0, 0, 0, zoneGMT)
+	sunrise, sunset, status = solarEventTimes(date, 51.5074, -0.1278, solarElevationSunrise)
+	require.Equal(t, solarEventNormal, status)
+	assertTimeNear(t, time.Date(2023, 12, 21, 8, 4, 0, 0, zoneGMT), sunrise)
+	assertTimeNear(t, time.Date(2023, 12, 21, 15, 54, 0, 0, zoneGMT), sunset)
+
+	// 特罗姆瑟的极昼和极夜
+	_, _, status = solarEventTimes(time.Date(2023, 6, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96, solarElevationSunrise)
+	assert.Equal(t, solarEventAlwaysAbove, status)
+	_, _, status = solarEventTimes(time.Date(2023, 12, 21, 12, 0, 0, 0, time.UTC), 69.65, 18.96, solarElevationSunrise)
+	assert.Equal(t, solarEventAlwaysBelow, status)
+}
+
+func Test_solarElevation(t *testing.T) {
+	// 北京夏至正午太阳高度角约为 90 - 39.9 + 23.44
+	noon := time.Date(2023, 6, 21, 12, 15, 0, 0, zoneCST)
+	assert.InDelta(t, 73.5, solarElevation(noon, beijingLatitude, beijingLongitude), 0.5)
+	midnight := time.Date(2023, 6, 21, 0, 15, 0, 0, zoneCST)
+	assert.InDelta(t, -26.7, solarElevation(midnight, beijingLatitude, beijingLongitude), 0.5)
+}
+
+func Test_nightLightConfig_calcTemperature(t *testing.T) {
+	cfg := nightLightConfig{
+		dayTemperature:   6500,
+		nightTemperature: 3500,
+	}
+	date := time.Date(2023, 6, 21, 0, 0, 0, 0, zoneCST)
+	at := func(hour, min int) time.Time {
+		return date.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	}
+
+	value, next := cfg.calcTemperature(at(1, 0), beijingLatitude, beijingLongitude)
+	assert.Equal(t, 3500, value)
+	assert.Equal(t, at(1, 10), next)
+
+	value, _ = cfg.calcTemperature(at(12, 0), beijingLatitude, beijingLongitude)
+	assert.Equal(t, 6500, value)
+
+	// 日落后逐渐降低
+	value, next = cfg.calcTemperature(at(20, 0), beijingLatitude, beijingLongitude)
+	assert.Less(t, value, 6500)
+	assert.Greater(t, value, 3500)
+	assert.Equal(t, at(20, 0).Add(nightLightTransitionInterval), next)
+	value2, _ := cfg.calcTemperature(at(20, 10), beijingLatitude, beijingLongitude)
+	assert.Less(t, value2, value)
+
+	value, _ = cfg.calcTemperature(at(22, 0), beijingLatitude, beijingLongitude)
+	assert.Equal(t, 3500, value)
+
+	// 固定的过渡时长
+	cfg.transition = 2 * time.Hour
+	value, _ = cfg.calcTemperature(at(21, 0), beijingLatitude, beijingLongitude)
+	assert.Less(t, value, 6500)
+	assert.Greater(t, value, 3500)
+
+	// 极昼
+	value, _ = cfg.calcTemperature(time.Date(2023, 6, 21, 23, 0, 0, 0, time.UTC), 69.65, 18.96)
+	assert.Equal(t, 6500, value)
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	f        func()
+	stopped  bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+// fakeClock 是可以手动调整时间的时钟，Advance 时同步执行到期的定时器。
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) nightLightTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, deadline: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		sort.Slice(c.timers, func(i, j int) bool {
+			return c.timers[i].deadline.Before(c.timers[j].deadline)
+		})
+		var timer *fakeTimer
+		for len(c.timers) > 0 {
+			first := c.timers[0]
+			if first.stopped {
+				c.timers = c.timers[1:]
+				continue
+			}
+			if !first.deadline.After(end) {
+				timer = first
+				c.timers = c.timers[1:]
+				timer.stopped = true
+				c.now = timer.deadline
+			}
+			break
+		}
+		if timer == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		timer.f()
+	}
+}
+
+func TestNightLight(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 6, 21, 12, 0, 0, 0, zoneCST)}
+	n := newNightLight(clock, nightLightConfig{
+		dayTemperature:   6000,
+		nightTemperature: 4000,
+	})
+	var values []int
+	n.cb = func(value int) {
+		values = append(values, value)
+	}
+	n.setLocation(beijingLatitude, beijingLongitude)
+	assert.Empty(t, values)
+	assert.Equal(t, 0, n.getValue())
+
+	n.start()
+	assert.True(t, n.isRunning())
+	assert.Equal(t, []int{6000}, values)
+
+	// 到第二天中午，经过一次日落和日出
+	clock.Advance(24 * time.Hour)
+	assert.Equal(t, 6000, n.getValue())
+	require.Greater(t, len(values), 10)
+	assert.Contains(t, values, 4000)
+	// 先降低再升高
+	minIdx := 0
+	for i, v := range values {
+		if v < values[minIdx] {
+			minIdx = i
+		}
+	}
+	for i := 1; i <= minIdx; i++ {
+		assert.Less(t, values[i], values[i-1])
+	}
+	for i := minIdx + 1; i < len(values); i++ {
+		assert.Greater(t, values[i], values[i-1])
+	}
+
+	// 修改配置立即生效
+	clock.Advance(10 * time.Hour)
+	assert.Equal(t, 4000, n.getValue())
+	n.setConfig(nightLightConfig{dayTemperature: 6500, nightTemperature: 3000})
+	assert.Equal(t, 3000, n.getValue())
+
+	n.stop()
+	assert.False(t, n.isRunning())
+	assert.Equal(t, 0, n.getValue())
+	values = nil
+	clock.Advance(24 * time.Hour)
+	assert.Empty(t, values)
+}
@@ -0,0 +1,324 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linuxdeepin/go-lib/strv"
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+// RandR output 属性，比如 HDMI 显示器的 RGB 范围、最大色深、内容类型和是否支持可变刷新率。
+// 通过 Monitor 上的接口设置的值按显示器的 uuid 保存在 SysConfig.OutputProperties 中，
+// 显示器重新连接后再设置回去。
+
+const (
+	outputPropBroadcastRGB = "Broadcast RGB"
+	outputPropMaxBpc       = "max bpc"
+	outputPropContentType  = "content type"
+	outputPropVrrCapable   = "vrr_capable"
+)
+
+// monitorOutputPropNames 是显示器 DBus 属性对应的 output 属性
+var monitorOutputPropNames = []string{
+	outputPropBroadcastRGB,
+	outputPropMaxBpc,
+	outputPropContentType,
+	outputPropVrrCapable,
+}
+
+const (
+	BroadcastRGBAutomatic = "Automatic"
+	BroadcastRGBFull      = "Full"
+	BroadcastRGBLimited   = "Limited"
+)
+
+// OutputProperty 显示器支持的 RandR output 属性
+type OutputProperty struct {
+	Name string
+	// 可选的值，Range 为 true 时为空
+	Values []string
+	// 为 true 时值是 Min 到 Max 之间的整数
+	Range     bool
+	Min       int32
+	Max       int32
+	Immutable bool
+	Current   string
+}
+
+// checkValue 检查 value 是否可以设置为属性的值
+func (p *OutputProperty) checkValue(value string) error {
+	if p.Immutable {
+		return fmt.Errorf("output property %q is immutable", p.Name)
+	}
+	if p.Range {
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for output property %q", value, p.Name)
+		}
+		if int32(v) < p.Min || int32(v) > p.Max {
+			return fmt.Errorf("value %v out of range [%v, %v] for output property %q", v, p.Min, p.Max, p.Name)
+		}
+		return nil
+	}
+	if len(p.Values) > 0 && !strv.Strv(p.Values).Contains(value) {
+		return fmt.Errorf("invalid value %q for output property %q, valid values: %v", value, p.Name, p.Values)
+	}
+	return nil
+}
+
+// matchValue 在可选的值中查找 value，找不到时查找以 value 加空格开头的值，比如 Limited 对应 Limited 16:235。
+func (p *OutputProperty) matchValue(value string) (string, bool) {
+	for _, v := range p.Values {
+		if v == value {
+			return v, true
+		}
+	}
+	for _, v := range p.Values {
+		if strings.HasPrefix(v, value+" ") {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func findOutputProperty(props []OutputProperty, name string) *OutputProperty {
+	for i := range props {
+		if props[i].Name == name {
+			return &props[i]
+		}
+	}
+	return nil
+}
+
+// getBroadcastRGB 把 Broadcast RGB 属性的值转换为 BroadcastRGBAutomatic、BroadcastRGBFull 或者 BroadcastRGBLimited
+func getBroadcastRGB(value string) string {
+	if strings.HasPrefix(value, BroadcastRGBLimited) {
+		return BroadcastRGBLimited
+	}
+	return value
+}
+
+// decodeOutputPropertyValue 解析 INTEGER 或 ATOM 类型属性的第一个值
+func decodeOutputPropertyValue(format uint8, data []byte) (int32, error) {
+	if len(data) < int(format/8) {
+		return 0, errors.New("property value too short")
+	}
+	r := x.NewReaderFromData(data)
+	switch format {
+	case 8:
+		return int32(int8(r.Read1b())), nil
+	case 16:
+		return int32(int16(r.Read2b())), nil
+	case 32:
+		return int32(r.Read4b()), nil
+	}
+	return 0, fmt.Errorf("invalid property format %d", format)
+}
+
+func encodeOutputPropertyValue(format uint8, value int32) ([]byte, error) {
+	w := x.NewWriter()
+	switch format {
+	case 8:
+		w.Write1b(uint8(value))
+	case 16:
+		w.Write2b(uint16(value))
+	case 32:
+		w.Write4b(uint32(value))
+	default:
+		return nil, fmt.Errorf("invalid property format %d", format)
+	}
+	return w.Bytes(), nil
+}
+
+func (cfg *SysConfig) getOutputProperties(uuid string) map[string]string {
+	props := cfg.OutputProperties[uuid]
+	if len(props) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(props))
+	for name, value := range props {
+		result[name] = value
+	}
+	return result
+}
+
+// setOutputProperty 保存显示器 uuid 的属性值，返回配置是否改变。
+func (cfg *SysConfig) setOutputProperty(uuid, name, value string) bool {
+	if cfg.OutputProperties[uuid][name] == value {
+		return false
+	}
+	if cfg.OutputProperties == nil {
+		cfg.OutputProperties = make(map[string]map[string]string)
+	}
+	if cfg.OutputProperties[uuid] == nil {
+		cfg.OutputProperties[uuid] = make(map[string]string)
+	}
+	cfg.OutputProperties[uuid][name] = value
+	return true
+}
+
+// checkSavedOutputProperty 检查配置中的 output 属性，只能保存可以通过 Monitor 上的接口设置的属性。
+func checkSavedOutputProperty(name, value string) error {
+	switch name {
+	case outputPropBroadcastRGB:
+		switch getBroadcastRGB(value) {
+		case BroadcastRGBAutomatic, BroadcastRGBFull, BroadcastRGBLimited:
+			return nil
+		}
+	case outputPropMaxBpc:
+		if _, err := strconv.ParseUint(value, 10, 32); err == nil {
+			return nil
+		}
+	case outputPropContentType:
+		if value != "" {
+			return nil
+		}
+	default:
+		return fmt.Errorf("output property %q can not be saved", name)
+	}
+	return fmt.Errorf("invalid value %q of output property %q", value, name)
+}
+
+// fixOutputProperties 删除配置中不合法的 output 属性
+func (cfg *SysConfig) fixOutputProperties() {
+	for uuid, props := range cfg.OutputProperties {
+		for name, value := range props {
+			err := checkSavedOutputProperty(name, value)
+			if err != nil {
+				logger.Warningf("output property of %s: %v", uuid, err)
+				delete(props, name)
+			}
+		}
+		if len(props) == 0 {
+			delete(cfg.OutputProperties, uuid)
+		}
+	}
+}
+
+// updateMonitorOutputProperties 从 output 属性更新显示器的 BroadcastRGB 等 DBus 属性
+func (m *Manager) updateMonitorOutputProperties(monitor *Monitor) {
+	props, err := m.mm.getOutputProperties(monitor.ID, monitorOutputPropNames)
+	if err != nil {
+		logger.Warningf("failed to get output properties of %v: %v", monitor, err)
+		return
+	}
+	var broadcastRGB, contentType string
+	var maxBpc uint32
+	var vrrCapable bool
+	for _, prop := range props {
+		switch prop.Name {
+		case outputPropBroadcastRGB:
+			broadcastRGB = getBroadcastRGB(prop.Current)
+		case outputPropMaxBpc:
+			v, _ := strconv.ParseUint(prop.Current, 10, 32)
+			maxBpc = uint32(v)
+		case outputPropContentType:
+			contentType = prop.Current
+		case outputPropVrrCapable:
+			vrrCapable = prop.Current == "1"
+		}
+	}
+
+	monitor.PropsMu.Lock()
+	monitor.setPropBroadcastRGB(broadcastRGB)
+	monitor.setPropMaxBpc(maxBpc)
+	monitor.setPropContentType(contentType)
+	monitor.setPropVrrCapable(vrrCapable)
+	monitor.PropsMu.Unlock()
+}
+
+// setMonitorOutputProperty 设置显示器的 output 属性并保存
+func (m *Manager) setMonitorOutputProperty(monitor *Monitor, name, value string) error {
+	err := m.mm.setOutputProperty(monitor.ID, name, value)
+	if err != nil {
+		return err
+	}
+
+	monitor.PropsMu.RLock()
+	uuid := monitor.uuid
+	monitor.PropsMu.RUnlock()
+	m.sysConfig.mu.Lock()
+	if m.sysConfig.Config.setOutputProperty(uuid, name, value) {
+		err = m.saveSysConfigNoLock("set output property")
+	}
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	m.updateMonitorOutputProperties(monitor)
+	return nil
+}
+
+// getMonitorOutputProperty 获取显示器的 output 属性，不支持时返回错误。
+func (m *Manager) getMonitorOutputProperty(monitor *Monitor, name string) (*OutputProperty, error) {
+	props, err := m.mm.getOutputProperties(monitor.ID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	prop := findOutputProperty(props, name)
+	if prop == nil {
+		return nil, fmt.Errorf("output property %q is not supported by %s", name, monitor.Name)
+	}
+	return prop, nil
+}
+
+func (m *Manager) setMonitorBroadcastRGB(monitor *Monitor, value string) error {
+	if value != BroadcastRGBAutomatic && value != BroadcastRGBFull && value != BroadcastRGBLimited {
+		return fmt.Errorf("invalid broadcast rgb %q", value)
+	}
+	prop, err := m.getMonitorOutputProperty(monitor, outputPropBroadcastRGB)
+	if err != nil {
+		return err
+	}
+	propValue, ok := prop.matchValue(value)
+	if !ok {
+		return fmt.Errorf("broadcast rgb %q is not supported by %s", value, monitor.Name)
+	}
+	return m.setMonitorOutputProperty(monitor, outputPropBroadcastRGB, propValue)
+}
+
+func (m *Manager) setMonitorMaxBpc(monitor *Monitor, value uint32) error {
+	return m.setMonitorOutputProperty(monitor, outputPropMaxBpc, strconv.FormatUint(uint64(value), 10))
+}
+
+func (m *Manager) setMonitorContentType(monitor *Monitor, value string) error {
+	return m.setMonitorOutputProperty(monitor, outputPropContentType, value)
+}
+
+// restoreMonitorOutputProperties 显示器连接或者 uuid 改变时，把配置中 uuid 对应的 output 属性设置回去。
+func (m *Manager) restoreMonitorOutputProperties(monitor *Monitor, uuid string) {
+	m.sysConfig.mu.Lock()
+	saved := m.sysConfig.Config.getOutputProperties(uuid)
+	m.sysConfig.mu.Unlock()
+
+	if len(saved) > 0 {
+		names := make([]string, 0, len(saved))
+		for name := range saved {
+			names = append(names, name)
+		}
+		props, err := m.mm.getOutputProperties(monitor.ID, names)
+		if err != nil {
+			logger.Warning(err)
+		}
+		for name, value := range saved {
+			prop := findOutputProperty(props, name)
+			if prop == nil || prop.Current == value {
+				continue
+			}
+			logger.Debugf("restore output property %q of %v to %q", name, monitor, value)
+			err = m.mm.setOutputProperty(monitor.ID, name, value)
+			if err != nil {
+				logger.Warningf("failed to restore output property %q of %v: %v", name, monitor, err)
+			}
+		}
+	}
+	m.updateMonitorOutputProperties(monitor)
+}
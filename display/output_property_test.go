@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputProperty_checkValue(t *testing.T) {
+	maxBpc := &OutputProperty{Name: outputPropMaxBpc, Range: true, Min: 6, Max: 12}
+	assert.NoError(t, maxBpc.checkValue("8"))
+	assert.NoError(t, maxBpc.checkValue("12"))
+	assert.Error(t, maxBpc.checkValue("16"))
+	assert.Error(t, maxBpc.checkValue("abc"))
+
+	broadcastRGB := &OutputProperty{
+		Name:   outputPropBroadcastRGB,
+		Values: []string{"Automatic", "Full", "Limited 16:235"},
+	}
+	assert.NoError(t, broadcastRGB.checkValue("Full"))
+	assert.Error(t, broadcastRGB.checkValue("Limited"))
+
+	vrrCapable := &OutputProperty{Name: outputPropVrrCapable, Range: true, Max: 1, Immutable: true}
+	assert.Error(t, vrrCapable.checkValue("1"))
+}
+
+func TestOutputProperty_matchValue(t *testing.T) {
+	prop := &OutputProperty{
+		Name:   outputPropBroadcastRGB,
+		Values: []string{"Automatic", "Full", "Limited 16:235"},
+	}
+	value, ok := prop.matchValue(BroadcastRGBLimited)
+	assert.True(t, ok)
+	assert.Equal(t, "Limited 16:235", value)
+	value, ok = prop.matchValue(BroadcastRGBFull)
+	assert.True(t, ok)
+	assert.Equal(t, "Full", value)
+	_, ok = prop.matchValue("Lim")
+	assert.False(t, ok)
+
+	assert.Equal(t, BroadcastRGBLimited, getBroadcastRGB("Limited 16:235"))
+	assert.Equal(t, BroadcastRGBAutomatic, getBroadcastRGB("Automatic"))
+}
+
+func Test_outputPropertyValue(t *testing.T) {
+	for _, format := range []uint8{8, 16, 32} {
+		data, err := encodeOutputPropertyValue(format, -2)
+		require.NoError(t, err)
+		assert.Len(t, data, int(format/8))
+		value, err := decodeOutputPropertyValue(format, data)
+		require.NoError(t, err)
+		assert.Equal(t, int32(-2), value)
+	}
+	_, err := encodeOutputPropertyValue(24, 1)
+	assert.Error(t, err)
+	_, err = decodeOutputPropertyValue(32, []byte{1})
+	assert.Error(t, err)
+}
+
+func TestSysConfig_setOutputProperty(t *testing.T) {
+	var cfg SysConfig
+	assert.Nil(t, cfg.getOutputProperties("uuid1"))
+	assert.True(t, cfg.setOutputProperty("uuid1", outputPropMaxBpc, "10"))
+	assert.False(t, cfg.setOutputProperty("uuid1", outputPropMaxBpc, "10"))
+	assert.True(t, cfg.setOutputProperty("uuid1", outputPropBroadcastRGB, "Full"))
+
+	props := cfg.getOutputProperties("uuid1")
+	assert.Equal(t, map[string]string{
+		outputPropMaxBpc:       "10",
+		outputPropBroadcastRGB: "Full",
+	}, props)
+	props[outputPropMaxBpc] = "8"
+	assert.Equal(t, "10", cfg.OutputProperties["uuid1"][outputPropMaxBpc])
+}
@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+)
+
+// 多显卡：randr 1.4 中每个显卡是一个 provider，第一个是主显卡。其他显卡上的接口要把主显卡设置为它的输出源后
+// 才会出现在屏幕资源中，相当于 xrandr --setprovideroutputsource；用其他显卡渲染要把主显卡设置为它的渲染卸载目标，
+// 相当于 xrandr --setprovideroffloadsink。启动时和显卡热插拔时自动设置这些关联，关联后其他显卡上的接口和主显卡上的一样处理。
+
+const (
+	providerCapSourceOutput  = "SourceOutput"
+	providerCapSinkOutput    = "SinkOutput"
+	providerCapSourceOffload = "SourceOffload"
+	providerCapSinkOffload   = "SinkOffload"
+)
+
+// ProviderInfo 是显卡的信息
+type ProviderInfo struct {
+	Id   uint32
+	Name string
+	// 是否是主显卡
+	Primary bool
+	// 能力，可能有 SourceOutput、SinkOutput、SourceOffload 和 SinkOffload
+	Capabilities []string
+	NumCrtcs     uint32
+	NumOutputs   uint32
+	// 输出源的 Id，为 0 表示没有设置
+	OutputSource uint32
+	// 渲染卸载目标的 Id，为 0 表示没有设置
+	OffloadSink uint32
+}
+
+// randr 1.4 的 ProviderChangeNotify 事件，库中没有对应的类型，只用来通知 xMonitorManager 更新显卡信息。
+type providerChangeNotifyEvent struct{}
+
+type providerInfo struct {
+	id           randr.Provider
+	name         string
+	capabilities uint32
+	numCrtcs     int
+	numOutputs   int
+	outputSource randr.Provider
+	offloadSink  randr.Provider
+}
+
+func toProviderInfo(id randr.Provider, reply *randr.GetProviderInfoReply) *providerInfo {
+	info := &providerInfo{
+		id:           id,
+		name:         reply.Name,
+		capabilities: reply.Capabilities,
+		numCrtcs:     len(reply.Crtcs),
+		numOutputs:   len(reply.Outputs),
+	}
+	// 关联的能力是对方的角色，对方是 SourceOutput 表示它是这个 provider 的输出源，
+	// 对方是 SinkOffload 表示它是这个 provider 的渲染卸载目标。
+	for i, associated := range reply.AssociatedProviders {
+		if i >= len(reply.AssociatedCapability) {
+			break
+		}
+		capability := reply.AssociatedCapability[i]
+		if capability&randr.ProviderCapabilitySourceOutput != 0 {
+			info.outputSource = associated
+		}
+		if capability&randr.ProviderCapabilitySinkOffload != 0 {
+			info.offloadSink = associated
+		}
+	}
+	return info
+}
+
+func getProviderCapabilityNames(capabilities uint32) []string {
+	names := make([]string, 0, 4)
+	if capabilities&randr.ProviderCapabilitySourceOutput != 0 {
+		names = append(names, providerCapSourceOutput)
+	}
+	if capabilities&randr.ProviderCapabilitySinkOutput != 0 {
+		names = append(names, providerCapSinkOutput)
+	}
+	if capabilities&randr.ProviderCapabilitySourceOffload != 0 {
+		names = append(names, providerCapSourceOffload)
+	}
+	if capabilities&randr.ProviderCapabilitySinkOffload != 0 {
+		names = append(names, providerCapSinkOffload)
+	}
+	return names
+}
+
+func (pi *providerInfo) toProviderInfo(primary bool) ProviderInfo {
+	return ProviderInfo{
+		Id:           uint32(pi.id),
+		Name:         pi.name,
+		Primary:      primary,
+		Capabilities: getProviderCapabilityNames(pi.capabilities),
+		NumCrtcs:     uint32(pi.numCrtcs),
+		NumOutputs:   uint32(pi.numOutputs),
+		OutputSource: uint32(pi.outputSource),
+		OffloadSink:  uint32(pi.offloadSink),
+	}
+}
+
+func toProviderInfos(providers []*providerInfo) []ProviderInfo {
+	result := make([]ProviderInfo, len(providers))
+	for i, provider := range providers {
+		result[i] = provider.toProviderInfo(i == 0)
+	}
+	return result
+}
+
+type providerLink struct {
+	provider randr.Provider
+	target   randr.Provider
+	// 为 true 时把 target 设置为渲染卸载目标，否则设置为输出源
+	offload bool
+}
+
+// getProviderLinks 返回还需要设置的关联，其他显卡有接口时把主显卡设置为它的输出源，能卸载渲染时把主显卡设置为它的渲染卸载目标。
+func getProviderLinks(providers []*providerInfo) []providerLink {
+	if len(providers) < 2 {
+		return nil
+	}
+	primary := providers[0]
+	var links []providerLink
+	for _, provider := range providers[1:] {
+		if provider.numOutputs > 0 &&
+			provider.capabilities&randr.ProviderCapabilitySinkOutput != 0 &&
+			primary.capabilities&randr.ProviderCapabilitySourceOutput != 0 &&
+			provider.outputSource != primary.id {
+			links = append(links, providerLink{provider: provider.id, target: primary.id})
+		}
+		if provider.capabilities&randr.ProviderCapabilitySourceOffload != 0 &&
+			primary.capabilities&randr.ProviderCapabilitySinkOffload != 0 &&
+			provider.offloadSink != primary.id {
+			links = append(links, providerLink{provider: provider.id, target: primary.id, offload: true})
+		}
+	}
+	return links
+}
+
+func (mm *xMonitorManager) queryProviders() ([]*providerInfo, error) {
+	root := mm.xConn.GetDefaultScreen().Root
+	reply, err := randr.GetProviders(mm.xConn, root).Reply(mm.xConn)
+	if err != nil {
+		return nil, err
+	}
+	providers := make([]*providerInfo, 0, len(reply.Providers))
+	for _, id := range reply.Providers {
+		infoReply, err := randr.GetProviderInfo(mm.xConn, id, mm.cfgTs).Reply(mm.xConn)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, toProviderInfo(id, infoReply))
+	}
+	return providers, nil
+}
+
+// setupProviders 获取显卡信息并设置多显卡的关联，有新设置的关联时返回 true，这时需要重新获取屏幕资源。
+func (mm *xMonitorManager) setupProviders() (linked bool) {
+	// NOTE: 不要加锁
+	if !_hasRandr1d4 {
+		return false
+	}
+	providers, err := mm.queryProviders()
+	if err != nil {
+		logger.Warning("get providers failed:", err)
+		return false
+	}
+
+	for _, link := range getProviderLinks(providers) {
+		var err error
+		if link.offload {
+			logger.Debugf("set provider %v offload sink %v", link.provider, link.target)
+			err = randr.SetProviderOffloadSinkChecked(mm.xConn, link.provider, link.target, mm.cfgTs).Check(mm.xConn)
+		} else {
+			logger.Debugf("set provider %v output source %v", link.provider, link.target)
+			err = randr.SetProviderOutputSourceChecked(mm.xConn, link.provider, link.target, mm.cfgTs).Check(mm.xConn)
+		}
+		if err != nil {
+			logger.Warningf("link provider %v to %v failed: %v", link.provider, link.target, err)
+			continue
+		}
+		linked = true
+	}
+
+	if linked {
+		providers, err = mm.queryProviders()
+		if err != nil {
+			logger.Warning("get providers failed:", err)
+		}
+	}
+	if err == nil {
+		mm.providers = toProviderInfos(providers)
+	}
+	return linked
+}
+
+func (mm *xMonitorManager) getProviders() []ProviderInfo {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	result := make([]ProviderInfo, len(mm.providers))
+	for i, provider := range mm.providers {
+		result[i] = provider
+		result[i].Capabilities = append([]string(nil), provider.Capabilities...)
+	}
+	return result
+}
+
+// handleProvidersChanged 处理显卡变化。显卡热插拔时 rewire 为 true，需要重新设置关联；只是关联变化时不重新设置，
+// 以免覆盖用户用 xrandr 做的设置。之后重新获取屏幕资源，这样新增的接口会作为新的显示器加入。
+func (mm *xMonitorManager) handleProvidersChanged(rewire bool) {
+	// NOTE: 不要加锁
+	if rewire {
+		mm.setupProviders()
+	} else {
+		providers, err := mm.queryProviders()
+		if err != nil {
+			logger.Warning("get providers failed:", err)
+		} else {
+			mm.providers = toProviderInfos(providers)
+		}
+	}
+	mm.reloadResources()
+}
+
+func (m *Manager) updatePropProviders() {
+	providers := m.mm.getProviders()
+	m.PropsMu.Lock()
+	m.setPropProviders(providers)
+	m.PropsMu.Unlock()
+}
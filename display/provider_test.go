@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testProviderCapsIntel = randr.ProviderCapabilitySourceOutput | randr.ProviderCapabilitySinkOutput |
+		randr.ProviderCapabilitySourceOffload | randr.ProviderCapabilitySinkOffload
+	testProviderCapsNvidia = randr.ProviderCapabilitySinkOutput | randr.ProviderCapabilitySourceOffload
+)
+
+func Test_toProviderInfo(t *testing.T) {
+	info := toProviderInfo(0x1c0, &randr.GetProviderInfoReply{
+		Name:                 "NVIDIA-G0",
+		Capabilities:         testProviderCapsNvidia,
+		Crtcs:                []randr.Crtc{1, 2, 3, 4},
+		Outputs:              []randr.Output{5, 6},
+		AssociatedProviders:  []randr.Provider{0x47},
+		AssociatedCapability: []uint32{randr.ProviderCapabilitySinkOffload | randr.ProviderCapabilitySourceOutput},
+	})
+	assert.Equal(t, ProviderInfo{
+		Id:           0x1c0,
+		Name:         "NVIDIA-G0",
+		Capabilities: []string{providerCapSinkOutput, providerCapSourceOffload},
+		NumCrtcs:     4,
+		NumOutputs:   2,
+		OutputSource: 0x47,
+		OffloadSink:  0x47,
+	}, info.toProviderInfo(false))
+
+	assert.Equal(t, []string{providerCapSourceOutput, providerCapSinkOutput, providerCapSourceOffload,
+		providerCapSinkOffload}, getProviderCapabilityNames(testProviderCapsIntel))
+	assert.Empty(t, getProviderCapabilityNames(0))
+}
+
+func Test_getProviderLinks(t *testing.T) {
+	intel := &providerInfo{id: 0x47, name: "modesetting", capabilities: testProviderCapsIntel, numOutputs: 3}
+	nvidia := &providerInfo{id: 0x1c0, name: "NVIDIA-G0", capabilities: testProviderCapsNvidia, numOutputs: 2}
+
+	// 只有一个显卡
+	assert.Empty(t, getProviderLinks([]*providerInfo{intel}))
+
+	assert.Equal(t, []providerLink{
+		{provider: 0x1c0, target: 0x47},
+		{provider: 0x1c0, target: 0x47, offload: true},
+	}, getProviderLinks([]*providerInfo{intel, nvidia}))
+
+	// 已经设置过的关联不再设置
+	nvidia.outputSource = 0x47
+	assert.Equal(t, []providerLink{
+		{provider: 0x1c0, target: 0x47, offload: true},
+	}, getProviderLinks([]*providerInfo{intel, nvidia}))
+	nvidia.offloadSink = 0x47
+	assert.Empty(t, getProviderLinks([]*providerInfo{intel, nvidia}))
+
+	// 没有接口的显卡不需要输出源
+	amd := &providerInfo{id: 0x200, name: "AMD", capabilities: testProviderCapsIntel}
+	assert.Equal(t, []providerLink{
+		{provider: 0x200, target: 0x47, offload: true},
+	}, getProviderLinks([]*providerInfo{intel, amd}))
+
+	// 主显卡不能作为输出源
+	intel.capabilities &^= randr.ProviderCapabilitySourceOutput
+	nvidia.offloadSink = 0
+	assert.Equal(t, []providerLink{
+		{provider: 0x1c0, target: 0x47, offload: true},
+	}, getProviderLinks([]*providerInfo{intel, nvidia}))
+
+	infos := toProviderInfos([]*providerInfo{intel, nvidia})
+	assert.True(t, infos[0].Primary)
+	assert.False(t, infos[1].Primary)
+}
@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"math"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/go-x11-client/ext/render"
+)
+
+// 变换缩放：X 环境下 GTK 和普通的 X 程序只支持一个全局的缩放比，ScaleFactors 中各显示器的缩放比只对 Qt 有效。
+// 开启后所有程序统一按整数的渲染缩放比渲染，再用 crtc 的 transform 把每个显示器上的画面缩放到它自己的缩放比。
+// 比如 1.5 倍的 4K 屏和 1 倍的 1080p 屏，渲染缩放比为 2，4K 屏在屏幕上占 5120x2880，缩小 1.333 倍显示，
+// 1080p 屏在屏幕上占 3840x2160，缩小 2 倍显示。
+
+const (
+	transformFilterScale    = "bilinear"
+	transformFilterIdentity = "nearest"
+)
+
+// getMonitorScaleFactor 获取显示器的缩放比，factors 的键是显示器名称，"ALL" 表示所有显示器。
+func getMonitorScaleFactor(factors map[string]float64, name string) float64 {
+	if factor, ok := factors[name]; ok && factor > 0 {
+		return factor
+	}
+	if factor, ok := factors["ALL"]; ok && factor > 0 {
+		return factor
+	}
+	if len(factors) == 1 {
+		for _, factor := range factors {
+			if factor > 0 {
+				return factor
+			}
+		}
+	}
+	return 1
+}
+
+// calcTransformScales 计算渲染缩放比和各个显示器 crtc transform 的缩放比。
+// 所有显示器的缩放比都相同时用全局的缩放比就够了，不需要 transform，返回的 renderScale 为 0。
+func calcTransformScales(factors map[string]float64, monitors []*Monitor) (renderScale float64, scales map[uint32]float64) {
+	if len(monitors) == 0 {
+		return 0, nil
+	}
+	monitorFactors := make(map[uint32]float64, len(monitors))
+	maxFactor := 0.0
+	allEqual := true
+	for _, monitor := range monitors {
+		factor := getMonitorScaleFactor(factors, monitor.Name)
+		if maxFactor != 0 && factor != maxFactor {
+			allEqual = false
+		}
+		if factor > maxFactor {
+			maxFactor = factor
+		}
+		monitorFactors[monitor.ID] = factor
+	}
+	if allEqual {
+		return 0, nil
+	}
+
+	// 减去一个小数，避免浮点误差让 2.0 变成 3
+	renderScale = math.Ceil(maxFactor - 0.001)
+	if renderScale < 1 {
+		renderScale = 1
+	}
+	scales = make(map[uint32]float64, len(monitorFactors))
+	for id, factor := range monitorFactors {
+		scales[id] = renderScale / factor
+	}
+	return renderScale, scales
+}
+
+// getTransformScale 返回显示器 crtc transform 的缩放比，1 表示不缩放。
+func (m *Monitor) getTransformScale() float64 {
+	if m.transformScale <= 0 {
+		return 1
+	}
+	return m.transformScale
+}
+
+// scaleSizeByTransform 计算 crtc 按 transform 缩放后在屏幕上占的尺寸，与 X server 一样用定点数计算并向上取整。
+func scaleSizeByTransform(size uint16, scale float64) uint16 {
+	if scale == 1 {
+		return size
+	}
+	v := math.Ceil(float64(size) * render.ToFixed(scale).ToFloat64())
+	if v > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(v)
+}
+
+func getScaleTransform(scale float64) *render.Transform {
+	return &render.Transform{
+		Matrix11: render.ToFixed(scale),
+		Matrix22: render.ToFixed(scale),
+		Matrix33: render.ToFixed(1),
+	}
+}
+
+// setCrtcTransform 设置 crtc 的 transform，在下一次设置 crtc 配置时生效。
+func (mm *xMonitorManager) setCrtcTransform(crtc randr.Crtc, transform *render.Transform) error {
+	filter := transformFilterScale
+	if *transform == *getScaleTransform(1) {
+		filter = transformFilterIdentity
+	}
+	logger.Debugf("setCrtcTransform crtc: %v, transform: %+v, filter: %v", crtc, *transform, filter)
+	return randr.SetCrtcTransformChecked(mm.xConn, crtc, transform, filter, nil).Check(mm.xConn)
+}
+
+func (m *Manager) isTransformScalingEnabled() bool {
+	if _useWayland {
+		return false
+	}
+	m.sysConfig.mu.Lock()
+	defer m.sysConfig.mu.Unlock()
+	return m.sysConfig.Config.TransformScaling
+}
+
+// updateTransformScales 根据缩放比配置，设置 monitorMap 中启用的显示器的 transform 缩放比，返回渲染缩放比。
+func (m *Manager) updateTransformScales(monitorMap map[uint32]*Monitor) (renderScale float64) {
+	for _, monitor := range monitorMap {
+		monitor.transformScale = 0
+	}
+	if !m.isTransformScalingEnabled() {
+		return 0
+	}
+
+	m.sysConfig.mu.Lock()
+	factors := m.sysConfig.Config.ScaleFactors
+	m.sysConfig.mu.Unlock()
+
+	var monitors []*Monitor
+	for _, monitor := range monitorMap {
+		if monitor.realConnected && monitor.Enabled {
+			monitors = append(monitors, monitor)
+		}
+	}
+	renderScale, scales := calcTransformScales(factors, monitors)
+	for id, scale := range scales {
+		monitorMap[id].transformScale = scale
+	}
+	return renderScale
+}
+
+// getRenderScaleFactor 返回正在使用的渲染缩放比，ok 为 false 表示没有使用变换缩放。
+func (m *Manager) getRenderScaleFactor() (renderScale float64, ok bool) {
+	m.PropsMu.RLock()
+	renderScale = m.renderScale
+	m.PropsMu.RUnlock()
+	return renderScale, renderScale > 0
+}
+
+// setRenderScaleFactor 记录渲染缩放比，改变时通知 xsettings 模块重新设置全局缩放比。
+func (m *Manager) setRenderScaleFactor(renderScale float64) {
+	m.PropsMu.Lock()
+	changed := m.renderScale != renderScale
+	m.renderScale = renderScale
+	m.PropsMu.Unlock()
+	if !changed {
+		return
+	}
+
+	logger.Debug("render scale factor changed:", renderScale)
+	m.sysConfig.mu.Lock()
+	factors := m.sysConfig.Config.ScaleFactors
+	m.sysConfig.mu.Unlock()
+	if len(factors) == 0 || ScaleFactorsHelper.changedCb == nil {
+		return
+	}
+	go func() {
+		err := ScaleFactorsHelper.changedCb(factors)
+		if err != nil {
+			logger.Warning("scale factors changed cb err:", err)
+		}
+	}()
+}
+
+func (m *Manager) setTransformScaling(enabled bool) error {
+	if _useWayland {
+		return errors.New("transform scaling is not supported on wayland")
+	}
+	m.sysConfig.mu.Lock()
+	if m.sysConfig.Config.TransformScaling == enabled {
+		m.sysConfig.mu.Unlock()
+		return nil
+	}
+	m.sysConfig.Config.TransformScaling = enabled
+	err := m.saveSysConfigNoLock("transform scaling changed")
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.PropsMu.Lock()
+	m.setPropTransformScaling(enabled)
+	m.PropsMu.Unlock()
+	return m.reapplyDisplayConfig()
+}
+
+// reapplyDisplayConfig 按当前的显示模式和配置重新应用，用于缩放比或者变换缩放开关改变之后。
+func (m *Manager) reapplyDisplayConfig() error {
+	monitorMap := m.cloneMonitorMap()
+	monitorsId := getConnectedMonitors(monitorMap).getMonitorsId()
+	m.PropsMu.RLock()
+	mode := m.DisplayMode
+	m.PropsMu.RUnlock()
+	return m.applyDisplayConfig(mode, monitorsId, monitorMap, false, nil)
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_calcTransformScales(t *testing.T) {
+	a := newTestMonitor("eDP-1", true, 0, 0, 3840, 2160)
+	a.ID = 1
+	b := newTestMonitor("HDMI-1", true, 3840, 0, 1920, 1080)
+	b.ID = 2
+	monitors := []*Monitor{a, b}
+
+	// 缩放比都相同，不需要 transform
+	renderScale, scales := calcTransformScales(map[string]float64{"ALL": 1.5}, monitors)
+	assert.Equal(t, 0.0, renderScale)
+	assert.Nil(t, scales)
+
+	renderScale, scales = calcTransformScales(map[string]float64{"eDP-1": 1.5, "HDMI-1": 1}, monitors)
+	assert.Equal(t, 2.0, renderScale)
+	assert.InDelta(t, 4.0/3, scales[1], 1e-9)
+	assert.Equal(t, 2.0, scales[2])
+
+	// 没有单独设置的显示器使用 ALL 的值
+	renderScale, scales = calcTransformScales(map[string]float64{"ALL": 1, "eDP-1": 2}, monitors)
+	assert.Equal(t, 2.0, renderScale)
+	assert.Equal(t, 1.0, scales[1])
+	assert.Equal(t, 2.0, scales[2])
+}
+
+func Test_scaleSizeByTransform(t *testing.T) {
+	assert.Equal(t, uint16(1920), scaleSizeByTransform(1920, 1))
+	assert.Equal(t, uint16(5120), scaleSizeByTransform(3840, 2.0/1.5))
+	assert.Equal(t, uint16(2880), scaleSizeByTransform(2160, 2.0/1.5))
+	assert.Equal(t, uint16(3840), scaleSizeByTransform(1920, 2))
+	assert.Equal(t, uint16(2186), scaleSizeByTransform(1366, 2/1.25))
+}
+
+func Test_getMonitorRectWithTransform(t *testing.T) {
+	monitor := newTestMonitor("eDP-1", true, 3840, 0, 3840, 2160)
+	monitor.transformScale = 2.0 / 1.5
+	assert.Equal(t, uint16(5120), getMonitorRect(monitor).Width)
+	assert.Equal(t, uint16(2880), getMonitorRect(monitor).Height)
+	assert.Equal(t, int16(3840), getMonitorRect(monitor).X)
+}
@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"math"
+	"time"
+)
+
+// 根据经纬度计算太阳高度角和日出、日落、晨昏蒙影的时间，算法来自 NOAA 的太阳位置计算表。
+
+const (
+	// 日出日落时太阳中心的高度角，考虑了大气折射和太阳半径
+	solarElevationSunrise = -0.833
+	// 民用晨昏蒙影
+	solarElevationCivilTwilight = -6.0
+
+	julianDayUnixEpoch = 2440587.5
+	julianDayJ2000     = 2451545.0
+)
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func julianDay(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + julianDayUnixEpoch
+}
+
+// solarPosition 计算太阳赤纬（度）和时差（分钟）
+func solarPosition(t time.Time) (declination, eqTime float64) {
+	jc := (julianDay(t) - julianDayJ2000) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnomaly := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccent := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+
+	m := degToRad(meanAnomaly)
+	center := math.Sin(m)*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(2*m)*(0.019993-0.000101*jc) + math.Sin(3*m)*0.000289
+	trueLong := meanLong + center
+	omega := degToRad(125.04 - 1934.136*jc)
+	appLong := trueLong - 0.00569 - 0.00478*math.Sin(omega)
+
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := degToRad(meanObliq + 0.00256*math.Cos(omega))
+
+	declination = radToDeg(math.Asin(math.Sin(obliq) * math.Sin(degToRad(appLong))))
+
+	y := math.Pow(math.Tan(obliq/2), 2)
+	l0 := degToRad(meanLong)
+	eqTime = 4 * radToDeg(y*math.Sin(2*l0)-2*eccent*math.Sin(m)+
+		4*eccent*y*math.Sin(m)*math.Cos(2*l0)-
+		0.5*y*y*math.Sin(4*l0)-1.25*eccent*eccent*math.Sin(2*m))
+	return
+}
+
+// solarElevation 计算 t 时刻在纬度 latitude、经度 longitude（东经为正）处的太阳高度角，单位是度。
+func solarElevation(t time.Time, latitude, longitude float64) float64 {
+	declination, eqTime := solarPosition(t)
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + float64(utc.Second())/60
+	trueSolarTime := math.Mod(minutes+eqTime+4*longitude, 1440)
+	if trueSolarTime < 0 {
+		trueSolarTime += 1440
+	}
+	hourAngle := degToRad(trueSolarTime/4 - 180)
+
+	lat := degToRad(latitude)
+	decl := degToRad(declination)
+	cosZenith := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(hourAngle)
+	cosZenith = math.Max(-1, math.Min(1, cosZenith))
+	return 90 - radToDeg(math.Acos(cosZenith))
+}
+
+// solarEventStatus 表示一天中太阳是否经过某个高度角
+type solarEventStatus int
+
+const (
+	solarEventNormal solarEventStatus = iota
+	// 整天都在这个高度角之上
+	solarEventAlwaysAbove
+	// 整天都在这个高度角之下
+	solarEventAlwaysBelow
+)
+
+// solarNoon 返回离 date 所在那一天当地正午最近的太阳正午，eqTime 是时差（分钟）。
+func solarNoon(date time.Time, longitude, eqTime float64) time.Time {
+	y, mon, d := date.Date()
+	localNoon := time.Date(y, mon, d, 12, 0, 0, 0, date.Location())
+	uy, umon, ud := localNoon.UTC().Date()
+	minutes := 720 - 4*longitude - eqTime
+	noon := time.Date(uy, umon, ud, 0, 0, 0, 0, time.UTC).Add(time.Duration(minutes * float64(time.Minute)))
+	if diff := noon.Sub(localNoon); diff > 12*time.Hour {
+		noon = noon.Add(-24 * time.Hour)
+	} else if diff < -12*time.Hour {
+		noon = noon.Add(24 * time.Hour)
+	}
+	return noon
+}
+
+// solarEventTimes 计算 date 所在的那一天（按 date 的时区）太阳升到和降到高度角 elevation 的时间。
+func solarEventTimes(date time.Time, latitude, longitude, elevation float64) (rise, set time.Time,
+	status solarEventStatus) {
+	_, eqTime := solarPosition(date)
+	noon := solarNoon(date, longitude, eqTime)
+
+	calc := func(rising bool) (time.Time, solarEventStatus) {
+		t := noon
+		// 迭代两次，用事件时刻的赤纬和时差修正
+		for i := 0; i < 2; i++ {
+			declination, eqTime := solarPosition(t)
+			lat := degToRad(latitude)
+			decl := degToRad(declination)
+			cosHA := (math.Sin(degToRad(elevation)) - math.Sin(lat)*math.Sin(decl)) /
+				(math.Cos(lat) * math.Cos(decl))
+			if cosHA > 1 {
+				return time.Time{}, solarEventAlwaysBelow
+			} else if cosHA < -1 {
+				return time.Time{}, solarEventAlwaysAbove
+			}
+			ha := radToDeg(math.Acos(cosHA))
+			if rising {
+				ha = -ha
+			}
+			t = solarNoon(date, longitude, eqTime).Add(time.Duration(4 * ha * float64(time.Minute)))
+		}
+		return t.In(date.Location()), solarEventNormal
+	}
+
+	rise, status = calc(true)
+	if status != solarEventNormal {
+		return time.Time{}, time.Time{}, status
+	}
+	set, status = calc(false)
+	if status != solarEventNormal {
+		return time.Time{}, time.Time{}, status
+	}
+	return rise, set, solarEventNormal
+}
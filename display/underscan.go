@@ -0,0 +1,362 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/linuxdeepin/go-x11-client/ext/render"
+)
+
+// underscan：电视机会裁掉画面的边缘，需要在画面四周留出边框。边框的宽度按显示器的 uuid 保存在 SysConfig.Underscans 中。
+// 驱动有 underscan 属性时（比如 radeon 和 amdgpu）用驱动的属性，否则用 crtc 的 transform 把画面缩小到边框以内。
+// 用 transform 时边框显示的是显示器区域外面的画面，边框会被限制在不会显示其他显示器画面的宽度，见 clampUnderscanBorder。
+// 支持 transform 的驱动允许 crtc 显示屏幕的一部分，X server 不检查 transform 后的范围，不需要增大屏幕，镜像缩放也是这样。
+
+const (
+	outputPropUnderscan        = "underscan"
+	outputPropUnderscanHBorder = "underscan hborder"
+	outputPropUnderscanVBorder = "underscan vborder"
+
+	underscanOn  = "on"
+	underscanOff = "off"
+)
+
+// underscanOutputPropNames 是驱动的 underscan 属性，第一个是开关
+var underscanOutputPropNames = []string{
+	outputPropUnderscan,
+	outputPropUnderscanHBorder,
+	outputPropUnderscanVBorder,
+}
+
+// 边框最宽为显示器宽或高的 1/8，即画面最多缩小 25%
+const underscanMaxBorderRatio = 8
+
+// 配置中边框宽度的上限，按 8192x8192 的模式计算，应用时再按当前的模式限制
+const underscanConfigMaxBorder = 8192 / underscanMaxBorderRatio
+
+type SysUnderscanConfig struct {
+	Horizontal uint32
+	Vertical   uint32
+}
+
+func (cfg *SysConfig) getUnderscan(uuid string) (horizontal, vertical uint32) {
+	underscanCfg := cfg.Underscans[uuid]
+	if underscanCfg == nil {
+		return 0, 0
+	}
+	return underscanCfg.Horizontal, underscanCfg.Vertical
+}
+
+// setUnderscan 设置显示器 uuid 的边框宽度，都为 0 时删除，返回配置是否改变。
+func (cfg *SysConfig) setUnderscan(uuid string, horizontal, vertical uint32) bool {
+	h, v := cfg.getUnderscan(uuid)
+	if h == horizontal && v == vertical {
+		return false
+	}
+	if horizontal == 0 && vertical == 0 {
+		delete(cfg.Underscans, uuid)
+		return true
+	}
+	if cfg.Underscans == nil {
+		cfg.Underscans = make(map[string]*SysUnderscanConfig)
+	}
+	cfg.Underscans[uuid] = &SysUnderscanConfig{
+		Horizontal: horizontal,
+		Vertical:   vertical,
+	}
+	return true
+}
+
+// fixUnderscans 删除都为 0 或者超出上限的边框配置
+func (cfg *SysConfig) fixUnderscans() {
+	for uuid, underscanCfg := range cfg.Underscans {
+		if underscanCfg == nil || (underscanCfg.Horizontal == 0 && underscanCfg.Vertical == 0) {
+			delete(cfg.Underscans, uuid)
+			continue
+		}
+		if underscanCfg.Horizontal > underscanConfigMaxBorder || underscanCfg.Vertical > underscanConfigMaxBorder {
+			logger.Warningf("underscan border %dx%d of %s is too large", underscanCfg.Horizontal, underscanCfg.Vertical, uuid)
+			delete(cfg.Underscans, uuid)
+		}
+	}
+}
+
+// checkUnderscan 检查边框宽度，width 和 height 是旋转后的模式尺寸。
+func checkUnderscan(width, height uint16, horizontal, vertical uint32) error {
+	if horizontal*underscanMaxBorderRatio > uint32(width) || vertical*underscanMaxBorderRatio > uint32(height) {
+		return fmt.Errorf("underscan border %dx%d is too large for %dx%d", horizontal, vertical, width, height)
+	}
+	return nil
+}
+
+// getUnderscanTransform 返回在 scale 缩放的基础上，把 width x height 的画面缩小到四周留出边框的 transform。
+// transform 把 crtc 上的坐标转换为屏幕上的坐标，边框以内的区域对应显示器在屏幕上的区域。
+func getUnderscanTransform(scale float64, width, height, hBorder, vBorder uint16) *render.Transform {
+	sx := scale * float64(width) / float64(width-2*hBorder)
+	sy := scale * float64(height) / float64(height-2*vBorder)
+	return &render.Transform{
+		Matrix11: render.ToFixed(sx),
+		Matrix13: render.ToFixed(-sx * float64(hBorder)),
+		Matrix22: render.ToFixed(sy),
+		Matrix23: render.ToFixed(-sy * float64(vBorder)),
+		Matrix33: render.ToFixed(1),
+	}
+}
+
+// getUnderscanBorders 返回需要用 transform 实现的边框宽度，不需要时返回 0。
+func (m *Monitor) getUnderscanBorders() (hBorder, vBorder uint16) {
+	if !m.underscanByTransform || m.mirrorWidth > 0 {
+		return 0, 0
+	}
+	return m.underscanHBorder, m.underscanVBorder
+}
+
+// clampUnderscanBorder 限制边框的宽度，size 是模式的宽或高。边框显示的是显示器区域外面宽度为 sx*border 的画面，
+// 其中 sx = scale*size/(size-2*border)，free 是这个方向上到其他显示器的距离，sx*border 不能超过 free。
+func clampUnderscanBorder(border, size uint16, scale, free float64) uint16 {
+	if border == 0 || math.IsInf(free, 1) {
+		return border
+	}
+	// scale*size*border <= free*(size-2*border)
+	maxBorder := free * float64(size) / (scale*float64(size) + 2*free)
+	if float64(border) > maxBorder {
+		return uint16(math.Floor(maxBorder))
+	}
+	return border
+}
+
+// getMonitorFreeSpace 返回显示器左右和上下两边到其他显示器的最小距离，没有其他显示器时为正无穷，
+// underscan 的边框和镜像 fit 的黑边在这个范围内显示的是屏幕外面或者没有显示器的区域。
+func getMonitorFreeSpace(monitor *Monitor, monitors []*Monitor) (hFree, vFree float64) {
+	hFree, vFree = math.Inf(1), math.Inf(1)
+	rect := getMonitorRect(monitor)
+	left, top := int(rect.X), int(rect.Y)
+	right, bottom := left+int(rect.Width), top+int(rect.Height)
+	for _, other := range monitors {
+		if other == monitor {
+			continue
+		}
+		otherRect := getMonitorRect(other)
+		if rectsOverlap(rect, otherRect) {
+			// 镜像组中的其他显示器，显示的是相同的画面
+			continue
+		}
+		oLeft, oTop := int(otherRect.X), int(otherRect.Y)
+		oRight, oBottom := oLeft+int(otherRect.Width), oTop+int(otherRect.Height)
+		if oTop < bottom && top < oBottom {
+			if oRight <= left {
+				hFree = math.Min(hFree, float64(left-oRight))
+			} else if oLeft >= right {
+				hFree = math.Min(hFree, float64(oLeft-right))
+			}
+		}
+		if oLeft < right && left < oRight {
+			if oBottom <= top {
+				vFree = math.Min(vFree, float64(top-oBottom))
+			} else if oTop >= bottom {
+				vFree = math.Min(vFree, float64(oTop-bottom))
+			}
+		}
+	}
+	return
+}
+
+// setMonitorUnderscan 用驱动的 underscan 属性设置边框，驱动没有这个属性时什么都不做，在设置 crtc 配置时生效。
+func (mm *xMonitorManager) setMonitorUnderscan(monitor *Monitor) error {
+	if !monitor.Enabled || monitor.underscanByTransform {
+		return nil
+	}
+	props, err := mm.getOutputProperties(monitor.ID, underscanOutputPropNames)
+	if err != nil {
+		return err
+	}
+	underscanProp := findOutputProperty(props, outputPropUnderscan)
+	if underscanProp == nil {
+		return nil
+	}
+
+	values := []struct {
+		name  string
+		value string
+	}{
+		{outputPropUnderscanHBorder, strconv.FormatUint(uint64(monitor.UnderscanHorizontal), 10)},
+		{outputPropUnderscanVBorder, strconv.FormatUint(uint64(monitor.UnderscanVertical), 10)},
+		{outputPropUnderscan, underscanOff},
+	}
+	if monitor.UnderscanHorizontal > 0 || monitor.UnderscanVertical > 0 {
+		values[2].value = underscanOn
+	}
+	for _, v := range values {
+		prop := findOutputProperty(props, v.name)
+		if prop == nil || prop.Current == v.value {
+			continue
+		}
+		err = mm.setOutputProperty(monitor.ID, v.name, v.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasDriverUnderscan 显示器的驱动是否有 underscan 属性
+func (m *Manager) hasDriverUnderscan(monitor *Monitor) bool {
+	props, err := m.mm.getOutputProperties(monitor.ID, underscanOutputPropNames[:1])
+	if err != nil {
+		logger.Warning(err)
+		return false
+	}
+	return findOutputProperty(props, outputPropUnderscan) != nil
+}
+
+// updateUnderscanTransforms 设置 monitorMap 中启用的显示器是否用 transform 实现 underscan，
+// 并计算限制后的边框宽度，在布局确定之后、应用前调用。
+func (m *Manager) updateUnderscanTransforms(monitorMap map[uint32]*Monitor) {
+	var enabledMonitors []*Monitor
+	for _, monitor := range monitorMap {
+		monitor.underscanByTransform = !_useWayland && monitor.realConnected && monitor.Enabled &&
+			(monitor.UnderscanHorizontal > 0 || monitor.UnderscanVertical > 0) &&
+			!m.hasDriverUnderscan(monitor)
+		if monitor.realConnected && monitor.Enabled {
+			enabledMonitors = append(enabledMonitors, monitor)
+		}
+	}
+	updateUnderscanBorders(enabledMonitors)
+}
+
+// updateUnderscanBorders 计算用 transform 实现 underscan 的显示器限制后的边框宽度。
+// 设置时按当时的模式检查过边框，之后模式可能变小，这里再按当前的模式限制，见 checkUnderscan。
+func updateUnderscanBorders(monitors []*Monitor) {
+	for _, monitor := range monitors {
+		monitor.underscanHBorder, monitor.underscanVBorder = 0, 0
+		if !monitor.underscanByTransform {
+			continue
+		}
+		width := monitor.CurrentMode.Width
+		height := monitor.CurrentMode.Height
+		swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
+		hBorder := uint16(minUint32(monitor.UnderscanHorizontal, uint32(width/underscanMaxBorderRatio)))
+		vBorder := uint16(minUint32(monitor.UnderscanVertical, uint32(height/underscanMaxBorderRatio)))
+		scale := monitor.getTransformScale()
+		hFree, vFree := getMonitorFreeSpace(monitor, monitors)
+		monitor.underscanHBorder = clampUnderscanBorder(hBorder, width, scale, hFree)
+		monitor.underscanVBorder = clampUnderscanBorder(vBorder, height, scale, vFree)
+		if uint32(monitor.underscanHBorder) != monitor.UnderscanHorizontal ||
+			uint32(monitor.underscanVBorder) != monitor.UnderscanVertical {
+			logger.Infof("underscan border of %s clamped from %dx%d to %dx%d", monitor.Name,
+				monitor.UnderscanHorizontal, monitor.UnderscanVertical, monitor.underscanHBorder, monitor.underscanVBorder)
+		}
+	}
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// getAppliedUnderscan 返回应用后实际的边框宽度，用驱动的属性时就是设置的宽度。
+func (m *Monitor) getAppliedUnderscan() (horizontal, vertical uint32) {
+	if !m.Enabled {
+		return 0, 0
+	}
+	if !m.underscanByTransform {
+		return m.UnderscanHorizontal, m.UnderscanVertical
+	}
+	hBorder, vBorder := m.getUnderscanBorders()
+	return uint32(hBorder), uint32(vBorder)
+}
+
+// updateAppliedUnderscans 应用成功后，把 monitorMap 中实际的边框宽度更新到对应显示器的 UnderscanAppliedHorizontal 和 UnderscanAppliedVertical 属性。
+func (m *Manager) updateAppliedUnderscans(monitorMap map[uint32]*Monitor) {
+	for id, applied := range monitorMap {
+		m.monitorMapMu.Lock()
+		monitor := m.monitorMap[id]
+		m.monitorMapMu.Unlock()
+		if monitor == nil {
+			continue
+		}
+		horizontal, vertical := applied.getAppliedUnderscan()
+		monitor.PropsMu.Lock()
+		monitor.setPropUnderscanAppliedHorizontal(horizontal)
+		monitor.setPropUnderscanAppliedVertical(vertical)
+		monitor.PropsMu.Unlock()
+	}
+}
+
+func (m *Manager) setMonitorUnderscan(monitor *Monitor, horizontal, vertical uint32) error {
+	if _useWayland {
+		return errors.New("underscan is not supported on wayland")
+	}
+	monitor.PropsMu.RLock()
+	uuid := monitor.uuid
+	width := monitor.CurrentMode.Width
+	height := monitor.CurrentMode.Height
+	swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
+	monitor.PropsMu.RUnlock()
+
+	err := checkUnderscan(width, height, horizontal, vertical)
+	if err != nil {
+		return err
+	}
+	// 检查是否超出驱动支持的范围
+	props, err := m.mm.getOutputProperties(monitor.ID, underscanOutputPropNames)
+	if err != nil {
+		return err
+	}
+	if findOutputProperty(props, outputPropUnderscan) != nil {
+		for _, v := range []struct {
+			name  string
+			value uint32
+		}{
+			{outputPropUnderscanHBorder, horizontal},
+			{outputPropUnderscanVBorder, vertical},
+		} {
+			prop := findOutputProperty(props, v.name)
+			if prop == nil {
+				continue
+			}
+			err = prop.checkValue(strconv.FormatUint(uint64(v.value), 10))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	monitor.PropsMu.Lock()
+	changed := monitor.setPropUnderscanHorizontal(horizontal)
+	changed = monitor.setPropUnderscanVertical(vertical) || changed
+	monitor.PropsMu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	m.sysConfig.mu.Lock()
+	if m.sysConfig.Config.setUnderscan(uuid, horizontal, vertical) {
+		err = m.saveSysConfigNoLock("set underscan")
+	}
+	m.sysConfig.mu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+	}
+	return m.reapplyDisplayConfig()
+}
+
+// restoreMonitorUnderscan 显示器连接或者 uuid 改变时，从配置中加载 uuid 对应的边框宽度，在下一次应用时生效。
+func (m *Manager) restoreMonitorUnderscan(monitor *Monitor, uuid string) {
+	m.sysConfig.mu.Lock()
+	horizontal, vertical := m.sysConfig.Config.getUnderscan(uuid)
+	m.sysConfig.mu.Unlock()
+
+	monitor.PropsMu.Lock()
+	monitor.setPropUnderscanHorizontal(horizontal)
+	monitor.setPropUnderscanVertical(vertical)
+	monitor.PropsMu.Unlock()
+}
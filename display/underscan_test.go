@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkUnderscan(t *testing.T) {
+	assert.NoError(t, checkUnderscan(1920, 1080, 0, 0))
+	assert.NoError(t, checkUnderscan(1920, 1080, 240, 135))
+	assert.Error(t, checkUnderscan(1920, 1080, 241, 0))
+	assert.Error(t, checkUnderscan(1920, 1080, 0, 136))
+}
+
+func Test_getUnderscanTransform(t *testing.T) {
+	// 把 crtc 上的点转换为屏幕上的点
+	apply := func(scale float64, hBorder, vBorder uint16, x, y float64) (float64, float64) {
+		transform := getUnderscanTransform(scale, 1920, 1080, hBorder, vBorder)
+		return transform.Matrix11.ToFloat64()*x + transform.Matrix13.ToFloat64(),
+			transform.Matrix22.ToFloat64()*y + transform.Matrix23.ToFloat64()
+	}
+
+	// 边框以内的区域对应显示器在屏幕上的区域
+	x, y := apply(1, 48, 27, 48, 27)
+	assert.InDelta(t, 0, x, 0.01)
+	assert.InDelta(t, 0, y, 0.01)
+	x, y = apply(1, 48, 27, 1920-48, 1080-27)
+	assert.InDelta(t, 1920, x, 0.1)
+	assert.InDelta(t, 1080, y, 0.1)
+
+	// 同时有缩放
+	x, y = apply(2, 48, 0, 1920-48, 1080)
+	assert.InDelta(t, 3840, x, 0.1)
+	assert.InDelta(t, 2160, y, 0.1)
+
+	// 没有边框时与缩放的 transform 相同
+	assert.Equal(t, *getScaleTransform(1), *getUnderscanTransform(1, 1920, 1080, 0, 0))
+	assert.Equal(t, *getScaleTransform(1.5), *getUnderscanTransform(1.5, 1920, 1080, 0, 0))
+}
+
+func Test_underscanScreenSize(t *testing.T) {
+	monitor := newTestMonitor("HDMI-1", true, 1920, 0, 1920, 1080)
+	monitor.realConnected = true
+	monitor.UnderscanHorizontal = 48
+	monitor.UnderscanVertical = 27
+
+	// 用 transform 时也不增大屏幕
+	monitor.underscanByTransform = true
+	assert.Equal(t, uint16(1920), getMonitorRect(monitor).Width)
+
+	other := newTestMonitor("eDP-1", true, 0, 0, 1920, 1080)
+	other.realConnected = true
+	sw, sh := getScreenWidthHeight(map[uint32]*Monitor{1: other, 2: monitor})
+	assert.Equal(t, uint16(1920+1920), sw)
+	assert.Equal(t, uint16(1080), sh)
+}
+
+func Test_clampUnderscanBorder(t *testing.T) {
+	assert.Equal(t, uint16(48), clampUnderscanBorder(48, 1920, 1, math.Inf(1)))
+	assert.Equal(t, uint16(0), clampUnderscanBorder(48, 1920, 1, 0))
+	// 100*1920/(1920+200) = 90.5
+	assert.Equal(t, uint16(90), clampUnderscanBorder(120, 1920, 1, 100))
+	assert.Equal(t, uint16(48), clampUnderscanBorder(48, 1920, 1, 100))
+	// 边框采样的宽度不超过 free
+	border := clampUnderscanBorder(200, 1920, 1.5, 100)
+	assert.LessOrEqual(t, 1.5*1920/float64(1920-2*border)*float64(border), 100.0)
+}
+
+func Test_updateUnderscanBorders(t *testing.T) {
+	tv := newTestMonitor("HDMI-1", true, 1920, 0, 1920, 1080)
+	tv.UnderscanHorizontal = 48
+	tv.UnderscanVertical = 27
+	tv.underscanByTransform = true
+
+	// 只有一个显示器时边框外面是屏幕外面
+	updateUnderscanBorders([]*Monitor{tv})
+	hBorder, vBorder := tv.getUnderscanBorders()
+	assert.Equal(t, uint16(48), hBorder)
+	assert.Equal(t, uint16(27), vBorder)
+
+	// 左边紧挨着其他显示器时不能有左右边框，否则会显示其他显示器的画面
+	builtin := newTestMonitor("eDP-1", true, 0, 0, 1920, 1080)
+	monitors := []*Monitor{builtin, tv}
+	updateUnderscanBorders(monitors)
+	hBorder, vBorder = tv.getUnderscanBorders()
+	assert.Equal(t, uint16(0), hBorder)
+	assert.Equal(t, uint16(27), vBorder)
+	hBorder, vBorder = builtin.getUnderscanBorders()
+	assert.Equal(t, uint16(0), hBorder)
+	assert.Equal(t, uint16(0), vBorder)
+
+	// 中间有空隙，40*1920/(1920+80) = 38.4
+	tv.X = 1920 + 40
+	updateUnderscanBorders(monitors)
+	hBorder, _ = tv.getUnderscanBorders()
+	assert.Equal(t, uint16(38), hBorder)
+
+	// 上下没有重叠的显示器不影响左右边框
+	builtin.Y = 1080
+	updateUnderscanBorders(monitors)
+	hBorder, vBorder = tv.getUnderscanBorders()
+	assert.Equal(t, uint16(48), hBorder)
+	assert.Equal(t, uint16(27), vBorder)
+
+	// 之后切换到小的模式时按当前模式限制，320/8 = 40
+	tv.CurrentMode = ModeInfo{Width: 320, Height: 200}
+	updateUnderscanBorders(monitors)
+	hBorder, vBorder = tv.getUnderscanBorders()
+	assert.Equal(t, uint16(40), hBorder)
+	assert.Equal(t, uint16(25), vBorder)
+}
+
+func TestMonitor_getAppliedUnderscan(t *testing.T) {
+	tv := newTestMonitor("HDMI-1", true, 1920, 0, 1920, 1080)
+	tv.UnderscanHorizontal = 48
+	tv.UnderscanVertical = 27
+
+	// 用驱动的属性时是设置的宽度
+	horizontal, vertical := tv.getAppliedUnderscan()
+	assert.Equal(t, uint32(48), horizontal)
+	assert.Equal(t, uint32(27), vertical)
+
+	// 用 transform 时是限制后的宽度
+	tv.underscanByTransform = true
+	updateUnderscanBorders([]*Monitor{newTestMonitor("eDP-1", true, 0, 0, 1920, 1080), tv})
+	horizontal, vertical = tv.getAppliedUnderscan()
+	assert.Equal(t, uint32(0), horizontal)
+	assert.Equal(t, uint32(27), vertical)
+
+	tv.Enabled = false
+	horizontal, vertical = tv.getAppliedUnderscan()
+	assert.Equal(t, uint32(0), horizontal)
+	assert.Equal(t, uint32(0), vertical)
+}
+
+func TestSysConfig_setUnderscan(t *testing.T) {
+	var cfg SysConfig
+	assert.False(t, cfg.setUnderscan("uuid1", 0, 0))
+	assert.True(t, cfg.setUnderscan("uuid1", 48, 27))
+	assert.False(t, cfg.setUnderscan("uuid1", 48, 27))
+	h, v := cfg.getUnderscan("uuid1")
+	assert.Equal(t, uint32(48), h)
+	assert.Equal(t, uint32(27), v)
+
+	assert.True(t, cfg.setUnderscan("uuid1", 0, 0))
+	assert.Empty(t, cfg.Underscans)
+}
@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"fmt"
+	"sort"
+
+	x "github.com/linuxdeepin/go-x11-client"
+)
+
+// ValidateChanges 返回的问题代码
+const (
+	problemNoEnabledMonitor = "no-enabled-monitor"
+	problemOverlap          = "overlap"
+	problemGap              = "gap"
+	problemScreenTooLarge   = "screen-too-large"
+	problemNoFreeCrtc       = "no-free-crtc"
+)
+
+// ValidateProblem 是 ValidateChanges 检查出的一个问题，Monitor 为空表示不针对某个显示器。
+type ValidateProblem struct {
+	Code    string
+	Monitor string
+	Message string
+}
+
+// validateChanges 按照 applyChanges 的方式计算出修改后的布局，检查布局中的问题，不会真正应用修改。
+func (m *Manager) validateChanges() []ValidateProblem {
+	monitorMap := m.cloneMonitorMap()
+	monitors := getConnectedMonitors(monitorMap)
+	monitorsId := monitors.getMonitorsId()
+
+	m.PropsMu.RLock()
+	displayMode := m.DisplayMode
+	m.PropsMu.RUnlock()
+
+	var primaryMonitorID uint32
+	configs := m.getSuitableSysMonitorConfigs(displayMode, monitorsId, monitors)
+	if len(configs) > 0 {
+		for _, config := range configs {
+			monitor := monitors.GetByUuid(config.UUID)
+			if monitor == nil {
+				continue
+			}
+			config.modify(monitor.changes)
+		}
+		// monitorMap 中是副本，可以直接修改
+		primaryMonitorID, _ = setMonitorsBySysConfigs(monitorMap, configs)
+	}
+	// 没有配置时，显示器上的属性就是修改后的状态
+	var enabledMonitors []*Monitor
+	for _, monitor := range monitors {
+		if monitor.Enabled {
+			enabledMonitors = append(enabledMonitors, monitor)
+		}
+	}
+	if primaryMonitorID == 0 {
+		primaryMonitor := m.getDefaultPrimaryMonitor(enabledMonitors)
+		if primaryMonitor != nil {
+			primaryMonitorID = primaryMonitor.ID
+		}
+	}
+
+	// 与 applySysMonitorConfigs 一样设置镜像和整理布局，只检查应用后仍然存在的问题
+	setMonitorsMirrorGroups(monitorMap, m.getMirrorGroups(displayMode, monitorsId, nil))
+	if m.shouldMirrorScale(displayMode, enabledMonitors) {
+		setMonitorsMirrorLeader(enabledMonitors, primaryMonitorID)
+	}
+	m.updateTransformScales(monitorMap)
+	m.updateUnderscanTransforms(monitorMap)
+	updateMirrorTransforms(monitorMap, m.getMirrorScaling())
+	if m.shouldNormalizeLayout(displayMode, enabledMonitors, nil) {
+		normalizeMonitorsLayout(enabledMonitors, monitorMap[primaryMonitorID])
+	}
+
+	layoutMode := displayMode
+	if len(monitors) == 1 {
+		// 单屏时没有显示模式
+		layoutMode = DisplayModeInvalid
+	}
+	problems := checkMonitorsLayout(monitors, layoutMode)
+	if len(problems) > 0 && problems[0].Code == problemNoEnabledMonitor {
+		// 没有启用的显示器，不必再检查 crtc 配置
+		return problems
+	}
+	return append(problems, m.mm.validate(monitorMap)...)
+}
+
+// getMonitorRect 返回显示器应用后在屏幕上占的区域，考虑了旋转、变换缩放和镜像组的缩放。
+func getMonitorRect(monitor *Monitor) x.Rectangle {
+	if monitor.mirrorWidth > 0 && monitor.mirrorHeight > 0 {
+		return x.Rectangle{
+			X:      monitor.X,
+			Y:      monitor.Y,
+			Width:  monitor.mirrorWidth,
+			Height: monitor.mirrorHeight,
+		}
+	}
+	width := monitor.CurrentMode.Width
+	height := monitor.CurrentMode.Height
+	swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
+	scale := monitor.getTransformScale()
+	return x.Rectangle{
+		X:      monitor.X,
+		Y:      monitor.Y,
+		Width:  scaleSizeByTransform(width, scale),
+		Height: scaleSizeByTransform(height, scale),
+	}
+}
+
+// rectsOverlap 两个矩形是否有重叠的区域
+func rectsOverlap(r1, r2 x.Rectangle) bool {
+	return int(r1.X) < int(r2.X)+int(r2.Width) && int(r2.X) < int(r1.X)+int(r1.Width) &&
+		int(r1.Y) < int(r2.Y)+int(r2.Height) && int(r2.Y) < int(r1.Y)+int(r1.Height)
+}
+
+// rectsAdjacent 两个矩形是否相接（包括只有一个角相接），或者有重叠的区域
+func rectsAdjacent(r1, r2 x.Rectangle) bool {
+	if rectsOverlap(r1, r2) {
+		return true
+	}
+	r1Right := int(r1.X) + int(r1.Width)
+	r1Bottom := int(r1.Y) + int(r1.Height)
+	r2Right := int(r2.X) + int(r2.Width)
+	r2Bottom := int(r2.Y) + int(r2.Height)
+
+	// 左右相接，且在垂直方向有公共部分
+	if r1Right == int(r2.X) || r2Right == int(r1.X) {
+		return int(r1.Y) <= r2Bottom && int(r2.Y) <= r1Bottom
+	}
+	// 上下相接，且在水平方向有公共部分
+	if r1Bottom == int(r2.Y) || r2Bottom == int(r1.Y) {
+		return int(r1.X) <= r2Right && int(r2.X) <= r1Right
+	}
+	return false
+}
+
+// checkMonitorsLayout 检查已连接显示器的布局，扩展模式下检查显示器之间的重叠和间隙。
+func checkMonitorsLayout(monitors Monitors, displayMode byte) (problems []ValidateProblem) {
+	var enabledMonitors Monitors
+	for _, monitor := range monitors {
+		if monitor.Enabled {
+			enabledMonitors = append(enabledMonitors, monitor)
+		}
+	}
+	if len(enabledMonitors) == 0 {
+		return []ValidateProblem{{
+			Code:    problemNoEnabledMonitor,
+			Message: "no enabled monitor",
+		}}
+	}
+	if displayMode != DisplayModeExtend || len(enabledMonitors) < 2 {
+		return nil
+	}
+
+	// 按名称排序，让结果稳定
+	sort.Slice(enabledMonitors, func(i, j int) bool {
+		return enabledMonitors[i].Name < enabledMonitors[j].Name
+	})
+	rects := make([]x.Rectangle, len(enabledMonitors))
+	for i, monitor := range enabledMonitors {
+		rects[i] = getMonitorRect(monitor)
+	}
+
+	for i := 0; i < len(rects); i++ {
+		for j := i + 1; j < len(rects); j++ {
+			// 镜像组不算重叠
+			if inSameMirrorGroup(enabledMonitors[i], enabledMonitors[j]) {
+				continue
+			}
+			if rectsOverlap(rects[i], rects[j]) {
+				problems = append(problems, ValidateProblem{
+					Code:    problemOverlap,
+					Monitor: enabledMonitors[i].Name,
+					Message: fmt.Sprintf("monitor %s overlaps monitor %s",
+						enabledMonitors[i].Name, enabledMonitors[j].Name),
+				})
+			}
+		}
+	}
+
+	// 从第一个显示器开始，找出所有与之相连的显示器，剩下的显示器与其之间有间隙。
+	connected := make([]bool, len(rects))
+	connected[0] = true
+	queue := []int{0}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for j := range rects {
+			if !connected[j] && rectsAdjacent(rects[i], rects[j]) {
+				connected[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	for i, ok := range connected {
+		if !ok {
+			problems = append(problems, ValidateProblem{
+				Code:    problemGap,
+				Monitor: enabledMonitors[i].Name,
+				Message: fmt.Sprintf("monitor %s is not adjacent to other monitors", enabledMonitors[i].Name),
+			})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2022 UnionTech Software Technology Co., Ltd.
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package display
+
+import (
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMonitor(name string, enabled bool, x, y int16, width, height uint16) *Monitor {
+	return &Monitor{
+		Name:        name,
+		Enabled:     enabled,
+		X:           x,
+		Y:           y,
+		CurrentMode: ModeInfo{Width: width, Height: height},
+	}
+}
+
+func Test_checkMonitorsLayout(t *testing.T) {
+	// 没有启用的显示器
+	problems := checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", false, 0, 0, 1920, 1080),
+	}, DisplayModeExtend)
+	assert.Len(t, problems, 1)
+	assert.Equal(t, problemNoEnabledMonitor, problems[0].Code)
+
+	// 左右相接
+	problems = checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("eDP-1", true, 1920, 200, 1366, 768),
+	}, DisplayModeExtend)
+	assert.Empty(t, problems)
+
+	// 只有一个角相接
+	problems = checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("eDP-1", true, 1920, 1080, 1366, 768),
+	}, DisplayModeExtend)
+	assert.Empty(t, problems)
+
+	// 重叠
+	problems = checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("eDP-1", true, 1000, 0, 1366, 768),
+	}, DisplayModeExtend)
+	assert.Equal(t, []ValidateProblem{{
+		Code:    problemOverlap,
+		Monitor: "HDMI-1",
+		Message: "monitor HDMI-1 overlaps monitor eDP-1",
+	}}, problems)
+
+	// 复制模式不检查重叠
+	problems = checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		newTestMonitor("eDP-1", true, 0, 0, 1920, 1080),
+	}, DisplayModeMirror)
+	assert.Empty(t, problems)
+
+	// 间隙，旋转后宽高互换
+	rotated := newTestMonitor("DP-1", true, 1920, 0, 1920, 1080)
+	rotated.Rotation = randr.RotationRotate90
+	problems = checkMonitorsLayout(Monitors{
+		newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080),
+		rotated,
+		newTestMonitor("eDP-1", true, 4000, 0, 1366, 768),
+	}, DisplayModeExtend)
+	assert.Len(t, problems, 1)
+	assert.Equal(t, problemGap, problems[0].Code)
+	assert.Equal(t, "eDP-1", problems[0].Monitor)
+}
+
+func Test_checkMonitorsLayoutAfterNormalize(t *testing.T) {
+	// 应用时会整理布局，整理后重叠和间隙都不存在了
+	a := newTestMonitor("HDMI-1", true, 0, 0, 1920, 1080)
+	b := newTestMonitor("eDP-1", true, 1800, 0, 1366, 768)
+	c := newTestMonitor("DP-1", true, 0, 1200, 1920, 1080)
+	monitors := Monitors{a, b, c}
+	assert.NotEmpty(t, checkMonitorsLayout(monitors, DisplayModeExtend))
+
+	assert.True(t, normalizeMonitorsLayout(monitors, a))
+	assert.Empty(t, checkMonitorsLayout(monitors, DisplayModeExtend))
+}
@@ -449,6 +449,18 @@ func (mm *kMonitorManager) setMonitorFillMode(monitor *Monitor, fillMode string)
 	return nil
 }
 
+func (mm *kMonitorManager) listOutputProperties(monitorId uint32) ([]OutputProperty, error) {
+	return nil, nil
+}
+
+func (mm *kMonitorManager) getOutputProperties(monitorId uint32, names []string) ([]OutputProperty, error) {
+	return nil, nil
+}
+
+func (mm *kMonitorManager) setOutputProperty(monitorId uint32, name, value string) error {
+	return errors.New("output property is not supported on wayland")
+}
+
 func (mm *kMonitorManager) getMonitors() []*MonitorInfo {
 	mm.mu.Lock()
 	defer mm.mu.Unlock()
@@ -680,10 +692,27 @@ func (mm *kMonitorManager) setMonitorPrimary(monitorId uint32) error {
 	return nil
 }
 
+// validate 由 kwin 负责输出设备的配置，这里没有额外的限制需要检查。
+func (mm *kMonitorManager) validate(monitorMap map[uint32]*Monitor) []ValidateProblem {
+	return nil
+}
+
+func (mm *kMonitorManager) addMonitorMode(monitorId uint32, modeInfo randr.ModeInfo) error {
+	return errors.New("not supported")
+}
+
+func (mm *kMonitorManager) removeMonitorMode(monitorId uint32, name string) error {
+	return errors.New("not supported")
+}
+
 func (mm *kMonitorManager) showCursor(show bool) error {
 	return nil
 }
 
+func (mm *kMonitorManager) getProviders() []ProviderInfo {
+	return nil
+}
+
 func (mm *kMonitorManager) HandleEvent(ev interface{}) {
 
 }
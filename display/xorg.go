@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"sync"
 	"time"
 
@@ -23,6 +24,8 @@ var _xConn *x.Conn
 
 var _hasRandr1d2 bool // 是否 randr 版本大于等于 1.2
 
+var _hasRandr1d4 bool // 是否 randr 版本大于等于 1.4，支持多显卡
+
 var _useWayland bool
 
 var _inVM bool
@@ -40,7 +43,12 @@ func Init(xConn *x.Conn, useWayland bool, inVM bool) {
 			(randrVersion.ServerMajorVersion == 1 && randrVersion.ServerMinorVersion >= 2) {
 			_hasRandr1d2 = true
 		}
+		if randrVersion.ServerMajorVersion > 1 ||
+			(randrVersion.ServerMajorVersion == 1 && randrVersion.ServerMinorVersion >= 4) {
+			_hasRandr1d4 = true
+		}
 		logger.Debug("has randr1.2:", _hasRandr1d2)
+		logger.Debug("has randr1.4:", _hasRandr1d4)
 	}
 
 	if _greeterMode {
@@ -133,9 +141,13 @@ func (m *Manager) listenXEvents() {
 	eventChan := m.xConn.MakeAndAddEventChan(50)
 	root := m.xConn.GetDefaultScreen().Root
 	// 选择监听哪些 randr 事件
-	err := randr.SelectInputChecked(m.xConn, root,
-		randr.NotifyMaskOutputChange|randr.NotifyMaskOutputProperty|
-			randr.NotifyMaskCrtcChange|randr.NotifyMaskScreenChange).Check(m.xConn)
+	var evMask uint16 = randr.NotifyMaskOutputChange | randr.NotifyMaskOutputProperty |
+		randr.NotifyMaskCrtcChange | randr.NotifyMaskScreenChange
+	if _hasRandr1d4 {
+		// 显卡变化和显卡热插拔
+		evMask |= randr.NotifyMaskProviderChange | randr.NotifyMaskResourceChange
+	}
+	err := randr.SelectInputChecked(m.xConn, root, evMask).Check(m.xConn)
 	if err != nil {
 		logger.Warning("failed to select randr event:", err)
 		return
@@ -171,6 +183,15 @@ func (m *Manager) listenXEvents() {
 					e, _ := event.NewOutputPropertyNotifyEvent()
 					// TODO mm 可能也应该处理这个事件
 					m.handleOutputPropertyChanged(e)
+
+				case randr.NotifyProviderChange:
+					m.mm.HandleEvent(&providerChangeNotifyEvent{})
+					m.updatePropProviders()
+
+				case randr.NotifyResourceChange:
+					e, _ := event.NewResourceChangeNotifyEvent()
+					m.mm.HandleEvent(e)
+					m.updatePropProviders()
 				}
 
 			case randr.ScreenChangeNotifyEventCode + rrExtData.FirstEvent:
@@ -213,7 +234,14 @@ type monitorManager interface {
 	apply(monitorsId monitorsId, monitorMap map[uint32]*Monitor, prevScreenSize screenSize, options applyOptions, fillModes map[string]string, primaryMonitorID uint32, displayMode byte) error
 	setMonitorPrimary(monitorId uint32) error
 	setMonitorFillMode(monitor *Monitor, fillMode string) error
+	listOutputProperties(monitorId uint32) ([]OutputProperty, error)
+	getOutputProperties(monitorId uint32, names []string) ([]OutputProperty, error)
+	setOutputProperty(monitorId uint32, name, value string) error
+	validate(monitorMap map[uint32]*Monitor) []ValidateProblem
+	addMonitorMode(monitorId uint32, modeInfo randr.ModeInfo) error
+	removeMonitorMode(monitorId uint32, name string) error
 	showCursor(show bool) error
+	getProviders() []ProviderInfo
 	HandleEvent(ev interface{})
 	HandleScreenChanged(e *randr.ScreenChangeNotifyEvent) (cfgTsChanged bool)
 }
@@ -229,6 +257,7 @@ type xMonitorManager struct {
 	crtcs                   map[randr.Crtc]*CrtcInfo
 	outputs                 map[randr.Output]*OutputInfo
 	primary                 randr.Output
+	providers               []ProviderInfo
 	monitorChangedCbEnabled bool
 	// 键是 x 的 output 名称，值是标准名。
 	stdNamesCache map[string]string
@@ -309,6 +338,11 @@ func (mm *xMonitorManager) init() error {
 		mm.crtcs[crtcId] = (*CrtcInfo)(reply)
 	}
 
+	if mm.setupProviders() {
+		// 其他显卡上的接口加入了屏幕资源
+		mm.reloadResources()
+	}
+
 	mm.refreshMonitorsCache()
 	return nil
 }
@@ -377,9 +411,26 @@ func (mm *xMonitorManager) doDiff() {
 					mm.mu.Lock()
 				}
 			}
-		} else {
-			logger.Warning("can not handle new monitor")
+		} else if mm.hooks != nil {
+			// 关联其他显卡后新增的接口
+			logger.Debug("call manager handleMonitorAdded", monitor.ID)
+			// NOTE: mm.mu 已经上锁了
+			mm.mu.Unlock()
+			mm.hooks.handleMonitorAdded(monitor)
+			mm.mu.Lock()
+		}
+	}
+
+	for id := range oldMonitors {
+		if _, ok := mm.outputs[randr.Output(id)]; ok || mm.hooks == nil {
+			continue
 		}
+		// 显卡被移除后消失的接口
+		logger.Debug("call manager handleMonitorRemoved", id)
+		// NOTE: mm.mu 已经上锁了
+		mm.mu.Unlock()
+		mm.hooks.handleMonitorRemoved(id)
+		mm.mu.Lock()
 	}
 }
 
@@ -587,6 +638,8 @@ type applyOptions map[string]interface{}
 const (
 	optionDisableCrtc = "disableCrtc"
 	optionOnlyOne     = "onlyOne"
+	// 不整理扩展模式下显示器的布局
+	optionNoNormalize = "noNormalize"
 )
 
 type crtcConfig struct {
@@ -597,6 +650,17 @@ type crtcConfig struct {
 	y        int16
 	rotation uint16
 	mode     randr.Mode
+	// transform 的缩放比，只对启用的 crtc 有效
+	scale float64
+	// 用 transform 实现 underscan 时的边框宽度和旋转后的模式尺寸
+	hBorder uint16
+	vBorder uint16
+	width   uint16
+	height  uint16
+	// 镜像中用 transform 缩放到的尺寸和缩放方式
+	mirrorWidth   uint16
+	mirrorHeight  uint16
+	mirrorScaling string
 }
 
 func findOutputInCrtcCfgs(crtcCfgs map[randr.Crtc]crtcConfig, crtc randr.Crtc) randr.Output {
@@ -610,13 +674,19 @@ func findOutputInCrtcCfgs(crtcCfgs map[randr.Crtc]crtcConfig, crtc randr.Crtc) r
 	return 0
 }
 
-func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*Monitor, prevScreenSize screenSize,
-	options applyOptions, fillModes map[string]string, primaryMonitorID uint32, displayMode byte) error {
+type noFreeCrtcError struct {
+	name string
+}
 
-	logger.Debug("call apply", monitorsId)
-	optDisableCrtc, _ := options[optionDisableCrtc].(bool)
+func (err *noFreeCrtcError) Error() string {
+	return fmt.Sprintf("failed to find free crtc for %s", err.name)
+}
 
-	disabledOutputs := make(map[randr.Output]bool)
+// buildCrtcConfigs 根据 monitorMap 中显示器的设置，计算出各个 crtc 的配置和需要禁用的 output，不会修改 X 的状态。
+func (mm *xMonitorManager) buildCrtcConfigs(monitorMap map[uint32]*Monitor) (crtcCfgs map[randr.Crtc]crtcConfig,
+	disabledOutputs map[randr.Output]bool, err error) {
+
+	disabledOutputs = make(map[randr.Output]bool)
 	freeCrtcs := mm.getFreeCrtcMap()
 
 	// 继续找更多的 free crtc
@@ -639,7 +709,7 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 	}
 
 	// 根据 monitor 的配置，准备 crtc 配置放到 crtcCfgs 中。
-	crtcCfgs := make(map[randr.Crtc]crtcConfig)
+	crtcCfgs = make(map[randr.Crtc]crtcConfig)
 	for output, monitor := range monitorMap {
 		monitorInfo := mm.getMonitor(monitor.ID)
 		if monitorInfo == nil {
@@ -653,16 +723,28 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 			if crtc == 0 {
 				crtc = mm.findFreeCrtc(randr.Output(output), freeCrtcs)
 				if crtc == 0 {
-					return errors.New("failed to find free crtc")
+					return nil, nil, &noFreeCrtcError{name: monitor.Name}
 				}
 			}
+			width := monitor.CurrentMode.Width
+			height := monitor.CurrentMode.Height
+			swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
+			hBorder, vBorder := monitor.getUnderscanBorders()
 			crtcCfgs[crtc] = crtcConfig{
-				crtc:     crtc,
-				x:        monitor.X,
-				y:        monitor.Y,
-				mode:     randr.Mode(monitor.CurrentMode.Id),
-				rotation: monitor.Rotation | monitor.Reflect,
-				outputs:  []randr.Output{randr.Output(output)},
+				crtc:          crtc,
+				x:             monitor.X,
+				y:             monitor.Y,
+				mode:          randr.Mode(monitor.CurrentMode.Id),
+				rotation:      monitor.Rotation | monitor.Reflect,
+				outputs:       []randr.Output{randr.Output(output)},
+				scale:         monitor.getTransformScale(),
+				hBorder:       hBorder,
+				vBorder:       vBorder,
+				width:         width,
+				height:        height,
+				mirrorWidth:   monitor.mirrorWidth,
+				mirrorHeight:  monitor.mirrorHeight,
+				mirrorScaling: monitor.mirrorScaling,
 			}
 		}
 	}
@@ -678,6 +760,51 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 	}
 	logger.Debug("freeCrtcs:", freeCrtcs)
 	logger.Debug("disableOutputs", disabledOutputs)
+	return crtcCfgs, disabledOutputs, nil
+}
+
+// validate 检查 monitorMap 中的设置能否被应用，与 apply 的计算方式相同，但不会抓取服务器和修改 X 的状态。
+func (mm *xMonitorManager) validate(monitorMap map[uint32]*Monitor) (problems []ValidateProblem) {
+	_, _, err := mm.buildCrtcConfigs(monitorMap)
+	if err != nil {
+		problem := ValidateProblem{
+			Code:    problemNoFreeCrtc,
+			Message: err.Error(),
+		}
+		var crtcErr *noFreeCrtcError
+		if errors.As(err, &crtcErr) {
+			problem.Monitor = crtcErr.name
+		}
+		problems = append(problems, problem)
+	}
+
+	ss := getScreenSize(monitorMap)
+	root := mm.xConn.GetDefaultScreen().Root
+	sizeRange, err := randr.GetScreenSizeRange(mm.xConn, root).Reply(mm.xConn)
+	if err != nil {
+		logger.Warning("failed to get screen size range:", err)
+		return problems
+	}
+	if ss.width > sizeRange.MaxWidth || ss.height > sizeRange.MaxHeight {
+		problems = append(problems, ValidateProblem{
+			Code: problemScreenTooLarge,
+			Message: fmt.Sprintf("screen size %dx%d exceeds the maximum %dx%d",
+				ss.width, ss.height, sizeRange.MaxWidth, sizeRange.MaxHeight),
+		})
+	}
+	return problems
+}
+
+func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*Monitor, prevScreenSize screenSize,
+	options applyOptions, fillModes map[string]string, primaryMonitorID uint32, displayMode byte) error {
+
+	logger.Debug("call apply", monitorsId)
+	optDisableCrtc, _ := options[optionDisableCrtc].(bool)
+
+	crtcCfgs, disabledOutputs, err := mm.buildCrtcConfigs(monitorMap)
+	if err != nil {
+		return err
+	}
 
 	if logger.GetLogLevel() == log.LevelDebug {
 		logger.Debug("crtcCfgs:", spew.Sdump(crtcCfgs))
@@ -737,8 +864,9 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 				monitor := monitors.GetById(uint32(output))
 				// 根据 crtc 找到对应的 monitor
 				if monitor != nil && monitor.Enabled {
-					if rect.X != monitor.X || rect.Y != monitor.Y ||
-						rect.Width != monitor.Width || rect.Height != monitor.Height ||
+					monitorRect := getMonitorRect(monitor)
+					if rect.X != monitorRect.X || rect.Y != monitorRect.Y ||
+						rect.Width != monitorRect.Width || rect.Height != monitorRect.Height ||
 						crtcInfo.Rotation != monitor.Rotation|monitor.Reflect {
 						// crtc 的参数将发生改变, 这里的 monitor 包含了 crtc 未来的状态。
 						logger.Debugf("should disable crtc %v because of the parameters of crtc changed", crtc)
@@ -764,7 +892,7 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 		time.Sleep(1 * time.Second)
 	}
 
-	err := mm.setScreenSize(screenSize)
+	err = mm.setScreenSize(screenSize)
 	if err != nil {
 		return err
 	}
@@ -788,6 +916,11 @@ func (mm *xMonitorManager) apply(monitorsId monitorsId, monitorMap map[uint32]*M
 		if err != nil {
 			logger.Warning("set monitor fill mode failed:", monitor, err)
 		}
+
+		err = mm.setMonitorUnderscan(monitor)
+		if err != nil {
+			logger.Warning("set monitor underscan failed:", monitor, err)
+		}
 	}
 
 	for _, crtcCfg := range crtcCfgs {
@@ -892,13 +1025,9 @@ func getScreenWidthHeight(monitorMap map[uint32]*Monitor) (sw, sh uint16) {
 			continue
 		}
 
-		width := monitor.CurrentMode.Width
-		height := monitor.CurrentMode.Height
-
-		swapWidthHeightWithRotation(monitor.Rotation, &width, &height)
-
-		w1 := int(monitor.X) + int(width)
-		h1 := int(monitor.Y) + int(height)
+		rect := getMonitorRect(monitor)
+		w1 := int(rect.X) + int(rect.Width)
+		h1 := int(rect.Y) + int(rect.Height)
 
 		if w < w1 {
 			w = w1
@@ -942,6 +1071,23 @@ func (mm *xMonitorManager) setCrtcConfig(cfg crtcConfig) error {
 	logger.Debugf("setCrtcConfig crtc: %v, cfgTs: %v, x: %v, y: %v,"+
 		" mode: %v, rotation|reflect: %v, outputs: %v",
 		cfg.crtc, cfgTs, cfg.x, cfg.y, cfg.mode, cfg.rotation, cfg.outputs)
+	if len(cfg.outputs) > 0 {
+		// transform 在设置 crtc 配置时才生效，不缩放时也要设置，以便清除之前的 transform。
+		scale := cfg.scale
+		if scale <= 0 {
+			scale = 1
+		}
+		transform := getScaleTransform(scale)
+		if cfg.mirrorWidth > 0 && cfg.mirrorHeight > 0 && cfg.width > 0 && cfg.height > 0 {
+			transform = getMirrorTransform(cfg.width, cfg.height, cfg.mirrorWidth, cfg.mirrorHeight, cfg.mirrorScaling)
+		} else if (cfg.hBorder > 0 || cfg.vBorder > 0) && cfg.width > 2*cfg.hBorder && cfg.height > 2*cfg.vBorder {
+			transform = getUnderscanTransform(scale, cfg.width, cfg.height, cfg.hBorder, cfg.vBorder)
+		}
+		err := mm.setCrtcTransform(cfg.crtc, transform)
+		if err != nil {
+			logger.Warning("failed to set crtc transform:", err)
+		}
+	}
 	setCfg, err := randr.SetCrtcConfig(mm.xConn, cfg.crtc, 0, cfgTs,
 		cfg.x, cfg.y, cfg.mode, cfg.rotation,
 		cfg.outputs).Reply(mm.xConn)
@@ -1020,6 +1166,155 @@ func (mm *xMonitorManager) setOutputScalingMode(output randr.Output, fillMode st
 	return nil
 }
 
+// getOutputProperty 获取 output 的属性，只支持单个值的 INTEGER 和 ATOM 类型属性，其他类型返回 nil。
+func (mm *xMonitorManager) getOutputProperty(output randr.Output, atom x.Atom) (*OutputProperty,
+	*randr.GetOutputPropertyReply, error) {
+	xConn := mm.xConn
+	valueReply, err := randr.GetOutputProperty(xConn, output, atom, x.GetPropertyTypeAny,
+		0, 1, false, false).Reply(xConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if (valueReply.Type != x.AtomInteger && valueReply.Type != x.AtomAtom) ||
+		valueReply.ValueLen != 1 || valueReply.BytesAfter != 0 {
+		return nil, nil, nil
+	}
+	value, err := decodeOutputPropertyValue(valueReply.Format, valueReply.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	queryReply, err := randr.QueryOutputProperty(xConn, output, atom).Reply(xConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	name, err := xConn.GetAtomName(atom)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prop := &OutputProperty{
+		Name:      name,
+		Range:     queryReply.Range,
+		Immutable: queryReply.Immutable,
+	}
+	if valueReply.Type == x.AtomAtom {
+		prop.Range = false
+		prop.Current, _ = xConn.GetAtomName(x.Atom(value))
+		for _, validValue := range queryReply.ValidValues {
+			atomName, err := xConn.GetAtomName(x.Atom(validValue))
+			if err != nil {
+				logger.Warning(err)
+				continue
+			}
+			prop.Values = append(prop.Values, atomName)
+		}
+		return prop, valueReply, nil
+	}
+
+	prop.Current = strconv.Itoa(int(value))
+	if prop.Range {
+		if len(queryReply.ValidValues) == 2 {
+			prop.Min = queryReply.ValidValues[0]
+			prop.Max = queryReply.ValidValues[1]
+		} else {
+			// 没有范围的整数属性
+			prop.Range = false
+		}
+	} else {
+		for _, validValue := range queryReply.ValidValues {
+			prop.Values = append(prop.Values, strconv.Itoa(int(validValue)))
+		}
+	}
+	return prop, valueReply, nil
+}
+
+func (mm *xMonitorManager) listOutputProperties(monitorId uint32) ([]OutputProperty, error) {
+	output := randr.Output(monitorId)
+	lsPropsReply, err := randr.ListOutputProperties(mm.xConn, output).Reply(mm.xConn)
+	if err != nil {
+		return nil, err
+	}
+	var result []OutputProperty
+	for _, atom := range lsPropsReply.Atoms {
+		prop, _, err := mm.getOutputProperty(output, atom)
+		if err != nil {
+			logger.Debugf("failed to get output %v property %v: %v", output, atom, err)
+			continue
+		}
+		if prop != nil {
+			result = append(result, *prop)
+		}
+	}
+	return result, nil
+}
+
+// getOutputProperties 只获取 names 中的 output 属性，不支持的属性不包含在结果中。
+// 属性名称对应的 atom 由 xConn 缓存，不用像 listOutputProperties 那样列出所有属性再逐个查询名称。
+func (mm *xMonitorManager) getOutputProperties(monitorId uint32, names []string) ([]OutputProperty, error) {
+	output := randr.Output(monitorId)
+	var result []OutputProperty
+	for _, name := range names {
+		atom, err := mm.xConn.GetAtomExisting(name)
+		if err != nil {
+			return nil, err
+		}
+		if atom == x.AtomNone {
+			continue
+		}
+		prop, _, err := mm.getOutputProperty(output, atom)
+		if err != nil {
+			logger.Debugf("failed to get output %v property %q: %v", output, name, err)
+			continue
+		}
+		if prop != nil {
+			result = append(result, *prop)
+		}
+	}
+	return result, nil
+}
+
+func (mm *xMonitorManager) setOutputProperty(monitorId uint32, name, value string) error {
+	xConn := mm.xConn
+	output := randr.Output(monitorId)
+	atom, err := xConn.GetAtomExisting(name)
+	if err != nil || atom == x.AtomNone {
+		return fmt.Errorf("output property %q is not supported", name)
+	}
+	prop, valueReply, err := mm.getOutputProperty(output, atom)
+	if err != nil {
+		return err
+	}
+	if prop == nil {
+		return fmt.Errorf("output property %q is not supported", name)
+	}
+	err = prop.checkValue(value)
+	if err != nil {
+		return err
+	}
+
+	var v int32
+	if valueReply.Type == x.AtomAtom {
+		valueAtom, err := xConn.GetAtom(value)
+		if err != nil {
+			return err
+		}
+		v = int32(valueAtom)
+	} else {
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		v = int32(i)
+	}
+	data, err := encodeOutputPropertyValue(valueReply.Format, v)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("set output %v property %q to %q", output, name, value)
+	return randr.ChangeOutputPropertyChecked(xConn, output, atom,
+		valueReply.Type, valueReply.Format, x.PropModeReplace, data).Check(xConn)
+}
+
 func (mm *xMonitorManager) setMonitorPrimary(monitorId uint32) error {
 	logger.Debug("mm.setMonitorPrimary", monitorId)
 	mm.mu.Lock()
@@ -1189,6 +1484,10 @@ func (mm *xMonitorManager) HandleEvent(ev interface{}) {
 	case *randr.OutputChangeNotifyEvent:
 		mm.handleOutputChanged(e)
 		// NOTE: ScreenChangeNotifyEvent 事件比较特殊，不在这里处理。
+	case *randr.ResourceChangeNotifyEvent:
+		mm.handleProvidersChanged(true)
+	case *providerChangeNotifyEvent:
+		mm.handleProvidersChanged(false)
 	default:
 		logger.Debug("invalid event", ev)
 		return
@@ -1213,6 +1512,13 @@ func (mm *xMonitorManager) handleScreenChanged(e *randr.ScreenChangeNotifyEvent)
 		return false
 	}
 	cfgTsChanged = true
+	mm.reloadResources()
+	return
+}
+
+// reloadResources 重新获取屏幕资源和所有 output、crtc 的信息
+func (mm *xMonitorManager) reloadResources() {
+	// NOTE: 不要加锁
 	resources, err := mm.getScreenResourcesCurrent()
 	if err != nil {
 		logger.Warning("get current screen resources failed:", err)
@@ -1240,7 +1546,6 @@ func (mm *xMonitorManager) handleScreenChanged(e *randr.ScreenChangeNotifyEvent)
 		}
 		mm.crtcs[crtcId] = (*CrtcInfo)(reply)
 	}
-	return
 }
 
 func (mm *xMonitorManager) showCursor(show bool) error {
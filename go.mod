@@ -11,6 +11,7 @@ require (
 	github.com/linuxdeepin/go-lib v0.0.0-20240104021143-cce3c07f84f6
 	github.com/linuxdeepin/go-x11-client v0.0.0-20230329071904-56c906e1ab5d
 	github.com/stretchr/testify v1.8.2
+	golang.org/x/sys v0.5.0
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
 )
@@ -21,6 +22,5 @@ require (
 	github.com/kr/text v0.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
-	golang.org/x/sys v0.5.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
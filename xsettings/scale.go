@@ -267,13 +267,19 @@ func (m *XSManager) setScreenScaleFactors(factors map[string]float64, emitSignal
 
 	// 同时要设置单值的
 	singleFactor := getSingleScaleFactor(factors)
+	qtFactors := factors
+	if renderScale, ok := m.dsfHelper.GetRenderScaleFactor(); ok {
+		// display 用 crtc transform 实现各屏幕的缩放比，程序都按统一的渲染缩放比渲染
+		singleFactor = renderScale
+		qtFactors = singleToMapSF(renderScale)
+	}
 	m.setScaleFactor(singleFactor, emitSignal)
 
 	// 关键保存位置
 	factorsJoined := joinScreenScaleFactors(factors)
 	m.gs.SetString(gsKeyIndividualScaling, factorsJoined)
 
-	err = m.setScreenScaleFactorsForQt(factors)
+	err = m.setScreenScaleFactorsForQt(qtFactors)
 	if err != nil {
 		return err
 	}
@@ -34,6 +34,7 @@ const (
 type displayScaleFactorsHelper interface {
 	SetScaleFactors(factors map[string]float64) error
 	GetScaleFactors() (map[string]float64, error)
+	GetRenderScaleFactor() (float64, bool)
 	SetChangedCb(fn func(factors map[string]float64) error)
 }
 